@@ -0,0 +1,89 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// This file contains functions for catching performance regressions.
+
+// Runs a benchmark. This is a variable so tests can replace it.
+var runBenchmark = testing.Benchmark
+
+// The contents of a stored benchmark baseline.
+type benchmarkBaseline struct {
+	NsPerOp     int64  `json:"ns_per_op"`
+	AllocsPerOp int64  `json:"allocs_per_op"`
+	BytesPerOp  int64  `json:"bytes_per_op"`
+	GoVersion   string `json:"go_version"`
+	Platform    string `json:"platform"`
+}
+
+// Benchmarks fn and compares the time per operation against a baseline
+// stored in testdata/benchmarks/<name>.json, failing the test if fn is more
+// than maxSlowdownPct percent slower. Running the tests with
+// -testlib.update records a new baseline instead. Since benchmark results
+// depend on the machine, baselines should be recorded on the machine (or
+// class of machine) that runs the check, and maxSlowdownPct should leave
+// room for noise.
+func (t *T) ExpectNoRegression(
+	name string, fn func(b *testing.B), maxSlowdownPct float64,
+) {
+	path := goldenPath(filepath.Join("benchmarks", name+".json"))
+	result := runBenchmark(fn)
+	if result.N == 0 {
+		t.Fatalf("Benchmark %s did not run; did it call b.Fatal?", name)
+	}
+	have := benchmarkBaseline{
+		NsPerOp:     result.NsPerOp(),
+		AllocsPerOp: result.AllocsPerOp(),
+		BytesPerOp:  result.AllocedBytesPerOp(),
+		GoVersion:   runtime.Version(),
+		Platform:    runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if *updateGolden {
+		data, err := json.MarshalIndent(have, "", "  ")
+		t.ExpectSuccess(err)
+		t.ExpectSuccess(osMkdirAll(filepath.Dir(path), os.FileMode(0755)))
+		t.ExpectSuccess(ioutil.WriteFile(path, append(data, '\n'), 0644))
+		t.Logf("Recorded benchmark baseline %s: %d ns/op", name, have.NsPerOp)
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read benchmark baseline (run with "+
+			"-testlib.update to create it): %s", err)
+	}
+	var want benchmarkBaseline
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatalf("Unable to decode benchmark baseline %s: %s", path, err)
+	} else if want.NsPerOp <= 0 {
+		t.Fatalf("Benchmark baseline %s has no ns_per_op.", path)
+	}
+	slowdown := float64(have.NsPerOp-want.NsPerOp) * 100 / float64(want.NsPerOp)
+	if slowdown > maxSlowdownPct {
+		t.Fatalf("Benchmark %s is %.1f%% slower than its baseline "+
+			"(allowed %.1f%%): %d ns/op, baseline %d ns/op (%s, %s)",
+			name, slowdown, maxSlowdownPct, have.NsPerOp, want.NsPerOp,
+			want.GoVersion, want.Platform)
+	}
+}
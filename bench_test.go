@@ -0,0 +1,82 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestT_ExpectNoRegression(t *testing.T) {
+	defer func(dir string) { goldenDir = dir }(goldenDir)
+	defer func(update bool) { *updateGolden = update }(*updateGolden)
+	defer func(f func(func(*testing.B)) testing.BenchmarkResult) {
+		runBenchmark = f
+	}(runBenchmark)
+
+	_, setup := testSetup()
+	defer setup.Finish()
+	goldenDir = setup.TempDir()
+
+	nsPerOp := int64(1000)
+	runBenchmark = func(fn func(*testing.B)) testing.BenchmarkResult {
+		return testing.BenchmarkResult{
+			N: 100,
+			T: time.Duration(nsPerOp * 100),
+		}
+	}
+	fn := func(b *testing.B) {}
+
+	m, T := testSetup()
+	defer T.Finish()
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	// Without a baseline the test fails and explains how to create one.
+	m.CheckFail(t, func() { T.ExpectNoRegression("sum", fn, 10) })
+	if !strings.Contains(msg, "-testlib.update") {
+		t.Fatalf("The update flag was not suggested: %s", msg)
+	}
+
+	// Record a baseline.
+	*updateGolden = true
+	m.CheckPass(t, func() { T.ExpectNoRegression("sum", fn, 10) })
+	path := filepath.Join(goldenDir, "benchmarks", "sum.json")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("The baseline was not written: %s", err)
+	}
+	*updateGolden = false
+
+	// Within the allowed slowdown, or faster, passes.
+	nsPerOp = 1100
+	m.CheckPass(t, func() { T.ExpectNoRegression("sum", fn, 10) })
+	nsPerOp = 500
+	m.CheckPass(t, func() { T.ExpectNoRegression("sum", fn, 10) })
+
+	// Too slow fails.
+	nsPerOp = 1500
+	m.CheckFail(t, func() { T.ExpectNoRegression("sum", fn, 10) })
+	if !strings.Contains(msg, "50.0% slower") {
+		t.Fatalf("The slowdown was not reported: %s", msg)
+	} else if !strings.Contains(msg, "1500 ns/op, baseline 1000 ns/op") {
+		t.Fatalf("The timings were not reported: %s", msg)
+	}
+}
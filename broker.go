@@ -0,0 +1,132 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// This file contains message queue brokers built on top of T.Container().
+
+// The images used for each broker. These are variables so that they can be
+// pinned to a different version if needed.
+var (
+	KafkaImage = "apache/kafka:3.7.0"
+	AMQPImage  = "rabbitmq:3-management"
+)
+
+// The characters that are not allowed in a topic or queue name.
+var brokerNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// The longest topic name Kafka will accept.
+const maxBrokerName = 249
+
+// Returns a topic or queue name derived from the test's name.
+func (t *T) brokerName() string {
+	name := brokerNameInvalid.ReplaceAllString(t.Name(), "_")
+	if len(name) > maxBrokerName {
+		name = name[:maxBrokerName]
+	}
+	return name
+}
+
+// A single node Kafka broker returned from T.KafkaBroker().
+type KafkaBroker struct {
+	// The container the broker is running in.
+	Container *Container
+
+	// The host:port address clients should bootstrap from.
+	Addr string
+
+	// A topic, named after the test, that was created for the test.
+	Topic string
+}
+
+// Starts a single node Kafka broker (in KRaft mode) in a container and
+// creates a topic named after the test. The topic is deleted and the
+// broker removed when the test finishes.
+func (t *T) KafkaBroker() *KafkaBroker {
+	// Kafka tells clients to reconnect to the address it advertises so the
+	// host port must be known before the broker starts. A second listener
+	// is used by the tools run inside of the container.
+	port := t.FreePort()
+	c := t.Container(KafkaImage, ContainerOptions{
+		HostPorts: map[int]int{9092: port},
+		Env: map[string]string{
+			"KAFKA_NODE_ID":                          "1",
+			"KAFKA_PROCESS_ROLES":                    "broker,controller",
+			"KAFKA_CONTROLLER_QUORUM_VOTERS":         "1@localhost:9093",
+			"KAFKA_CONTROLLER_LISTENER_NAMES":        "CONTROLLER",
+			"KAFKA_INTER_BROKER_LISTENER_NAME":       "INTERNAL",
+			"KAFKA_OFFSETS_TOPIC_REPLICATION_FACTOR": "1",
+			"KAFKA_LISTENERS": "EXTERNAL://:9092,INTERNAL://:29092," +
+				"CONTROLLER://:9093",
+			"KAFKA_ADVERTISED_LISTENERS": fmt.Sprintf(
+				"EXTERNAL://127.0.0.1:%d,INTERNAL://localhost:29092", port),
+			"KAFKA_LISTENER_SECURITY_PROTOCOL_MAP": "EXTERNAL:PLAINTEXT," +
+				"INTERNAL:PLAINTEXT,CONTROLLER:PLAINTEXT",
+		},
+		ReadyLog: "Kafka Server started",
+	})
+	b := &KafkaBroker{
+		Container: c,
+		Addr:      c.Addr(9092),
+		Topic:     t.brokerName(),
+	}
+	tool := []string{
+		"/opt/kafka/bin/kafka-topics.sh",
+		"--bootstrap-server", "localhost:29092",
+		"--topic", b.Topic,
+	}
+	c.Exec(append(tool, "--create")...)
+	t.AddNamedFinalizer("kafka topic "+b.Topic, func() {
+		c.Exec(append(tool, "--delete")...)
+	})
+	return b
+}
+
+// A RabbitMQ broker returned from T.AMQPBroker().
+type AMQPBroker struct {
+	// The container the broker is running in.
+	Container *Container
+
+	// The amqp:// URL clients should connect to.
+	URL string
+
+	// A queue, named after the test, that was declared for the test.
+	Queue string
+}
+
+// Starts a RabbitMQ broker in a container and declares a queue named after
+// the test. The queue is deleted and the broker removed when the test
+// finishes.
+func (t *T) AMQPBroker() *AMQPBroker {
+	c := t.Container(AMQPImage, ContainerOptions{
+		Ports:     []int{5672},
+		ReadyPort: 5672,
+		ReadyLog:  "Server startup complete",
+	})
+	b := &AMQPBroker{
+		Container: c,
+		URL:       "amqp://guest:guest@" + c.Addr(5672) + "/",
+		Queue:     t.brokerName(),
+	}
+	c.Exec("rabbitmqadmin", "declare", "queue", "name="+b.Queue, "durable=false")
+	t.AddNamedFinalizer("amqp queue "+b.Queue, func() {
+		c.Exec("rabbitmqadmin", "delete", "queue", "name="+b.Queue)
+	})
+	return b
+}
@@ -0,0 +1,97 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestT_KafkaBroker(t *testing.T) {
+	_, T := testSetup()
+	defer T.Finish()
+	dir := T.TempDir()
+	defer fakeDocker(t, dir, 0)()
+
+	_, T2 := testSetup()
+	b := T2.KafkaBroker()
+	if b.Topic != "TestT_KafkaBroker" {
+		t.Fatalf("Wrong topic: %s", b.Topic)
+	}
+	_, port, _ := net.SplitHostPort(b.Addr)
+	calls := dockerCalls(t, dir)
+	if !strings.Contains(calls, fmt.Sprintf("--publish 127.0.0.1:%s:9092", port)) {
+		t.Fatalf("The broker port was not published:\n%s", calls)
+	} else if !strings.Contains(calls, "EXTERNAL://127.0.0.1:"+port) {
+		t.Fatalf("The broker port was not advertised:\n%s", calls)
+	} else if !strings.Contains(calls, "exec cid123 /opt/kafka/bin/kafka-topics.sh "+
+		"--bootstrap-server localhost:29092 --topic TestT_KafkaBroker --create\n") {
+		t.Fatalf("The topic was not created:\n%s", calls)
+	}
+
+	// The topic is deleted before the container is removed.
+	T2.Finish()
+	calls = dockerCalls(t, dir)
+	if !strings.HasSuffix(calls, "--topic TestT_KafkaBroker --delete\nrm --force cid123\n") {
+		t.Fatalf("Unexpected teardown:\n%s", calls)
+	}
+}
+
+func TestT_AMQPBroker(t *testing.T) {
+	_, T := testSetup()
+	defer T.Finish()
+	dir := T.TempDir()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	defer l.Close()
+	defer fakeDocker(t, dir, l.Addr().(*net.TCPAddr).Port)()
+
+	_, T2 := testSetup()
+	b := T2.AMQPBroker()
+	if b.Queue != "TestT_AMQPBroker" {
+		t.Fatalf("Wrong queue: %s", b.Queue)
+	} else if b.URL != "amqp://guest:guest@"+l.Addr().String()+"/" {
+		t.Fatalf("Wrong URL: %s", b.URL)
+	}
+	calls := dockerCalls(t, dir)
+	if !strings.Contains(calls, "exec cid123 rabbitmqadmin declare queue "+
+		"name=TestT_AMQPBroker durable=false\n") {
+		t.Fatalf("The queue was not declared:\n%s", calls)
+	}
+
+	T2.Finish()
+	calls = dockerCalls(t, dir)
+	if !strings.HasSuffix(calls, "rabbitmqadmin delete queue "+
+		"name=TestT_AMQPBroker\nrm --force cid123\n") {
+		t.Fatalf("Unexpected teardown:\n%s", calls)
+	}
+}
+
+func TestT_BrokerName(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+	T.name = "TestFoo/case 1:a"
+	if name := T.brokerName(); name != "TestFoo_case_1_a" {
+		t.Fatalf("Wrong name: %s", name)
+	}
+	T.name = strings.Repeat("x", 300)
+	if name := T.brokerName(); len(name) != maxBrokerName {
+		t.Fatalf("Name was not truncated: %d", len(name))
+	}
+}
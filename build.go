@@ -0,0 +1,89 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// This file contains functions for building binaries under test.
+
+// The go tool that is executed by BuildBinary(). This is a variable so
+// tests can replace it.
+var goCommand = "go"
+
+// Runs "go build" on the package at pkgPath and returns the path of the
+// resulting binary, which is named after the package's directory. The
+// package may be given as an import path or as a directory, like
+// "./cmd/server", which is built from within that directory so it works
+// regardless of the module the tests are in. Each package is only built
+// once per run of the test binary (see CachedFixture()) so many tests can
+// share the binary cheaply. If the go tool can not be found then the test
+// is skipped, and if the build fails the compiler output is reported. If
+// the tests are running with coverage enabled then the binary is built with
+// -cover; run it with Exec() so its coverage is included in the report.
+func (t *T) BuildBinary(pkgPath string) string {
+	goPath, err := exec.LookPath(goCommand)
+	if err != nil {
+		t.Skipf("The go tool is not available: %s", err)
+	}
+	cwd, err := os.Getwd()
+	t.ExpectSuccess(err)
+
+	// Directories are built from within so the go tool finds the right
+	// module, otherwise the package is resolved from the current one.
+	dir, target, name := cwd, pkgPath, filepath.Base(pkgPath)
+	if info, err := os.Stat(pkgPath); err == nil && info.IsDir() {
+		abs, err := filepath.Abs(pkgPath)
+		t.ExpectSuccess(err)
+		dir, target, name = abs, ".", filepath.Base(abs)
+	}
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	// When the tests are measuring coverage the binary is built to measure
+	// it too, see Exec().
+	args := []string{"build", "-o", filepath.Join("OUT", name)}
+	if mode := testing.CoverMode(); mode != "" {
+		args = append(args, "-cover", "-covermode="+mode)
+	}
+	args = append(args, target)
+
+	key := fmt.Sprintf("go %s in %s", strings.Join(args, " "), dir)
+	out, err := cachedFixtureDir(key, func(out string) error {
+		args[2] = filepath.Join(out, name)
+		cmd := exec.Command(goPath, args...)
+		cmd.Dir = dir
+		output := &bytes.Buffer{}
+		cmd.Stdout = output
+		cmd.Stderr = output
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s\n%s", err, output)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unable to build %s: %s", pkgPath, err)
+	}
+	return filepath.Join(out, name)
+}
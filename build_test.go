@@ -0,0 +1,73 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestT_BuildBinary(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+	defer T.Finish()
+	if _, err := exec.LookPath(goCommand); err != nil {
+		t.Skipf("The go tool is not available: %s", err)
+	}
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	dir := filepath.Join(T.TempDir(), "hello")
+	T.ExpectSuccess(osMkdirAll(dir, 0755))
+	T.ExpectSuccess(ioutil.WriteFile(filepath.Join(dir, "go.mod"),
+		[]byte("module hello\n\ngo 1.16\n"), 0644))
+	T.ExpectSuccess(ioutil.WriteFile(filepath.Join(dir, "main.go"),
+		[]byte("package main\n\nfunc main() { println(\"hello\") }\n"), 0644))
+
+	var path, again string
+	m.CheckPass(t, func() {
+		path = T.BuildBinary(dir)
+		again = T.BuildBinary(dir)
+	})
+	if path != again {
+		t.Fatalf("The binary was built twice: %s, %s", path, again)
+	} else if !strings.HasPrefix(filepath.Base(path), "hello") {
+		t.Fatalf("The binary was not named after the package: %s", path)
+	}
+	out, err := exec.Command(path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Unable to run the binary: %s", err)
+	} else if string(out) != "hello\n" {
+		t.Fatalf("Unexpected output: %q", out)
+	}
+
+	// Build failures include the compiler output.
+	broken := filepath.Join(T.TempDir(), "broken")
+	T.ExpectSuccess(osMkdirAll(broken, 0755))
+	T.ExpectSuccess(ioutil.WriteFile(filepath.Join(broken, "go.mod"),
+		[]byte("module broken\n\ngo 1.16\n"), 0644))
+	T.ExpectSuccess(ioutil.WriteFile(filepath.Join(broken, "main.go"),
+		[]byte("package main\n\nfunc main() { undefinedFunction() }\n"), 0644))
+	m.CheckFail(t, func() { T.BuildBinary(broken) })
+	if !strings.Contains(msg, "undefinedFunction") {
+		t.Fatalf("The compiler output was not reported: %s", msg)
+	}
+}
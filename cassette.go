@@ -0,0 +1,171 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// This file contains a record and replay system for HTTP interactions.
+
+// Returns a Cassette stored in testdata/cassettes/<name>.json. If the test
+// binary is run with -testlib.update then the cassette is in record mode:
+// every request is sent using base (or http.DefaultTransport if base is nil)
+// and the interactions are written to the cassette file when the test
+// finishes. Otherwise the cassette is in replay mode and responses are
+// served from the file without any network access.
+func (t *T) Cassette(name string, base http.RoundTripper) *Cassette {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := &Cassette{
+		t:         t,
+		path:      goldenPath(filepath.Join("cassettes", name+".json")),
+		base:      base,
+		recording: *updateGolden,
+	}
+	if c.recording {
+		t.AddNamedFinalizer("cassette "+name, c.save)
+		return c
+	}
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		t.Fatalf("Unable to read cassette (run with -testlib.update to "+
+			"record it): %s", err)
+	}
+	t.ExpectSuccess(json.Unmarshal(data, &c.interactions),
+		"Invalid cassette "+c.path)
+	c.used = make([]bool, len(c.interactions))
+	return c
+}
+
+// An http.RoundTripper that records or replays HTTP interactions. This is
+// returned from T.Cassette() and is safe to use from multiple goroutines.
+type Cassette struct {
+	t            *T
+	path         string
+	base         http.RoundTripper
+	recording    bool
+	lock         sync.Mutex
+	interactions []interaction
+	used         []bool
+}
+
+// A single recorded request and response. The bodies are stored as byte
+// slices, which are base64 encoded in the cassette file, so that binary
+// bodies (compressed data, images and so on) are replayed exactly.
+type interaction struct {
+	Method         string
+	URL            string
+	RequestBody    []byte `json:",omitempty"`
+	Status         int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+}
+
+// Returns an http.Client that uses the cassette as its transport.
+func (c *Cassette) Client() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// Implements http.RoundTripper. In replay mode requests are matched on
+// their method, URL and body, and each recorded interaction is only served
+// once so repeated identical requests are replayed in the order they were
+// recorded. A request with no matching interaction fails the test.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := []byte{}
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if c.recording {
+		resp, err := c.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+		c.lock.Lock()
+		c.interactions = append(c.interactions, interaction{
+			Method:         req.Method,
+			URL:            req.URL.String(),
+			RequestBody:    body,
+			Status:         resp.StatusCode,
+			ResponseHeader: resp.Header,
+			ResponseBody:   respBody,
+		})
+		c.lock.Unlock()
+		return resp, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i, in := range c.interactions {
+		if c.used[i] || in.Method != req.Method ||
+			in.URL != req.URL.String() || !bytes.Equal(in.RequestBody, body) {
+			continue
+		}
+		c.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode:    in.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.ResponseHeader.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader(in.ResponseBody)),
+			ContentLength: int64(len(in.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+	// This is likely to be called from a goroutine other than the test's
+	// so Errorf is used rather than Fatalf.
+	err := fmt.Errorf("no interaction in cassette %s matches %s %s",
+		c.path, req.Method, req.URL)
+	c.t.Errorf("%s", err)
+	return nil, err
+}
+
+// Writes the recorded interactions to the cassette file.
+func (c *Cassette) save() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	data, err := json.MarshalIndent(c.interactions, "", "  ")
+	if err != nil {
+		c.t.Errorf("Unable to encode cassette: %s", err)
+		return
+	}
+	if err := osMkdirAll(filepath.Dir(c.path), os.FileMode(0755)); err != nil {
+		c.t.Errorf("Unable to create cassette directory: %s", err)
+	} else if err := ioutil.WriteFile(c.path, append(data, '\n'), 0644); err != nil {
+		c.t.Errorf("Unable to write cassette: %s", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestT_Cassette(t *testing.T) {
+	defer func(dir string) { goldenDir = dir }(goldenDir)
+	defer func(update bool) { *updateGolden = update }(*updateGolden)
+
+	_, setup := testSetup()
+	defer setup.Finish()
+	goldenDir = setup.TempDir()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("X-Call", fmt.Sprint(calls))
+			fmt.Fprintf(w, "call %d: %s", calls, body)
+		}))
+	defer server.Close()
+
+	get := func(client *http.Client, body string) (string, error) {
+		resp, err := client.Post(server.URL+"/api", "text/plain",
+			strings.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		return resp.Header.Get("X-Call") + " " + string(data), err
+	}
+
+	// Record.
+	*updateGolden = true
+	m, T := testSetup()
+	c := T.Cassette("api", nil)
+	for _, body := range []string{"a", "a", "b"} {
+		if _, err := get(c.Client(), body); err != nil {
+			t.Fatalf("Request failed: %s", err)
+		}
+	}
+	T.Finish()
+	if m.failed {
+		t.Fatalf("Recording failed.")
+	}
+
+	// Replay without touching the server.
+	server.Close()
+	*updateGolden = false
+	m, T = testSetup()
+	c = T.Cassette("api", nil)
+	for i, want := range []string{"1 call 1: a", "3 call 3: b", "2 call 2: a"} {
+		body := "a"
+		if i == 1 {
+			body = "b"
+		}
+		have, err := get(c.Client(), body)
+		if err != nil {
+			t.Fatalf("Replay failed: %s", err)
+		} else if have != want {
+			t.Fatalf("Unexpected response: have %q, want %q", have, want)
+		}
+	}
+
+	// Unmatched requests fail.
+	msg := ""
+	m.funcError = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+	if _, err := get(c.Client(), "a"); err == nil {
+		t.Fatalf("Expected an error.")
+	} else if !strings.Contains(msg, "no interaction in cassette") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	// Missing cassettes fail.
+	m.CheckFail(t, func() { T.Cassette("missing", nil) })
+}
+
+func TestT_CassetteBinary(t *testing.T) {
+	defer func(dir string) { goldenDir = dir }(goldenDir)
+	defer func(update bool) { *updateGolden = update }(*updateGolden)
+
+	_, setup := testSetup()
+	defer setup.Finish()
+	goldenDir = setup.TempDir()
+
+	// Neither body is valid UTF-8.
+	request := []byte{0x00, 0xff, 0xfe, 'q'}
+	response := []byte{0x1f, 0x8b, 0x08, 0x00, 0xc3, 0x28, 0xff}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			if !bytes.Equal(body, request) {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			w.Write(response)
+		}))
+	defer server.Close()
+
+	post := func(client *http.Client) []byte {
+		resp, err := client.Post(server.URL, "application/octet-stream",
+			bytes.NewReader(request))
+		if err != nil {
+			t.Fatalf("Request failed: %s", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Unexpected status: %d", resp.StatusCode)
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Unable to read the response: %s", err)
+		}
+		return data
+	}
+
+	*updateGolden = true
+	m, T := testSetup()
+	post(T.Cassette("binary", nil).Client())
+	T.Finish()
+	if m.failed {
+		t.Fatalf("Recording failed.")
+	}
+
+	server.Close()
+	*updateGolden = false
+	m, T = testSetup()
+	defer T.Finish()
+	if have := post(T.Cassette("binary", nil).Client()); !bytes.Equal(
+		have, response) {
+		t.Fatalf("Unexpected response: have %x, want %x", have, response)
+	} else if m.failed {
+		t.Fatalf("Replay failed.")
+	}
+}
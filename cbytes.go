@@ -0,0 +1,130 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// This file contains functions for comparing memory owned by C code.
+
+// The number of bytes shown on each line of a hex dump.
+const hexDumpWidth = 16
+
+// Copies length bytes starting at ptr into a Go slice and fails the test if
+// they are not equal to want. This is intended for packages wrapping C
+// libraries where the memory to check is owned by C (for example a buffer
+// returned from C.malloc()). The memory is copied before being compared so
+// nothing in the failure output references it after this returns.
+//
+// On failure the two buffers are shown as a hex dump with the rows that
+// differ marked "-" for have and "+" for want.
+func (t *T) EqualCBytes(
+	ptr unsafe.Pointer, length int, want []byte, desc ...string,
+) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	if length < 0 {
+		t.Fatalf("%sInvalid length %d.", prefix, length)
+	} else if ptr == nil && length > 0 {
+		t.Fatalf("%sExpected %d bytes but the pointer is nil.", prefix, length)
+	}
+	have := make([]byte, length)
+	if length > 0 {
+		copy(have, unsafe.Slice((*byte)(ptr), length))
+	}
+	if bytes.Equal(have, want) {
+		return
+	}
+	t.Fatalf("%sBytes are not equal (- have, + want), len(have) %d, "+
+		"len(want) %d:\n%s", prefix, len(have), len(want),
+		hexDumpDiff(have, want, diffContext()))
+}
+
+// Returns a hex dump of have and want where rows that are the same in both
+// are shown once and rows that differ are shown twice, marked "-" for have
+// and "+" for want. Only context unchanged rows are shown around each
+// change, unless context is negative in which case every row is shown.
+func hexDumpDiff(have, want []byte, context int) string {
+	rows := (len(have) + hexDumpWidth - 1) / hexDumpWidth
+	if r := (len(want) + hexDumpWidth - 1) / hexDumpWidth; r > rows {
+		rows = r
+	}
+	lines := make([][]string, rows)
+	changed := make([]bool, rows)
+	for row := 0; row < rows; row++ {
+		offset := row * hexDumpWidth
+		h, hok := hexDumpRow(have, offset)
+		w, wok := hexDumpRow(want, offset)
+		switch {
+		case hok && wok && h == w:
+			lines[row] = []string{"  " + h}
+		case !wok:
+			lines[row] = []string{"- " + h}
+		case !hok:
+			lines[row] = []string{"+ " + w}
+		default:
+			lines[row] = []string{"- " + h, "+ " + w}
+		}
+		changed[row] = !(hok && wok && h == w)
+	}
+
+	output := []string{}
+	for row := range lines {
+		keep := context < 0
+		for k := row - context; !keep && k <= row+context; k++ {
+			keep = k >= 0 && k < rows && changed[k]
+		}
+		if keep {
+			output = append(output, lines[row]...)
+		} else if len(output) == 0 ||
+			output[len(output)-1] != "  ..." {
+			output = append(output, "  ...")
+		}
+	}
+	return strings.Join(output, "\n")
+}
+
+// Returns the row of a hex dump of data that starts at offset, in the same
+// format as encoding/hex.Dump(), and false if data is not that long.
+func hexDumpRow(data []byte, offset int) (string, bool) {
+	if offset >= len(data) {
+		return "", false
+	}
+	end := offset + hexDumpWidth
+	if end > len(data) {
+		end = len(data)
+	}
+	hexPart := make([]string, 0, hexDumpWidth)
+	ascii := make([]byte, 0, hexDumpWidth)
+	for i, b := range data[offset:end] {
+		if i == hexDumpWidth/2 {
+			hexPart = append(hexPart, "")
+		}
+		hexPart = append(hexPart, fmt.Sprintf("%02x", b))
+		if b >= 0x20 && b < 0x7f {
+			ascii = append(ascii, b)
+		} else {
+			ascii = append(ascii, '.')
+		}
+	}
+	return fmt.Sprintf("%08x  %-49s |%s|",
+		offset, strings.Join(hexPart, " "), ascii), true
+}
@@ -0,0 +1,91 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestT_EqualCBytes(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+
+	buf := []byte("hello, world")
+	ptr := unsafe.Pointer(&buf[0])
+	m.CheckPass(t, func() { T.EqualCBytes(ptr, len(buf), []byte("hello, world")) })
+	m.CheckPass(t, func() { T.EqualCBytes(ptr, 5, []byte("hello")) })
+	m.CheckPass(t, func() { T.EqualCBytes(nil, 0, nil) })
+
+	m.CheckFail(t, func() { T.EqualCBytes(ptr, 5, []byte("help!"), "buffer") })
+	if !strings.HasPrefix(msg, "buffer: Bytes are not equal (- have, + want), "+
+		"len(have) 5, len(want) 5:\n") {
+		t.Fatalf("Unexpected message: %s", msg)
+	} else if !strings.Contains(msg, "- 00000000  68 65 6c 6c 6f") ||
+		!strings.Contains(msg, "+ 00000000  68 65 6c 70 21") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	m.CheckFail(t, func() { T.EqualCBytes(nil, 4, []byte("abcd")) })
+	m.CheckFail(t, func() { T.EqualCBytes(ptr, -1, nil) })
+}
+
+func TestHexDumpRow(t *testing.T) {
+	t.Parallel()
+	data := []byte("0123456789abcdef\x00\x01xyz")
+	want := strings.Split(strings.TrimSuffix(hex.Dump(data), "\n"), "\n")
+	for i, line := range want {
+		if have, ok := hexDumpRow(data, i*hexDumpWidth); !ok || have != line {
+			t.Fatalf("Row %d:\nhave: %q\nwant: %q", i, have, line)
+		}
+	}
+	if _, ok := hexDumpRow(data, 32); ok {
+		t.Fatalf("A row past the end of the data was returned.")
+	}
+}
+
+func TestHexDumpDiff(t *testing.T) {
+	t.Parallel()
+	have := bytes.Repeat([]byte{0xaa}, 16*10)
+	want := append([]byte{}, have...)
+	want[16*5] = 0xbb
+	want = append(want, 1, 2)
+
+	diff := hexDumpDiff(have, want, 1)
+	lines := strings.Split(diff, "\n")
+	prefixes := []string{
+		"  ...", "  00000040", "- 00000050", "+ 00000050", "  00000060",
+		"  ...", "  00000090", "+ 000000a0",
+	}
+	if len(lines) != len(prefixes) {
+		t.Fatalf("Unexpected diff:\n%s", diff)
+	}
+	for i, prefix := range prefixes {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Fatalf("Unexpected diff:\n%s", diff)
+		}
+	}
+
+	if diff := hexDumpDiff(have, want, -1); len(strings.Split(diff, "\n")) != 12 {
+		t.Fatalf("Unexpected diff:\n%s", diff)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// This file contains functions for inspecting the process that cleans up
+// the root temporary directory.
+
+// The state of the process that removes RootTempDir() when the tests
+// finish.
+type CleanupState int
+
+const (
+	// RootTempDir() has not been called yet so there is no process.
+	CleanupNotStarted CleanupState = iota
+
+	// The process is running and will remove the directory once this
+	// process exits.
+	CleanupRunning
+
+	// The process exited before this one did, so the directory will not
+	// be removed.
+	CleanupExited
+
+	// The process could not be started, so RootTempDir() is unavailable.
+	CleanupFailed
+)
+
+// Returns a human readable name for the state.
+func (s CleanupState) String() string {
+	switch s {
+	case CleanupNotStarted:
+		return "not started"
+	case CleanupRunning:
+		return "running"
+	case CleanupExited:
+		return "exited"
+	case CleanupFailed:
+		return "failed to start"
+	}
+	return fmt.Sprintf("CleanupState(%d)", int(s))
+}
+
+// Describes the process that removes RootTempDir() when the tests finish.
+// This is returned from T.CleanupProcessInfo().
+type CleanupProcess struct {
+	// The directory that the process will remove.
+	Dir string
+
+	// The process ID of the cleanup process, or zero if it was never
+	// started.
+	PID int
+
+	// The current state of the process.
+	State CleanupState
+
+	// The error that stopped the process from starting, or describing how
+	// it exited early. This is nil while the process is running.
+	Err error
+}
+
+// Returns information about the child process that removes RootTempDir()
+// once this process exits. This does not start the process if
+// RootTempDir() has not been called yet.
+func (t *T) CleanupProcessInfo() CleanupProcess {
+	return cleanupProcessInfo()
+}
+
+// Creates RootTempDir() if needed and returns an error if its cleanup
+// process could not be started or has since exited. Misconfigured
+// environments, like a temporary directory or test binary on a noexec file
+// system, otherwise only fail the first test that happens to need a
+// temporary file. Calling this from TestMain surfaces the problem once,
+// with a clear message, before any test runs. Main() does this
+// automatically.
+func CheckCleanupProcess() error {
+	if _, err := rootTempDir(); err != nil {
+		return err
+	}
+	info := cleanupProcessInfo()
+	if info.State == CleanupExited {
+		return fmt.Errorf("The process that removes %s exited early: %s",
+			info.Dir, info.Err)
+	}
+	return nil
+}
+
+// Returns the current state of the cleanup process.
+func cleanupProcessInfo() CleanupProcess {
+	testLibRootDirLock.Lock()
+	defer testLibRootDirLock.Unlock()
+	info := CleanupProcess{
+		Dir:   testLibRootDir,
+		State: testLibCleanupState,
+		Err:   testLibCleanupErr,
+	}
+	if testLibCleanupCmd != nil && testLibCleanupCmd.Process != nil {
+		info.PID = testLibCleanupCmd.Process.Pid
+	}
+	return info
+}
+
+// Waits for the cleanup process to exit. This normally only happens after
+// this process has exited, so if it returns the process exited early and
+// the root temporary directory will not be removed.
+func waitCleanupProcess(cmd *exec.Cmd) {
+	err := cmd.Wait()
+	testLibRootDirLock.Lock()
+	defer testLibRootDirLock.Unlock()
+	if testLibCleanupCmd != cmd {
+		return
+	}
+	testLibCleanupState = CleanupExited
+	if err == nil {
+		err = fmt.Errorf("%s", cmd.ProcessState)
+	}
+	testLibCleanupErr = err
+}
@@ -0,0 +1,89 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestT_CleanupProcessInfo(t *testing.T) {
+	m, T := testSetup()
+	var dir string
+	m.CheckPass(t, func() { dir = T.RootTempDir() })
+	if err := CheckCleanupProcess(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	info := T.CleanupProcessInfo()
+	if info.State != CleanupRunning {
+		t.Fatalf("Unexpected state: %s (%v)", info.State, info.Err)
+	} else if info.Dir != dir {
+		t.Fatalf("Unexpected directory: %s", info.Dir)
+	} else if info.PID <= 0 {
+		t.Fatalf("Unexpected pid: %d", info.PID)
+	} else if info.Err != nil {
+		t.Fatalf("Unexpected error: %s", info.Err)
+	}
+	if p, err := os.FindProcess(info.PID); err != nil {
+		t.Fatalf("Unable to find the cleanup process: %s", err)
+	} else if p.Signal(syscall.Signal(0)) != nil {
+		t.Fatalf("The cleanup process is not running.")
+	}
+}
+
+func TestMakeRootTempDir_StartFailure(t *testing.T) {
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("/testlib-nonexistent/binary", args...)
+	}
+	defer func() { execCommand = exec.Command }()
+	var removed string
+	osRemoveAll = func(path string) error {
+		removed = path
+		return os.RemoveAll(path)
+	}
+	defer func() { osRemoveAll = os.RemoveAll }()
+
+	dir, err := makeRootTempDir()
+	if err == nil {
+		t.Fatalf("Expected an error.")
+	} else if dir != "" {
+		t.Fatalf("Unexpected directory: %s", dir)
+	} else if !strings.Contains(err.Error(), "noexec") {
+		t.Fatalf("Unexpected error: %s", err)
+	} else if removed == "" {
+		t.Fatalf("The directory was not removed.")
+	} else if _, err := os.Stat(removed); !os.IsNotExist(err) {
+		t.Fatalf("The directory still exists: %v", err)
+	}
+}
+
+func TestCleanupState_String(t *testing.T) {
+	t.Parallel()
+	for state, want := range map[CleanupState]string{
+		CleanupNotStarted: "not started",
+		CleanupRunning:    "running",
+		CleanupExited:     "exited",
+		CleanupFailed:     "failed to start",
+		CleanupState(9):   "CleanupState(9)",
+	} {
+		if have := state.String(); have != want {
+			t.Fatalf("Unexpected string for %d: %s", int(state), have)
+		}
+	}
+}
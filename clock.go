@@ -0,0 +1,181 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file contains a fake clock for testing time dependent code, such as
+// rate limiters, without sleeping.
+
+// The time that every FakeClock starts at.
+var fakeClockEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Returns a FakeClock set to midnight UTC on January 1st 2000. Time only
+// moves forward when Advance() is called.
+func (t *T) FakeClock() *FakeClock {
+	return &FakeClock{now: fakeClockEpoch}
+}
+
+// A manually controlled clock. Code under test should accept the functions
+// it needs (Now, After, NewTicker) so that a FakeClock can be substituted
+// for the time package. This is safe to use from multiple goroutines.
+type FakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// A pending timer or ticker.
+type fakeWaiter struct {
+	when   time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+// Returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// Returns the time elapsed since t according to the fake clock.
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Returns a channel that receives the fake time once the clock has been
+// advanced by at least d.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	w := &fakeWaiter{when: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.c <- c.now
+		return w.c
+	}
+	c.waiters = append(c.waiters, w)
+	return w.c
+}
+
+// Returns a FakeTicker that delivers a tick every d of fake time. Like
+// time.Ticker, ticks are dropped if the receiver falls behind. This panics
+// if d is not positive.
+func (c *FakeClock) NewTicker(d time.Duration) *FakeTicker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	w := &fakeWaiter{when: c.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	return &FakeTicker{C: w.c, clock: c, waiter: w}
+}
+
+// Moves the clock forward by d, firing every timer and ticker that becomes
+// due along the way in chronological order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool {
+			return c.waiters[i].when.Before(c.waiters[j].when)
+		})
+		if len(c.waiters) == 0 || c.waiters[0].when.After(end) {
+			break
+		}
+		w := c.waiters[0]
+		c.now = w.when
+		select {
+		case w.c <- w.when:
+		default:
+		}
+		if w.period > 0 {
+			w.when = w.when.Add(w.period)
+		} else {
+			c.waiters = c.waiters[1:]
+		}
+	}
+	c.now = end
+}
+
+// Removes the waiter so that it no longer fires.
+func (c *FakeClock) remove(w *fakeWaiter) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i := range c.waiters {
+		if c.waiters[i] == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// A ticker driven by a FakeClock. This is returned from
+// FakeClock.NewTicker().
+type FakeTicker struct {
+	// The channel on which ticks are delivered.
+	C <-chan time.Time
+
+	clock  *FakeClock
+	waiter *fakeWaiter
+}
+
+// Stops the ticker. No more ticks will be delivered.
+func (f *FakeTicker) Stop() {
+	f.clock.remove(f.waiter)
+}
+
+// The number of steps ExpectRate() divides the window into.
+const rateSteps = 100
+
+// The maximum number of attempts ExpectRate() makes in a single step, which
+// protects against limiters that never refuse a call.
+const maxRateAttemptsPerStep = 10000
+
+// Verifies that a rate limiter allows exactly calls attempts within window.
+// The window is divided into small steps and at each step allow is called
+// repeatedly until it refuses (returns false), after which clock is
+// advanced to the next step. No real time passes, so the limiter under test
+// must read the time from clock.
+func (t *T) ExpectRate(
+	clock *FakeClock, allow func() bool, calls int, window time.Duration,
+	desc ...string,
+) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	allowed := 0
+	step := window / rateSteps
+	for i := 0; i < rateSteps; i++ {
+		for n := 0; n < maxRateAttemptsPerStep && allow(); n++ {
+			allowed++
+		}
+		if i < rateSteps-1 {
+			clock.Advance(step)
+		}
+	}
+	if allowed != calls {
+		t.Fatalf("%sUnexpected number of calls allowed within %s.\n"+
+			"  have: %d\n  want: %d", prefix, window, allowed, calls)
+	}
+}
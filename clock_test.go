@@ -0,0 +1,125 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestT_FakeClock(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+
+	c := T.FakeClock()
+	start := c.Now()
+	T.Equal(start, fakeClockEpoch)
+
+	after := c.After(time.Second)
+	ticker := c.NewTicker(300 * time.Millisecond)
+	c.Advance(500 * time.Millisecond)
+	T.Equal(c.Since(start), 500*time.Millisecond)
+	select {
+	case <-after:
+		t.Fatalf("The timer fired early.")
+	case tick := <-ticker.C:
+		T.Equal(tick, start.Add(300*time.Millisecond))
+	}
+
+	// Missed ticks are dropped.
+	c.Advance(time.Second)
+	select {
+	case when := <-after:
+		T.Equal(when, start.Add(time.Second))
+	default:
+		t.Fatalf("The timer did not fire.")
+	}
+	<-ticker.C
+	select {
+	case <-ticker.C:
+		t.Fatalf("Ticks were not dropped.")
+	default:
+	}
+
+	ticker.Stop()
+	c.Advance(time.Second)
+	select {
+	case <-ticker.C:
+		t.Fatalf("The ticker fired after being stopped.")
+	default:
+	}
+
+	select {
+	case <-c.After(0):
+	default:
+		t.Fatalf("A zero timer should fire immediately.")
+	}
+}
+
+// A simple token bucket used to test ExpectRate.
+type testBucket struct {
+	clock  *FakeClock
+	tokens float64
+	burst  float64
+	rate   float64
+	last   time.Time
+}
+
+func (b *testBucket) Allow() bool {
+	now := b.clock.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func TestT_ExpectRate(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	newBucket := func(c *FakeClock) *testBucket {
+		return &testBucket{clock: c, tokens: 5, burst: 5, rate: 10, last: c.Now()}
+	}
+
+	// A burst of 5 plus 10 per second over 0.99 seconds of steps.
+	c := T.FakeClock()
+	m.CheckPass(t, func() { T.ExpectRate(c, newBucket(c).Allow, 14, time.Second) })
+	c = T.FakeClock()
+	m.CheckFail(t, func() {
+		T.ExpectRate(c, newBucket(c).Allow, 5, time.Second, "prefix")
+	})
+	if !strings.HasPrefix(msg, "prefix: Unexpected number of calls allowed within 1s.\n  have: 14\n  want: 5") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	// Limiters that never refuse are capped.
+	c = T.FakeClock()
+	m.CheckFail(t, func() {
+		T.ExpectRate(c, func() bool { return true }, 1, time.Second)
+	})
+}
@@ -0,0 +1,295 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file contains functions for running docker containers as test
+// fixtures.
+
+// The docker binary that is executed. This is a variable so tests can
+// replace it.
+var dockerCommand = "docker"
+
+// The default amount of time to wait for a container to become ready.
+const defaultContainerReadyTimeout = time.Minute
+
+// How long to wait between readiness probes.
+const containerProbeInterval = 100 * time.Millisecond
+
+// Configures a container started with T.Container().
+type ContainerOptions struct {
+	// Container ports (TCP) that should be published on random ports of
+	// the loopback interface.
+	Ports []int
+
+	// Like Ports except that each container port (the key) is published
+	// on the given host port (the value) rather than a random one. This is
+	// needed by services that advertise their own address to clients.
+	HostPorts map[int]int
+
+	// Environment variables to set in the container.
+	Env map[string]string
+
+	// The command to run in the container. If empty the image's default
+	// is used.
+	Command []string
+
+	// Any additional arguments to pass to "docker run".
+	RunArgs []string
+
+	// If non zero the container is not considered ready until this
+	// container port (which must be in Ports) accepts connections.
+	ReadyPort int
+
+	// If non empty the container is not considered ready until its logs
+	// match this regular expression.
+	ReadyLog string
+
+	// How long to wait for the container to become ready. Defaults to one
+	// minute.
+	ReadyTimeout time.Duration
+}
+
+// A running docker container.
+type Container struct {
+	// The ID of the container.
+	ID string
+
+	t     *T
+	ports map[int]int
+}
+
+// Starts a docker container from the given image using the docker command
+// line tool and waits for it to become ready. The container is removed when
+// the test finishes, even if the test panics. If the whole test process is
+// killed then a cleanup process (like the one used by RootTempDir()) will
+// remove the container once the test process has died so that containers
+// are never orphaned.
+func (t *T) Container(image string, opts ContainerOptions) *Container {
+	var logRegexp *regexp.Regexp
+	if opts.ReadyLog != "" {
+		var err error
+		if logRegexp, err = regexp.Compile(opts.ReadyLog); err != nil {
+			t.Fatalf("Invalid ReadyLog for a container from %s: %s", image, err)
+		}
+	}
+
+	args := []string{"run", "--detach"}
+	for _, port := range opts.Ports {
+		args = append(args, "--publish", fmt.Sprintf("127.0.0.1::%d", port))
+	}
+	for port, host := range opts.HostPorts {
+		args = append(args, "--publish", fmt.Sprintf("127.0.0.1:%d:%d", host, port))
+	}
+	for k, v := range opts.Env {
+		args = append(args, "--env", k+"="+v)
+	}
+	args = append(args, opts.RunArgs...)
+	args = append(args, image)
+	args = append(args, opts.Command...)
+	out, err := exec.Command(dockerCommand, args...).Output()
+	t.ExpectSuccess(err, "Unable to start a container from "+image)
+	c := &Container{
+		ID:    strings.TrimSpace(string(out)),
+		t:     t,
+		ports: make(map[int]int, len(opts.Ports)),
+	}
+	if c.ID == "" {
+		t.Fatalf("docker run did not return a container ID.")
+	}
+
+	// The reaper is started before anything else can fail so that the
+	// container is always cleaned up.
+	reaper, err := startContainerReaper(c.ID)
+	if err != nil {
+		exec.Command(dockerCommand, "rm", "--force", c.ID).Run()
+		t.Fatalf("Unable to start the container cleanup process: %s", err)
+	}
+	t.AddNamedFinalizer("container "+c.ID, func() {
+		if err := exec.Command(dockerCommand, "rm", "--force", c.ID).Run(); err != nil {
+			t.Errorf("Unable to remove container %s: %s", c.ID, err)
+			reaper.Close()
+			return
+		}
+		io.WriteString(reaper, containerRemoved)
+		reaper.Close()
+	})
+
+	for _, port := range opts.Ports {
+		c.ports[port] = c.lookupPort(port)
+	}
+	for port, host := range opts.HostPorts {
+		c.ports[port] = host
+	}
+
+	timeout := opts.ReadyTimeout
+	if timeout == 0 {
+		timeout = defaultContainerReadyTimeout
+	}
+	defer t.trackWait("Container")()
+	t.TryUntil(func() bool {
+		if opts.ReadyPort != 0 {
+			conn, err := net.DialTimeout("tcp", c.Addr(opts.ReadyPort), time.Second)
+			if err != nil {
+				time.Sleep(containerProbeInterval)
+				return false
+			}
+			conn.Close()
+		}
+		if logRegexp != nil && !logRegexp.MatchString(c.Logs()) {
+			time.Sleep(containerProbeInterval)
+			return false
+		}
+		return true
+	}, timeout, "Container "+c.ID+" from "+image+" did not become ready")
+	return c
+}
+
+// Returns the host port that the given container port was published on.
+// The port must have been listed in ContainerOptions.Ports.
+func (c *Container) Port(containerPort int) int {
+	port, ok := c.ports[containerPort]
+	if !ok {
+		c.t.Fatalf("Container port %d was not published.", containerPort)
+	}
+	return port
+}
+
+// Returns the host:port address that the given container port was
+// published on.
+func (c *Container) Addr(containerPort int) string {
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(c.Port(containerPort)))
+}
+
+// Runs a command inside of the container returning its combined stdout and
+// stderr output. The test fails if the command fails.
+func (c *Container) Exec(args ...string) string {
+	out, err := exec.Command(
+		dockerCommand, append([]string{"exec", c.ID}, args...)...,
+	).CombinedOutput()
+	if err != nil {
+		c.t.Fatalf("Command %q failed in container %s: %s\n%s",
+			strings.Join(args, " "), c.ID, err, out)
+	}
+	return string(out)
+}
+
+// Returns the combined stdout and stderr output of the container so far.
+func (c *Container) Logs() string {
+	out, err := exec.Command(dockerCommand, "logs", c.ID).CombinedOutput()
+	c.t.ExpectSuccess(err, "Unable to read the logs of container "+c.ID)
+	return string(out)
+}
+
+// Asks docker which host port a container port was published on.
+func (c *Container) lookupPort(containerPort int) int {
+	out, err := exec.Command(
+		dockerCommand, "port", c.ID, fmt.Sprintf("%d/tcp", containerPort),
+	).Output()
+	c.t.ExpectSuccess(err, fmt.Sprintf(
+		"Unable to find the host port for container port %d", containerPort))
+	// The output has one host:port line per binding.
+	line := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	i := strings.LastIndex(line, ":")
+	port, err := strconv.Atoi(line[i+1:])
+	if err != nil {
+		c.t.Fatalf("Unable to parse the output of docker port: %q", out)
+	}
+	return port
+}
+
+// -----------------------------
+// Container Cleanup Internals
+// -----------------------------
+
+// If the process is started with this string as its first argument and a
+// container ID as its second argument then the startup flow is intercepted
+// and the process removes the container once its parent dies.
+const containerReaperArg = "hf8s7dfh3kdfoi"
+
+// Written to the reaper's stdin when the container has already been removed
+// so it can exit without doing anything.
+const containerRemoved = "removed"
+
+// Starts a process that removes the container once the returned writer is
+// closed, which happens automatically if this process dies.
+func startContainerReaper(id string) (io.WriteCloser, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(os.Args[0], containerReaperArg, id)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = reader
+	cmd.Env = append(os.Environ(), "TESTLIB_DOCKER="+dockerCommand)
+	if err := cmd.Start(); err != nil {
+		reader.Close()
+		writer.Close()
+		return nil, err
+	} else if err := reader.Close(); err != nil {
+		writer.Close()
+		return nil, err
+	}
+	// Reap the process in the background so it does not linger as a
+	// zombie once it exits.
+	go cmd.Wait()
+	return writer, nil
+}
+
+// Intercepts the process startup if it is a container reaper. Args will be
+// os.Args, and reader will be os.Stdin.
+func initContainerReaper(args []string, reader io.Reader) {
+	if len(args) != 3 || args[1] != containerReaperArg {
+		return
+	}
+	docker := osGetenv("TESTLIB_DOCKER")
+	if docker == "" {
+		docker = dockerCommand
+	}
+
+	// The parent process holds our stdin open until it dies or the
+	// container has been removed.
+	data, _ := ioutil.ReadAll(reader)
+	if string(data) == containerRemoved {
+		osExit(0)
+		return
+	}
+	out, err := exec.Command(docker, "rm", "--force", args[2]).CombinedOutput()
+	if err != nil {
+		fmtFprintf(os.Stderr, "Error removing container %s: %s: %s\n",
+			args[2], err, out)
+		osExit(1)
+		return
+	}
+	osExit(0)
+}
+
+// On startup call the initContainerReaper() function.
+func init() {
+	initContainerReaper(os.Args, os.Stdin)
+}
@@ -0,0 +1,182 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Replaces dockerCommand with a shell script that logs its arguments to a
+// file named calls in dir and pretends that container port 80 is published
+// on hostPort. The returned function restores the original command.
+func fakeDocker(t *testing.T, dir string, hostPort int) func() {
+	script := filepath.Join(dir, "docker")
+	contents := fmt.Sprintf(`#!/bin/sh
+echo "$@" >> %s/calls
+case "$1" in
+run) echo cid123 ;;
+port) echo 127.0.0.1:%d; echo "[::1]:%d" ;;
+logs) echo starting; echo ready to accept; echo Kafka Server started; echo Server startup complete ;;
+esac
+`, dir, hostPort, hostPort)
+	if err := ioutil.WriteFile(script, []byte(contents), 0755); err != nil {
+		t.Fatalf("Unable to write the fake docker: %s", err)
+	}
+	old := dockerCommand
+	dockerCommand = script
+	return func() { dockerCommand = old }
+}
+
+// Returns the docker commands that have been run.
+func dockerCalls(t *testing.T, dir string) string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Unable to read calls: %s", err)
+	}
+	return string(data)
+}
+
+func TestT_Container(t *testing.T) {
+	_, T := testSetup()
+	defer T.Finish()
+	dir := T.TempDir()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+	defer fakeDocker(t, dir, port)()
+
+	_, T2 := testSetup()
+	c := T2.Container("redis:7", ContainerOptions{
+		Ports:     []int{80},
+		Env:       map[string]string{"A": "b"},
+		Command:   []string{"serve"},
+		ReadyPort: 80,
+		ReadyLog:  "ready",
+	})
+	if c.ID != "cid123" {
+		t.Fatalf("Wrong container ID: %q", c.ID)
+	} else if c.Port(80) != port {
+		t.Fatalf("Wrong port: %d", c.Port(80))
+	} else if c.Addr(80) != l.Addr().String() {
+		t.Fatalf("Wrong address: %s", c.Addr(80))
+	}
+	want := "run --detach --publish 127.0.0.1::80 --env A=b redis:7 serve\n"
+	if calls := dockerCalls(t, dir); !strings.HasPrefix(calls, want) {
+		t.Fatalf("Unexpected docker calls:\n%s", calls)
+	}
+
+	T2.Finish()
+	if calls := dockerCalls(t, dir); !strings.HasSuffix(calls, "rm --force cid123\n") {
+		t.Fatalf("The container was not removed:\n%s", calls)
+	}
+}
+
+func TestT_Container_NotReady(t *testing.T) {
+	_, T := testSetup()
+	defer T.Finish()
+	dir := T.TempDir()
+	defer fakeDocker(t, dir, T.FreePort())()
+
+	m, T2 := testSetup()
+	m.CheckFail(t, func() {
+		T2.Container("redis:7", ContainerOptions{
+			Ports:        []int{80},
+			ReadyPort:    80,
+			ReadyTimeout: 50 * time.Millisecond,
+		})
+	})
+	m.CheckFail(t, func() {
+		T2.Container("redis:7", ContainerOptions{
+			ReadyLog:     "never",
+			ReadyTimeout: 50 * time.Millisecond,
+		})
+	})
+
+	// Containers are removed even though the test failed.
+	T2.Finish()
+	if calls := dockerCalls(t, dir); strings.Count(calls, "rm --force cid123\n") != 2 {
+		t.Fatalf("The containers were not removed:\n%s", calls)
+	}
+}
+
+func TestT_Container_InvalidReadyLog(t *testing.T) {
+	_, T := testSetup()
+	defer T.Finish()
+	dir := T.TempDir()
+	defer fakeDocker(t, dir, 0)()
+
+	m, T2 := testSetup()
+	defer T2.Finish()
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+	m.CheckFail(t, func() {
+		T2.Container("redis:7", ContainerOptions{ReadyLog: "("})
+	})
+	if !strings.HasPrefix(msg, "Invalid ReadyLog for a container from redis:7: ") {
+		t.Fatalf("Unexpected message: %s", msg)
+	} else if calls := dockerCalls(t, dir); calls != "" {
+		t.Fatalf("A container was started:\n%s", calls)
+	}
+}
+
+func TestInitContainerReaper(t *testing.T) {
+	_, T := testSetup()
+	defer T.Finish()
+	dir := T.TempDir()
+	defer fakeDocker(t, dir, 0)()
+
+	exitCode := -1
+	oldExit := osExit
+	defer func() { osExit = oldExit }()
+	osExit = func(code int) { exitCode = code }
+
+	// Not a reaper invocation.
+	initContainerReaper([]string{"test"}, strings.NewReader(""))
+	if exitCode != -1 {
+		t.Fatalf("Unexpected exit: %d", exitCode)
+	}
+
+	// The container was already removed by the parent.
+	initContainerReaper(
+		[]string{"test", containerReaperArg, "cid1"},
+		strings.NewReader(containerRemoved))
+	if exitCode != 0 {
+		t.Fatalf("Unexpected exit code: %d", exitCode)
+	} else if calls := dockerCalls(t, dir); calls != "" {
+		t.Fatalf("Unexpected docker calls:\n%s", calls)
+	}
+
+	// The parent died without removing the container.
+	exitCode = -1
+	initContainerReaper(
+		[]string{"test", containerReaperArg, "cid2"}, strings.NewReader(""))
+	if exitCode != 0 {
+		t.Fatalf("Unexpected exit code: %d", exitCode)
+	} else if calls := dockerCalls(t, dir); calls != "rm --force cid2\n" {
+		t.Fatalf("Unexpected docker calls:\n%s", calls)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// This file contains functions for running subprocesses without losing
+// their code coverage.
+
+// The environment variable that tells a process started by ForkTest() which
+// test it is running in.
+const forkTestEnv = "TESTLIB_FORKED_TEST"
+
+// Coverage written by child processes which is merged into the parent's
+// coverage profile by Main().
+var (
+	childCoverLock     sync.Mutex
+	childCoverDir      string
+	childCoverProfiles []string
+)
+
+// Like exec.Command except that if the tests are running with coverage
+// enabled then GOCOVERDIR is set for the child. Binaries built with -cover
+// (like those from BuildBinary() when coverage is enabled) write their
+// coverage data there, which Main() merges into the test binary's coverage
+// profile so end to end tests count towards the coverage report.
+func (t *T) Exec(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	if dir := t.coverDir(); dir != "" {
+		cmd.Env = append(os.Environ(), "GOCOVERDIR="+dir)
+	}
+	return cmd
+}
+
+// Returns a command that runs the test binary again, running only the
+// current test. In the child Forked() returns true, which lets a test run
+// code that must be isolated in its own process (like code that calls
+// os.Exit) and then check the result from the parent:
+//
+//	if T.Forked() {
+//		runAndExit()
+//		return
+//	}
+//	out, err := T.ForkTest().CombinedOutput()
+//
+// If the tests are running with -test.coverprofile then the child writes
+// its own profile which Main() merges into the parent's, so the code run in
+// the child is not missing from the coverage report.
+func (t *T) ForkTest(args ...string) *exec.Cmd {
+	cmdArgs := []string{"-test.run=^" + regexp.QuoteMeta(t.Name()) + "$"}
+	if coverProfile() != "" {
+		f, err := ioutilTempFile(t.RootTempDir(), t.tempPrefix()+"-*.cover")
+		t.ExpectSuccess(err)
+		f.Close()
+		childCoverLock.Lock()
+		childCoverProfiles = append(childCoverProfiles, f.Name())
+		childCoverLock.Unlock()
+		cmdArgs = append(cmdArgs, "-test.coverprofile="+f.Name())
+	}
+	cmd := t.Exec(os.Args[0], append(cmdArgs, args...)...)
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, forkTestEnv+"="+t.Name())
+	return cmd
+}
+
+// Returns true if this process was started by ForkTest() for the running
+// test.
+func (t *T) Forked() bool {
+	return osGetenv(forkTestEnv) == t.Name()
+}
+
+// Returns the directory that child processes should write coverage data
+// to, or an empty string if coverage is not enabled.
+func (t *T) coverDir() string {
+	if testing.CoverMode() == "" {
+		return ""
+	} else if dir := osGetenv("GOCOVERDIR"); dir != "" {
+		return dir
+	}
+	childCoverLock.Lock()
+	defer childCoverLock.Unlock()
+	if childCoverDir == "" {
+		dir := filepath.Join(t.RootTempDir(), "covdata")
+		t.ExpectSuccess(osMkdirAll(dir, os.FileMode(0755)))
+		childCoverDir = dir
+	}
+	return childCoverDir
+}
+
+// Returns the path of the coverage profile the test binary is writing, or
+// an empty string if there is none.
+func coverProfile() string {
+	f := flag.Lookup("test.coverprofile")
+	if f == nil || f.Value.String() == "" {
+		return ""
+	}
+	path := f.Value.String()
+	if out := flag.Lookup("test.outputdir"); out != nil &&
+		out.Value.String() != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(out.Value.String(), path)
+	}
+	return path
+}
+
+// Merges the coverage written by child processes into the test binary's
+// coverage profile. This must be called after the profile has been
+// written, which happens at the end of testing.M.Run().
+func mergeChildCoverage() error {
+	dst := coverProfile()
+	childCoverLock.Lock()
+	profiles := append([]string(nil), childCoverProfiles...)
+	dir := childCoverDir
+	childCoverLock.Unlock()
+	if dst == "" {
+		return nil
+	}
+
+	// Binary coverage data is converted to a text profile first.
+	if dir != "" {
+		if entries, err := ioutil.ReadDir(dir); err == nil && len(entries) > 0 {
+			text := dir + ".txt"
+			out, err := exec.Command(goCommand, "tool", "covdata", "textfmt",
+				"-i="+dir, "-o="+text).CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("Unable to convert coverage data: %s\n%s",
+					err, out)
+			}
+			profiles = append(profiles, text)
+		}
+	}
+	return mergeCoverProfiles(dst, profiles)
+}
+
+// Appends the blocks from each of the profiles to dst. Tools that read
+// coverage profiles add the counts of blocks that appear more than once.
+// Profiles that are missing or empty, which happens when a child process
+// failed before writing one, are skipped.
+func mergeCoverProfiles(dst string, profiles []string) error {
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	for _, profile := range profiles {
+		if err := appendCoverBlocks(out, profile); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	return out.Close()
+}
+
+// Copies every line but the mode line from the profile to w.
+func appendCoverBlocks(w io.Writer, profile string) error {
+	in, err := os.Open(profile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer in.Close()
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
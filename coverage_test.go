@@ -0,0 +1,83 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestT_ForkTest(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+	defer T.Finish()
+
+	// The mock test is named after the real test so the child runs this
+	// function again.
+	if T.Forked() {
+		os.Stdout.WriteString("running in the child\n")
+		return
+	}
+	out, err := T.ForkTest("-test.v").CombinedOutput()
+	if err != nil {
+		t.Fatalf("The child failed: %s\n%s", err, out)
+	} else if !strings.Contains(string(out), "running in the child") {
+		t.Fatalf("The child did not run the test:\n%s", out)
+	} else if strings.Contains(string(out), "TestT_Exec") {
+		t.Fatalf("The child ran other tests:\n%s", out)
+	}
+}
+
+func TestT_Exec(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+	defer T.Finish()
+
+	cmd := T.Exec("echo", "hello")
+	if cmd.Args[0] != "echo" || cmd.Args[1] != "hello" {
+		t.Fatalf("Unexpected arguments: %v", cmd.Args)
+	}
+	if testing.CoverMode() == "" && cmd.Env != nil {
+		t.Fatalf("The environment was changed without coverage: %v", cmd.Env)
+	}
+}
+
+func TestMergeCoverProfiles(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+	defer T.Finish()
+
+	dir := T.TempDir()
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		T.ExpectSuccess(ioutil.WriteFile(path, []byte(contents), 0644))
+		return path
+	}
+	dst := write("parent", "mode: set\na.go:1.1,2.2 1 1\n")
+	child := write("child", "mode: set\nb.go:1.1,2.2 1 1\n\n")
+	empty := write("empty", "")
+	missing := filepath.Join(dir, "missing")
+
+	T.ExpectSuccess(mergeCoverProfiles(dst, []string{child, empty, missing}))
+	data, err := ioutil.ReadFile(dst)
+	T.ExpectSuccess(err)
+	want := "mode: set\na.go:1.1,2.2 1 1\nb.go:1.1,2.2 1 1\n"
+	if string(data) != want {
+		t.Fatalf("Unexpected profile:\n%s", data)
+	}
+}
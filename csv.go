@@ -0,0 +1,209 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+)
+
+// This file contains functions for comparing CSV documents.
+
+// Options that control how EqualCSV() compares documents.
+type CSVOptions struct {
+	// The field delimiter. If this is zero then a comma is used.
+	Comma rune
+
+	// If true then the first row of each document is treated as a header
+	// and differences are reported using the column names.
+	Header bool
+
+	// If true then columns are matched by their header name rather than
+	// their position, so the order of the columns does not matter. This
+	// implies Header.
+	IgnoreColumnOrder bool
+}
+
+// Parses both CSV documents and compares them cell by cell, failing the
+// test with a list of every cell that differs.
+func (t *T) EqualCSV(have, want string, opts CSVOptions, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	parse := func(name, doc string) [][]string {
+		r := csv.NewReader(strings.NewReader(doc))
+		if opts.Comma != 0 {
+			r.Comma = opts.Comma
+		}
+		r.FieldsPerRecord = -1
+		records, err := r.ReadAll()
+		if err != nil {
+			t.Fatalf("%sUnable to parse %s CSV: %s", prefix, name, err)
+		}
+		return records
+	}
+	haveRows := parse("have", have)
+	wantRows := parse("want", want)
+
+	diffs := []string{}
+	if opts.Header || opts.IgnoreColumnOrder {
+		diffs = csvHeaderDiffs(haveRows, wantRows, opts.IgnoreColumnOrder)
+	} else {
+		diffs = csvDiffs(haveRows, wantRows)
+	}
+	if len(diffs) > 0 {
+		t.Fatalf("%sCSV documents are not equal\n%s",
+			prefix, strings.Join(diffs, "\n"))
+	}
+}
+
+// Compares two documents without headers by position.
+func csvDiffs(have, want [][]string) []string {
+	diffs := []string{}
+	if len(have) != len(want) {
+		diffs = append(diffs, fmt.Sprintf(
+			"row count: have %d, want %d", len(have), len(want)))
+	}
+	for i := 0; i < len(have) && i < len(want); i++ {
+		if len(have[i]) != len(want[i]) {
+			diffs = append(diffs, fmt.Sprintf(
+				"row %d: column count: have %d, want %d",
+				i+1, len(have[i]), len(want[i])))
+		}
+		for j := 0; j < len(have[i]) && j < len(want[i]); j++ {
+			if have[i][j] != want[i][j] {
+				diffs = append(diffs,
+					fmt.Sprintf("row %d, column %d: not equal.", i+1, j+1),
+					fmt.Sprintf("  have: %q", have[i][j]),
+					fmt.Sprintf("  want: %q", want[i][j]))
+			}
+		}
+	}
+	return diffs
+}
+
+// Compares two documents with a header row. If ignoreOrder is true then
+// columns are matched by name rather than position.
+func csvHeaderDiffs(have, want [][]string, ignoreOrder bool) []string {
+	if len(have) == 0 || len(want) == 0 {
+		if len(have) != len(want) {
+			return []string{fmt.Sprintf(
+				"row count: have %d, want %d", len(have), len(want))}
+		}
+		return nil
+	}
+	haveHeader, wantHeader := have[0], want[0]
+	diffs := []string{}
+
+	// Work out which column in have matches each column in want.
+	columns := make([]int, len(wantHeader))
+	if ignoreOrder {
+		index := make(map[string]int, len(haveHeader))
+		for i, name := range haveHeader {
+			index[name] = i
+		}
+		for i, name := range wantHeader {
+			if j, ok := index[name]; ok {
+				columns[i] = j
+				delete(index, name)
+			} else {
+				columns[i] = -1
+				diffs = append(diffs,
+					fmt.Sprintf("column %q: Expected column is missing.", name))
+			}
+		}
+		for _, name := range haveHeader {
+			if _, ok := index[name]; ok {
+				diffs = append(diffs,
+					fmt.Sprintf("column %q: Unexpected column.", name))
+			}
+		}
+	} else {
+		if strings.Join(haveHeader, "\x00") != strings.Join(wantHeader, "\x00") {
+			diffs = append(diffs, "header: not equal.",
+				fmt.Sprintf("  have: %q", haveHeader),
+				fmt.Sprintf("  want: %q", wantHeader))
+		}
+		for i := range columns {
+			columns[i] = i
+		}
+	}
+
+	if len(have) != len(want) {
+		diffs = append(diffs, fmt.Sprintf(
+			"row count: have %d, want %d", len(have)-1, len(want)-1))
+	}
+	for r := 1; r < len(have) && r < len(want); r++ {
+		// When the headers differ in length the header diffs already
+		// explain why the rows do.
+		haveRow, wantRow := have[r], want[r]
+		if len(haveHeader) == len(wantHeader) &&
+			len(haveRow) != len(wantRow) {
+			diffs = append(diffs, fmt.Sprintf(
+				"row %d: column count: have %d, want %d",
+				r, len(haveRow), len(wantRow)))
+		}
+		for i, name := range wantHeader {
+			j := columns[i]
+			if j < 0 {
+				continue
+			}
+			haveOK, wantOK := j < len(haveRow), i < len(wantRow)
+			switch {
+			case !haveOK && !wantOK:
+			case !haveOK:
+				diffs = append(diffs, fmt.Sprintf(
+					"row %d, column %q: Expected cell is missing.", r, name))
+			case !wantOK:
+				diffs = append(diffs, fmt.Sprintf(
+					"row %d, column %q: Unexpected cell.", r, name))
+			case haveRow[j] != wantRow[i]:
+				diffs = append(diffs,
+					fmt.Sprintf("row %d, column %q: not equal.", r, name),
+					fmt.Sprintf("  have: %q", haveRow[j]),
+					fmt.Sprintf("  want: %q", wantRow[i]))
+			}
+		}
+
+		// Cells past the end of the header have no name so they are
+		// matched by position.
+		haveExtra, wantExtra := []string{}, []string{}
+		if len(haveRow) > len(haveHeader) {
+			haveExtra = haveRow[len(haveHeader):]
+		}
+		if len(wantRow) > len(wantHeader) {
+			wantExtra = wantRow[len(wantHeader):]
+		}
+		for n := 0; n < len(haveExtra) || n < len(wantExtra); n++ {
+			column := len(wantHeader) + n + 1
+			switch {
+			case n >= len(haveExtra):
+				diffs = append(diffs, fmt.Sprintf(
+					"row %d, column %d: Expected cell is missing.", r, column))
+			case n >= len(wantExtra):
+				diffs = append(diffs, fmt.Sprintf(
+					"row %d, column %d: Unexpected cell.", r, column))
+			case haveExtra[n] != wantExtra[n]:
+				diffs = append(diffs,
+					fmt.Sprintf("row %d, column %d: not equal.", r, column),
+					fmt.Sprintf("  have: %q", haveExtra[n]),
+					fmt.Sprintf("  want: %q", wantExtra[n]))
+			}
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,113 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestT_EqualCSV(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	doc := "name,age\nbob,10\nalice,20\n"
+
+	// Positional comparison.
+	m.CheckPass(t, func() { T.EqualCSV(doc, doc, CSVOptions{}) })
+	m.CheckFail(t, func() {
+		T.EqualCSV(doc, "name,age\nbob,11\nalice,20\n", CSVOptions{}, "prefix")
+	})
+	if !strings.HasPrefix(msg, "prefix: ") {
+		t.Fatalf("The prefix was not prepended to the message: '''%s'''", msg)
+	} else if !strings.Contains(msg, "row 2, column 2: not equal.") {
+		t.Fatalf("The cell was not reported: %s", msg)
+	}
+	m.CheckFail(t, func() { T.EqualCSV(doc, "name,age\n", CSVOptions{}) })
+	if !strings.Contains(msg, "row count: have 3, want 1") {
+		t.Fatalf("The row count was not reported: %s", msg)
+	}
+
+	// Header keyed comparison.
+	opts := CSVOptions{Header: true}
+	m.CheckFail(t, func() {
+		T.EqualCSV(doc, "name,age\nbob,11\nalice,20\n", opts)
+	})
+	if !strings.Contains(msg, `row 1, column "age": not equal.`) {
+		t.Fatalf("The cell was not reported: %s", msg)
+	}
+	m.CheckFail(t, func() {
+		T.EqualCSV(doc, "age,name\n10,bob\n20,alice\n", opts)
+	})
+
+	// Ignoring column order.
+	opts = CSVOptions{IgnoreColumnOrder: true}
+	m.CheckPass(t, func() {
+		T.EqualCSV(doc, "age,name\n10,bob\n20,alice\n", opts)
+	})
+	m.CheckFail(t, func() {
+		T.EqualCSV(doc, "age,city\n10,x\n20,y\n", opts)
+	})
+	if !strings.Contains(msg, `column "city": Expected column is missing.`) {
+		t.Fatalf("The missing column was not reported: %s", msg)
+	} else if !strings.Contains(msg, `column "name": Unexpected column.`) {
+		t.Fatalf("The unexpected column was not reported: %s", msg)
+	}
+
+	// Ragged rows.
+	ragged := []CSVOptions{{Header: true}, {IgnoreColumnOrder: true}}
+	for _, opts := range ragged {
+		m.CheckPass(t, func() {
+			T.EqualCSV("a,b\n1\n2,3,4\n", "a,b\n1\n2,3,4\n", opts)
+		})
+		m.CheckFail(t, func() {
+			T.EqualCSV("a,b\n1,2,3\n", "a,b\n1,2\n", opts)
+		})
+		if !strings.Contains(msg, "row 1: column count: have 3, want 2") {
+			t.Fatalf("The column count was not reported: %s", msg)
+		} else if !strings.Contains(msg, "row 1, column 3: Unexpected cell.") {
+			t.Fatalf("The extra cell was not reported: %s", msg)
+		}
+		m.CheckFail(t, func() {
+			T.EqualCSV("a,b\n1\n", "a,b\n1,\n", opts)
+		})
+		if !strings.Contains(msg, "row 1: column count: have 1, want 2") {
+			t.Fatalf("The column count was not reported: %s", msg)
+		} else if !strings.Contains(msg,
+			`row 1, column "b": Expected cell is missing.`) {
+			t.Fatalf("The missing cell was not reported: %s", msg)
+		}
+		m.CheckFail(t, func() {
+			T.EqualCSV("a,b\n1,2,x\n", "a,b\n1,2,y\n", opts)
+		})
+		if !strings.Contains(msg, "row 1, column 3: not equal.") {
+			t.Fatalf("The extra cell was not compared: %s", msg)
+		}
+	}
+
+	// Alternate delimiters.
+	m.CheckPass(t, func() {
+		T.EqualCSV("a;b\n", "a;b\n", CSVOptions{Comma: ';'})
+	})
+
+	// Parse errors.
+	m.CheckFail(t, func() { T.EqualCSV("\"a", doc, CSVOptions{}) })
+}
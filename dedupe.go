@@ -0,0 +1,109 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+)
+
+// This file contains functions for collapsing repeated failures.
+
+// A failure that has been reported more than once.
+type repeatedFailure struct {
+	msg   string
+	frame string
+	count int
+}
+
+// If enabled then a failure reported via Error() or Errorf() with the same
+// message, from the same line, as an earlier failure is not reported
+// again. Instead, when the test finishes, a summary of how many times each
+// failure was repeated is logged. This keeps an assertion that fails on
+// every iteration of a large loop from burying the rest of the output while
+// the first occurrence is still reported with its full stack trace.
+func (t *T) SetDedupeFailures(enabled bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.dedupe = enabled
+}
+
+// Returns true if the failure has already been reported and should be
+// suppressed, counting the repetition.
+func (t *T) repeated(msg string) bool {
+	t.lock.Lock()
+	if !t.dedupe {
+		t.lock.Unlock()
+		return false
+	}
+	frame := topFrame()
+	key := frame + "\x00" + msg
+	if t.failureCounts == nil {
+		t.failureCounts = map[string]*repeatedFailure{}
+	}
+	if r, ok := t.failureCounts[key]; ok {
+		r.count++
+		first := !t.repeatsLogged
+		t.repeatsLogged = true
+		t.lock.Unlock()
+		if first {
+			t.AddNamedFinalizer("repeated failures", t.logRepeated)
+		}
+		return true
+	}
+	r := &repeatedFailure{msg: msg, frame: frame}
+	t.failureCounts[key] = r
+	t.failureOrder = append(t.failureOrder, r)
+	t.lock.Unlock()
+	return false
+}
+
+// Logs how many times each failure was repeated.
+func (t *T) logRepeated() {
+	t.lock.Lock()
+	order := t.failureOrder
+	t.lock.Unlock()
+	for _, r := range order {
+		t.lock.Lock()
+		count := r.count
+		t.lock.Unlock()
+		if count == 0 {
+			continue
+		} else if r.frame == "" {
+			t.Logf("Failure repeated %d more time(s): %s", count, r.msg)
+		} else {
+			t.Logf("Failure repeated %d more time(s) at %s: %s",
+				count, r.frame, r.msg)
+		}
+	}
+}
+
+// Returns the file and line of the first stack frame outside of this
+// package, the Go runtime and the testing package.
+func topFrame() string {
+	_, thisfile, _, _ := runtime.Caller(0)
+	thisdir := path.Dir(thisfile)
+	for i := 1; true; i++ {
+		pc, file, line, ok := runtime.Caller(i)
+		if !ok {
+			break
+		} else if path.Dir(file) == thisdir || isRuntimeFrame(pc) {
+			continue
+		}
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return ""
+}
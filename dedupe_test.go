@@ -0,0 +1,68 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestT_SetDedupeFailures(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+	errors := []string{}
+	m.funcError = func(args ...interface{}) {
+		errors = append(errors, fmt.Sprint(args...))
+	}
+	logs := []string{}
+	m.funcLogf = func(f string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(f, args...))
+	}
+
+	// Without de-duplication every failure is reported.
+	for i := 0; i < 3; i++ {
+		T.Errorf("same")
+	}
+	if len(errors) != 3 {
+		t.Fatalf("Expected 3 errors, got %d", len(errors))
+	}
+
+	errors = nil
+	T.SetDedupeFailures(true)
+	for i := 0; i < 5; i++ {
+		T.Errorf("same")
+		T.Error("other")
+	}
+	T.Error("once")
+	if len(errors) != 3 {
+		t.Fatalf("Expected 3 errors, got %d: %#v", len(errors), errors)
+	} else if !strings.HasPrefix(errors[0], "same") {
+		t.Fatalf("The first occurrence was not reported: %s", errors[0])
+	}
+	T.Finish()
+	want := []string{
+		"Failure repeated 4 more time(s): same",
+		"Failure repeated 4 more time(s): other",
+	}
+	if len(logs) != len(want) {
+		t.Fatalf("Unexpected logs: %#v", logs)
+	}
+	for i := range want {
+		if !strings.HasPrefix(logs[i], want[i]) {
+			t.Fatalf("Unexpected log %d: %s", i, logs[i])
+		}
+	}
+}
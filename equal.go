@@ -16,8 +16,10 @@ package testlib
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"strings"
+	"unicode/utf8"
 )
 
 // This file contains a super utility for checking the equality of structures
@@ -39,7 +41,11 @@ func (t *T) Equalf(have, want interface{}, spec string, args ...interface{}) {
 // Like Equal, except the third argument is a list of paths that should not
 // be considered. This can be used to mask out expected differences in objects.
 //
-// The ignores list contains strings which match the output format of Equal.
+// The ignores list contains paths in the same format used by the output of
+// Equal. Struct fields are joined with dots, slice and array elements use
+// [3] and map entries use the key as a Go literal, for example
+// `Users["bob"].Groups[2]`. Paths in the format used by older versions of
+// this library are still accepted.
 func (t *T) EqualWithIgnores(
 	have, want interface{}, ignores []string, desc ...string,
 ) {
@@ -77,9 +83,12 @@ func (t *T) equalWithIgnoresPrefix_(
 	// Next we need to get the value of both objects so we can compare them.
 	haveValue := reflect.ValueOf(have)
 	wantValue := reflect.ValueOf(want)
-	visited := make(map[uintptr]*visitedNode)
-	reason := t.deepEqual("", haveValue, wantValue, ignores, visited)
-	if len(reason) > 0 {
+	state := newEqualState(ignores)
+	reason := t.deepEqual("", haveValue, wantValue, state)
+	if len(reason) > 0 && t.printLiterals() {
+		t.Fatalf("%sNot Equal\n%s\n\nhave as a Go literal:\n%s", prefix,
+			strings.Join(reason, "\n"), goLiteral(haveValue))
+	} else if len(reason) > 0 {
 		t.Fatalf("%sNot Equal\n%s", prefix, strings.Join(reason, "\n"))
 	}
 }
@@ -114,13 +123,65 @@ func (t *T) notEqualPrefix_(have, unwanted interface{}, prefix string) {
 	// Next we need to get the value of both objects so we can compare them.
 	haveValue := reflect.ValueOf(have)
 	unwantedValue := reflect.ValueOf(unwanted)
-	visited := make(map[uintptr]*visitedNode)
-	reason := t.deepEqual("", haveValue, unwantedValue, nil, visited)
+	reason := t.deepEqual("", haveValue, unwantedValue, newEqualState(nil))
 	if len(reason) == 0 {
-		t.Fatalf("%sValues are not expected to be equal: %#v", prefix, have)
+		t.Fatalf("%sValues are not expected to be equal: %s",
+			prefix, t.stringValue(haveValue))
 	}
 }
 
+// Returns the paths of every value that differs between have and want. The
+// returned paths are in the same format that EqualWithIgnores() accepts so
+// they can be used to quickly build an ignores list from a real failure
+// rather than transcribing paths by hand. If the values are equal then an
+// empty list is returned.
+func (t *T) DiffPaths(have, want interface{}) []string {
+	haveNil := t.isNil(have)
+	wantNil := t.isNil(want)
+	if haveNil && wantNil {
+		return []string{}
+	} else if haveNil || wantNil {
+		return []string{""}
+	}
+	state := newEqualState(nil)
+	t.deepEqual("", reflect.ValueOf(have), reflect.ValueOf(want), state)
+	return append([]string{}, state.paths...)
+}
+
+// Tracks the state of a single deep comparison.
+type equalState struct {
+	// Paths that should not be compared.
+	ignores []string
+
+	// Tracks pointers that have already been compared.
+	visited map[uintptr]*visitedNode
+
+	// The paths of every value found to differ.
+	paths []string
+}
+
+// Returns a new equalState with the given ignores list.
+func newEqualState(ignores []string) *equalState {
+	normalized := make([]string, len(ignores))
+	for i, ignore := range ignores {
+		normalized[i] = normalizePath(ignore)
+	}
+	return &equalState{
+		ignores: normalized,
+		visited: make(map[uintptr]*visitedNode),
+	}
+}
+
+// Returns true if the given path should not be compared.
+func (s *equalState) ignored(path string) bool {
+	for _, ignore := range s.ignores {
+		if path == ignore {
+			return true
+		}
+	}
+	return false
+}
+
 // Tracks access to specific pointers so we do not recurse.
 type visitedNode struct {
 	a1   uintptr
@@ -147,27 +208,132 @@ func (t *T) isNil(obj interface{}) bool {
 	return v.IsNil()
 }
 
+// Registers a function that will be used to render values of the given type
+// in failure output. This allows domain types like IDs, hashes or money to
+// be displayed compactly rather than as a deep %#v dump. When two values of
+// a type with a registered formatter differ the whole value is reported
+// using the formatter rather than each differing field.
+func (t *T) RegisterFormatter(typ reflect.Type, f func(v interface{}) string) {
+	if t.formatters == nil {
+		t.formatters = make(map[reflect.Type]func(interface{}) string)
+	}
+	t.formatters[typ] = f
+}
+
+// The default maximum length of a single value rendered in failure output.
+const defaultMaxValueLength = 4096
+
+// If this environment variable is set to a non empty value then values in
+// failure output are never truncated, regardless of SetMaxValueLength().
+const fullOutputEnv = "TESTLIB_FULL_OUTPUT"
+
+// Sets the maximum length of a single value that will be rendered in failure
+// output. Values longer than this have their middle removed, leaving the
+// head and tail as context along with a note of how much was omitted. A
+// value of zero or less disables truncation. The default is 4096 bytes.
+//
+// Setting the TESTLIB_FULL_OUTPUT environment variable disables truncation
+// entirely which is useful when debugging a specific failure.
+func (t *T) SetMaxValueLength(n int) {
+	t.maxValueLength = n
+}
+
+// Truncates a rendered value so that it fits within the maximum value
+// length configured for this T.
+func (t *T) truncate(s string) string {
+	max := t.maxValueLength
+	if max <= 0 || len(s) <= max || osGetenv(fullOutputEnv) != "" {
+		return s
+	}
+
+	// Find the head and tail making sure not to split a multi byte rune.
+	head := max / 2
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	tail := len(s) - max/2
+	for tail < len(s) && !utf8.RuneStart(s[tail]) {
+		tail++
+	}
+	return fmt.Sprintf("%s...(%d bytes omitted)...%s",
+		s[:head], tail-head, s[tail:])
+}
+
 // Returns a string representation of a Value that is sanitized based on what
 // we are allowed to see. Private fields can not be exposed via a call to
 // Interface() and trying will cause a panic, so we must use String()
 // in that case.
-func stringValue(v reflect.Value) string {
-	if v.CanInterface() {
-		return fmt.Sprintf("%#v", v.Interface())
-	} else {
-		return v.String()
+func (t *T) stringValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<invalid>"
+	} else if !v.CanInterface() {
+		return t.truncate(v.String())
+	} else if f, ok := t.formatters[v.Type()]; ok {
+		return t.truncate(f(v.Interface()))
 	}
+	return t.truncate(fmt.Sprintf("%#v", v.Interface()))
 }
 
-// Deep comparison. This is based on golang 1.2's reflect.Equal functionality.
+// If this environment variable is set to a non empty value then panics
+// raised while comparing values are not recovered. This allows the full
+// stack of the panic to be seen when debugging the comparison itself.
+const equalPanicEnv = "TESTLIB_EQUAL_PANIC"
+
+// Deep comparison. If the type being compared has a formatter registered
+// via RegisterFormatter() then any differences are collapsed into a single
+// difference rendered with the formatter.
+//
+// Comparing exotic values can cause reflect (or a registered formatter) to
+// panic. Rather than killing the whole test binary the panic is recovered
+// and reported as a difference at the path being compared, unless the
+// TESTLIB_EQUAL_PANIC environment variable is set.
 func (t *T) deepEqual(
-	desc string, have, want reflect.Value, ignores []string,
-	visited map[uintptr]*visitedNode,
+	desc string, have, want reflect.Value, state *equalState,
 ) (diffs []string) {
-	for _, ignore := range ignores {
-		if desc == ignore {
-			return nil
-		}
+	paths := len(state.paths)
+	if osGetenv(equalPanicEnv) == "" {
+		defer func() {
+			if r := recover(); r != nil {
+				state.paths = append(state.paths[:paths], desc)
+				diffs = []string{
+					fmt.Sprintf("%s: panic while comparing: %v", desc, r),
+					fmt.Sprintf("  set %s to see the full panic.",
+						equalPanicEnv),
+				}
+			}
+		}()
+	}
+	diffs = t.deepEqualValues(desc, have, want, state)
+	if len(diffs) > 0 && len(state.paths) == paths {
+		// None of the children recorded a difference so this is the
+		// specific value that differs.
+		state.paths = append(state.paths, desc)
+	}
+	if len(diffs) == 0 || !have.IsValid() || !want.IsValid() {
+		return diffs
+	} else if have.Type() != want.Type() {
+		return diffs
+	} else if !have.CanInterface() || !want.CanInterface() {
+		return diffs
+	} else if _, ok := t.formatters[want.Type()]; !ok {
+		return diffs
+	}
+	state.paths = append(state.paths[:paths], desc)
+	return []string{
+		fmt.Sprintf("%s: not equal.", desc),
+		fmt.Sprintf("  have: %s", t.stringValue(have)),
+		fmt.Sprintf("  want: %s", t.stringValue(want)),
+	}
+}
+
+// Deep comparison. This is based on golang 1.2's reflect.Equal functionality.
+func (t *T) deepEqualValues(
+	desc string, have, want reflect.Value, state *equalState,
+) (diffs []string) {
+	if state.ignored(desc) {
+		return nil
+	} else if diffs, ok := t.matchWant(desc, have, want); ok {
+		return diffs
 	}
 	if !want.IsValid() && !have.IsValid() {
 		return nil
@@ -202,7 +368,7 @@ func (t *T) deepEqual(
 
 		// ... or already seen
 		h := 17*addr1 + addr2
-		seen := visited[h]
+		seen := state.visited[h]
 		typ := want.Type()
 		for p := seen; p != nil; p = p.next {
 			if p.a1 == addr1 && p.a2 == addr2 && p.typ == typ {
@@ -211,20 +377,20 @@ func (t *T) deepEqual(
 		}
 
 		// Remember for later.
-		visited[h] = &visitedNode{addr1, addr2, typ, seen}
+		state.visited[h] = &visitedNode{addr1, addr2, typ, seen}
 	}
 
 	// Checks to see if one value is nil, while the other is not.
 	checkNil := func() bool {
 		if want.IsNil() && !have.IsNil() {
 			diffs = append(diffs, fmt.Sprintf("%s: not equal.", desc))
-			diffs = append(diffs, fmt.Sprintf("  have: %s", stringValue(have)))
+			diffs = append(diffs, fmt.Sprintf("  have: %s", t.stringValue(have)))
 			diffs = append(diffs, "  want: nil")
 			return true
 		} else if !want.IsNil() && have.IsNil() {
 			diffs = append(diffs, fmt.Sprintf("%s: not equal.", desc))
 			diffs = append(diffs, "  have: nil")
-			diffs = append(diffs, fmt.Sprintf("  want: %s", stringValue(want)))
+			diffs = append(diffs, fmt.Sprintf("  want: %s", t.stringValue(want)))
 			return true
 		}
 		return false
@@ -236,8 +402,8 @@ func (t *T) deepEqual(
 			diffs = append(diffs, fmt.Sprintf(
 				"%s: (len(have): %d, len(want): %d)",
 				desc, have.Len(), want.Len()))
-			diffs = append(diffs, fmt.Sprintf("  have: %s", stringValue(have)))
-			diffs = append(diffs, fmt.Sprintf("  want: %s", stringValue(want)))
+			diffs = append(diffs, fmt.Sprintf("  have: %s", t.stringValue(have)))
+			diffs = append(diffs, fmt.Sprintf("  want: %s", t.stringValue(want)))
 			return true
 		}
 		return false
@@ -249,7 +415,7 @@ func (t *T) deepEqual(
 			for i := 0; i < want.Len(); i++ {
 				newdiffs := t.deepEqual(
 					fmt.Sprintf("%s[%d]", desc, i),
-					have.Index(i), want.Index(i), ignores, visited)
+					have.Index(i), want.Index(i), state)
 				diffs = append(diffs, newdiffs...)
 			}
 		}
@@ -262,7 +428,7 @@ func (t *T) deepEqual(
 		wcap := want.Cap()
 		if hcap != wcap {
 			diffs = append(diffs, fmt.Sprintf(
-				"%sCapacities differ:\n  have: %d\n  want: %d",
+				"%s: capacities differ.\n  have: %d\n  want: %d",
 				desc, hcap, wcap))
 			return diffs
 		}
@@ -274,7 +440,7 @@ func (t *T) deepEqual(
 	case reflect.Interface:
 		if !checkNil() {
 			newdiffs := t.deepEqual(
-				desc, have.Elem(), want.Elem(), ignores, visited)
+				desc, have.Elem(), want.Elem(), state)
 			diffs = append(diffs, newdiffs...)
 		}
 
@@ -282,35 +448,58 @@ func (t *T) deepEqual(
 		if !checkNil() {
 			// Check that the keys are present in both maps.
 			for _, k := range want.MapKeys() {
-				if !have.MapIndex(k).IsValid() {
+				path := desc + "[" + mapKeyPath(k) + "]"
+				if state.ignored(path) {
+					continue
+				}
+				hv, reason := mapLookup(have, k)
+				if reason != "" {
+					diffs = append(diffs, fmt.Sprintf(
+						"%s: key %s so the maps can not be compared.",
+						path, reason))
+					state.paths = append(state.paths, path)
+					continue
+				} else if !hv.IsValid() {
 					// Add the error.
 					diffs = append(diffs, fmt.Sprintf(
-						"%sExpected key [%q] is missing.", desc, k))
+						"%s: Expected key is missing.", path))
 					diffs = append(diffs, "  have: not present")
-					diffs = append(diffs, fmt.Sprintf("  want: %#v",
-						want.MapIndex(k)))
+					diffs = append(diffs, fmt.Sprintf("  want: %s",
+						t.stringValue(want.MapIndex(k))))
+					state.paths = append(state.paths, path)
 					continue
 				}
 				newdiffs := t.deepEqual(
-					fmt.Sprintf("%s[%q] ", desc, k),
-					have.MapIndex(k), want.MapIndex(k), ignores, visited)
+					path, hv, want.MapIndex(k), state)
 				diffs = append(diffs, newdiffs...)
 			}
 			for _, k := range have.MapKeys() {
-				if !want.MapIndex(k).IsValid() {
+				path := desc + "[" + mapKeyPath(k) + "]"
+				if state.ignored(path) {
+					continue
+				}
+				wv, reason := mapLookup(want, k)
+				if reason != "" {
+					diffs = append(diffs, fmt.Sprintf(
+						"%s: key %s so the maps can not be compared.",
+						path, reason))
+					state.paths = append(state.paths, path)
+					continue
+				} else if !wv.IsValid() {
 					// Add the error.
 					diffs = append(diffs, fmt.Sprintf(
-						"%sUnexpected key [%q].", desc, k))
-					diffs = append(diffs,
-						fmt.Sprintf("  have: %#v", have.MapIndex(k)))
+						"%s: Unexpected key.", path))
+					diffs = append(diffs, fmt.Sprintf("  have: %s",
+						t.stringValue(have.MapIndex(k))))
 					diffs = append(diffs, "  want: not present")
+					state.paths = append(state.paths, path)
 				}
 			}
 		}
 
 	case reflect.Ptr:
 		newdiffs := t.deepEqual(
-			desc, have.Elem(), want.Elem(), ignores, visited)
+			desc, have.Elem(), want.Elem(), state)
 		diffs = append(diffs, newdiffs...)
 
 	case reflect.Slice:
@@ -318,7 +507,7 @@ func (t *T) deepEqual(
 			for i := 0; i < want.Len(); i++ {
 				newdiffs := t.deepEqual(
 					fmt.Sprintf("%s[%d]", desc, i),
-					have.Index(i), want.Index(i), ignores, visited)
+					have.Index(i), want.Index(i), state)
 				diffs = append(diffs, newdiffs...)
 			}
 		}
@@ -335,20 +524,22 @@ func (t *T) deepEqual(
 		}
 		hrunes := []rune(hstr)
 		wrunes := []rune(wstr)
+		hquoted := t.truncate(fmt.Sprintf("%#v", hstr))
+		wquoted := t.truncate(fmt.Sprintf("%#v", wstr))
 		if len(hrunes) != len(wrunes) {
 			return []string{
 				fmt.Sprintf("%s: len(have) %d != len(want) %d.",
 					desc, len(hrunes), len(wrunes)),
-				fmt.Sprintf("  have: %#v", hstr),
-				fmt.Sprintf("  want: %#v", wstr),
+				fmt.Sprintf("  have: %s", hquoted),
+				fmt.Sprintf("  want: %s", wquoted),
 			}
 		}
 		for i, r := range hrunes {
 			if r != wrunes[i] {
 				return []string{
 					fmt.Sprintf("%s: difference at rune %d.", desc, i),
-					fmt.Sprintf("  have: %#v", hstr),
-					fmt.Sprintf("  want: %#v", wstr),
+					fmt.Sprintf("  have: %s", hquoted),
+					fmt.Sprintf("  want: %s", wquoted),
 				}
 			}
 		}
@@ -360,23 +551,28 @@ func (t *T) deepEqual(
 			// first object given to us is a struct.
 			if desc == "" {
 				newdiffs := t.deepEqual(
-					name, have.Field(i), want.Field(i), ignores, visited)
+					name, have.Field(i), want.Field(i), state)
 				diffs = append(diffs, newdiffs...)
 			} else {
 				newdiffs := t.deepEqual(
 					fmt.Sprintf("%s.%s", desc, name),
-					have.Field(i), want.Field(i), ignores, visited)
+					have.Field(i), want.Field(i), state)
 				diffs = append(diffs, newdiffs...)
 			}
 		}
 
 	case reflect.Uintptr:
-		// Uintptr's work like UnsafePointers. We can't evaluate them or
-		// do much with them so we have to cast them into a number and
-		// compare them that way.
+		// Uintptr's work like UnsafePointers. Unless a hint was registered
+		// via RegisterPointerHint() we can't evaluate them or do much with
+		// them so we have to cast them into a number and compare them that
+		// way.
 		havePtr := have.Uint()
 		wantPtr := want.Uint()
 		if havePtr != wantPtr {
+			if hv, wv, ok := t.pointedValues(have, want); ok {
+				// A hint says what the pointers point at so compare that.
+				return t.deepEqual(desc, hv, wv, state)
+			}
 			return []string{
 				fmt.Sprintf("%s: not equal.", desc),
 				fmt.Sprintf("  have: %#v", havePtr),
@@ -391,6 +587,9 @@ func (t *T) deepEqual(
 		havePtr := have.Pointer()
 		wantPtr := want.Pointer()
 		if havePtr != wantPtr {
+			if hv, wv, ok := t.pointedValues(have, want); ok {
+				return t.deepEqual(desc, hv, wv, state)
+			}
 			return []string{
 				fmt.Sprintf("%s: not equal.", desc),
 				fmt.Sprintf("  have: %#v", havePtr),
@@ -466,3 +665,49 @@ func (t *T) deepEqual(
 
 	return diffs
 }
+
+// Looks up the key k in the map m. Some keys can be stored in a map but can
+// never be found again by a lookup, like NaN which is never equal to itself.
+// Others, like an interface holding a slice, cause reflect to panic. In both
+// cases an invalid Value is returned along with a reason explaining why the
+// key can not be looked up.
+func mapLookup(m, k reflect.Value) (v reflect.Value, reason string) {
+	if hasNaN(k) {
+		return reflect.Value{}, "contains NaN which is never equal to itself"
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			v = reflect.Value{}
+			reason = fmt.Sprintf("can not be looked up (%v)", r)
+		}
+	}()
+	return m.MapIndex(k), ""
+}
+
+// Returns true if the value is, or directly contains, a floating point or
+// complex NaN. Pointers are not followed since pointer keys are compared by
+// address.
+func hasNaN(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return math.IsNaN(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return math.IsNaN(real(c)) || math.IsNaN(imag(c))
+	case reflect.Interface:
+		return !v.IsNil() && hasNaN(v.Elem())
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if hasNaN(v.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if hasNaN(v.Field(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
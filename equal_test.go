@@ -17,9 +17,11 @@ package testlib
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"math/rand"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"unicode"
@@ -379,3 +381,223 @@ func TestEqualWithIgnoresf(t *testing.T) {
 		T.EqualWithIgnoresf(have, want, []string{"link1.str"}, "foo %d", 4)
 	})
 }
+
+type testEqualMoney struct {
+	Cents    int64
+	Currency string
+}
+
+func TestT_RegisterFormatter(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+	T.RegisterFormatter(
+		reflect.TypeOf(testEqualMoney{}),
+		func(v interface{}) string {
+			money := v.(testEqualMoney)
+			return fmt.Sprintf("%d.%02d %s",
+				money.Cents/100, money.Cents%100, money.Currency)
+		})
+
+	have := []testEqualMoney{{Cents: 100, Currency: "USD"}}
+	want := []testEqualMoney{{Cents: 150, Currency: "EUR"}}
+	m.CheckPass(t, func() { T.Equal(have, have) })
+	m.CheckFail(t, func() { T.Equal(have, want) })
+	if !strings.Contains(msg, "[0]: not equal.") {
+		t.Fatalf("The difference was not reported at the value: %s", msg)
+	} else if !strings.Contains(msg, "have: 1.00 USD") {
+		t.Fatalf("The formatter was not used for have: %s", msg)
+	} else if !strings.Contains(msg, "want: 1.50 EUR") {
+		t.Fatalf("The formatter was not used for want: %s", msg)
+	} else if strings.Contains(msg, "Cents") {
+		t.Fatalf("Individual fields were reported: %s", msg)
+	}
+
+	m.CheckFail(t, func() { T.NotEqual(have[0], have[0]) })
+	if !strings.Contains(msg, "1.00 USD") {
+		t.Fatalf("The formatter was not used by NotEqual: %s", msg)
+	}
+}
+
+func TestT_SetMaxValueLength(t *testing.T) {
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	have := strings.Repeat("a", 10000)
+	want := strings.Repeat("a", 9999) + "b"
+
+	// The default length truncates the values.
+	m.CheckFail(t, func() { T.Equal(have, want) })
+	if !strings.Contains(msg, "bytes omitted") {
+		t.Fatalf("The value was not truncated: %d bytes", len(msg))
+	} else if len(msg) > 3*defaultMaxValueLength {
+		t.Fatalf("The output was too long: %d bytes", len(msg))
+	} else if !strings.Contains(msg, "ab\"") {
+		t.Fatalf("The tail of the value was not kept: %s", msg)
+	}
+
+	// Smaller limits are honored.
+	T.SetMaxValueLength(100)
+	m.CheckFail(t, func() { T.Equal(have, want) })
+	if len(msg) > 1000 {
+		t.Fatalf("The output was too long: %d bytes", len(msg))
+	}
+
+	// Zero disables truncation.
+	T.SetMaxValueLength(0)
+	m.CheckFail(t, func() { T.Equal(have, want) })
+	if strings.Contains(msg, "bytes omitted") {
+		t.Fatalf("The value was truncated.")
+	}
+
+	// The environment variable disables truncation.
+	T.SetMaxValueLength(100)
+	osGetenv = func(s string) string {
+		if s == fullOutputEnv {
+			return "1"
+		}
+		return ""
+	}
+	defer func() { osGetenv = os.Getenv }()
+	m.CheckFail(t, func() { T.Equal(have, want) })
+	if strings.Contains(msg, "bytes omitted") {
+		t.Fatalf("The value was truncated.")
+	}
+}
+
+func TestT_DiffPaths(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	have := &testObject{
+		str:   "same1",
+		link1: &testObject{str: "different_have1"},
+		link2: &testObject{str: "different_have2"},
+	}
+	want := &testObject{
+		str:   "same1",
+		link1: &testObject{str: "different_want1"},
+		link2: &testObject{str: "different_want2"},
+	}
+	paths := T.DiffPaths(have, want)
+	if strings.Join(paths, ",") != "link1.str,link2.str" {
+		t.Fatalf("Unexpected paths returned: %#v", paths)
+	}
+
+	// The returned paths can be used directly as ignores.
+	m.CheckPass(t, func() { T.EqualWithIgnores(have, want, paths) })
+
+	// Equal values return no paths.
+	if paths := T.DiffPaths(have, have); len(paths) != 0 {
+		t.Fatalf("Unexpected paths returned: %#v", paths)
+	}
+	if paths := T.DiffPaths(nil, nil); len(paths) != 0 {
+		t.Fatalf("Unexpected paths returned: %#v", paths)
+	}
+
+	// Missing and unexpected map keys are reported.
+	haveMap := map[string]int{"a": 1, "b": 2, "c": 3}
+	wantMap := map[string]int{"a": 1, "b": 3, "d": 4}
+	paths = T.DiffPaths(haveMap, wantMap)
+	sort.Strings(paths)
+	m.CheckPass(t, func() { T.EqualWithIgnores(haveMap, wantMap, paths) })
+	if len(paths) != 3 {
+		t.Fatalf("Unexpected paths returned: %#v", paths)
+	}
+}
+
+func TestT_Equal_PathologicalMapKeys(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	// NaN keys can never be looked up so the maps are never equal, but the
+	// failure should explain why rather than claiming a key is missing.
+	nan := math.NaN()
+	have := map[float64]int{1: 1, nan: 2}
+	want := map[float64]int{1: 1, nan: 2}
+	m.CheckFail(t, func() { T.Equal(have, want) })
+	if !strings.Contains(msg, "[NaN]: key contains NaN") {
+		t.Fatalf("Unexpected failure message: %s", msg)
+	}
+	m.CheckPass(t, func() { T.NotEqual(have, want) })
+
+	// NaN hidden inside of interface and struct keys.
+	type point struct{ X, Y float64 }
+	m.CheckFail(t, func() {
+		T.Equal(
+			map[interface{}]int{point{1, nan}: 1},
+			map[interface{}]int{point{1, nan}: 1})
+	})
+	if !strings.Contains(msg, "contains NaN") {
+		t.Fatalf("Unexpected failure message: %s", msg)
+	}
+
+	// Keys that reflect can not hash are reported rather than panicking.
+	ifaceMap := reflect.ValueOf(map[interface{}]int{})
+	v, reason := mapLookup(ifaceMap, reflect.ValueOf([]int{1}))
+	if v.IsValid() {
+		t.Fatalf("Expected an invalid value.")
+	} else if !strings.Contains(reason, "can not be looked up") {
+		t.Fatalf("Unexpected reason: %s", reason)
+	}
+
+	// Normal keys still work.
+	v, reason = mapLookup(reflect.ValueOf(have), reflect.ValueOf(1.0))
+	if reason != "" || v.Int() != 1 {
+		t.Fatalf("Unexpected lookup result: %v, %s", v, reason)
+	}
+}
+
+func TestT_Equal_RecoversPanics(t *testing.T) {
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	// A formatter that panics while rendering a difference.
+	T.RegisterFormatter(
+		reflect.TypeOf(testEqualCustomStruct{}),
+		func(v interface{}) string { panic("formatter exploded") })
+	type wrapper struct{ Inner testEqualCustomStruct }
+	have := wrapper{testEqualCustomStruct{"a", "b"}}
+	want := wrapper{testEqualCustomStruct{"a", "c"}}
+	m.CheckFail(t, func() { T.Equal(have, want) })
+	if !strings.Contains(msg, "Inner: panic while comparing: formatter exploded") {
+		t.Fatalf("Unexpected failure message: %s", msg)
+	}
+	if paths := T.DiffPaths(have, want); strings.Join(paths, ",") != "Inner" {
+		t.Fatalf("Unexpected paths returned: %#v", paths)
+	}
+
+	// The environment variable lets the panic through for debugging.
+	osGetenv = func(s string) string {
+		if s == equalPanicEnv {
+			return "1"
+		}
+		return ""
+	}
+	defer func() { osGetenv = os.Getenv }()
+	func() {
+		defer func() {
+			if r := recover(); r != "formatter exploded" {
+				t.Fatalf("Unexpected panic: %v", r)
+			}
+		}()
+		T.DiffPaths(have, want)
+	}()
+}
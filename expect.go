@@ -15,8 +15,12 @@
 package testlib
 
 import (
+	"errors"
 	"fmt"
+	"os/exec"
+	"reflect"
 	"strings"
+	"time"
 )
 
 // This file contains functions to assert specific expectations.
@@ -69,6 +73,131 @@ func (t *T) ExpectSuccessf(err error, spec string, args ...interface{}) {
 		prefix, err, err.Error())
 }
 
+// Calls fn every interval until it returns nil, failing the test if it is
+// still returning an error after timeout has passed. The failure includes
+// the last error along with every error in its wrap chain. This is useful
+// for resources that take a while to become available, like a server that
+// is starting up.
+func (t *T) ExpectSuccessEventually(
+	fn func() error, timeout, interval time.Duration, desc ...string,
+) {
+	defer t.trackWait("ExpectSuccessEventually")()
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	end := time.Now().Add(timeout)
+	attempts := 0
+	for {
+		attempts++
+		err := fn()
+		if err == nil {
+			return
+		} else if !time.Now().Add(interval).Before(end) {
+			t.Fatalf("%sStill failing after %s (%d attempts): %s\n"+
+				"Error chain:\n  %s", prefix, timeout, attempts, err,
+				strings.Join(errorChain(err), "\n  "))
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Returns a line for every error in the wrap chain of err, indented to
+// show the tree formed by errors that wrap more than one error.
+func errorChain(err error) []string {
+	lines := []string{}
+	var walk func(err error, depth int)
+	walk = func(err error, depth int) {
+		lines = append(lines, fmt.Sprintf("%s%T: %s",
+			strings.Repeat("  ", depth), err, err))
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			if inner := e.Unwrap(); inner != nil {
+				walk(inner, depth)
+			}
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				if inner != nil {
+					walk(inner, depth+1)
+				}
+			}
+		}
+	}
+	walk(err, 0)
+	return lines
+}
+
+// Fails the test unless errs contains exactly wantCount non nil errors.
+// Errors created with errors.Join() (or anything else with an
+// Unwrap() []error method) are counted as each of their components, so a
+// single joined error can be checked with:
+//
+//	t.ExpectErrors([]error{err}, 3)
+func (t *T) ExpectErrors(errs []error, wantCount int, desc ...string) {
+	flat := flattenErrors(errs)
+	if len(flat) == wantCount {
+		return
+	}
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	t.Fatalf("%sExpected %d error(s), got %d:%s",
+		prefix, wantCount, len(flat), formatErrorList(flat))
+}
+
+// Fails the test unless every substring is contained in the message of at
+// least one of the errors. Joined errors are split into their components
+// as in ExpectErrors().
+func (t *T) ExpectErrorsContain(errs []error, substrs ...string) {
+	flat := flattenErrors(errs)
+	missing := []string{}
+	for _, substr := range substrs {
+		found := false
+		for _, err := range flat {
+			if strings.Contains(err.Error(), substr) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, fmt.Sprintf("%q", substr))
+		}
+	}
+	if len(missing) > 0 {
+		t.Fatalf("No error contained %s. Errors:%s",
+			strings.Join(missing, ", "), formatErrorList(flat))
+	}
+}
+
+// Returns the non nil errors in errs with any joined errors replaced by
+// their components.
+func flattenErrors(errs []error) []error {
+	flat := make([]error, 0, len(errs))
+	for _, err := range errs {
+		if err == nil {
+			continue
+		} else if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			flat = append(flat, flattenErrors(joined.Unwrap())...)
+		} else {
+			flat = append(flat, err)
+		}
+	}
+	return flat
+}
+
+// Renders a list of errors one per line for failure messages.
+func formatErrorList(errs []error) string {
+	if len(errs) == 0 {
+		return " (none)"
+	}
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = fmt.Sprintf("\n  [%d] %s", i, err)
+	}
+	return strings.Join(lines, "")
+}
+
 // Fails if the error message does not contain the given string.
 func (t *T) ExpectErrorMessage(err error, msg string, desc ...string) {
 	prefix := ""
@@ -95,6 +224,100 @@ func (t *T) ExpectErrorMessagef(err error, msg string, spec string, args ...inte
 	}
 }
 
+// Compares two errors by what they mean rather than by their contents,
+// since Equal() compares unexported fields and so often fails for errors
+// that are semantically the same. The errors are considered equal if both
+// are nil, if errors.Is(have, want) is true or if they have the same
+// message. On failure the wrap chain of have is shown.
+func (t *T) EqualError(have, want error, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	if have == nil && want == nil {
+		return
+	} else if have == nil {
+		t.Fatalf("%sExpected error %q, got nil.", prefix, want.Error())
+	} else if want == nil {
+		t.Fatalf("%sExpected nil, got error %q.", prefix, have.Error())
+	} else if errors.Is(have, want) || have.Error() == want.Error() {
+		return
+	}
+	t.Fatalf("%sErrors are not equal.\n  have: %T: %s\n  want: %T: %s\n"+
+		"have wraps:\n  %s", prefix, have, have, want, want,
+		strings.Join(errorChain(have), "\n  "))
+}
+
+// Like EqualError() except that only the types of the errors are compared.
+// This passes if have, or any error it wraps, has the same type as want,
+// which is useful when the message contains details (like a path or an
+// address) that change from run to run.
+func (t *T) EqualErrorType(have, want error, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	if have == nil && want == nil {
+		return
+	} else if have == nil {
+		t.Fatalf("%sExpected an error of type %T, got nil.", prefix, want)
+	} else if want == nil {
+		t.Fatalf("%sExpected nil, got error %q.", prefix, have.Error())
+	}
+	target := reflect.New(reflect.TypeOf(want))
+	if errors.As(have, target.Interface()) {
+		return
+	}
+	t.Fatalf("%sExpected an error of type %T, got:\n  %s",
+		prefix, want, strings.Join(errorChain(have), "\n  "))
+}
+
+// Fails the test unless err is (or wraps) an *exec.ExitError for a process
+// that exited with wantCode. This avoids the platform specific WaitStatus
+// casts normally needed to check the exit status of a subprocess. If the
+// error came from exec.Cmd.Output() then the process's stderr is included
+// in the failure.
+func (t *T) ExpectExitError(err error, wantCode int, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	if err == nil {
+		t.Fatalf("%sExpected the process to exit with status %d but it "+
+			"succeeded.", prefix, wantCode)
+	}
+	exitErr := findExitError(err)
+	if exitErr == nil {
+		t.Fatalf("%sExpected the process to exit with status %d but the "+
+			"error is not an *exec.ExitError: %#v (%s)",
+			prefix, wantCode, err, err.Error())
+	}
+	if code := exitErr.ExitCode(); code != wantCode {
+		stderr := ""
+		if len(exitErr.Stderr) > 0 {
+			stderr = "\nstderr:\n" + string(exitErr.Stderr)
+		}
+		t.Fatalf("%sExpected the process to exit with status %d, got: %s%s",
+			prefix, wantCode, exitErr.ProcessState, stderr)
+	}
+}
+
+// Returns the first *exec.ExitError in the wrap chain of err, or nil if
+// there is not one.
+func findExitError(err error) *exec.ExitError {
+	for err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil
+		}
+		err = u.Unwrap()
+	}
+	return nil
+}
+
 // Expects the function passed in to panic. This will call f() and expect
 // that an error matching err will be raised as a panic.
 func (t *T) ExpectPanic(f func(), err interface{}, desc ...string) {
@@ -111,3 +334,50 @@ func (t *T) ExpectPanic(f func(), err interface{}, desc ...string) {
 	}()
 	f()
 }
+
+// Fails the test if any exported field of the struct v (or the struct that v
+// points to) is set to its zero value. Nested structs are checked field by
+// field. Fields can be excluded by listing their dotted path (for example
+// "Address.Line2") in ignore. This is useful for verifying that a decoder or
+// mapper populated every field of the object it produced.
+func (t *T) AllFieldsSet(v interface{}, ignore ...string) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		t.Fatalf("AllFieldsSet requires a struct, got %T", v)
+	}
+	ignored := make(map[string]bool, len(ignore))
+	for _, name := range ignore {
+		ignored[name] = true
+	}
+	unset := unsetFields(value, "", ignored)
+	if len(unset) > 0 {
+		t.Fatalf("%d field(s) were not set:\n  %s",
+			len(unset), strings.Join(unset, "\n  "))
+	}
+}
+
+// Returns the paths of all exported fields in the struct v that are set to
+// their zero value.
+func unsetFields(v reflect.Value, path string, ignored map[string]bool) []string {
+	unset := []string{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := path + field.Name
+		if ignored[name] {
+			continue
+		}
+		value := v.Field(i)
+		if value.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+			unset = append(unset, unsetFields(value, name+".", ignored)...)
+		} else if isZero(value) {
+			unset = append(unset, name)
+		}
+	}
+	return unset
+}
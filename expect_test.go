@@ -15,9 +15,12 @@
 package testlib
 
 import (
+	"errors"
 	"fmt"
+	"os/exec"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestT_ExpectError(t *testing.T) {
@@ -92,6 +95,98 @@ func TestT_ExpectSuccessf(t *testing.T) {
 	}
 }
 
+func TestT_ExpectSuccessEventually(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	calls := 0
+	m.CheckPass(t, func() {
+		T.ExpectSuccessEventually(func() error {
+			calls++
+			if calls < 3 {
+				return fmt.Errorf("not yet")
+			}
+			return nil
+		}, time.Second, time.Millisecond)
+	})
+	if calls != 3 {
+		t.Fatalf("Wrong number of calls: %d", calls)
+	}
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	base := errors.New("connection refused")
+	m.CheckFail(t, func() {
+		T.ExpectSuccessEventually(func() error {
+			return fmt.Errorf("dial: %w", base)
+		}, 20*time.Millisecond, time.Millisecond, "prefix")
+	})
+	want := "Error chain:\n" +
+		"  *fmt.wrapError: dial: connection refused\n" +
+		"  *errors.errorString: connection refused"
+	if !strings.HasPrefix(msg, "prefix: Still failing after 20ms (") {
+		t.Fatalf("Unexpected message: %s", msg)
+	} else if !strings.Contains(msg, want) {
+		t.Fatalf("The error chain was not reported: %s", msg)
+	}
+}
+
+func TestErrorChain(t *testing.T) {
+	t.Parallel()
+	err := fmt.Errorf("outer: %w", errors.Join(
+		errors.New("a"), fmt.Errorf("b: %w", errors.New("c"))))
+	have := strings.Join(errorChain(err), "\n")
+	want := "*fmt.wrapError: outer: a\nb: c\n" +
+		"*errors.joinError: a\nb: c\n" +
+		"  *errors.errorString: a\n" +
+		"  *fmt.wrapError: b: c\n" +
+		"  *errors.errorString: c"
+	if have != want {
+		t.Fatalf("Unexpected chain:\n%s", have)
+	}
+}
+
+func TestT_ExpectErrors(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	joined := errors.Join(errors.New("a"), errors.New("b"))
+	m.CheckPass(t, func() { T.ExpectErrors(nil, 0) })
+	m.CheckPass(t, func() { T.ExpectErrors([]error{nil, nil}, 0) })
+	m.CheckPass(t, func() { T.ExpectErrors([]error{joined}, 2) })
+	m.CheckPass(t, func() {
+		T.ExpectErrors([]error{errors.New("c"), nil, joined}, 3)
+	})
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	m.CheckFail(t, func() { T.ExpectErrors([]error{joined}, 1, "prefix") })
+	if !strings.HasPrefix(msg, "prefix: Expected 1 error(s), got 2:\n  [0] a\n  [1] b") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
+
+func TestT_ExpectErrorsContain(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	errs := []error{
+		errors.Join(errors.New("bad name"), errors.New("bad age")),
+		fmt.Errorf("bad email"),
+	}
+	m.CheckPass(t, func() { T.ExpectErrorsContain(errs) })
+	m.CheckPass(t, func() { T.ExpectErrorsContain(errs, "name", "age", "email") })
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	m.CheckFail(t, func() { T.ExpectErrorsContain(errs, "name", "phone", "zip") })
+	if !strings.HasPrefix(msg, `No error contained "phone", "zip". Errors:`+
+		"\n  [0] bad name\n  [1] bad age\n  [2] bad email") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { T.ExpectErrorsContain(nil, "x") })
+}
+
 func TestT_ExpectErrorMessage(t *testing.T) {
 	t.Parallel()
 	m, T := testSetup()
@@ -150,6 +245,107 @@ func TestT_ExpectErrorMessagef(t *testing.T) {
 	})
 }
 
+func TestT_ExpectExitError(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	_, err := exec.Command("sh", "-c", "echo oops >&2; exit 3").Output()
+	m.CheckPass(t, func() { T.ExpectExitError(err, 3) })
+	m.CheckPass(t, func() {
+		T.ExpectExitError(fmt.Errorf("wrapped: %w", err), 3)
+	})
+
+	m.CheckFail(t, func() { T.ExpectExitError(err, 1, "prefix") })
+	if !strings.HasPrefix(msg, "prefix: Expected the process to exit "+
+		"with status 1, got: exit status 3\nstderr:\noops\n") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	m.CheckFail(t, func() { T.ExpectExitError(nil, 1) })
+	if !strings.Contains(msg, "but it succeeded") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { T.ExpectExitError(errors.New("other"), 1) })
+	if !strings.Contains(msg, "is not an *exec.ExitError") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
+
+func TestT_EqualError(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	sentinel := errors.New("sentinel")
+	m.CheckPass(t, func() { T.EqualError(nil, nil) })
+	m.CheckPass(t, func() { T.EqualError(sentinel, sentinel) })
+	m.CheckPass(t, func() {
+		T.EqualError(fmt.Errorf("wrapped: %w", sentinel), sentinel)
+	})
+	m.CheckPass(t, func() {
+		T.EqualError(errors.New("same"), fmt.Errorf("same"))
+	})
+
+	m.CheckFail(t, func() { T.EqualError(nil, sentinel, "prefix") })
+	if !strings.HasPrefix(msg, "prefix: Expected error \"sentinel\", got nil.\n") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { T.EqualError(sentinel, nil) })
+	if !strings.HasPrefix(msg, "Expected nil, got error \"sentinel\".\n") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() {
+		T.EqualError(fmt.Errorf("outer: %w", errors.New("inner")), sentinel)
+	})
+	if !strings.HasPrefix(msg, "Errors are not equal.\n") ||
+		!strings.Contains(msg, "want: *errors.errorString: sentinel") ||
+		!strings.Contains(msg, "have wraps:\n") ||
+		!strings.Contains(msg, "inner") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
+
+func TestT_EqualErrorType(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	_, err := exec.Command("sh", "-c", "exit 3").Output()
+	_, other := exec.Command("sh", "-c", "exit 4").Output()
+	m.CheckPass(t, func() { T.EqualErrorType(nil, nil) })
+	m.CheckPass(t, func() { T.EqualErrorType(err, other) })
+	m.CheckPass(t, func() {
+		T.EqualErrorType(fmt.Errorf("wrapped: %w", err), other)
+	})
+
+	m.CheckFail(t, func() {
+		T.EqualErrorType(errors.New("other"), err, "prefix")
+	})
+	if !strings.HasPrefix(msg,
+		"prefix: Expected an error of type *exec.ExitError, got:\n") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { T.EqualErrorType(nil, err) })
+	if !strings.HasPrefix(msg,
+		"Expected an error of type *exec.ExitError, got nil.\n") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { T.EqualErrorType(err, nil) })
+}
+
 func TestT_ExpectErrorPanic(t *testing.T) {
 	t.Parallel()
 	m, T := testSetup()
@@ -163,3 +359,49 @@ func TestT_ExpectErrorPanic(t *testing.T) {
 		T.ExpectPanic(func() {}, "UNEXPECTED")
 	})
 }
+
+func TestT_AllFieldsSet(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	type inner struct {
+		Line1 string
+		Line2 string
+	}
+	type outer struct {
+		Name    string
+		Count   int
+		Tags    []string
+		When    time.Time
+		Address inner
+		private int
+	}
+
+	full := outer{
+		Name:    "name",
+		Count:   1,
+		Tags:    []string{},
+		When:    time.Now(),
+		Address: inner{Line1: "a", Line2: "b"},
+	}
+	m.CheckPass(t, func() { T.AllFieldsSet(full) })
+	m.CheckPass(t, func() { T.AllFieldsSet(&full) })
+
+	m.CheckFail(t, func() { T.AllFieldsSet(outer{Name: "name"}) })
+	want := "5 field(s) were not set:\n  Count\n  Tags\n  When\n" +
+		"  Address.Line1\n  Address.Line2"
+	if !strings.HasPrefix(msg, want) {
+		t.Fatalf("Unexpected message:\n%s\nwant:\n%s", msg, want)
+	}
+
+	m.CheckPass(t, func() {
+		T.AllFieldsSet(outer{Name: "name", Count: 1, Tags: []string{},
+			When: time.Now(), Address: inner{Line1: "a"}}, "Address.Line2")
+	})
+	m.CheckFail(t, func() { T.AllFieldsSet(1) })
+}
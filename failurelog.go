@@ -0,0 +1,167 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file contains the failure log, which records every failure to a file
+// so that flaky tests can be found across many runs, along with the random
+// seed that is recorded in it.
+
+// If this environment variable is set then every failure is appended to
+// the file it names. See SetFailureLog().
+const failureLogEnv = "TESTLIB_FAILURE_LOG"
+
+// If this environment variable is set to an integer then it is used as the
+// seed for every test rather than a random one.
+const seedEnv = "TESTLIB_SEED"
+
+// The file set by SetFailureLog(), and the lock that serializes writes to
+// it.
+var (
+	failureLogPath string
+	failureLogLock sync.Mutex
+)
+
+// A single line in the failure log.
+type failureRecord struct {
+	Time      time.Time `json:"time"`
+	Test      string    `json:"test"`
+	Assertion string    `json:"assertion,omitempty"`
+	Message   string    `json:"message"`
+	Seed      int64     `json:"seed"`
+	Duration  float64   `json:"duration_seconds"`
+
+	// The attempt number when the failure happened inside of Retry().
+	Attempt int `json:"attempt,omitempty"`
+
+	// Set on the record written when Retry() passes after failing.
+	RetriedThenPassed bool `json:"retried_then_passed,omitempty"`
+}
+
+// Enables the failure log. Every failure reported by any test is appended
+// to the file at path as a single line of JSON containing the test name,
+// the assertion that failed, the message, the test's seed and how long the
+// test had been running. Runs that passed only after being retried by
+// Retry() are also recorded. Collecting these files from many CI runs makes
+// it easy to find flaky tests. An empty path disables the log. This can
+// also be enabled by setting the TESTLIB_FAILURE_LOG environment variable.
+func SetFailureLog(path string) {
+	failureLogLock.Lock()
+	defer failureLogLock.Unlock()
+	failureLogPath = path
+}
+
+// Appends a record to the failure log if it is enabled. Errors writing the
+// log are ignored since they should not fail the test.
+func writeFailureRecord(record failureRecord) {
+	failureLogLock.Lock()
+	defer failureLogLock.Unlock()
+	path := failureLogPath
+	if path == "" {
+		path = osGetenv(failureLogEnv)
+	}
+	if path == "" {
+		return
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
+
+// Records a failure with the given message in the failure log.
+func (t *T) recordFailure(msg string) {
+	writeFailureRecord(failureRecord{
+		Time:      time.Now(),
+		Test:      t.Name(),
+		Assertion: assertionName(),
+		Message:   msg,
+		Seed:      t.Seed(),
+		Duration:  time.Since(t.start).Seconds(),
+		Attempt:   t.attempt,
+	})
+}
+
+// Matches the names of exported methods in this package, capturing the
+// type and method names.
+var assertionFuncRegexp = regexp.MustCompile(`\.\(\*([A-Z]\w*)\)\.([A-Z]\w*)$`)
+
+// The prefix of the names of functions in this package.
+var packageFuncPrefix = reflect.TypeOf(T{}).PkgPath() + "."
+
+// Returns the name (for example "T.Equal") of the outer most exported
+// method of this library on the current call stack, which is the
+// assertion that the test called.
+func assertionName() string {
+	pcs := make([]uintptr, 100)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	name := ""
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packageFuncPrefix) {
+			if name != "" {
+				break
+			}
+		} else if m := assertionFuncRegexp.FindStringSubmatch(frame.Function); m != nil {
+			name = m[1] + "." + m[2]
+		}
+		if !more {
+			break
+		}
+	}
+	return name
+}
+
+// Returns the seed for this test. Tests that use random values should
+// derive them from this seed (or use Rand()) so that a failure can be
+// reproduced; the seed is included in the failure log. The seed is taken
+// from the TESTLIB_SEED environment variable if it is set, otherwise it is
+// chosen at random the first time this is called.
+func (t *T) Seed() int64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.seeded {
+		t.seed = time.Now().UnixNano()
+		if s, err := strconv.ParseInt(osGetenv(seedEnv), 10, 64); err == nil {
+			t.seed = s
+		}
+		t.seeded = true
+	}
+	return t.seed
+}
+
+// Returns a random number generator seeded with Seed(). The generator is
+// not safe for use from multiple goroutines.
+func (t *T) Rand() *rand.Rand {
+	return rand.New(rand.NewSource(t.Seed()))
+}
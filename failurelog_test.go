@@ -0,0 +1,107 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Enables the failure log in a temporary file, returning a function that
+// reads the records written to it and a function that disables the log.
+func enableFailureLog(t *testing.T, T *T) (func() []failureRecord, func()) {
+	path := filepath.Join(T.TempDir(), "failures.jsonl")
+	SetFailureLog(path)
+	read := func() []failureRecord {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			t.Fatalf("Unable to open the failure log: %s", err)
+		}
+		defer f.Close()
+		records := []failureRecord{}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var r failureRecord
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				t.Fatalf("Invalid line %q: %s", scanner.Text(), err)
+			}
+			records = append(records, r)
+		}
+		return records
+	}
+	return read, func() { SetFailureLog("") }
+}
+
+func TestSetFailureLog(t *testing.T) {
+	_, T := testSetup()
+	defer T.Finish()
+	read, disable := enableFailureLog(t, T)
+	defer disable()
+
+	m, T2 := testSetup()
+	T2.name = "TestSomething"
+	m.CheckFail(t, func() { T2.Equal(1, 2) })
+	m.CheckFail(t, func() { T2.That(1).IsNil() })
+	m.CheckFail(t, func() { T2.Errorf("direct") })
+
+	records := read()
+	if len(records) != 3 {
+		t.Fatalf("Wrong number of records: %#v", records)
+	}
+	for i, assertion := range []string{"T.Equal", "Assertion.IsNil", "T.Errorf"} {
+		r := records[i]
+		if r.Test != "TestSomething" || r.Assertion != assertion ||
+			r.Seed != T2.Seed() || r.Duration <= 0 || r.Time.IsZero() {
+			t.Fatalf("Unexpected record %d: %#v", i, r)
+		}
+	}
+	if records[2].Message != "direct" {
+		t.Fatalf("Unexpected message: %q", records[2].Message)
+	}
+
+	// Nothing is written once the log is disabled.
+	disable()
+	m.CheckFail(t, func() { T2.Errorf("not logged") })
+	if len(read()) != 3 {
+		t.Fatalf("A record was written after the log was disabled.")
+	}
+}
+
+func TestT_Seed(t *testing.T) {
+	_, T := testSetup()
+	if T.Seed() != T.Seed() {
+		t.Fatalf("The seed changed between calls.")
+	} else if T.Rand().Int63() != T.Rand().Int63() {
+		t.Fatalf("Rand() was not seeded consistently.")
+	}
+
+	old := osGetenv
+	defer func() { osGetenv = old }()
+	osGetenv = func(name string) string {
+		if name == seedEnv {
+			return "1234"
+		}
+		return old(name)
+	}
+	_, T = testSetup()
+	if T.Seed() != 1234 {
+		t.Fatalf("The seed was not read from the environment: %d", T.Seed())
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// This file contains a fake testing.TB for testing helpers built on T.
+
+// A fake testing.TB that records failures, skips and log messages rather
+// than reporting them. Wrapping one with NewT() lets helpers built on top of
+// T be tested the same way this library tests itself:
+//
+//	fake := testlib.NewFakeTB("TestHelper")
+//	T := testlib.NewT(fake)
+//	fake.CheckFail(t, func() { myAssertion(T, "bad input") })
+//	if !strings.Contains(fake.Output(), "bad input") {
+//		t.Fatalf("Unexpected output: %s", fake.Output())
+//	}
+//
+// Like testing.T, FailNow(), Fatal(), Fatalf() and the Skip functions end
+// the calling goroutine, so code that may call them must be run via one of
+// the Check functions (or in a goroutine of its own). All methods are safe
+// to call from multiple goroutines.
+type FakeTB struct {
+	name string
+
+	// Protects all of the fields below.
+	lock     sync.Mutex
+	failed   bool
+	skipped  bool
+	failures []string
+	logs     []string
+	skips    []string
+}
+
+// The part of testing.TB used by the Check functions, which allows a FakeTB
+// to be checked by another FakeTB.
+type checkTB interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+}
+
+// Returns a FakeTB whose Name() returns name.
+func NewFakeTB(name string) *FakeTB {
+	return &FakeTB{name: name}
+}
+
+// Runs fn and fails t if fn marked the fake as failed or skipped.
+func (f *FakeTB) CheckPass(t checkTB, fn func(), desc ...string) {
+	t.Helper()
+	f.check(t, false, false, fn, desc)
+}
+
+// Runs fn and fails t unless fn marked the fake as failed.
+func (f *FakeTB) CheckFail(t checkTB, fn func(), desc ...string) {
+	t.Helper()
+	f.check(t, true, false, fn, desc)
+}
+
+// Runs fn and fails t unless fn marked the fake as skipped.
+func (f *FakeTB) CheckSkips(t checkTB, fn func(), desc ...string) {
+	t.Helper()
+	f.check(t, false, true, fn, desc)
+}
+
+// Resets the fake, runs fn in its own goroutine (so calls to FailNow() and
+// friends can end it) and then compares the result with what was expected.
+func (f *FakeTB) check(
+	t checkTB, fails, skips bool, fn func(), desc []string,
+) {
+	t.Helper()
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	f.Reset()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	<-done
+
+	failed, skipped := f.Failed(), f.Skipped()
+	switch {
+	case fails && !failed:
+		t.Fatalf("%sExpected a failure but there was none.\n%s",
+			prefix, f.Output())
+	case !fails && failed:
+		t.Fatalf("%sUnexpected failure:\n%s", prefix, f.Output())
+	case skips && !skipped:
+		t.Fatalf("%sExpected a skip but there was none.\n%s",
+			prefix, f.Output())
+	case !skips && skipped:
+		t.Fatalf("%sUnexpected skip:\n%s", prefix, f.Output())
+	}
+}
+
+// Clears everything recorded so far.
+func (f *FakeTB) Reset() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.failed = false
+	f.skipped = false
+	f.failures = nil
+	f.logs = nil
+	f.skips = nil
+}
+
+// Returns the messages passed to Error, Errorf, Fatal and Fatalf.
+func (f *FakeTB) Failures() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string(nil), f.failures...)
+}
+
+// Returns the messages passed to Log and Logf.
+func (f *FakeTB) Logs() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string(nil), f.logs...)
+}
+
+// Returns the messages passed to Skip and Skipf.
+func (f *FakeTB) Skips() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string(nil), f.skips...)
+}
+
+// Returns every recorded message, one per line, labeled with its kind.
+func (f *FakeTB) Output() string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	lines := []string{}
+	for _, msg := range f.failures {
+		lines = append(lines, "FAIL: "+msg)
+	}
+	for _, msg := range f.skips {
+		lines = append(lines, "SKIP: "+msg)
+	}
+	for _, msg := range f.logs {
+		lines = append(lines, "LOG: "+msg)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Records a message of the given kind and updates the failure and skip
+// state.
+func (f *FakeTB) record(list *[]string, msg string, fail, skip bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	*list = append(*list, msg)
+	f.failed = f.failed || fail
+	f.skipped = f.skipped || skip
+}
+
+// testing.TB compatibility functions.
+
+func (f *FakeTB) Error(args ...interface{}) {
+	f.record(&f.failures, fmt.Sprint(args...), true, false)
+}
+
+func (f *FakeTB) Errorf(format string, args ...interface{}) {
+	f.record(&f.failures, fmt.Sprintf(format, args...), true, false)
+}
+
+func (f *FakeTB) Fail() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.failed = true
+}
+
+func (f *FakeTB) FailNow() {
+	f.Fail()
+	runtime.Goexit()
+}
+
+func (f *FakeTB) Failed() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.failed
+}
+
+func (f *FakeTB) Fatal(args ...interface{}) {
+	f.Error(args...)
+	runtime.Goexit()
+}
+
+func (f *FakeTB) Fatalf(format string, args ...interface{}) {
+	f.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+func (f *FakeTB) Helper() {}
+
+func (f *FakeTB) Log(args ...interface{}) {
+	f.record(&f.logs, fmt.Sprint(args...), false, false)
+}
+
+func (f *FakeTB) Logf(format string, args ...interface{}) {
+	f.record(&f.logs, fmt.Sprintf(format, args...), false, false)
+}
+
+func (f *FakeTB) Name() string {
+	return f.name
+}
+
+func (f *FakeTB) Skip(args ...interface{}) {
+	f.record(&f.skips, fmt.Sprint(args...), false, true)
+	runtime.Goexit()
+}
+
+func (f *FakeTB) SkipNow() {
+	f.lock.Lock()
+	f.skipped = true
+	f.lock.Unlock()
+	runtime.Goexit()
+}
+
+func (f *FakeTB) Skipf(format string, args ...interface{}) {
+	f.record(&f.skips, fmt.Sprintf(format, args...), false, true)
+	runtime.Goexit()
+}
+
+func (f *FakeTB) Skipped() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.skipped
+}
+
+// Runs fn with a scratch T, backed by a FakeTB, and fails the test unless
+// fn (or one of the finalizers it added) failed with a message containing
+// msgSubstr. The scratch T has the same name and settings as this T. This
+// makes testing a custom assertion helper a one liner:
+//
+//	T.ExpectTestFailure(func(s *testlib.T) {
+//		ExpectValidJSON(s, "{")
+//	}, "unexpected end of JSON input")
+func (t *T) ExpectTestFailure(fn func(*T), msgSubstr string) {
+	fake := NewFakeTB(t.Name())
+	scratch := t.derive(fake)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer scratch.Finish()
+		fn(scratch)
+	}()
+	<-done
+
+	failures := fake.Failures()
+	for _, msg := range failures {
+		if strings.Contains(msg, msgSubstr) {
+			return
+		}
+	}
+	switch {
+	case fake.Skipped() && !fake.Failed():
+		t.Fatalf("Expected a failure containing %q but the test was "+
+			"skipped:\n%s", msgSubstr, fake.Output())
+	case !fake.Failed():
+		t.Fatalf("Expected a failure containing %q but there was none.",
+			msgSubstr)
+	default:
+		t.Fatalf("Expected a failure containing %q, got:\n%s",
+			msgSubstr, strings.Join(failures, "\n"))
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFakeTB(t *testing.T) {
+	t.Parallel()
+	fake := NewFakeTB("TestHelper")
+	T := NewT(fake)
+	if T.Name() != "TestHelper" {
+		t.Fatalf("The name was not used: %s", T.Name())
+	}
+
+	fake.CheckPass(t, func() { T.Logf("hello %d", 1) })
+	if logs := fake.Logs(); len(logs) != 1 || logs[0] != "hello 1" {
+		t.Fatalf("Unexpected logs: %#v", logs)
+	}
+
+	reached := false
+	fake.CheckFail(t, func() {
+		T.Equal(1, 2)
+		reached = true
+	})
+	if reached {
+		t.Fatalf("Fatal did not end the goroutine.")
+	} else if failures := fake.Failures(); len(failures) != 1 {
+		t.Fatalf("Unexpected failures: %#v", failures)
+	} else if !strings.HasPrefix(fake.Output(), "FAIL: ") {
+		t.Fatalf("Unexpected output: %s", fake.Output())
+	}
+
+	fake.CheckSkips(t, func() { T.Skipf("not %s", "today") })
+	if skips := fake.Skips(); len(skips) != 1 || !strings.Contains(skips[0], "not today") {
+		t.Fatalf("Unexpected skips: %#v", skips)
+	} else if len(fake.Failures()) != 0 {
+		t.Fatalf("The failures were not reset.")
+	}
+
+	// Failures from many goroutines are all recorded.
+	fake.CheckFail(t, func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fake.Errorf("error")
+			}()
+		}
+		wg.Wait()
+	})
+	if n := len(fake.Failures()); n != 10 {
+		t.Fatalf("Expected 10 failures, got %d", n)
+	}
+}
+
+func TestFakeTB_Check(t *testing.T) {
+	t.Parallel()
+	outer := NewFakeTB("outer")
+	inner := NewFakeTB("inner")
+
+	for _, test := range []struct {
+		check func(checkTB, func(), ...string)
+		fn    func()
+		want  string
+	}{
+		{inner.CheckPass, func() { inner.Error("oops") }, "desc: Unexpected failure:\nFAIL: oops"},
+		{inner.CheckPass, func() { inner.SkipNow() }, "desc: Unexpected skip:"},
+		{inner.CheckFail, func() {}, "desc: Expected a failure but there was none."},
+		{inner.CheckSkips, func() {}, "desc: Expected a skip but there was none."},
+	} {
+		outer.CheckFail(t, func() { test.check(outer, test.fn, "desc") })
+		if failures := outer.Failures(); len(failures) != 1 ||
+			!strings.HasPrefix(failures[0], test.want) {
+			t.Fatalf("Unexpected failures: %#v, want %q", failures, test.want)
+		}
+	}
+}
+
+func TestT_ExpectTestFailure(t *testing.T) {
+	t.Parallel()
+	m, outer := testSetup()
+	defer outer.Finish()
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	m.CheckPass(t, func() {
+		outer.ExpectTestFailure(func(s *T) { s.Equal(1, 2, "numbers") }, "numbers: ")
+	})
+	m.CheckPass(t, func() {
+		outer.ExpectTestFailure(func(s *T) {
+			s.AddFinalizer(func() { s.Errorf("in a finalizer") })
+		}, "in a finalizer")
+	})
+
+	m.CheckFail(t, func() {
+		outer.ExpectTestFailure(func(s *T) {}, "anything")
+	})
+	if !strings.Contains(msg, `Expected a failure containing "anything" but there was none.`) {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() {
+		outer.ExpectTestFailure(func(s *T) { s.Fatalf("other") }, "anything")
+	})
+	if !strings.Contains(msg, `Expected a failure containing "anything", got:`) ||
+		!strings.Contains(msg, "other") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() {
+		outer.ExpectTestFailure(func(s *T) { s.Skip("skipping") }, "anything")
+	})
+	if !strings.Contains(msg, "but the test was skipped") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// This file contains a file system layer that can inject errors.
+
+// The file system operations used by code that wants its error handling to
+// be testable. Production code uses OSFS while tests can substitute a
+// FaultyFS. Each method behaves like the os or ioutil package function of
+// the same name.
+type FS interface {
+	Open(name string) (*os.File, error)
+	Create(name string) (*os.File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (*os.File, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	ReadDir(name string) ([]os.FileInfo, error)
+	Stat(name string) (os.FileInfo, error)
+	Mkdir(name string, perm os.FileMode) error
+	MkdirAll(name string, perm os.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldpath, newpath string) error
+	Chmod(name string, mode os.FileMode) error
+}
+
+// An FS that calls straight through to the os package.
+var OSFS FS = osFS{}
+
+// Implements FS using the os package.
+type osFS struct{}
+
+func (osFS) Open(name string) (*os.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) Create(name string) (*os.File, error) {
+	return os.Create(name)
+}
+
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+func (osFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(name, data, perm)
+}
+
+func (osFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(name)
+}
+
+func (osFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) Mkdir(name string, perm os.FileMode) error {
+	return os.Mkdir(name, perm)
+}
+
+func (osFS) MkdirAll(name string, perm os.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFS) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+func (osFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (osFS) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+// Describes which FaultyFS calls fail and how.
+type FaultRule struct {
+	// The name of the FS method to fail, like "Open" or "Rename". An empty
+	// string matches every method.
+	Op string
+
+	// A filepath.Match pattern that the path passed to the method must
+	// match, like filepath.Join(dir, "*.tmp"). For Rename either path may
+	// match. An empty pattern matches every path.
+	Path string
+
+	// The error to return, such as syscall.EACCES, syscall.EIO or
+	// os.ErrNotExist. It is wrapped in an *os.PathError so callers see the
+	// same error shape as from the os package. If this is nil then
+	// syscall.EIO is used.
+	Err error
+
+	// The number of calls to fail before the rule stops matching. Zero
+	// means the rule matches forever.
+	Count int
+}
+
+// Returns an FS that passes calls through to OSFS except when a call
+// matches one of rules, in which case the rule's error is returned without
+// touching the disk. Rules are checked in order and the first match wins.
+// This allows error paths to be tested by injecting EACCES, EIO, ENOENT and
+// so on into code that is written against the FS interface.
+func (t *T) FaultyFS(rules ...FaultRule) *FaultyFS {
+	f := &FaultyFS{Base: OSFS}
+	for _, rule := range rules {
+		f.rules = append(f.rules, faultRule{FaultRule: rule})
+	}
+	return f
+}
+
+// An FS that injects errors into matching calls. This is returned from
+// T.FaultyFS() and is safe to use from multiple goroutines.
+type FaultyFS struct {
+	// The FS that calls which do not fail are passed to.
+	Base FS
+
+	lock     sync.Mutex
+	rules    []faultRule
+	injected int
+}
+
+// A FaultRule along with the number of times it has matched.
+type faultRule struct {
+	FaultRule
+	used int
+}
+
+// Adds a rule which is checked after the existing rules.
+func (f *FaultyFS) AddRule(rule FaultRule) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.rules = append(f.rules, faultRule{FaultRule: rule})
+}
+
+// Returns the number of errors that have been injected so far.
+func (f *FaultyFS) Injected() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.injected
+}
+
+// Returns the error to inject for the given call, or nil if the call
+// should be passed through.
+func (f *FaultyFS) fault(op string, paths ...string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for i := range f.rules {
+		rule := &f.rules[i]
+		if rule.Op != "" && rule.Op != op {
+			continue
+		} else if rule.Count > 0 && rule.used >= rule.Count {
+			continue
+		}
+		for _, path := range paths {
+			if rule.Path != "" {
+				if ok, _ := filepath.Match(rule.Path, path); !ok {
+					continue
+				}
+			}
+			rule.used++
+			f.injected++
+			err := rule.Err
+			if err == nil {
+				err = syscall.EIO
+			}
+			return &os.PathError{Op: op, Path: path, Err: err}
+		}
+	}
+	return nil
+}
+
+// Implements FS.
+func (f *FaultyFS) Open(name string) (*os.File, error) {
+	if err := f.fault("Open", name); err != nil {
+		return nil, err
+	}
+	return f.Base.Open(name)
+}
+
+// Implements FS.
+func (f *FaultyFS) Create(name string) (*os.File, error) {
+	if err := f.fault("Create", name); err != nil {
+		return nil, err
+	}
+	return f.Base.Create(name)
+}
+
+// Implements FS.
+func (f *FaultyFS) OpenFile(
+	name string, flag int, perm os.FileMode,
+) (*os.File, error) {
+	if err := f.fault("OpenFile", name); err != nil {
+		return nil, err
+	}
+	return f.Base.OpenFile(name, flag, perm)
+}
+
+// Implements FS.
+func (f *FaultyFS) ReadFile(name string) ([]byte, error) {
+	if err := f.fault("ReadFile", name); err != nil {
+		return nil, err
+	}
+	return f.Base.ReadFile(name)
+}
+
+// Implements FS.
+func (f *FaultyFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := f.fault("WriteFile", name); err != nil {
+		return err
+	}
+	return f.Base.WriteFile(name, data, perm)
+}
+
+// Implements FS.
+func (f *FaultyFS) ReadDir(name string) ([]os.FileInfo, error) {
+	if err := f.fault("ReadDir", name); err != nil {
+		return nil, err
+	}
+	return f.Base.ReadDir(name)
+}
+
+// Implements FS.
+func (f *FaultyFS) Stat(name string) (os.FileInfo, error) {
+	if err := f.fault("Stat", name); err != nil {
+		return nil, err
+	}
+	return f.Base.Stat(name)
+}
+
+// Implements FS.
+func (f *FaultyFS) Mkdir(name string, perm os.FileMode) error {
+	if err := f.fault("Mkdir", name); err != nil {
+		return err
+	}
+	return f.Base.Mkdir(name, perm)
+}
+
+// Implements FS.
+func (f *FaultyFS) MkdirAll(name string, perm os.FileMode) error {
+	if err := f.fault("MkdirAll", name); err != nil {
+		return err
+	}
+	return f.Base.MkdirAll(name, perm)
+}
+
+// Implements FS.
+func (f *FaultyFS) Remove(name string) error {
+	if err := f.fault("Remove", name); err != nil {
+		return err
+	}
+	return f.Base.Remove(name)
+}
+
+// Implements FS.
+func (f *FaultyFS) RemoveAll(name string) error {
+	if err := f.fault("RemoveAll", name); err != nil {
+		return err
+	}
+	return f.Base.RemoveAll(name)
+}
+
+// Implements FS.
+func (f *FaultyFS) Rename(oldpath, newpath string) error {
+	if err := f.fault("Rename", oldpath, newpath); err != nil {
+		return err
+	}
+	return f.Base.Rename(oldpath, newpath)
+}
+
+// Implements FS.
+func (f *FaultyFS) Chmod(name string, mode os.FileMode) error {
+	if err := f.fault("Chmod", name); err != nil {
+		return err
+	}
+	return f.Base.Chmod(name, mode)
+}
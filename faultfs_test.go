@@ -0,0 +1,86 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestT_FaultyFS(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+	defer T.Finish()
+
+	dir := T.TempDir()
+	fsys := T.FaultyFS(
+		FaultRule{Op: "WriteFile", Path: filepath.Join(dir, "*.log"), Err: syscall.EIO},
+		FaultRule{Op: "Remove", Err: syscall.EACCES, Count: 1},
+		FaultRule{Op: "Rename", Path: filepath.Join(dir, "locked"), Err: syscall.EPERM},
+	)
+	var _ FS = fsys
+
+	// Non matching calls go to the disk.
+	path := filepath.Join(dir, "data.txt")
+	T.ExpectSuccess(fsys.WriteFile(path, []byte("data"), 0644))
+	if data, err := fsys.ReadFile(path); err != nil || string(data) != "data" {
+		t.Fatalf("Unexpected read: %q, %v", data, err)
+	}
+
+	// Matching paths fail without touching the disk.
+	log := filepath.Join(dir, "app.log")
+	err := fsys.WriteFile(log, []byte("x"), 0644)
+	var pathErr *os.PathError
+	if !errors.Is(err, syscall.EIO) {
+		t.Fatalf("Expected EIO, got: %v", err)
+	} else if !errors.As(err, &pathErr) || pathErr.Path != log || pathErr.Op != "WriteFile" {
+		t.Fatalf("Unexpected error: %#v", err)
+	} else if _, err := os.Stat(log); !os.IsNotExist(err) {
+		t.Fatalf("The failed write created the file.")
+	}
+
+	// Rules with a count stop matching once used up.
+	if err := fsys.Remove(path); !errors.Is(err, syscall.EACCES) {
+		t.Fatalf("Expected EACCES, got: %v", err)
+	}
+	T.ExpectSuccess(fsys.Remove(path))
+
+	// Either path of a rename can match.
+	err = fsys.Rename(filepath.Join(dir, "a"), filepath.Join(dir, "locked"))
+	if !errors.Is(err, syscall.EPERM) {
+		t.Fatalf("Expected EPERM, got: %v", err)
+	}
+
+	fsys.AddRule(FaultRule{Path: filepath.Join(dir, "missing"), Err: os.ErrNotExist})
+	if _, err := fsys.Stat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Fatalf("Expected ErrNotExist, got: %v", err)
+	}
+
+	// Rules without an error inject EIO.
+	T.ExpectSuccess(fsys.WriteFile(path, []byte("data"), 0644))
+	if infos, err := fsys.ReadDir(dir); err != nil || len(infos) != 1 {
+		t.Fatalf("Unexpected ReadDir results: %v, %v", infos, err)
+	}
+	fsys.AddRule(FaultRule{Op: "ReadDir"})
+	if _, err := fsys.ReadDir(dir); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("Expected EIO, got: %v", err)
+	}
+	if n := fsys.Injected(); n != 5 {
+		t.Fatalf("Expected 5 injected errors, got %d", n)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file contains functions for detecting leaked file descriptors.
+
+// The directories that are checked (in order) for a listing of the open
+// file descriptors in this process.
+var fdDirs = []string{"/proc/self/fd", "/dev/fd"}
+
+// Snapshots the file descriptors that are currently open in this process
+// and then checks, when the test finishes, that no new descriptors have been
+// left open. Any leaked descriptors will be reported along with the file
+// they point to.
+//
+// Since descriptors are a process wide resource this should not be used in
+// tests that call t.Parallel(). If the platform does not provide a way to
+// list open descriptors then this will log a message and do nothing.
+func (t *T) NoFDLeaks() {
+	before, err := openFDs()
+	if err != nil {
+		t.Logf("Unable to check for file descriptor leaks: %s", err)
+		return
+	}
+	t.AddFinalizer(func() {
+		after, err := openFDs()
+		if err != nil {
+			t.Errorf("Unable to check for file descriptor leaks: %s", err)
+			return
+		}
+		leaked := leakedFDs(before, after)
+		if len(leaked) == 0 {
+			return
+		}
+		lines := make([]string, len(leaked))
+		for i, fd := range leaked {
+			lines[i] = fmt.Sprintf("  fd %d: %s", fd, after[fd])
+		}
+		t.Errorf("%d file descriptor(s) leaked:\n%s",
+			len(leaked), strings.Join(lines, "\n"))
+	})
+}
+
+// Returns a sorted list of the descriptors in after that were not open, or
+// pointed at a different file, in before.
+func leakedFDs(before, after map[int]string) []int {
+	leaked := make([]int, 0, len(after))
+	for fd, target := range after {
+		if old, ok := before[fd]; ok && old == target {
+			continue
+		}
+		leaked = append(leaked, fd)
+	}
+	sort.Ints(leaked)
+	return leaked
+}
+
+// Returns a map of all the open file descriptors in the process mapped to
+// the file that they point at. Descriptors used internally by the Go runtime
+// or by this library are excluded.
+func openFDs() (map[int]string, error) {
+	var lastErr error
+	for _, dir := range fdDirs {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var ignored uintptr = ^uintptr(0)
+		testLibRootDirLock.Lock()
+		if f, ok := testLibRootDirStdin.(*os.File); ok && f != nil {
+			ignored = f.Fd()
+		}
+		testLibRootDirLock.Unlock()
+		fds := make(map[int]string, len(infos))
+		for _, info := range infos {
+			fd, err := strconv.Atoi(info.Name())
+			if err != nil || uintptr(fd) == ignored {
+				continue
+			}
+			// The descriptor used to read the directory listing will have
+			// been closed by now so it will fail to resolve. This also
+			// filters any other descriptors that have since been closed.
+			target, err := os.Readlink(filepath.Join(dir, info.Name()))
+			if err != nil {
+				continue
+			}
+			// The runtime lazily creates its network poller descriptors
+			// the first time they are needed.
+			if strings.HasPrefix(target, "anon_inode:[eventpoll]") ||
+				strings.HasPrefix(target, "anon_inode:[eventfd]") {
+				continue
+			}
+			fds[fd] = target
+		}
+		return fds, nil
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,62 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestT_NoFDLeaks(t *testing.T) {
+	if _, err := os.Stat(fdDirs[0]); err != nil {
+		t.Skipf("%s is not available on this platform.", fdDirs[0])
+	}
+
+	// Test 1: Nothing leaked.
+	m, T := testSetup()
+	m.CheckPass(t, func() {
+		T.NoFDLeaks()
+		fd, err := os.Open(os.Args[0])
+		if err != nil {
+			t.Fatalf("Error opening file: %s", err)
+		}
+		fd.Close()
+		T.Finish()
+	})
+
+	// Test 2: A descriptor is leaked.
+	m, T = testSetup()
+	msg := ""
+	m.funcError = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+	var fd *os.File
+	m.CheckFail(t, func() {
+		T.NoFDLeaks()
+		var err error
+		if fd, err = os.Open(os.Args[0]); err != nil {
+			t.Fatalf("Error opening file: %s", err)
+		}
+		T.Finish()
+	})
+	fd.Close()
+	if !strings.Contains(msg, "leaked") {
+		t.Fatalf("Leak was not reported: %s", msg)
+	} else if !strings.Contains(msg, fd.Name()) {
+		t.Fatalf("Leaked file target was not reported: %s", msg)
+	}
+}
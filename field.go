@@ -0,0 +1,59 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// This file contains functions for labeling assertions in table tests.
+
+// Returns a T whose failures are prefixed with label and the current table
+// row so that table driven loops get consistent context without passing a
+// description to every assertion:
+//
+//	for _, tc := range cases {
+//		f := t.Field(tc.name)
+//		f.Equal(parse(tc.input), tc.want)
+//	}
+//
+// The row is counted per call site, so the first call from a given line is
+// row 0, the next is row 1 and so on, which matches the index of the entry
+// in the table when Field() is called once per iteration. Fields can be
+// nested, in which case the labels are joined with ": ". Failures are
+// reported through t and anything the returned T needs cleaned up is
+// cleaned up when t finishes.
+func (t *T) Field(label string) *T {
+	site := ""
+	if _, file, line, ok := runtime.Caller(1); ok {
+		site = fmt.Sprintf("%s:%d", file, line)
+	}
+	t.lock.Lock()
+	if t.fieldRows == nil {
+		t.fieldRows = make(map[string]int)
+	}
+	row := t.fieldRows[site]
+	t.fieldRows[site]++
+	t.lock.Unlock()
+
+	child := t.derive(t.t)
+	child.label = fmt.Sprintf("%s (row %d)", label, row)
+	if t.label != "" {
+		child.label = t.label + ": " + child.label
+	}
+	t.AddFinalizer(child.Finish)
+	return child
+}
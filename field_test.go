@@ -0,0 +1,81 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestT_Field(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	cases := []struct {
+		name string
+		have int
+	}{
+		{"one", 1},
+		{"two", 3},
+	}
+	messages := []string{}
+	for _, tc := range cases {
+		f := T.Field(tc.name)
+		msg = ""
+		m.CheckFail(t, func() { f.Equal(tc.have, 2, "value") })
+		messages = append(messages, msg)
+	}
+	if !strings.HasPrefix(messages[0], "one (row 0): value: ") {
+		t.Fatalf("Unexpected message: %s", messages[0])
+	} else if !strings.HasPrefix(messages[1], "two (row 1): value: ") {
+		t.Fatalf("Unexpected message: %s", messages[1])
+	}
+
+	// Nested fields include the outer label, and a new call site starts
+	// counting rows from zero again.
+	outer := T.Field("outer")
+	m.CheckFail(t, func() { outer.Field("inner").Fatalf("broken") })
+	if !strings.HasPrefix(msg, "outer (row 0): inner (row 0): broken") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	// Steps wrap the label.
+	m.CheckFail(t, func() {
+		T.Step("run", func() { T.Field("x").Fatalf("broken") })
+	})
+	if !strings.HasPrefix(msg, "[run] x (row 0): broken") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	// The parent itself is not labeled.
+	m.CheckFail(t, func() { T.Fatalf("plain") })
+	if !strings.HasPrefix(msg, "plain") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	// Finalizers added to a field run when the parent finishes.
+	finalized := false
+	T.Field("cleanup").AddFinalizer(func() { finalized = true })
+	T.Finish()
+	if !finalized {
+		t.Fatalf("The field's finalizers were not run.")
+	}
+}
@@ -15,10 +15,12 @@
 package testlib
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
 )
@@ -31,43 +33,195 @@ import (
 //
 // Files in this directory are cleaned up by a child process that is forked
 // from the running process so that nothing can stop them from being cleaned.
+//
+// The directory is created in os.TempDir() (which honors TMPDIR) and if that
+// is not writable, as can happen on read only or containerized file
+// systems, /var/tmp and then /dev/shm are tried in turn. If none of them
+// work then the test is skipped with a message starting with "temp storage
+// unavailable" that lists why each location failed.
 func (t *T) RootTempDir() string {
-	testLibRootDirOnce.Do(func() {
-		var err error
-		var reader *os.File
-		mode := os.FileMode(0777)
-		testLibRootDir, err = ioutilTempDir("", "golang-testlib")
-		t.NotEqual(testLibRootDir, "")
-		t.ExpectSuccess(err)
-		t.ExpectSuccess(osChmod(testLibRootDir, mode))
-		reader, testLibRootDirStdin, err = os.Pipe()
-		t.ExpectSuccess(err)
-		cmd := exec.Command(os.Args[0], testInterceptorArg,
-			testLibRootDir)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Stdin = reader
-		t.ExpectSuccess(cmd.Start())
-		t.ExpectSuccess(reader.Close())
-	})
-	return testLibRootDir
+	dir, err := rootTempDir()
+	if _, ok := err.(*tempUnavailableError); ok {
+		t.Skipf("%s", err)
+	}
+	t.ExpectSuccess(err)
+	return dir
+}
+
+// Locations that are tried, in order, if the root temporary directory can
+// not be created in os.TempDir().
+var fallbackTempDirs = []string{"/var/tmp", "/dev/shm"}
+
+// Returns the directories that the root temporary directory may be created
+// in, in the order they should be tried.
+func tempDirCandidates() []string {
+	candidates := make([]string, 0, len(fallbackTempDirs)+1)
+	seen := map[string]bool{}
+	for _, dir := range append([]string{osTempDir()}, fallbackTempDirs...) {
+		if dir != "" && !seen[dir] {
+			seen[dir] = true
+			candidates = append(candidates, dir)
+		}
+	}
+	return candidates
+}
+
+// Returned from makeRootTempDir() if none of the candidate locations could
+// be used.
+type tempUnavailableError struct {
+	// The reason each candidate failed, as "dir (error)".
+	reasons []string
+}
+
+func (e *tempUnavailableError) Error() string {
+	return "temp storage unavailable: " + strings.Join(e.reasons, ", ")
+}
+
+// Creates the process wide temporary directory the first time it is called,
+// returning the same directory on every call after. If creating the
+// directory fails then the next call will try again.
+func rootTempDir() (string, error) {
+	testLibRootDirLock.Lock()
+	defer testLibRootDirLock.Unlock()
+	if testLibRootDir == "" {
+		dir, err := makeRootTempDir()
+		if err != nil {
+			testLibCleanupState = CleanupFailed
+			testLibCleanupErr = err
+			return "", err
+		}
+		testLibRootDir = dir
+		testLibCleanupErr = nil
+		testLibCleanupState = CleanupRunning
+	}
+	return testLibRootDir, nil
+}
+
+// Makes the root temporary directory and starts the child process that will
+// clean it up once this process exits.
+func makeRootTempDir() (string, error) {
+	dir, err := createRootTempDir()
+	if err != nil {
+		return "", err
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	cmd := execCommand(os.Args[0], testInterceptorArg, dir)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = reader
+	if err := cmd.Start(); err != nil {
+		reader.Close()
+		writer.Close()
+		osRemoveAll(dir)
+		return "", fmt.Errorf("Unable to start the process that removes %s "+
+			"once the tests finish (is %s on a noexec file system?): %s",
+			dir, os.Args[0], err)
+	} else if err := reader.Close(); err != nil {
+		return "", err
+	}
+	testLibRootDirStdin = writer
+	testLibCleanupCmd = cmd
+	go waitCleanupProcess(cmd)
+	return dir, nil
+}
 
+// Creates the root temporary directory in the first candidate location
+// that is writable. If none are then a *tempUnavailableError is returned.
+func createRootTempDir() (string, error) {
+	unavailable := &tempUnavailableError{}
+	for _, base := range tempDirCandidates() {
+		dir, err := ioutilTempDir(base, "golang-testlib")
+		if err != nil {
+			unavailable.reasons = append(unavailable.reasons,
+				fmt.Sprintf("%s (%s)", base, err))
+			continue
+		} else if dir == "" {
+			return "", fmt.Errorf("Unable to create a root temporary directory.")
+		} else if err := osChmod(dir, os.FileMode(0777)); err != nil {
+			osRemoveAll(dir)
+			unavailable.reasons = append(unavailable.reasons,
+				fmt.Sprintf("%s (%s)", base, err))
+			continue
+		}
+		return dir, nil
+	}
+	return "", unavailable
 }
 
 // Creates a temporary directory for this specific test which will be cleaned
 // once the test has finished executing. This calls RootTempDir() to create the
 // base directory.
 func (t *T) TempDirMode(mode os.FileMode) string {
-	f, err := ioutilTempDir(t.RootTempDir(), t.Name())
+	root, prefix := t.RootTempDir(), t.tempPrefix()
+	var f string
+	err := retryTemp(func() (err error) {
+		f, err = ioutilTempDir(root, prefix)
+		return err
+	})
 	t.ExpectSuccess(err)
 	t.NotEqual(f, "")
 	t.ExpectSuccess(osChmod(f, mode))
+	if t.isVerbose() {
+		t.Logf("Created temporary directory %s", f)
+	}
 	t.AddFinalizer(func() {
+		t.noteTempUsage(f)
 		osRemoveAll(f)
 	})
 	return f
 }
 
+// The number of times that creating a temporary file or directory is
+// attempted before the test is failed.
+const tempAttempts = 3
+
+// Calls create until it succeeds or has been tried tempAttempts times,
+// returning the last error. ioutil.TempDir() and ioutil.TempFile() already
+// retry on name collisions, but heavily parallel tests can still race with
+// each other, or with a finalizer removing a directory, so a failure is
+// given a couple more chances before it fails the test.
+func retryTemp(create func() error) (err error) {
+	for i := 0; i < tempAttempts; i++ {
+		if err = create(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// Returns a directory under RootTempDir() that is named after the running
+// test (see tempPrefix()). The directory is created the first time this is
+// called and the same path is returned on every subsequent call, which
+// allows several helpers in a single test to share a predictable location.
+// The directory and all of its contents are removed when the test finishes.
+func (t *T) TestTempDir() string {
+	if t.testTempDir != "" {
+		return t.testTempDir
+	}
+	root := t.RootTempDir()
+	dir := filepath.Join(root, t.tempPrefix())
+
+	// The directory is removed when the test finishes, so it must never be
+	// the root itself or anything outside of it.
+	if filepath.Dir(dir) != filepath.Clean(root) {
+		t.Fatalf("Refusing to use %s as the test's temporary directory, it "+
+			"is not directly below %s.", dir, root)
+	}
+	t.ExpectSuccess(osMkdirAll(dir, os.FileMode(0755)))
+	if t.isVerbose() {
+		t.Logf("Created temporary directory %s", dir)
+	}
+	t.AddFinalizer(func() {
+		t.noteTempUsage(dir)
+		osRemoveAll(dir)
+	})
+	t.testTempDir = dir
+	return dir
+}
+
 // Like TempDirMode except this sets the default mode to 0755.
 func (t *T) TempDir() string {
 	return t.TempDirMode(os.FileMode(0755))
@@ -76,12 +230,27 @@ func (t *T) TempDir() string {
 // Creates a temporary file in a temporary directory with a specific mode
 // set on it. This will return the file descriptor of the open file.
 func (t *T) TempFileMode(mode os.FileMode) *os.File {
-	f, err := ioutilTempFile(t.RootTempDir(), t.Name())
+	return t.tempFile(mode, "")
+}
+
+// Like TempFileMode except that the file name ends with the given suffix,
+// which allows code that looks at file extensions to be tested.
+func (t *T) tempFile(mode os.FileMode, suffix string) *os.File {
+	root, pattern := t.RootTempDir(), t.tempPrefix()+"*"+suffix
+	var f *os.File
+	err := retryTemp(func() (err error) {
+		f, err = ioutilTempFile(root, pattern)
+		return err
+	})
 	t.ExpectSuccess(err)
 	t.NotEqual(f, nil)
 	t.ExpectSuccess(osChmod(f.Name(), mode))
 	name := f.Name()
+	if t.isVerbose() {
+		t.Logf("Created temporary file %s", name)
+	}
 	t.AddFinalizer(func() {
+		t.noteTempUsage(name)
 		osRemove(name)
 	})
 	return f
@@ -92,6 +261,34 @@ func (t *T) TempFile() *os.File {
 	return t.TempFileMode(os.FileMode(0644))
 }
 
+// Like TempFileMode except that the returned file handle will be closed
+// automatically when the test finishes. This prevents long running test
+// suites from exhausting file descriptors.
+func (t *T) TempFileModeAutoClose(mode os.FileMode) *os.File {
+	f := t.TempFileMode(mode)
+	t.AddFinalizer(func() {
+		f.Close()
+	})
+	return f
+}
+
+// Like TempFileModeAutoClose except that it uses a default mode of 0644.
+func (t *T) TempFileAutoClose() *os.File {
+	return t.TempFileModeAutoClose(os.FileMode(0644))
+}
+
+// Opens the given file for reading and returns the handle. The handle will
+// be closed automatically when the test finishes so it is not necessary to
+// close it explicitly.
+func (t *T) OpenTemp(path string) *os.File {
+	f, err := osOpen(path)
+	t.ExpectSuccess(err)
+	t.AddFinalizer(func() {
+		f.Close()
+	})
+	return f
+}
+
 // Makes a temporary file with the given string as contents. This returns
 // the name of the created file.
 func (t *T) WriteTempFileMode(contents string, mode os.FileMode) string {
@@ -108,6 +305,73 @@ func (t *T) WriteTempFile(contents string) string {
 	return t.WriteTempFileMode(contents, 0644)
 }
 
+// Replaces the contents of the existing file at path with the result of
+// calling transform on its current contents. The original contents and
+// permissions are restored when the test finishes. This allows integration
+// tests to temporarily tweak real files, like configuration in a checkout,
+// without leaving them modified if the test fails.
+func (t *T) ModifyFile(path string, transform func([]byte) []byte) {
+	info, err := os.Stat(path)
+	t.ExpectSuccess(err, "Unable to modify file")
+	original, err := ioutil.ReadFile(path)
+	t.ExpectSuccess(err, "Unable to modify file")
+	mode := info.Mode().Perm()
+	t.AddFinalizerErr(func() error {
+		if err := ioutil.WriteFile(path, original, mode); err != nil {
+			return fmt.Errorf("Unable to restore %s: %s", path, err)
+		}
+		return osChmod(path, mode)
+	})
+	modified := transform(append([]byte(nil), original...))
+	t.ExpectSuccess(ioutil.WriteFile(path, modified, mode),
+		"Unable to modify file")
+}
+
+// Removes the write permission from path and, if it is a directory,
+// everything under it. The original permissions are restored when the test
+// finishes. This allows the error handling of code that can not write its
+// output to be tested. Since permissions do not apply to root the test is
+// skipped when running as root.
+func (t *T) ReadOnlyDir(path string) {
+	if osGeteuid() == 0 {
+		t.Skipf("ReadOnlyDir(%s) has no effect when running as root.", path)
+	}
+	type saved struct {
+		path string
+		mode os.FileMode
+	}
+	modes := []saved{}
+	t.AddFinalizer(func() {
+		for _, s := range modes {
+			if err := osChmod(s.path, s.mode); err != nil {
+				t.Errorf("Unable to restore the mode of %s: %s", s.path, err)
+			}
+		}
+	})
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		mode := info.Mode().Perm()
+		if err := osChmod(p, mode&^0222); err != nil {
+			return err
+		}
+		modes = append(modes, saved{path: p, mode: mode})
+		return nil
+	})
+	t.ExpectSuccess(err, "Unable to make the directory read only")
+}
+
+// Like TempDir except the directory is read only for the rest of the test.
+// See ReadOnlyDir().
+func (t *T) ReadOnlyTempDir() string {
+	dir := t.TempDir()
+	t.ReadOnlyDir(dir)
+	return dir
+}
+
 // -------------------------------
 // Temporary Dir Cleanup Internals
 // -------------------------------
@@ -130,9 +394,17 @@ func initRootTempDir(args []string, reader io.Reader) {
 	// Only remove files if it is in the operating systems temporary directory
 	// structure. This is a safety trap to prevent us from accidentally
 	// removing files critical to the system.
-	if !strings.HasPrefix(args[2], osTempDir()) {
+	candidates := tempDirCandidates()
+	safe := false
+	for _, dir := range candidates {
+		if strings.HasPrefix(args[2], dir) {
+			safe = true
+		}
+	}
+	if !safe {
 		fmtFprintf(os.Stderr, "Refusing to clean a non temporary directory: "+
-			"%s since it is not under %s\n", args[2], osTempDir())
+			"%s since it is not under %s\n", args[2],
+			strings.Join(candidates, ", "))
 		osExit(1)
 		return
 	}
@@ -163,6 +435,13 @@ func init() {
 // so it is preserved between tests.
 var (
 	testLibRootDir      string
-	testLibRootDirOnce  sync.Once
+	testLibRootDirLock  sync.Mutex
 	testLibRootDirStdin io.Writer
+
+	// The process started to clean up testLibRootDir, its state and the
+	// error from the last attempt to start it. See CleanupProcessInfo().
+	// These are protected by testLibRootDirLock.
+	testLibCleanupCmd   *exec.Cmd
+	testLibCleanupState CleanupState
+	testLibCleanupErr   error
 )
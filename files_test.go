@@ -15,9 +15,12 @@
 package testlib
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -220,3 +223,293 @@ func TestT_WhiteTempFile(t *testing.T) {
 	}
 	T.Finish()
 }
+
+func TestT_TempFileAutoClose(t *testing.T) {
+	m, T := testSetup()
+	var file *os.File
+	m.CheckPass(t, func() {
+		file = T.TempFileAutoClose()
+	})
+	if file == nil {
+		t.Fatalf("Returned file can not be nil.")
+	} else if _, err := file.Write([]byte("x")); err != nil {
+		t.Fatalf("Error writing to the returned file: %s", err)
+	}
+	T.Finish()
+	if _, err := file.Write([]byte("x")); err == nil {
+		t.Fatalf("The file was not closed by Finish().")
+	}
+}
+
+func TestT_OpenTemp(t *testing.T) {
+	// Test 1: os.Open failure.
+	m, T := testSetup()
+	m.CheckFail(t, func() {
+		osOpen = func(s string) (*os.File, error) {
+			return nil, fmt.Errorf("Expected")
+		}
+		defer func() { osOpen = os.Open }()
+		T.OpenTemp("/nonexistent")
+	})
+
+	// Test 2: Success, the handles are closed by Finish().
+	m, T = testSetup()
+	var name string
+	var fd1, fd2 *os.File
+	m.CheckPass(t, func() {
+		name = T.WriteTempFile("contents")
+		fd1 = T.OpenTemp(name)
+		fd2 = T.OpenTemp(name)
+	})
+	if contents, err := ioutil.ReadAll(fd1); err != nil {
+		t.Fatalf("Error reading %s: %s", name, err)
+	} else if string(contents) != "contents" {
+		t.Fatalf("File contained the wrong contents")
+	}
+	T.Finish()
+	for _, fd := range []*os.File{fd1, fd2} {
+		if _, err := fd.Read(make([]byte, 1)); err == nil {
+			t.Fatalf("The file was not closed by Finish().")
+		}
+	}
+}
+
+func TestT_TempDirSubtest(t *testing.T) {
+	t.Run("sub/test", func(t *testing.T) {
+		T := NewT(t)
+		defer T.Finish()
+		if dir := T.TempDir(); !strings.HasPrefix(
+			filepath.Base(dir), "TestT_TempDirSubtest_sub_test") {
+			t.Fatalf("TempDir() was not namespaced by subtest: %s", dir)
+		}
+		if file := T.WriteTempFile(""); !strings.HasPrefix(
+			filepath.Base(file), "TestT_TempDirSubtest_sub_test") {
+			t.Fatalf("TempFile() was not namespaced by subtest: %s", file)
+		}
+	})
+}
+
+func TestT_TempDirSanitized(t *testing.T) {
+	t.Run("spaces and * stars", func(t *testing.T) {
+		T := NewT(t)
+		defer T.Finish()
+		want := "TestT_TempDirSanitized_spaces_and___stars"
+		if prefix := T.tempPrefix(); prefix != want+"-"+T.ID() {
+			t.Fatalf("Unexpected prefix: %s", prefix)
+		}
+		if dir := T.TempDir(); !strings.HasPrefix(filepath.Base(dir), want) {
+			t.Fatalf("TempDir() was not sanitized: %s", dir)
+		}
+		if file := T.WriteTempFile(""); !strings.HasPrefix(
+			filepath.Base(file), want) {
+			t.Fatalf("TempFile() was not sanitized: %s", file)
+		}
+	})
+	t.Run(strings.Repeat("long", 100), func(t *testing.T) {
+		T := NewT(t)
+		defer T.Finish()
+		if prefix := T.tempPrefix(); len(prefix) != maxTempPrefix {
+			t.Fatalf("Prefix was not shortened: %s", prefix)
+		} else if !strings.HasSuffix(prefix, "-"+T.ID()) {
+			t.Fatalf("The ID was shortened away: %s", prefix)
+		}
+		T.TempDir()
+	})
+}
+
+func TestT_TempDirUniqueID(t *testing.T) {
+	t.Parallel()
+	_, T1 := testSetup()
+	_, T2 := testSetup()
+	T1.name = "TestSame"
+	T2.name = "TestSame"
+	if T1.ID() == T2.ID() {
+		t.Fatalf("Both T instances have the ID %s", T1.ID())
+	} else if T1.tempPrefix() == T2.tempPrefix() {
+		t.Fatalf("Both T instances have the prefix %s", T1.tempPrefix())
+	} else if T1.Field("x").ID() == T1.ID() {
+		t.Fatalf("A field shares its parent's ID.")
+	}
+	want := "TestSame-" + T1.ID()
+	if dir := T1.TempDir(); !strings.HasPrefix(filepath.Base(dir), want) {
+		t.Fatalf("TempDir() does not include the ID: %s", dir)
+	}
+	if file := T1.WriteTempFile(""); !strings.HasPrefix(
+		filepath.Base(file), want) {
+		t.Fatalf("TempFile() does not include the ID: %s", file)
+	}
+}
+
+func TestT_TempDirRetries(t *testing.T) {
+	m, T := testSetup()
+	calls := 0
+	ioutilTempDir = func(a, b string) (string, error) {
+		calls++
+		if calls < tempAttempts {
+			return "", fmt.Errorf("Expected")
+		}
+		return ioutil.TempDir(a, b)
+	}
+	defer func() { ioutilTempDir = ioutil.TempDir }()
+	m.CheckPass(t, func() { T.TempDir() })
+	T.Finish()
+	if calls != tempAttempts {
+		t.Fatalf("Unexpected number of attempts: %d", calls)
+	}
+
+	// Failures past the last attempt fail the test.
+	m, T = testSetup()
+	calls = 0
+	ioutilTempFile = func(a, b string) (*os.File, error) {
+		calls++
+		return nil, fmt.Errorf("Expected")
+	}
+	defer func() { ioutilTempFile = ioutil.TempFile }()
+	m.CheckFail(t, func() { T.TempFile() })
+	if calls != tempAttempts {
+		t.Fatalf("Unexpected number of attempts: %d", calls)
+	}
+}
+
+func TestT_TestTempDir(t *testing.T) {
+	// Test 1: os.MkdirAll failure.
+	m, T := testSetup()
+	m.CheckFail(t, func() {
+		osMkdirAll = func(s string, m os.FileMode) error {
+			return fmt.Errorf("Expected")
+		}
+		defer func() { osMkdirAll = os.MkdirAll }()
+		T.TestTempDir()
+	})
+
+	// Test 2: Success.
+	m, T = testSetup()
+	var dir1, dir2 string
+	m.CheckPass(t, func() {
+		dir1 = T.TestTempDir()
+		dir2 = T.TestTempDir()
+	})
+	if dir1 != dir2 {
+		t.Fatalf("TestTempDir() returned different paths: %s, %s", dir1, dir2)
+	} else if filepath.Base(dir1) != "TestT_TestTempDir-"+T.ID() {
+		t.Fatalf("TestTempDir() was not named after the test: %s", dir1)
+	} else if stat, err := os.Stat(dir1); err != nil {
+		t.Fatalf("Error stating the returned directory: %s", err)
+	} else if !stat.IsDir() {
+		t.Fatalf("%s is not a directory.", dir1)
+	}
+	T.Finish()
+	if _, err := os.Stat(dir1); !os.IsNotExist(err) {
+		t.Fatalf("The directory %s shouldn't exist.", dir1)
+	}
+}
+
+func TestT_TestTempDirUnsafeNames(t *testing.T) {
+	t.Parallel()
+	_, setup := testSetup()
+	defer setup.Finish()
+	root := setup.RootTempDir()
+
+	for _, name := range []string{"..", "", ".", "Test/sub/../..", "/"} {
+		m, T := testSetup()
+		T.name = name
+		var dir string
+		m.CheckPass(t, func() { dir = T.TestTempDir() })
+		if filepath.Dir(dir) != root {
+			t.Fatalf("%q: %s is not directly below %s", name, dir, root)
+		}
+		T.Finish()
+		if _, err := os.Stat(root); err != nil {
+			t.Fatalf("%q: the root temporary directory was removed: %s",
+				name, err)
+		}
+	}
+}
+
+func TestT_ModifyFile(t *testing.T) {
+	t.Parallel()
+	_, setup := testSetup()
+	defer setup.Finish()
+	path := filepath.Join(setup.TempDir(), "config")
+	if err := ioutil.WriteFile(path, []byte("port=80\n"), 0600); err != nil {
+		t.Fatalf("Unable to write the file: %s", err)
+	}
+
+	m, T := testSetup()
+	m.CheckPass(t, func() {
+		T.ModifyFile(path, func(data []byte) []byte {
+			return bytes.Replace(data, []byte("80"), []byte("8080"), 1)
+		})
+	})
+	if data, _ := ioutil.ReadFile(path); string(data) != "port=8080\n" {
+		t.Fatalf("The file was not modified: %q", data)
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatalf("Unable to chmod the file: %s", err)
+	}
+	m.CheckPass(t, func() { T.Finish() })
+	if data, _ := ioutil.ReadFile(path); string(data) != "port=80\n" {
+		t.Fatalf("The file was not restored: %q", data)
+	} else if info, err := os.Stat(path); err != nil {
+		t.Fatalf("Unable to stat the file: %s", err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Fatalf("The mode was not restored: %s", info.Mode())
+	}
+
+	// Missing files can not be modified.
+	m, T = testSetup()
+	defer T.Finish()
+	m.CheckFail(t, func() {
+		T.ModifyFile(path+".missing", func(data []byte) []byte { return data })
+	})
+}
+
+func TestT_ReadOnlyDir(t *testing.T) {
+	defer func() { osGeteuid = os.Geteuid }()
+
+	// Permissions do not apply to root so the test is skipped.
+	osGeteuid = func() int { return 0 }
+	m, T := testSetup()
+	m.CheckSkips(t, func() { T.ReadOnlyDir("/") })
+	T.Finish()
+
+	osGeteuid = func() int { return 1000 }
+	_, setup := testSetup()
+	defer setup.Finish()
+	dir := setup.TempDir()
+	sub := filepath.Join(dir, "sub")
+	file := filepath.Join(sub, "file")
+	if err := os.Mkdir(sub, 0750); err != nil {
+		t.Fatalf("Unable to make the directory: %s", err)
+	} else if err := ioutil.WriteFile(file, nil, 0640); err != nil {
+		t.Fatalf("Unable to write the file: %s", err)
+	}
+	checkMode := func(path string, want os.FileMode) {
+		if info, err := os.Stat(path); err != nil {
+			t.Fatalf("Unable to stat %s: %s", path, err)
+		} else if info.Mode().Perm() != want {
+			t.Fatalf("%s has mode %s, expected %s", path, info.Mode().Perm(), want)
+		}
+	}
+
+	m, T = testSetup()
+	m.CheckPass(t, func() { T.ReadOnlyDir(dir) })
+	checkMode(sub, 0550)
+	checkMode(file, 0440)
+	m.CheckPass(t, func() { T.Finish() })
+	checkMode(sub, 0750)
+	checkMode(file, 0640)
+
+	m, T = testSetup()
+	var temp string
+	m.CheckPass(t, func() { temp = T.ReadOnlyTempDir() })
+	checkMode(temp, 0555)
+	m.CheckPass(t, func() { T.Finish() })
+	if _, err := os.Stat(temp); !os.IsNotExist(err) {
+		t.Fatalf("The temporary directory was not removed: %s", err)
+	}
+
+	m, T = testSetup()
+	m.CheckFail(t, func() { T.ReadOnlyDir(filepath.Join(dir, "missing")) })
+	T.Finish()
+}
@@ -0,0 +1,266 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// This file contains functions for managing expensive fixtures that are
+// shared between all of the tests in a package.
+
+// A resource that is shared between tests. This is returned from
+// SharedFixture() and is safe to use from parallel tests.
+type Fixture struct {
+	name  string
+	setup func() (interface{}, func())
+
+	// Protects all of the fields below.
+	lock     sync.Mutex
+	created  bool
+	refs     int
+	value    interface{}
+	teardown func()
+}
+
+// Registers a fixture that is shared between all tests in the package. The
+// setup function is not called until the first test calls Get() on the
+// returned Fixture, after which the value it returned is shared with every
+// other caller. Setup returns the value along with a function that will
+// tear it down, which may be nil.
+//
+// Each test that calls Get() holds a reference to the fixture until it
+// finishes, and the fixture is torn down when the last test using it
+// finishes. A later call to Get() sets it up again, so the fixture is only
+// shared by tests that overlap, like those that call t.Parallel(). As a
+// safety net TearDownSharedFixtures() tears down any fixture that is still
+// in use and should be called from TestMain once all the tests have
+// completed (Main() does this):
+//
+//	var db = testlib.SharedFixture("db", startDatabase)
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		testlib.TearDownSharedFixtures()
+//		os.Exit(code)
+//	}
+//
+// Calling SharedFixture more than once with the same name returns the
+// Fixture that was registered first.
+func SharedFixture(name string, setup func() (interface{}, func())) *Fixture {
+	sharedFixturesLock.Lock()
+	defer sharedFixturesLock.Unlock()
+	if f, ok := sharedFixtures[name]; ok {
+		return f
+	}
+	f := &Fixture{name: name, setup: setup}
+	sharedFixtures[name] = f
+	return f
+}
+
+// Returns the value of the fixture, creating it if this is the first use.
+// A reference to the fixture is held until the given test finishes.
+func (f *Fixture) Get(t *T) interface{} {
+	f.lock.Lock()
+	if !f.created {
+		f.value, f.teardown = f.setup()
+		f.created = true
+	}
+	f.refs++
+	value := f.value
+	f.lock.Unlock()
+
+	// The finalizer is added without the lock held since it runs right
+	// away if t has already finished.
+	t.AddNamedFinalizer("release fixture "+f.name, f.release)
+	return value
+}
+
+// Returns the name the fixture was registered with.
+func (f *Fixture) Name() string {
+	return f.name
+}
+
+// Releases a reference held by a test, tearing the fixture down if this
+// was the last one.
+func (f *Fixture) release() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.refs--; f.refs == 0 {
+		f.destroy()
+	}
+}
+
+// Tears down the fixture if it has been created. This returns the number of
+// references that were still held.
+func (f *Fixture) tearDown() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.destroy()
+	return f.refs
+}
+
+// Calls the teardown function if the fixture has been created. This must
+// be called with lock held.
+func (f *Fixture) destroy() {
+	if !f.created {
+		return
+	}
+	if f.teardown != nil {
+		f.teardown()
+	}
+	f.created = false
+	f.value = nil
+	f.teardown = nil
+}
+
+// Tears down every shared fixture that has been created. This should be
+// called from TestMain after all of the tests have finished. If any fixture
+// is still referenced by a running test it is torn down anyway and an error
+// naming the fixture is returned.
+func TearDownSharedFixtures() error {
+	sharedFixturesLock.Lock()
+	names := make([]string, 0, len(sharedFixtures))
+	for name := range sharedFixtures {
+		names = append(names, name)
+	}
+	sharedFixturesLock.Unlock()
+
+	// Tear down in a stable order so failures are reproducible.
+	sort.Strings(names)
+	inUse := []string{}
+	for _, name := range names {
+		sharedFixturesLock.Lock()
+		f := sharedFixtures[name]
+		sharedFixturesLock.Unlock()
+		if refs := f.tearDown(); refs > 0 {
+			inUse = append(inUse, fmt.Sprintf("%s (%d references)", name, refs))
+		}
+	}
+	if len(inUse) > 0 {
+		return fmt.Errorf("Shared fixtures were still in use: %s",
+			strings.Join(inUse, ", "))
+	}
+	return nil
+}
+
+// All of the fixtures registered via SharedFixture().
+var (
+	sharedFixtures     = map[string]*Fixture{}
+	sharedFixturesLock sync.Mutex
+)
+
+// Returns a directory containing an expensive artifact, like a compiled
+// binary or a generated data set, which is built once per run of the test
+// binary and then shared by every test. The first call for a given key
+// calls build with an empty directory to fill; every other call returns the
+// same directory, waiting for the build to finish if it is still running.
+// The key should identify everything the artifact depends on, for example
+// a hash of its inputs, since the directory is named after a hash of it.
+//
+// The directory lives under the root temporary directory so it is removed
+// when the test binary exits. If build returns an error (or panics) then
+// the calling test fails, as does every later call with the same key.
+func (t *T) CachedFixture(key string, build func(dir string) error) string {
+	dir, err := cachedFixtureDir(key, build)
+	if err != nil {
+		t.Fatalf("Unable to build cached fixture %q: %s", key, err)
+	}
+	return dir
+}
+
+// Like CachedFixture except that errors are returned rather than failing
+// the test.
+func cachedFixtureDir(key string, build func(dir string) error) (string, error) {
+	cachedFixturesLock.Lock()
+	c, ok := cachedFixtures[key]
+	if !ok {
+		c = &cachedFixture{}
+		cachedFixtures[key] = c
+	}
+	cachedFixturesLock.Unlock()
+
+	c.once.Do(func() {
+		// If build stops the goroutine (for example by calling Fatal)
+		// then buildCachedFixture never returns, so the error is set
+		// first to keep later callers from seeing an empty directory.
+		c.err = errFixtureBuildAborted
+		c.dir, c.err = buildCachedFixture(key, build)
+	})
+	return c.dir, c.err
+}
+
+// Builds a cached fixture into a scratch directory which is renamed into
+// place once build succeeds, so a failed build never leaves a partial
+// artifact behind.
+func buildCachedFixture(
+	key string, build func(dir string) error,
+) (dir string, err error) {
+	root, err := rootTempDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(key))
+	dir = filepath.Join(root, "fixtures", hex.EncodeToString(sum[:8]))
+	scratch := dir + ".building"
+	if err := osMkdirAll(scratch, os.FileMode(0755)); err != nil {
+		return "", err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("build panicked: %v", r)
+		}
+		if err != nil {
+			osRemoveAll(scratch)
+		}
+	}()
+
+	// This is replaced by the return below unless build stops the
+	// goroutine, in which case the deferred function still sees it.
+	err = errFixtureBuildAborted
+	if err := build(scratch); err != nil {
+		return "", err
+	}
+	if err := os.Rename(scratch, dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Returned for a cached fixture whose build stopped the goroutine it was
+// running in rather than returning.
+var errFixtureBuildAborted = errors.New(
+	"build did not return, it may have called Fatal or runtime.Goexit")
+
+// The state of a fixture created by CachedFixture().
+type cachedFixture struct {
+	once sync.Once
+	dir  string
+	err  error
+}
+
+// All of the fixtures created via CachedFixture(), keyed by their key.
+var (
+	cachedFixtures     = map[string]*cachedFixture{}
+	cachedFixturesLock sync.Mutex
+)
@@ -0,0 +1,218 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSharedFixture(t *testing.T) {
+	setups := 0
+	teardowns := 0
+	f := SharedFixture("TestSharedFixture", func() (interface{}, func()) {
+		setups++
+		return "value", func() { teardowns++ }
+	})
+	if SharedFixture("TestSharedFixture", nil) != f {
+		t.Fatalf("Registering the same name returned a new fixture.")
+	} else if f.Name() != "TestSharedFixture" {
+		t.Fatalf("Wrong name returned: %s", f.Name())
+	}
+
+	// Use the fixture from many parallel "tests", all of which hold a
+	// reference until every one has one.
+	var got, wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		got.Add(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, T := testSetup()
+			defer T.Finish()
+			if v := f.Get(T); v != "value" {
+				t.Errorf("Wrong value returned: %#v", v)
+			}
+			got.Done()
+			got.Wait()
+		}()
+	}
+	wg.Wait()
+	if setups != 1 {
+		t.Fatalf("Setup was called %d times.", setups)
+	} else if teardowns != 1 {
+		t.Fatalf("Fixture was torn down %d times.", teardowns)
+	}
+
+	// The next test to use it sets it up again.
+	_, T := testSetup()
+	if v := f.Get(T); v != "value" {
+		t.Fatalf("Wrong value returned: %#v", v)
+	} else if setups != 2 {
+		t.Fatalf("Fixture was not recreated: %d", setups)
+	}
+	T.Finish()
+	if teardowns != 2 {
+		t.Fatalf("Fixture was torn down %d times.", teardowns)
+	}
+	if err := TearDownSharedFixtures(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	} else if teardowns != 2 {
+		t.Fatalf("A released fixture was torn down again.")
+	}
+
+	// A fixture that is still referenced causes an error.
+	_, T = testSetup()
+	f.Get(T)
+	if err := TearDownSharedFixtures(); err == nil {
+		t.Fatalf("Expected an error for a fixture in use.")
+	} else if !strings.Contains(err.Error(), "TestSharedFixture") {
+		t.Fatalf("Error did not name the fixture: %s", err)
+	} else if setups != 3 || teardowns != 3 {
+		t.Fatalf("Fixture was not recreated: %d, %d", setups, teardowns)
+	}
+	T.Finish()
+	if teardowns != 3 {
+		t.Fatalf("Fixture was torn down %d times.", teardowns)
+	}
+}
+
+func TestSharedFixture_FinishedT(t *testing.T) {
+	teardowns := 0
+	f := SharedFixture("TestSharedFixture_FinishedT",
+		func() (interface{}, func()) {
+			return "value", func() { teardowns++ }
+		})
+	_, T := testSetup()
+	T.Finish()
+
+	// The reference is released straight away rather than deadlocking.
+	done := make(chan interface{})
+	go func() { done <- f.Get(T) }()
+	select {
+	case v := <-done:
+		if v != "value" {
+			t.Fatalf("Wrong value returned: %#v", v)
+		} else if teardowns != 1 {
+			t.Fatalf("Fixture was torn down %d times.", teardowns)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Get() deadlocked on a finished T.")
+	}
+}
+
+func TestT_CachedFixture(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	// Parallel callers share a single build.
+	var lock sync.Mutex
+	builds := 0
+	build := func(dir string) error {
+		lock.Lock()
+		builds++
+		lock.Unlock()
+		return ioutil.WriteFile(filepath.Join(dir, "artifact"), []byte("x"), 0644)
+	}
+	dirs := make([]string, 10)
+	var wg sync.WaitGroup
+	for i := range dirs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, T := testSetup()
+			defer T.Finish()
+			dirs[i] = T.CachedFixture("TestCachedFixture", build)
+		}(i)
+	}
+	wg.Wait()
+	if builds != 1 {
+		t.Fatalf("Expected 1 build, got %d", builds)
+	}
+	for _, dir := range dirs {
+		if dir != dirs[0] {
+			t.Fatalf("Different directories returned: %s, %s", dir, dirs[0])
+		}
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dirs[0], "artifact")); err != nil {
+		t.Fatalf("The artifact was not kept: %s", err)
+	} else if string(data) != "x" {
+		t.Fatalf("Unexpected artifact: %q", data)
+	}
+	if T.CachedFixture("TestCachedFixture other", build) == dirs[0] {
+		t.Fatalf("Different keys shared a directory.")
+	}
+
+	// Failures fail the calling test on every call and leave nothing
+	// behind.
+	scratch := ""
+	failing := func(dir string) error {
+		scratch = dir
+		return fmt.Errorf("EXPECTED")
+	}
+	for i := 0; i < 2; i++ {
+		m.CheckFail(t, func() {
+			T.CachedFixture("TestCachedFixture failing", failing)
+		})
+		if !strings.HasPrefix(msg, `Unable to build cached fixture `+
+			`"TestCachedFixture failing": EXPECTED`) {
+			t.Fatalf("Unexpected message: %s", msg)
+		}
+	}
+	if _, err := os.Stat(scratch); !os.IsNotExist(err) {
+		t.Fatalf("The failed build was not removed: %v", err)
+	}
+	m.CheckFail(t, func() {
+		T.CachedFixture("TestCachedFixture panic",
+			func(string) error { panic("oops") })
+	})
+	if !strings.Contains(msg, "build panicked: oops") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	// A build that calls Fatal (and so runtime.Goexit) fails every later
+	// call rather than returning an empty directory.
+	scratch = ""
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		T.CachedFixture("TestCachedFixture goexit", func(dir string) error {
+			scratch = dir
+			runtime.Goexit()
+			return nil
+		})
+	}()
+	<-done
+	m.CheckFail(t, func() {
+		T.CachedFixture("TestCachedFixture goexit", build)
+	})
+	if !strings.Contains(msg, errFixtureBuildAborted.Error()) {
+		t.Fatalf("Unexpected message: %s", msg)
+	} else if _, err := os.Stat(scratch); !os.IsNotExist(err) {
+		t.Fatalf("The aborted build was not removed: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"flag"
+	"path/filepath"
+)
+
+// This file contains the shared plumbing for golden file workflows.
+
+// If this flag is passed to the test binary (go test -args -testlib.update)
+// then golden files are rewritten from the values produced by the test
+// rather than being compared against.
+var updateGolden = flag.Bool("testlib.update", false,
+	"Rewrite testlib golden files rather than comparing against them.")
+
+// The directory that golden files are stored in, relative to the package
+// being tested.
+var goldenDir = "testdata"
+
+// Returns the path of the golden file with the given name.
+func goldenPath(name string) string {
+	return filepath.Join(goldenDir, name)
+}
@@ -0,0 +1,151 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// This file contains functions for grouping soft assertions.
+
+// Runs fn with a child T whose fatal failures are downgraded to errors.
+// Every assertion made in fn is reported, but none of them stop fn or the
+// enclosing test, which makes it possible to check all of the fields of a
+// result at once rather than fixing them one run at a time:
+//
+//	t.Group(func(t *testlib.T) {
+//		t.Equal(user.Name, "alice")
+//		t.Equal(user.Age, 30)
+//	})
+//
+// Since execution continues after a failure fn should only make assertions;
+// helpers that create resources expect a fatal failure to stop the test.
+// The child's finalizers are run when fn returns. This returns true if no
+// failures were reported within the group.
+func (t *T) Group(fn func(*T)) bool {
+	g := &groupTB{parent: t}
+	child := t.derive(g)
+	defer child.Finish()
+	fn(child)
+	return !g.Failed()
+}
+
+// Returns a new T that reports through tb but shares the parent's
+// configuration (name, seed, verbosity, formatters and so on).
+func (t *T) derive(tb testingTB) *T {
+	child := NewT(tb)
+	child.name = t.Name()
+	child.start = t.start
+	child.seed = t.Seed()
+	child.seeded = true
+	child.attempt = t.attempt
+	child.verbose = t.verbose
+	child.finalizerTimeout = t.finalizerTimeout
+	child.maxValueLength = t.maxValueLength
+	child.printLiteral = t.printLiteral
+	child.label = t.label
+	if t.formatters != nil {
+		child.formatters = make(map[reflect.Type]func(interface{}) string, len(t.formatters))
+		for typ, f := range t.formatters {
+			child.formatters[typ] = f
+		}
+	}
+	if t.pointerHints != nil {
+		child.pointerHints = make(map[reflect.Type]reflect.Type, len(t.pointerHints))
+		for typ, elem := range t.pointerHints {
+			child.pointerHints[typ] = elem
+		}
+	}
+	t.lock.Lock()
+	child.steps = append([]*step(nil), t.steps...)
+	child.traceCtx = t.traceCtx
+	child.dedupe = t.dedupe
+	child.tags = append([]string(nil), t.tags...)
+	child.secrets = append([]string(nil), t.secrets...)
+	t.lock.Unlock()
+	return child
+}
+
+// The testingTB used by the child T in a Group(). Fatal failures are
+// reported to the parent as errors and do not stop the calling goroutine.
+type groupTB struct {
+	parent *T
+	lock   sync.Mutex
+	failed bool
+}
+
+func (g *groupTB) fail() {
+	g.lock.Lock()
+	g.failed = true
+	g.lock.Unlock()
+}
+
+func (g *groupTB) Error(args ...interface{}) {
+	g.fail()
+	g.parent.t.Error(args...)
+}
+
+func (g *groupTB) Errorf(format string, args ...interface{}) {
+	g.Error(fmt.Sprintf(format, args...))
+}
+
+func (g *groupTB) Fail() {
+	g.fail()
+	g.parent.t.Fail()
+}
+
+func (g *groupTB) FailNow() {
+	g.Fail()
+}
+
+func (g *groupTB) Failed() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.failed
+}
+
+func (g *groupTB) Fatal(args ...interface{}) {
+	g.Error(args...)
+}
+
+func (g *groupTB) Fatalf(format string, args ...interface{}) {
+	g.Error(fmt.Sprintf(format, args...))
+}
+
+func (g *groupTB) Log(args ...interface{}) {
+	g.parent.t.Log(args...)
+}
+
+func (g *groupTB) Logf(format string, args ...interface{}) {
+	g.parent.t.Logf(format, args...)
+}
+
+func (g *groupTB) Skip(args ...interface{}) {
+	g.parent.t.Skip(args...)
+}
+
+func (g *groupTB) SkipNow() {
+	g.parent.t.SkipNow()
+}
+
+func (g *groupTB) Skipf(format string, args ...interface{}) {
+	g.parent.t.Skipf(format, args...)
+}
+
+func (g *groupTB) Skipped() bool {
+	return g.parent.t.Skipped()
+}
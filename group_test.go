@@ -0,0 +1,75 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestT_Group(t *testing.T) {
+	t.Parallel()
+	m, parent := testSetup()
+
+	errors := []string{}
+	m.funcError = func(args ...interface{}) {
+		errors = append(errors, fmt.Sprint(args...))
+	}
+
+	finalized := false
+	reached := false
+	passed := parent.Group(func(g *T) {
+		g.AddFinalizer(func() { finalized = true })
+		g.Equal(1, 2, "first")
+		g.Fatalf("second")
+		reached = true
+	})
+	if passed {
+		t.Fatalf("The group did not report its failure.")
+	} else if !reached {
+		t.Fatalf("The group was stopped by a fatal failure.")
+	} else if !finalized {
+		t.Fatalf("The group's finalizers were not run.")
+	} else if !m.failed {
+		t.Fatalf("The failures were not reported to the parent.")
+	} else if len(errors) != 2 || !strings.HasPrefix(errors[0], "first: ") ||
+		!strings.HasPrefix(errors[1], "second") {
+		t.Fatalf("Unexpected errors: %#v", errors)
+	}
+
+	// A group with no failures passes even if the test already failed.
+	if !parent.Group(func(g *T) { g.Equal(1, 1) }) {
+		t.Fatalf("A passing group reported a failure.")
+	}
+}
+
+func TestT_derive(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+
+	T.name = "TestParent"
+	T.SetMaxValueLength(10)
+	T.SetPrintLiteral(true)
+	T.steps = []*step{{name: "outer"}}
+	child := T.derive(new(mockT))
+	if child.Name() != "TestParent" {
+		t.Fatalf("The name was not copied: %s", child.Name())
+	} else if child.maxValueLength != 10 || !child.printLiteral {
+		t.Fatalf("The settings were not copied.")
+	} else if child.stepPath() != "outer" {
+		t.Fatalf("The steps were not copied: %s", child.stepPath())
+	}
+}
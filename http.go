@@ -0,0 +1,329 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file contains functions to help with testing HTTP code.
+
+// Compares two sets of HTTP headers. Header names are compared case
+// insensitively and the order of the values for each header is ignored.
+// Any header named in ignore (also case insensitive) is not compared, which
+// is useful for headers like Date that change on every request.
+func (t *T) EqualHeaders(have, want http.Header, ignore ...string) {
+	ignored := make(map[string]bool, len(ignore))
+	for _, name := range ignore {
+		ignored[http.CanonicalHeaderKey(name)] = true
+	}
+	canonicalize := func(h http.Header) map[string][]string {
+		c := make(map[string][]string, len(h))
+		for name, values := range h {
+			name = http.CanonicalHeaderKey(name)
+			if !ignored[name] {
+				c[name] = append(c[name], values...)
+			}
+		}
+		return c
+	}
+	diffs := multisetDiffs("header", canonicalize(have), canonicalize(want))
+	if len(diffs) > 0 {
+		t.Fatalf("Headers are not equal\n%s", strings.Join(diffs, "\n"))
+	}
+}
+
+// Wraps next with the middleware under test so that the request the
+// middleware passes on, the response it produces and whether it invokes the
+// next handler at all can be asserted against. If next is nil then a
+// handler that writes an empty 200 response is used.
+func (t *T) Middleware(
+	middleware func(http.Handler) http.Handler, next http.Handler,
+) *MiddlewareTest {
+	if next == nil {
+		next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	m := &MiddlewareTest{t: t}
+	m.handler = middleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			m.nextCalled = true
+			m.nextRequest = r
+			next.ServeHTTP(w, r)
+		}))
+	return m
+}
+
+// A middleware wrapped for testing. This is returned from T.Middleware().
+type MiddlewareTest struct {
+	t           *T
+	handler     http.Handler
+	nextCalled  bool
+	nextRequest *http.Request
+	response    *httptest.ResponseRecorder
+}
+
+// Serves the request through the middleware, resetting any state recorded
+// from a previous request. The recorded response is returned.
+func (m *MiddlewareTest) Serve(r *http.Request) *httptest.ResponseRecorder {
+	m.nextCalled = false
+	m.nextRequest = nil
+	m.response = httptest.NewRecorder()
+	m.handler.ServeHTTP(m.response, r)
+	return m.response
+}
+
+// Returns the request as it was passed to the next handler, or nil if the
+// next handler was not called.
+func (m *MiddlewareTest) NextRequest() *http.Request {
+	return m.nextRequest
+}
+
+// Returns the response recorded by the last call to Serve().
+func (m *MiddlewareTest) Response() *httptest.ResponseRecorder {
+	return m.response
+}
+
+// Fails the test if the middleware did not call the next handler.
+func (m *MiddlewareTest) ExpectNextCalled(desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	if !m.nextCalled {
+		m.t.Fatalf("%sThe next handler was not called.", prefix)
+	}
+}
+
+// Fails the test if the middleware called the next handler.
+func (m *MiddlewareTest) ExpectNextNotCalled(desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	if m.nextCalled {
+		m.t.Fatalf("%sThe next handler was called unexpectedly.", prefix)
+	}
+}
+
+// Fails the test if the request passed to the next handler does not have
+// the given value for the named header.
+func (m *MiddlewareTest) ExpectRequestHeader(name, value string, desc ...string) {
+	m.ExpectNextCalled(desc...)
+	m.t.Equal(m.nextRequest.Header.Get(name), value, append(desc,
+		"request header "+http.CanonicalHeaderKey(name))...)
+}
+
+// Fails the test if the response does not have the given value for the
+// named header.
+func (m *MiddlewareTest) ExpectResponseHeader(name, value string, desc ...string) {
+	m.t.Equal(m.response.Header().Get(name), value, append(desc,
+		"response header "+http.CanonicalHeaderKey(name))...)
+}
+
+// Fails the test if the response was not written with the given status.
+func (m *MiddlewareTest) ExpectStatus(code int, desc ...string) {
+	m.t.Equal(m.response.Code, code, append(desc, "response status")...)
+}
+
+// Starts a reverse proxy in front of upstream (for example
+// "http://127.0.0.1:8080") that records every request and response that
+// passes through it. Point the client under test at the proxy's URL. The
+// proxy is shut down when the test finishes.
+func (t *T) RecordingProxy(upstream string) *RecordingProxy {
+	target, err := url.Parse(upstream)
+	t.ExpectSuccess(err, "Invalid upstream URL")
+	p := &RecordingProxy{t: t}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = &recordingTransport{proxy: p, base: http.DefaultTransport}
+	p.server = httptest.NewServer(proxy)
+	p.URL = p.server.URL
+	t.AddNamedFinalizer("recording proxy", p.server.Close)
+	return p
+}
+
+// A single request and response that passed through a RecordingProxy.
+type Exchange struct {
+	Method         string
+	Path           string
+	RequestHeader  http.Header
+	RequestBody    string
+	Status         int
+	ResponseHeader http.Header
+	ResponseBody   string
+}
+
+// A reverse proxy that records everything passing through it. This is
+// returned from T.RecordingProxy().
+type RecordingProxy struct {
+	// The base URL of the proxy, for example "http://127.0.0.1:12345".
+	URL string
+
+	t         *T
+	server    *httptest.Server
+	lock      sync.Mutex
+	exchanges []Exchange
+}
+
+// Returns a copy of every exchange recorded so far, in the order the
+// responses were received.
+func (p *RecordingProxy) Exchanges() []Exchange {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]Exchange(nil), p.exchanges...)
+}
+
+// Compares the method, path, status and bodies of every recorded exchange
+// against the golden file with the given name in the testdata directory.
+// Headers are not compared since they often contain dates and other values
+// that change between runs. If the test binary is run with -testlib.update
+// then the golden file is written instead.
+func (p *RecordingProxy) GoldenExchanges(name string, desc ...string) {
+	type golden struct {
+		Method       string
+		Path         string
+		RequestBody  string
+		Status       int
+		ResponseBody string
+	}
+	have := []golden{}
+	for _, e := range p.Exchanges() {
+		have = append(have, golden{
+			e.Method, e.Path, e.RequestBody, e.Status, e.ResponseBody})
+	}
+	path := goldenPath(name)
+	if *updateGolden {
+		data, err := json.MarshalIndent(have, "", "  ")
+		p.t.ExpectSuccess(err)
+		p.t.ExpectSuccess(osMkdirAll(filepath.Dir(path), os.FileMode(0755)))
+		p.t.ExpectSuccess(ioutil.WriteFile(path, append(data, '\n'), 0644))
+		return
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		p.t.Fatalf("Unable to read golden file (run with -testlib.update "+
+			"to create it): %s", err)
+	}
+	want := []golden{}
+	p.t.ExpectSuccess(json.Unmarshal(data, &want), "Invalid golden file")
+	p.t.Equal(have, want, desc...)
+}
+
+// An http.RoundTripper that records each request and response that it
+// passes to base.
+type recordingTransport struct {
+	proxy *RecordingProxy
+	base  http.RoundTripper
+}
+
+// Implements http.RoundTripper.
+func (r *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody := []byte{}
+	if req.Body != nil {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	r.proxy.lock.Lock()
+	defer r.proxy.lock.Unlock()
+	r.proxy.exchanges = append(r.proxy.exchanges, Exchange{
+		Method:         req.Method,
+		Path:           req.URL.RequestURI(),
+		RequestHeader:  req.Header.Clone(),
+		RequestBody:    string(reqBody),
+		Status:         resp.StatusCode,
+		ResponseHeader: resp.Header.Clone(),
+		ResponseBody:   string(respBody),
+	})
+	return resp, nil
+}
+
+// How long WaitForHTTP() waits between requests.
+const waitForHTTPInterval = 50 * time.Millisecond
+
+// The most of a response body that WaitForHTTP() includes in a failure.
+const waitForHTTPMaxBody = 1024
+
+// Polls url with GET requests until it returns one of the given status
+// codes, failing the test if that does not happen within timeout. If no
+// status codes are given then any 2xx status is accepted. The failure
+// message includes the status and body (or error) of the last attempt.
+// This is useful for waiting on servers started as processes or
+// containers.
+func (t *T) WaitForHTTP(url string, timeout time.Duration, okStatus ...int) {
+	defer t.trackWait("WaitForHTTP")()
+	accepted := func(code int) bool {
+		if len(okStatus) == 0 {
+			return code >= 200 && code < 300
+		}
+		for _, ok := range okStatus {
+			if code == ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	client := &http.Client{Timeout: timeout}
+	last := "no request was made"
+	end := time.Now().Add(timeout)
+	for {
+		resp, err := client.Get(url)
+		if err != nil {
+			last = "error: " + err.Error()
+		} else {
+			body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, waitForHTTPMaxBody))
+			resp.Body.Close()
+			if accepted(resp.StatusCode) {
+				return
+			}
+			last = fmt.Sprintf("status: %s\n  body: %q", resp.Status, body)
+		}
+		if time.Now().Add(waitForHTTPInterval).After(end) {
+			break
+		}
+		time.Sleep(waitForHTTPInterval)
+	}
+	t.Fatalf("%s was not ready after %s. Last attempt:\n  %s",
+		url, timeout, last)
+}
@@ -0,0 +1,210 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestT_EqualHeaders(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	have := http.Header{
+		"Content-Type": {"text/plain"},
+		"accept":       {"b", "a"},
+		"Date":         {"today"},
+	}
+	want := http.Header{
+		"content-type": {"text/plain"},
+		"Accept":       {"a", "b"},
+		"Date":         {"yesterday"},
+	}
+	m.CheckFail(t, func() { T.EqualHeaders(have, want) })
+	if !strings.Contains(msg, `header["Date"]: not equal.`) {
+		t.Fatalf("The Date difference was not reported: %s", msg)
+	} else if strings.Contains(msg, "Accept") {
+		t.Fatalf("Accept should not have been reported: %s", msg)
+	}
+	m.CheckPass(t, func() { T.EqualHeaders(have, want, "date") })
+
+	delete(want, "Accept")
+	m.CheckFail(t, func() { T.EqualHeaders(have, want, "date") })
+	if !strings.Contains(msg, `header["Accept"]: Unexpected key.`) {
+		t.Fatalf("The Accept header was not reported: %s", msg)
+	}
+}
+
+func TestT_Middleware(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			r.Header.Set("X-User", "bob")
+			w.Header().Set("X-Served-By", "auth")
+			next.ServeHTTP(w, r)
+		})
+	}
+	mw := T.Middleware(auth, nil)
+
+	// Requests without credentials are rejected.
+	mw.Serve(httptest.NewRequest("GET", "/", nil))
+	m.CheckPass(t, func() { mw.ExpectNextNotCalled() })
+	m.CheckPass(t, func() { mw.ExpectStatus(http.StatusUnauthorized) })
+	m.CheckFail(t, func() { mw.ExpectNextCalled("prefix") })
+	if !strings.HasPrefix(msg, "prefix: The next handler was not called.") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { mw.ExpectRequestHeader("X-User", "bob") })
+
+	// Authenticated requests are mutated and passed on.
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "token")
+	mw.Serve(r)
+	m.CheckPass(t, func() { mw.ExpectNextCalled() })
+	m.CheckFail(t, func() { mw.ExpectNextNotCalled() })
+	m.CheckPass(t, func() { mw.ExpectStatus(http.StatusOK) })
+	m.CheckPass(t, func() { mw.ExpectRequestHeader("x-user", "bob") })
+	m.CheckFail(t, func() { mw.ExpectRequestHeader("x-user", "alice") })
+	m.CheckPass(t, func() { mw.ExpectResponseHeader("X-Served-By", "auth") })
+	m.CheckFail(t, func() { mw.ExpectResponseHeader("X-Served-By", "other") })
+	if mw.NextRequest() == nil || mw.Response() == nil {
+		t.Fatalf("The request and response were not recorded.")
+	}
+}
+
+func TestT_RecordingProxy(t *testing.T) {
+	m, T := testSetup()
+	defer T.Finish()
+
+	defer func(dir string) { goldenDir = dir }(goldenDir)
+	goldenDir = T.TempDir()
+	defer func(update bool) { *updateGolden = update }(*updateGolden)
+
+	upstream := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("X-Upstream", "yes")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, body)
+		}))
+	defer upstream.Close()
+
+	proxy := T.RecordingProxy(upstream.URL)
+	resp, err := http.Post(proxy.URL+"/items?x=1", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Request failed: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "POST /items hello" {
+		t.Fatalf("The response was not proxied: %s", body)
+	}
+
+	exchanges := proxy.Exchanges()
+	if len(exchanges) != 1 {
+		t.Fatalf("Unexpected exchanges: %#v", exchanges)
+	}
+	e := exchanges[0]
+	T.Equal(e.Method, "POST")
+	T.Equal(e.Path, "/items?x=1")
+	T.Equal(e.RequestBody, "hello")
+	T.Equal(e.RequestHeader.Get("Content-Type"), "text/plain")
+	T.Equal(e.Status, http.StatusCreated)
+	T.Equal(e.ResponseHeader.Get("X-Upstream"), "yes")
+	T.Equal(e.ResponseBody, "POST /items hello")
+
+	// Golden files.
+	*updateGolden = false
+	m.CheckFail(t, func() { proxy.GoldenExchanges("proxy.json") })
+	*updateGolden = true
+	m.CheckPass(t, func() { proxy.GoldenExchanges("proxy.json") })
+	*updateGolden = false
+	m.CheckPass(t, func() { proxy.GoldenExchanges("proxy.json") })
+	resp, err = http.Get(proxy.URL + "/other")
+	if err != nil {
+		t.Fatalf("Request failed: %s", err)
+	}
+	resp.Body.Close()
+	m.CheckFail(t, func() { proxy.GoldenExchanges("proxy.json") })
+}
+
+func TestT_WaitForHTTP(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	var lock sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			calls++
+			if calls < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprintf(w, "starting up")
+			} else if r.URL.Path == "/teapot" {
+				w.WriteHeader(http.StatusTeapot)
+			}
+		}))
+	defer server.Close()
+
+	m.CheckPass(t, func() { T.WaitForHTTP(server.URL, time.Second) })
+	m.CheckPass(t, func() {
+		T.WaitForHTTP(server.URL+"/teapot", time.Second, http.StatusTeapot)
+	})
+
+	// The last response is included in the failure.
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	m.CheckFail(t, func() {
+		T.WaitForHTTP(server.URL+"/teapot", 100*time.Millisecond)
+	})
+	if !strings.Contains(msg, "status: 418 I'm a teapot") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	// Connection errors are reported too.
+	addr := server.Listener.Addr().String()
+	server.Close()
+	m.CheckFail(t, func() {
+		T.WaitForHTTP("http://"+addr, 100*time.Millisecond)
+	})
+	if !strings.Contains(msg, "error: ") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
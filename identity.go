@@ -0,0 +1,99 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// This file contains functions for faking the identity of the machine and
+// user that a test is running as.
+
+// Makes the machine appear to be named name for the rest of the test.
+//
+// Go does not allow os.Hostname() itself to be replaced, so code under test
+// should look the hostname up through a package variable:
+//
+//	var hostname = os.Hostname
+//
+// and the test passes a pointer to that variable in targets, each of which
+// is replaced with a function returning name. The HOSTNAME environment
+// variable is also set for code that honors it. Everything is restored when
+// the test finishes. Since both are process wide this should not be used in
+// tests that call t.Parallel().
+func (t *T) FakeHostname(name string, targets ...*func() (string, error)) {
+	t.setenv("HOSTNAME", name)
+	for _, target := range targets {
+		t.Override(target, func() (string, error) { return name, nil })
+	}
+}
+
+// Makes the test appear to be running as the given user for the rest of the
+// test, returning the faked user. The user's home directory is a new
+// temporary directory (see TempDir()) and its group ID matches uid.
+//
+// Like FakeHostname(), code under test should look the user up through a
+// package variable:
+//
+//	var currentUser = user.Current
+//
+// which is passed in targets and replaced with a function returning the
+// fake user. The USER, LOGNAME and HOME environment variables are also set
+// for code that honors them, such as os.UserHomeDir(). Everything is
+// restored when the test finishes. This should not be used in tests that
+// call t.Parallel().
+func (t *T) FakeUser(
+	username string, uid int, targets ...*func() (*user.User, error),
+) *user.User {
+	u := &user.User{
+		Uid:      strconv.Itoa(uid),
+		Gid:      strconv.Itoa(uid),
+		Username: username,
+		Name:     username,
+		HomeDir:  t.TempDir(),
+	}
+	t.setenv("USER", username)
+	t.setenv("LOGNAME", username)
+	t.setenv("HOME", u.HomeDir)
+	for _, target := range targets {
+		t.Override(target, func() (*user.User, error) {
+			faked := *u
+			return &faked, nil
+		})
+	}
+	return u
+}
+
+// Sets the environment variable key to value, restoring (or unsetting) it
+// when the test finishes.
+func (t *T) setenv(key, value string) {
+	t.AddNamedFinalizer("restore $"+key, t.swapenv(key, value))
+}
+
+// Sets the environment variable key to value, returning a function that
+// restores (or unsets) it.
+func (t *T) swapenv(key, value string) func() {
+	old, existed := os.LookupEnv(key)
+	t.ExpectSuccess(os.Setenv(key, value))
+	return func() {
+		if existed {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"os"
+	"os/user"
+	"testing"
+)
+
+func TestT_FakeHostname(t *testing.T) {
+	m, T := testSetup()
+	hostname := os.Hostname
+	realHost, _ := os.Hostname()
+	oldEnv, hadEnv := os.LookupEnv("HOSTNAME")
+
+	m.CheckPass(t, func() { T.FakeHostname("fake.example.com", &hostname) })
+	if name, err := hostname(); err != nil || name != "fake.example.com" {
+		t.Fatalf("Unexpected hostname: %s, %v", name, err)
+	} else if env := os.Getenv("HOSTNAME"); env != "fake.example.com" {
+		t.Fatalf("Unexpected $HOSTNAME: %s", env)
+	}
+
+	T.Finish()
+	if name, _ := hostname(); name != realHost {
+		t.Fatalf("The hostname was not restored: %s", name)
+	} else if env, has := os.LookupEnv("HOSTNAME"); env != oldEnv || has != hadEnv {
+		t.Fatalf("$HOSTNAME was not restored: %s", env)
+	}
+}
+
+func TestT_FakeUser(t *testing.T) {
+	m, T := testSetup()
+	currentUser := user.Current
+	oldHome := os.Getenv("HOME")
+
+	var u *user.User
+	m.CheckPass(t, func() { u = T.FakeUser("alice", 1234, &currentUser) })
+	if u.Username != "alice" || u.Uid != "1234" || u.Gid != "1234" {
+		t.Fatalf("Unexpected user: %#v", u)
+	} else if stat, err := os.Stat(u.HomeDir); err != nil || !stat.IsDir() {
+		t.Fatalf("The home directory was not created: %v", err)
+	}
+	if have, err := currentUser(); err != nil || *have != *u {
+		t.Fatalf("Unexpected current user: %#v, %v", have, err)
+	}
+	if home, err := os.UserHomeDir(); err != nil || home != u.HomeDir {
+		t.Fatalf("Unexpected home directory: %s, %v", home, err)
+	} else if os.Getenv("USER") != "alice" || os.Getenv("LOGNAME") != "alice" {
+		t.Fatalf("The user environment variables were not set.")
+	}
+
+	T.Finish()
+	if os.Getenv("HOME") != oldHome {
+		t.Fatalf("$HOME was not restored: %s", os.Getenv("HOME"))
+	} else if have, _ := currentUser(); have != nil && have.Username == "alice" {
+		t.Fatalf("The user lookup was not restored.")
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// This file contains functions for comparing images.
+
+// If this environment variable is set then the diff images written by
+// EqualImage() are put in the named directory.
+const imageDiffDirEnv = "TESTLIB_IMAGEDIFF_DIR"
+
+// Compares two images pixel by pixel. A pixel is considered different if
+// any of its red, green, blue or alpha channels differ by more than
+// perChannelTolerance (on a 0-255 scale). The test fails if the images are
+// not the same size or if more than maxDiffPixels pixels differ. On failure
+// a diff image is written with the differing pixels colored red so the
+// difference can be inspected. Unlike most files made by this library the
+// diff image is not removed when the tests finish; it is written to the
+// directory named by the TESTLIB_IMAGEDIFF_DIR environment variable, or to
+// a "testlib-imagediff" directory in the system's temporary directory.
+func (t *T) EqualImage(
+	have, want image.Image, maxDiffPixels int, perChannelTolerance uint8,
+	desc ...string,
+) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	hb, wb := have.Bounds(), want.Bounds()
+	if hb.Dx() != wb.Dx() || hb.Dy() != wb.Dy() {
+		t.Fatalf("%sImages are not the same size.\n  have: %dx%d\n  want: %dx%d",
+			prefix, hb.Dx(), hb.Dy(), wb.Dx(), wb.Dy())
+	}
+
+	diff := image.NewNRGBA(image.Rect(0, 0, wb.Dx(), wb.Dy()))
+	count := 0
+	for y := 0; y < wb.Dy(); y++ {
+		for x := 0; x < wb.Dx(); x++ {
+			hc := color.NRGBAModel.Convert(
+				have.At(hb.Min.X+x, hb.Min.Y+y)).(color.NRGBA)
+			wc := color.NRGBAModel.Convert(
+				want.At(wb.Min.X+x, wb.Min.Y+y)).(color.NRGBA)
+			if channelDiff(hc.R, wc.R) > perChannelTolerance ||
+				channelDiff(hc.G, wc.G) > perChannelTolerance ||
+				channelDiff(hc.B, wc.B) > perChannelTolerance ||
+				channelDiff(hc.A, wc.A) > perChannelTolerance {
+				count++
+				diff.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+			} else {
+				// Matching pixels are drawn faded so the differences
+				// stand out.
+				gray := color.GrayModel.Convert(wc).(color.Gray)
+				diff.SetNRGBA(x, y, color.NRGBA{
+					R: gray.Y, G: gray.Y, B: gray.Y, A: 64})
+			}
+		}
+	}
+	if count <= maxDiffPixels {
+		return
+	}
+
+	dir := imageDiffDir()
+	t.ExpectSuccess(osMkdirAll(dir, os.FileMode(0755)))
+	f, err := ioutilTempFile(dir, t.tempPrefix()+"-imagediff-*.png")
+	t.ExpectSuccess(err)
+	err = png.Encode(f, diff)
+	f.Close()
+	t.ExpectSuccess(err)
+	t.Fatalf("%s%d pixel(s) differ, at most %d allowed.\n"+
+		"A diff image was written to %s\n"+
+		"It is kept after the tests finish, set %s to choose the directory.",
+		prefix, count, maxDiffPixels, f.Name(), imageDiffDirEnv)
+}
+
+// Returns the directory that EqualImage() writes diff images to.
+func imageDiffDir() string {
+	if dir := osGetenv(imageDiffDirEnv); dir != "" {
+		return dir
+	}
+	return filepath.Join(osTempDir(), "testlib-imagediff")
+}
+
+// Compares the image against the PNG golden file with the given name in
+// the testdata directory. If the test binary is run with -testlib.update
+// then the golden file is written from img instead.
+func (t *T) GoldenImage(name string, img image.Image, desc ...string) {
+	path := goldenPath(name)
+	if *updateGolden {
+		t.ExpectSuccess(osMkdirAll(filepath.Dir(path), os.FileMode(0755)))
+		f, err := os.Create(path)
+		t.ExpectSuccess(err)
+		err = png.Encode(f, img)
+		f.Close()
+		t.ExpectSuccess(err)
+		return
+	}
+
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	f, err := osOpen(path)
+	if err != nil {
+		t.Fatalf("%sUnable to open golden image (run with -testlib.update "+
+			"to create it): %s", prefix, err)
+	}
+	want, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("%sUnable to decode golden image %s: %s", prefix, path, err)
+	}
+	t.EqualImage(img, want, 0, 0, desc...)
+}
+
+// Returns the absolute difference between two channel values.
+func channelDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,142 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Returns a solid 4x4 image of the given color.
+func solidImage(c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestT_EqualImage(t *testing.T) {
+	m, T := testSetup()
+	defer T.Finish()
+
+	// Diff images are kept, so they are written somewhere this test
+	// cleans up.
+	dir := T.TempDir()
+	defer func(f func(string) string) { osGetenv = f }(osGetenv)
+	osGetenv = func(key string) string {
+		if key == imageDiffDirEnv {
+			return dir
+		}
+		return os.Getenv(key)
+	}
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	a := solidImage(white)
+	b := solidImage(white)
+	b.SetNRGBA(1, 1, color.NRGBA{R: 250, G: 255, B: 255, A: 255})
+	b.SetNRGBA(2, 2, color.NRGBA{A: 255})
+
+	m.CheckPass(t, func() { T.EqualImage(a, a, 0, 0) })
+	m.CheckPass(t, func() { T.EqualImage(a, b, 1, 10) })
+	m.CheckFail(t, func() { T.EqualImage(a, b, 0, 10, "prefix") })
+	if !strings.HasPrefix(msg, "prefix: ") {
+		t.Fatalf("The prefix was not prepended to the message: '''%s'''", msg)
+	} else if !strings.Contains(msg, "1 pixel(s) differ, at most 0 allowed.") {
+		t.Fatalf("The pixel count was not reported: %s", msg)
+	}
+
+	// The diff image should be written and highlight the changed pixel.
+	match := regexp.MustCompile(`written to (\S+)`).FindStringSubmatch(msg)
+	if match == nil {
+		t.Fatalf("The diff image was not reported: %s", msg)
+	} else if filepath.Dir(match[1]) != dir {
+		t.Fatalf("%s was not honored: %s", imageDiffDirEnv, match[1])
+	} else if !strings.Contains(msg, "It is kept after the tests finish") {
+		t.Fatalf("The message did not say the image is kept: %s", msg)
+	}
+	f, err := os.Open(match[1])
+	if err != nil {
+		t.Fatalf("Unable to open the diff image: %s", err)
+	}
+	diff, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("Unable to decode the diff image: %s", err)
+	} else if c := color.NRGBAModel.Convert(diff.At(2, 2)); c != (color.NRGBA{R: 255, A: 255}) {
+		t.Fatalf("The differing pixel was not highlighted: %v", c)
+	}
+
+	m.CheckFail(t, func() {
+		T.EqualImage(a, image.NewNRGBA(image.Rect(0, 0, 2, 2)), 100, 255)
+	})
+	if !strings.Contains(msg, "Images are not the same size.") {
+		t.Fatalf("The size difference was not reported: %s", msg)
+	}
+}
+
+func TestImageDiffDir(t *testing.T) {
+	defer func(f func(string) string) { osGetenv = f }(osGetenv)
+	defer func(f func() string) { osTempDir = f }(osTempDir)
+	env := ""
+	osGetenv = func(string) string { return env }
+	osTempDir = func() string { return "/tmp" }
+
+	if dir := imageDiffDir(); dir != "/tmp/testlib-imagediff" {
+		t.Fatalf("Unexpected default directory: %s", dir)
+	}
+	env = "/artifacts"
+	if dir := imageDiffDir(); dir != "/artifacts" {
+		t.Fatalf("The environment was not honored: %s", dir)
+	}
+}
+
+func TestT_GoldenImage(t *testing.T) {
+	m, T := testSetup()
+	defer T.Finish()
+
+	defer func(dir string) { goldenDir = dir }(goldenDir)
+	goldenDir = T.TempDir()
+	defer func(update bool) { *updateGolden = update }(*updateGolden)
+
+	img := solidImage(color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+
+	// Missing golden files fail.
+	*updateGolden = false
+	m.CheckFail(t, func() { T.GoldenImage("img.png", img) })
+
+	// Updating writes the file, after which it matches.
+	*updateGolden = true
+	m.CheckPass(t, func() { T.GoldenImage("img.png", img) })
+	*updateGolden = false
+	m.CheckPass(t, func() { T.GoldenImage("img.png", img) })
+	m.CheckFail(t, func() {
+		T.GoldenImage("img.png", solidImage(color.NRGBA{A: 255}))
+	})
+}
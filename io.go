@@ -0,0 +1,202 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file contains io.Reader and io.Writer test doubles that make it easy
+// to exercise the error paths of code that reads or writes data.
+
+// This is the error returned by the test doubles in this file when they
+// inject a failure that was not supplied by the caller.
+var ErrInjected = errors.New("testlib: injected failure")
+
+// Returns an io.Reader that returns the given error from every call to
+// Read(). If err is nil then ErrInjected is returned instead.
+func (t *T) ErrReader(err error) io.Reader {
+	if err == nil {
+		err = ErrInjected
+	}
+	return &errReader{err: err}
+}
+
+// Returns an io.Reader that will return the contents of data one byte at a
+// time, sleeping for delayPerByte before each byte is returned. Once all of
+// the data has been read this returns io.EOF.
+func (t *T) SlowReader(data []byte, delayPerByte time.Duration) io.Reader {
+	return &slowReader{data: data, delay: delayPerByte}
+}
+
+// Returns an io.Writer that will accept at most n bytes in total. Any write
+// that would exceed this limit will write as much as possible and then
+// return io.ErrShortWrite.
+func (t *T) ShortWriter(n int) io.Writer {
+	return &shortWriter{remaining: n}
+}
+
+// Returns an io.Reader that returns the contents of data normally until
+// failAt bytes have been read, at which point all further calls to Read()
+// will return ErrInjected. A negative failAt is treated as zero so the
+// first Read() fails.
+func (t *T) FlakyReader(data []byte, failAt int) io.Reader {
+	if failAt < 0 {
+		failAt = 0
+	} else if failAt > len(data) {
+		failAt = len(data)
+	}
+	return &flakyReader{data: data[:failAt]}
+}
+
+// Returns a RecordWriter that buffers all data written to it so that the
+// output of streaming or serialization code can be asserted against.
+func (t *T) RecordWriter() *RecordWriter {
+	return &RecordWriter{t: t}
+}
+
+// An io.Writer that records everything written to it. This is returned from
+// T.RecordWriter() and is safe to use from multiple goroutines.
+type RecordWriter struct {
+	t      *T
+	lock   sync.Mutex
+	buffer bytes.Buffer
+	writes int
+}
+
+// Implements io.Writer. This never returns an error.
+func (r *RecordWriter) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.writes++
+	return r.buffer.Write(p)
+}
+
+// Returns a copy of all of the data written so far.
+func (r *RecordWriter) Bytes() []byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]byte(nil), r.buffer.Bytes()...)
+}
+
+// Returns all of the data written so far as a string.
+func (r *RecordWriter) String() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.buffer.String()
+}
+
+// Fails the test if substr has not been written to the RecordWriter.
+func (r *RecordWriter) ExpectWritten(substr string, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	if have := r.String(); !strings.Contains(have, substr) {
+		r.t.Fatalf("%sExpected data was not written.\n"+
+			"  have: %#v\n  want substring: %#v", prefix, have, substr)
+	}
+}
+
+// Fails the test if Write() has not been called exactly n times.
+func (r *RecordWriter) ExpectWriteCount(n int, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	r.lock.Lock()
+	writes := r.writes
+	r.lock.Unlock()
+	if writes != n {
+		r.t.Fatalf("%sUnexpected number of writes.\n  have: %d\n  want: %d",
+			prefix, writes, n)
+	}
+}
+
+// Fails the test if exactly n bytes have not been written.
+func (r *RecordWriter) ExpectTotalBytes(n int, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	r.lock.Lock()
+	total := r.buffer.Len()
+	r.lock.Unlock()
+	if total != n {
+		r.t.Fatalf("%sUnexpected number of bytes written.\n"+
+			"  have: %d\n  want: %d", prefix, total, n)
+	}
+}
+
+// Always returns the configured error.
+type errReader struct {
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	return 0, e.err
+}
+
+// Returns data one byte at a time.
+type slowReader struct {
+	data  []byte
+	delay time.Duration
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	if len(s.data) == 0 {
+		return 0, io.EOF
+	} else if len(p) == 0 {
+		return 0, nil
+	}
+	time.Sleep(s.delay)
+	p[0] = s.data[0]
+	s.data = s.data[1:]
+	return 1, nil
+}
+
+// Accepts a limited number of bytes before failing.
+type shortWriter struct {
+	remaining int
+}
+
+func (s *shortWriter) Write(p []byte) (int, error) {
+	if len(p) <= s.remaining {
+		s.remaining -= len(p)
+		return len(p), nil
+	}
+	n := s.remaining
+	s.remaining = 0
+	return n, io.ErrShortWrite
+}
+
+// Returns the data given and then fails rather than returning io.EOF.
+type flakyReader struct {
+	data []byte
+}
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	if len(f.data) == 0 {
+		return 0, ErrInjected
+	}
+	n := copy(p, f.data)
+	f.data = f.data[n:]
+	return n, nil
+}
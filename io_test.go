@@ -0,0 +1,127 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestT_ErrReader(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+
+	err := fmt.Errorf("expected")
+	if _, rerr := T.ErrReader(err).Read(make([]byte, 10)); rerr != err {
+		t.Fatalf("Wrong error returned: %v", rerr)
+	}
+	if _, rerr := T.ErrReader(nil).Read(make([]byte, 10)); rerr != ErrInjected {
+		t.Fatalf("Wrong error returned: %v", rerr)
+	}
+}
+
+func TestT_SlowReader(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+
+	start := time.Now()
+	data, err := ioutil.ReadAll(T.SlowReader([]byte("abcde"), time.Millisecond))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	} else if string(data) != "abcde" {
+		t.Fatalf("Wrong data returned: %s", data)
+	} else if time.Since(start) < 5*time.Millisecond {
+		t.Fatalf("Reader returned data too quickly.")
+	}
+}
+
+func TestT_ShortWriter(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+
+	w := T.ShortWriter(5)
+	if n, err := w.Write([]byte("abc")); n != 3 || err != nil {
+		t.Fatalf("Unexpected write results: %d, %v", n, err)
+	}
+	if n, err := w.Write([]byte("def")); n != 2 || err != io.ErrShortWrite {
+		t.Fatalf("Unexpected write results: %d, %v", n, err)
+	}
+	if n, err := w.Write([]byte("g")); n != 0 || err != io.ErrShortWrite {
+		t.Fatalf("Unexpected write results: %d, %v", n, err)
+	}
+}
+
+func TestT_FlakyReader(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+
+	data, err := ioutil.ReadAll(T.FlakyReader([]byte("abcdef"), 4))
+	if err != ErrInjected {
+		t.Fatalf("Wrong error returned: %v", err)
+	} else if string(data) != "abcd" {
+		t.Fatalf("Wrong data returned: %s", data)
+	}
+
+	// failAt beyond the end of the data still fails rather than EOF.
+	data, err = ioutil.ReadAll(T.FlakyReader([]byte("ab"), 10))
+	if err != ErrInjected {
+		t.Fatalf("Wrong error returned: %v", err)
+	} else if string(data) != "ab" {
+		t.Fatalf("Wrong data returned: %s", data)
+	}
+
+	// A negative failAt fails the first read.
+	data, err = ioutil.ReadAll(T.FlakyReader([]byte("ab"), -1))
+	if err != ErrInjected {
+		t.Fatalf("Wrong error returned: %v", err)
+	} else if len(data) != 0 {
+		t.Fatalf("Wrong data returned: %s", data)
+	}
+}
+
+func TestT_RecordWriter(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	w := T.RecordWriter()
+	fmt.Fprintf(w, "hello ")
+	fmt.Fprintf(w, "world")
+	if w.String() != "hello world" {
+		t.Fatalf("Wrong data recorded: %s", w.String())
+	} else if string(w.Bytes()) != "hello world" {
+		t.Fatalf("Wrong data recorded: %s", w.Bytes())
+	}
+
+	m.CheckPass(t, func() { w.ExpectWritten("lo wo") })
+	m.CheckFail(t, func() { w.ExpectWritten("goodbye") })
+	m.CheckPass(t, func() { w.ExpectWriteCount(2) })
+	m.CheckFail(t, func() { w.ExpectWriteCount(1) })
+	m.CheckPass(t, func() { w.ExpectTotalBytes(11) })
+	m.CheckFail(t, func() { w.ExpectTotalBytes(10) })
+
+	// Ensure that the prefix is added to the failure message.
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+	m.CheckFail(t, func() { w.ExpectWritten("goodbye", "prefix") })
+	if !strings.HasPrefix(msg, "prefix: ") {
+		t.Fatalf("The prefix was not prepended to the message: '''%s'''", msg)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// This file contains functions for keeping parallel tests from colliding on
+// shared resources.
+
+// Unique names for the resources a test creates in shared external systems.
+// This is returned from T.Isolation(). Every name contains the test's name,
+// so leftovers can be traced back to the test that created them, and the
+// same random suffix, so tests running in parallel (or in other test runs
+// against the same system) never collide. Each name is sanitized to the
+// rules of the system it is intended for.
+type Isolation struct {
+	// The random suffix shared by every name.
+	Suffix string
+
+	// A general purpose prefix made of lower case letters, digits and
+	// underscores.
+	Prefix string
+
+	// A database schema or table name: lower case letters, digits and
+	// underscores, at most 63 characters (the PostgreSQL limit).
+	Schema string
+
+	// A Kafka topic or AMQP queue name: letters, digits, dots, underscores
+	// and hyphens, at most 249 characters.
+	Topic string
+
+	// An S3 bucket name: lower case letters, digits and hyphens, starting
+	// with a letter and at most 63 characters.
+	Bucket string
+
+	// A file name: letters, digits, dots, underscores and hyphens, at most
+	// 200 characters.
+	File string
+}
+
+// Characters that are replaced in each kind of name.
+var (
+	isolationLowerInvalid  = regexp.MustCompile(`[^a-z0-9_]+`)
+	isolationTopicInvalid  = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+	isolationBucketInvalid = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// Returns a new set of unique names for the test. Every call returns names
+// with a new random suffix. The suffix comes from crypto/rand rather than
+// Seed() so that rerunning a test with the same seed does not reuse names
+// that may still exist from the previous run.
+func (t *T) Isolation() *Isolation {
+	random := make([]byte, 4)
+	_, err := rand.Read(random)
+	t.ExpectSuccess(err, "Unable to generate a random suffix")
+	suffix := hex.EncodeToString(random)
+	name := t.Name()
+	lower := strings.ToLower(name)
+	return &Isolation{
+		Suffix: suffix,
+		Prefix: isolatedName(lower, suffix, "_", 0, isolationLowerInvalid),
+		Schema: isolatedName(lower, suffix, "_", 63, isolationLowerInvalid),
+		Topic:  isolatedName(name, suffix, "-", 249, isolationTopicInvalid),
+		Bucket: "t-" + isolatedName(lower, suffix, "-", 61, isolationBucketInvalid),
+		File:   isolatedName(name, suffix, "-", 200, isolationTopicInvalid),
+	}
+}
+
+// Returns Prefix followed by the sanitized kind, for naming additional
+// resources like "users" or "orders".
+func (i *Isolation) Name(kind string) string {
+	return i.Prefix + "_" + strings.Trim(isolationLowerInvalid.ReplaceAllString(
+		strings.ToLower(kind), "_"), "_")
+}
+
+// Joins name and suffix with sep after replacing the characters in name
+// that match invalid with sep. The name is shortened so the result is no
+// more than max characters (if max is not zero) while keeping the whole
+// suffix.
+func isolatedName(
+	name, suffix, sep string, max int, invalid *regexp.Regexp,
+) string {
+	name = strings.Trim(invalid.ReplaceAllString(name, sep), sep)
+	if keep := max - len(suffix) - len(sep); max > 0 && len(name) > keep {
+		name = strings.TrimRight(name[:keep], sep)
+	}
+	if name == "" {
+		return suffix
+	}
+	return name + sep + suffix
+}
@@ -0,0 +1,69 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestT_Isolation(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+	defer T.Finish()
+
+	a := T.Isolation()
+	b := T.Isolation()
+	if a.Suffix == b.Suffix || a.Prefix == b.Prefix {
+		t.Fatalf("Two calls returned the same names: %#v", a)
+	}
+	for _, check := range []struct {
+		name    string
+		value   string
+		pattern string
+	}{
+		{"Prefix", a.Prefix, `^testt_isolation_[0-9a-f]{8}$`},
+		{"Schema", a.Schema, `^testt_isolation_[0-9a-f]{8}$`},
+		{"Topic", a.Topic, `^TestT_Isolation-[0-9a-f]{8}$`},
+		{"Bucket", a.Bucket, `^t-testt-isolation-[0-9a-f]{8}$`},
+		{"File", a.File, `^TestT_Isolation-[0-9a-f]{8}$`},
+	} {
+		if !regexp.MustCompile(check.pattern).MatchString(check.value) {
+			t.Fatalf("%s %q does not match %s", check.name, check.value,
+				check.pattern)
+		}
+	}
+	if name := a.Name("Order Items"); name != a.Prefix+"_order_items" {
+		t.Fatalf("Unexpected name: %s", name)
+	}
+}
+
+func TestIsolatedName(t *testing.T) {
+	t.Parallel()
+	long := "Test/" + strings.Repeat("x", 100)
+	name := isolatedName(strings.ToLower(long), "abcd1234", "_", 63,
+		isolationLowerInvalid)
+	if len(name) != 63 {
+		t.Fatalf("Expected 63 characters, got %d: %s", len(name), name)
+	} else if !strings.HasPrefix(name, "test_xxx") ||
+		!strings.HasSuffix(name, "x_abcd1234") {
+		t.Fatalf("Unexpected name: %s", name)
+	}
+	if name := isolatedName("///", "abcd1234", "-", 0,
+		isolationBucketInvalid); name != "abcd1234" {
+		t.Fatalf("Unexpected name: %s", name)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// This file contains functions for rendering values as Go source code.
+
+// If this environment variable is set to a non empty value then Equal
+// failures always include the have value as a Go literal.
+const printLiteralEnv = "TESTLIB_PRINT_LITERAL"
+
+// If enabled then when Equal() fails the have value is also printed as a
+// ready to paste Go composite literal. This makes it quick to update an
+// expected value after an intentional change. This can also be enabled by
+// setting the TESTLIB_PRINT_LITERAL environment variable.
+func (t *T) SetPrintLiteral(enabled bool) {
+	t.printLiteral = enabled
+}
+
+// Returns true if Go literals should be printed on Equal failures.
+func (t *T) printLiterals() bool {
+	return t.printLiteral || osGetenv(printLiteralEnv) != ""
+}
+
+// Renders the given value as Go source code that would construct it. Values
+// that can not be represented in source (functions, channels, unsafe
+// pointers) are rendered as nil with a comment explaining why. Unexported
+// struct fields can not be set from another package so they are left out,
+// with a comment noting that they were.
+func goLiteral(v reflect.Value) string {
+	l := &literalWriter{visited: make(map[uintptr]bool)}
+	l.write(v, false)
+	return l.buffer.String()
+}
+
+// Tracks the state of rendering a single literal.
+type literalWriter struct {
+	buffer  bytes.Buffer
+	indent  int
+	visited map[uintptr]bool
+}
+
+// Starts a new line at the current indentation level.
+func (l *literalWriter) newline() {
+	l.buffer.WriteString("\n")
+	l.buffer.WriteString(strings.Repeat("\t", l.indent))
+}
+
+// Writes a basic value, wrapping it in a type conversion if the type is
+// not the default type Go would infer for the constant.
+func (l *literalWriter) writeBasic(v reflect.Value, s string, def reflect.Kind) {
+	if v.Type().Name() == def.String() && v.Type().PkgPath() == "" {
+		l.buffer.WriteString(s)
+	} else {
+		fmt.Fprintf(&l.buffer, "%s(%s)", v.Type(), s)
+	}
+}
+
+// Writes the given value. If elide is true then the type of a composite
+// literal is omitted since it is implied by the enclosing literal.
+func (l *literalWriter) write(v reflect.Value, elide bool) {
+	if !v.IsValid() {
+		l.buffer.WriteString("nil")
+		return
+	}
+
+	// time.Time has no exported fields so it is special cased.
+	if v.Type() == reflect.TypeOf(time.Time{}) && v.CanInterface() {
+		tm := v.Interface().(time.Time)
+		if tm.Location() == time.UTC {
+			fmt.Fprintf(&l.buffer,
+				"time.Date(%d, time.%s, %d, %d, %d, %d, %d, time.UTC)",
+				tm.Year(), tm.Month(), tm.Day(), tm.Hour(), tm.Minute(),
+				tm.Second(), tm.Nanosecond())
+		} else {
+			fmt.Fprintf(&l.buffer, "time.Unix(%d, %d)",
+				tm.Unix(), tm.Nanosecond())
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		l.writeBasic(v, strconv.FormatBool(v.Bool()), reflect.Bool)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		l.writeBasic(v, strconv.FormatInt(v.Int(), 10), reflect.Int)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		l.writeBasic(v, strconv.FormatUint(v.Uint(), 10), reflect.Invalid)
+
+	case reflect.Float32, reflect.Float64:
+		s := ""
+		switch f := v.Float(); {
+		case math.IsNaN(f):
+			s = "math.NaN()"
+		case math.IsInf(f, 1):
+			s = "math.Inf(1)"
+		case math.IsInf(f, -1):
+			s = "math.Inf(-1)"
+		default:
+			s = strconv.FormatFloat(f, 'g', -1, v.Type().Bits())
+			if !strings.ContainsAny(s, ".eE") {
+				s += ".0"
+			}
+		}
+		l.writeBasic(v, s, reflect.Float64)
+
+	case reflect.Complex64, reflect.Complex128:
+		l.writeBasic(v, fmt.Sprintf("%v", v.Complex()), reflect.Complex128)
+
+	case reflect.String:
+		l.writeBasic(v, strconv.Quote(v.String()), reflect.String)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			l.buffer.WriteString("nil")
+		} else {
+			l.write(v.Elem(), false)
+		}
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			l.buffer.WriteString("nil")
+			return
+		} else if l.visited[v.Pointer()] {
+			l.buffer.WriteString("nil /* cycle */")
+			return
+		}
+		l.visited[v.Pointer()] = true
+		defer delete(l.visited, v.Pointer())
+		switch v.Elem().Kind() {
+		case reflect.Struct, reflect.Slice, reflect.Map, reflect.Array:
+			if !elide {
+				l.buffer.WriteString("&")
+			}
+			l.write(v.Elem(), elide)
+		default:
+			// Pointers to basic types can not be written as a literal so
+			// a function is used to allocate the value.
+			fmt.Fprintf(&l.buffer, "func() %s { v := ", v.Type())
+			l.write(v.Elem(), false)
+			l.buffer.WriteString("; return &v }()")
+		}
+
+	case reflect.Slice:
+		if v.IsNil() {
+			fmt.Fprintf(&l.buffer, "%s(nil)", v.Type())
+			return
+		} else if v.Type().Elem().Kind() == reflect.Uint8 {
+			if b := v.Bytes(); utf8.Valid(b) {
+				fmt.Fprintf(&l.buffer, "%s(%s)",
+					v.Type(), strconv.Quote(string(b)))
+				return
+			}
+		}
+		l.writeList(v, elide)
+
+	case reflect.Array:
+		l.writeList(v, elide)
+
+	case reflect.Map:
+		if v.IsNil() {
+			fmt.Fprintf(&l.buffer, "%s(nil)", v.Type())
+			return
+		}
+		if !elide {
+			l.buffer.WriteString(v.Type().String())
+		}
+		l.buffer.WriteString("{")
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		elideKey := isComposite(v.Type().Key())
+		for _, k := range v.MapKeys() {
+			kl := &literalWriter{indent: l.indent + 1, visited: l.visited}
+			kl.write(k, elideKey)
+			keys = append(keys, kl.buffer.String())
+			values[kl.buffer.String()] = v.MapIndex(k)
+		}
+		sort.Strings(keys)
+		elideValue := isComposite(v.Type().Elem())
+		l.indent++
+		for _, k := range keys {
+			l.newline()
+			l.buffer.WriteString(k)
+			l.buffer.WriteString(": ")
+			l.write(values[k], elideValue)
+			l.buffer.WriteString(",")
+		}
+		l.indent--
+		if len(keys) > 0 {
+			l.newline()
+		}
+		l.buffer.WriteString("}")
+
+	case reflect.Struct:
+		if !elide {
+			l.buffer.WriteString(v.Type().String())
+		}
+		l.buffer.WriteString("{")
+		wrote := false
+		unexported := false
+		l.indent++
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if isZero(field) {
+				continue
+			} else if v.Type().Field(i).PkgPath != "" {
+				unexported = true
+				continue
+			}
+			wrote = true
+			l.newline()
+			l.buffer.WriteString(v.Type().Field(i).Name)
+			l.buffer.WriteString(": ")
+			l.write(field, false)
+			l.buffer.WriteString(",")
+		}
+		if unexported {
+			wrote = true
+			l.newline()
+			l.buffer.WriteString("// unexported fields omitted")
+		}
+		l.indent--
+		if wrote {
+			l.newline()
+		}
+		l.buffer.WriteString("}")
+
+	default:
+		fmt.Fprintf(&l.buffer, "nil /* %s can not be represented */", v.Type())
+	}
+}
+
+// Writes a slice or array.
+func (l *literalWriter) writeList(v reflect.Value, elide bool) {
+	if !elide {
+		l.buffer.WriteString(v.Type().String())
+	}
+	l.buffer.WriteString("{")
+	elideElem := isComposite(v.Type().Elem())
+	l.indent++
+	for i := 0; i < v.Len(); i++ {
+		l.newline()
+		l.write(v.Index(i), elideElem)
+		l.buffer.WriteString(",")
+	}
+	l.indent--
+	if v.Len() > 0 {
+		l.newline()
+	}
+	l.buffer.WriteString("}")
+}
+
+// Returns true if values of the given type are written as composite
+// literals whose type can be elided inside of another composite literal.
+func isComposite(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		return false
+	}
+	switch typ.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// Returns true if the value is the zero value for its type.
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !isZero(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isZero(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Complex64, reflect.Complex128:
+		return v.Complex() == 0
+	case reflect.String:
+		return v.Len() == 0
+	case reflect.UnsafePointer:
+		return v.Pointer() == 0
+	}
+	return v.IsNil()
+}
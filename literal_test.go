@@ -0,0 +1,128 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testLiteralStruct struct {
+	Name     string
+	Count    int64
+	Tags     []string
+	Children []*testLiteralStruct
+	Attrs    map[string]interface{}
+	Created  time.Time
+	private  *int
+}
+
+func TestGoLiteral(t *testing.T) {
+	t.Parallel()
+
+	five := 5
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, "nil"},
+		{1, "1"},
+		{int8(2), "int8(2)"},
+		{uint(3), "uint(3)"},
+		{1.5, "1.5"},
+		{float64(2), "2.0"},
+		{float32(2.5), "float32(2.5)"},
+		{math.NaN(), "math.NaN()"},
+		{math.Inf(1), "math.Inf(1)"},
+		{float32(math.Inf(-1)), "float32(math.Inf(-1))"},
+		{"str\n", `"str\n"`},
+		{true, "true"},
+		{[]byte("abc"), `[]uint8("abc")`},
+		{[]int(nil), "[]int(nil)"},
+		{[]int{}, "[]int{}"},
+		{[]int{1, 2}, "[]int{\n\t1,\n\t2,\n}"},
+		{[2]bool{true, false}, "[2]bool{\n\ttrue,\n\tfalse,\n}"},
+		{map[string]int{"b": 2, "a": 1}, "map[string]int{\n\t\"a\": 1,\n\t\"b\": 2,\n}"},
+		{&five, "func() *int { v := 5; return &v }()"},
+		{time.Date(2020, time.March, 4, 5, 6, 7, 8, time.UTC),
+			"time.Date(2020, time.March, 4, 5, 6, 7, 8, time.UTC)"},
+		{make(chan int), "nil /* chan int can not be represented */"},
+		{
+			&testLiteralStruct{
+				Name:     "parent",
+				Tags:     []string{"a"},
+				Children: []*testLiteralStruct{{Name: "child"}},
+				Attrs:    map[string]interface{}{"x": int64(1)},
+				private:  &five,
+			},
+			strings.Join([]string{
+				"&testlib.testLiteralStruct{",
+				"\tName: \"parent\",",
+				"\tTags: []string{",
+				"\t\t\"a\",",
+				"\t},",
+				"\tChildren: []*testlib.testLiteralStruct{",
+				"\t\t{",
+				"\t\t\tName: \"child\",",
+				"\t\t},",
+				"\t},",
+				"\tAttrs: map[string]interface {}{",
+				"\t\t\"x\": int64(1),",
+				"\t},",
+				"\t// unexported fields omitted",
+				"}",
+			}, "\n"),
+		},
+	}
+	for _, test := range tests {
+		if have := goLiteral(reflect.ValueOf(test.value)); have != test.want {
+			t.Errorf("Wrong literal for %#v:\nhave: %s\nwant: %s",
+				test.value, have, test.want)
+		}
+	}
+
+	// Cycles are broken rather than recursing forever.
+	node := &testLiteralStruct{Name: "a"}
+	node.Children = []*testLiteralStruct{node}
+	if have := goLiteral(reflect.ValueOf(node)); !strings.Contains(
+		have, "nil /* cycle */") {
+		t.Fatalf("Cycle was not detected: %s", have)
+	}
+}
+
+func TestT_SetPrintLiteral(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	m.CheckFail(t, func() { T.Equal([]int{1}, []int{2}) })
+	if strings.Contains(msg, "Go literal") {
+		t.Fatalf("Literal was printed when not enabled: %s", msg)
+	}
+
+	T.SetPrintLiteral(true)
+	m.CheckFail(t, func() { T.Equal([]int{1}, []int{2}) })
+	if !strings.Contains(msg, "have as a Go literal:\n[]int{\n\t1,\n}") {
+		t.Fatalf("Literal was not printed: %s", msg)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"time"
+)
+
+// This file contains functions for running code in a specific timezone or
+// locale.
+
+// Calls fn with the local timezone set to the named location (for example
+// "America/New_York" or "UTC"), restoring the original afterwards. Both
+// time.Local and the TZ environment variable are set so code formatting or
+// parsing local times behaves the same regardless of how the machine
+// running the test is configured. The test fails if the location can not
+// be loaded.
+//
+// Since time.Local is shared by the whole process this should not be used
+// in tests that call t.Parallel().
+func (t *T) WithTimezone(name string, fn func()) {
+	loc, err := time.LoadLocation(name)
+	t.ExpectSuccess(err, "Unable to load timezone", name)
+	restoreEnv := t.swapenv("TZ", name)
+	old := time.Local
+	time.Local = loc
+	defer func() {
+		time.Local = old
+		restoreEnv()
+	}()
+	fn()
+}
+
+// Calls fn with the locale environment variables (LC_ALL and LANG) set to
+// locale, for example "de_DE.UTF-8", restoring them afterwards. Go itself
+// ignores the locale but subprocesses, and libraries that honor these
+// variables, do not.
+//
+// Since the environment is shared by the whole process this should not be
+// used in tests that call t.Parallel().
+func (t *T) WithLocale(locale string, fn func()) {
+	restoreAll := t.swapenv("LC_ALL", locale)
+	defer restoreAll()
+	restoreLang := t.swapenv("LANG", locale)
+	defer restoreLang()
+	fn()
+}
@@ -0,0 +1,71 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestT_WithTimezone(t *testing.T) {
+	m, T := testSetup()
+	oldLocal := time.Local
+	oldTZ, hadTZ := os.LookupEnv("TZ")
+
+	instant := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.CheckPass(t, func() {
+		T.WithTimezone("Asia/Tokyo", func() {
+			if have := instant.Local().Format("15:04 MST"); have != "21:00 JST" {
+				t.Errorf("Unexpected local time: %s", have)
+			} else if os.Getenv("TZ") != "Asia/Tokyo" {
+				t.Errorf("Unexpected $TZ: %s", os.Getenv("TZ"))
+			}
+		})
+	})
+	if time.Local != oldLocal {
+		t.Fatalf("time.Local was not restored.")
+	} else if tz, has := os.LookupEnv("TZ"); tz != oldTZ || has != hadTZ {
+		t.Fatalf("$TZ was not restored: %s", tz)
+	}
+
+	m.CheckFail(t, func() {
+		T.WithTimezone("Not/AZone", func() {
+			t.Errorf("The function should not have been called.")
+		})
+	})
+}
+
+func TestT_WithLocale(t *testing.T) {
+	m, T := testSetup()
+	oldLang := os.Getenv("LANG")
+
+	m.CheckPass(t, func() {
+		T.WithLocale("de_DE.UTF-8", func() {
+			out, err := exec.Command("sh", "-c", "echo $LC_ALL $LANG").Output()
+			if err != nil {
+				t.Errorf("Unable to run sh: %s", err)
+			} else if have := strings.TrimSpace(string(out)); have !=
+				"de_DE.UTF-8 de_DE.UTF-8" {
+				t.Errorf("Unexpected locale: %s", have)
+			}
+		})
+	})
+	if os.Getenv("LANG") != oldLang {
+		t.Fatalf("$LANG was not restored: %s", os.Getenv("LANG"))
+	}
+}
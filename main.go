@@ -0,0 +1,143 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"os"
+	"testing"
+)
+
+// This file contains a helper for implementing TestMain.
+
+// Configures the behavior of Main().
+type MainOption func(*mainOptions)
+
+// The options that can be set via MainOption functions.
+type mainOptions struct {
+	rootTempDir bool
+	fdLeakCheck bool
+}
+
+// Prevents Main() from creating the root temporary directory before the
+// tests start. It will still be created on demand by the first test that
+// needs it.
+func WithoutRootTempDir() MainOption {
+	return func(o *mainOptions) { o.rootTempDir = false }
+}
+
+// Makes Main() check that no file descriptors were leaked by the test run
+// as a whole. If any are found they are reported and the process exits
+// with a non zero status.
+func WithFDLeakCheck() MainOption {
+	return func(o *mainOptions) { o.fdLeakCheck = true }
+}
+
+// This is the part of testing.M that Main() uses.
+type testingM interface {
+	Run() int
+}
+
+// Runs the tests in the package and then exits the process. This wires up
+// the library's lifecycle so that a package's TestMain can be as simple as:
+//
+//	func TestMain(m *testing.M) {
+//		testlib.Main(m)
+//	}
+//
+// Before the tests are run the root temporary directory (and its cleanup
+// process) is created so a failure is reported once, up front. The one
+// exception is when no temporary storage is writable at all, in which case
+// the tests are run and those that need it are skipped. After the
+// tests have finished the coverage of processes started by T.Exec() and
+// T.ForkTest() is merged into the coverage profile, all shared fixtures are
+// torn down and any execution trace started by T.Trace() is flushed. The
+// exit code returned from m.Run() is preserved so coverage and failure
+// reporting work as normal; it is only changed if the tests passed but
+// teardown failed.
+func Main(m *testing.M, opts ...MainOption) {
+	osExit(runMain(m, opts...))
+}
+
+// Implements Main() returning the exit code rather than exiting.
+func runMain(m testingM, opts ...MainOption) int {
+	options := mainOptions{rootTempDir: true}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	// If there is no writable temporary storage at all then the failure is
+	// left to the first call to RootTempDir(), which skips the test that
+	// made it rather than failing every test in the binary.
+	if options.rootTempDir {
+		err := CheckCleanupProcess()
+		if _, ok := err.(*tempUnavailableError); ok {
+			err = nil
+		}
+		if err != nil {
+			fmtFprintf(os.Stderr,
+				"testlib: Unable to create the root temporary directory: %s\n",
+				err)
+			return 1
+		}
+	}
+
+	var before map[int]string
+	if options.fdLeakCheck {
+		var err error
+		if before, err = openFDs(); err != nil {
+			fmtFprintf(os.Stderr,
+				"testlib: Unable to check for file descriptor leaks: %s\n", err)
+		}
+	}
+
+	code := m.Run()
+	failed := false
+
+	if err := mergeChildCoverage(); err != nil {
+		fmtFprintf(os.Stderr,
+			"testlib: Unable to merge subprocess coverage: %s\n", err)
+		failed = true
+	}
+
+	if err := TearDownSharedFixtures(); err != nil {
+		fmtFprintf(os.Stderr, "testlib: %s\n", err)
+		failed = true
+	}
+
+	if err := stopTrace(); err != nil {
+		fmtFprintf(os.Stderr,
+			"testlib: Unable to write the execution trace: %s\n", err)
+		failed = true
+	}
+
+	if before != nil {
+		after, err := openFDs()
+		if err != nil {
+			fmtFprintf(os.Stderr,
+				"testlib: Unable to check for file descriptor leaks: %s\n", err)
+			failed = true
+		}
+		for _, fd := range leakedFDs(before, after) {
+			fmtFprintf(os.Stderr, "testlib: file descriptor %d leaked: %s\n",
+				fd, after[fd])
+			failed = true
+		}
+	}
+
+	if code == 0 && failed {
+		code = 1
+	}
+	return code
+}
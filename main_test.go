@@ -0,0 +1,137 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A fake testing.M that runs a function.
+type fakeM struct {
+	run func() int
+}
+
+func (f *fakeM) Run() int {
+	return f.run()
+}
+
+func TestRunMain(t *testing.T) {
+	defer func() { fmtFprintf = fmt.Fprintf }()
+	output := ""
+	fmtFprintf = func(w io.Writer, s string, args ...interface{}) (int, error) {
+		output += fmt.Sprintf(s, args...)
+		return 0, nil
+	}
+
+	// The exit code from Run() is preserved and the root temp dir exists
+	// before the tests are run.
+	if code := runMain(&fakeM{run: func() int {
+		if testLibRootDir == "" {
+			t.Errorf("The root temporary directory was not created.")
+		}
+		return 7
+	}}); code != 7 {
+		t.Fatalf("Wrong exit code returned: %d", code)
+	}
+
+	// Shared fixtures are torn down after the tests run.
+	tornDown := false
+	f := SharedFixture("TestRunMain", func() (interface{}, func()) {
+		return nil, func() { tornDown = true }
+	})
+	if code := runMain(&fakeM{run: func() int {
+		_, T := testSetup()
+		f.Get(T)
+		T.Finish()
+		return 0
+	}}, WithoutRootTempDir()); code != 0 {
+		t.Fatalf("Wrong exit code returned: %d", code)
+	} else if !tornDown {
+		t.Fatalf("Shared fixtures were not torn down.")
+	}
+
+	// Leaked descriptors cause a passing run to fail.
+	if _, err := os.Stat(fdDirs[0]); err != nil {
+		t.Skipf("%s is not available on this platform.", fdDirs[0])
+	}
+	var fd *os.File
+	code := runMain(&fakeM{run: func() int {
+		var err error
+		if fd, err = os.Open(os.Args[0]); err != nil {
+			t.Fatalf("Error opening file: %s", err)
+		}
+		return 0
+	}}, WithFDLeakCheck())
+	fd.Close()
+	if code != 1 {
+		t.Fatalf("Wrong exit code returned: %d", code)
+	} else if !strings.Contains(output, "leaked") {
+		t.Fatalf("The leak was not reported: %s", output)
+	}
+}
+
+func TestRunMain_TempUnavailable(t *testing.T) {
+	defer func() { fmtFprintf = fmt.Fprintf }()
+	output := ""
+	fmtFprintf = func(w io.Writer, s string, args ...interface{}) (int, error) {
+		output += fmt.Sprintf(s, args...)
+		return 0, nil
+	}
+
+	// TMPDIR is below a regular file so nothing can be created in it.
+	file, err := ioutil.TempFile("", "testlib-unwritable")
+	if err != nil {
+		t.Fatalf("Unable to create a file: %s", err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	t.Setenv("TMPDIR", filepath.Join(file.Name(), "tmp"))
+	defer func(dirs []string) { fallbackTempDirs = dirs }(fallbackTempDirs)
+	fallbackTempDirs = nil
+
+	testLibRootDirLock.Lock()
+	oldDir, oldState, oldErr :=
+		testLibRootDir, testLibCleanupState, testLibCleanupErr
+	testLibRootDir = ""
+	testLibRootDirLock.Unlock()
+	defer func() {
+		testLibRootDirLock.Lock()
+		testLibRootDir, testLibCleanupState, testLibCleanupErr =
+			oldDir, oldState, oldErr
+		testLibRootDirLock.Unlock()
+	}()
+
+	// The tests still run, and the ones that need temporary storage skip.
+	ran := false
+	code := runMain(&fakeM{run: func() int {
+		ran = true
+		m, T := testSetup()
+		m.CheckSkips(t, func() { T.RootTempDir() })
+		return 0
+	}})
+	if code != 0 {
+		t.Fatalf("Wrong exit code returned: %d (%s)", code, output)
+	} else if !ran {
+		t.Fatalf("The tests were not run.")
+	} else if output != "" {
+		t.Fatalf("Unexpected output: %s", output)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// This file contains functions for writing encoded values to temporary
+// files.
+
+// Marshals v as indented JSON and writes it to a temporary file ending in
+// .json, returning its path. The file is removed when the test finishes.
+// This pairs with code under test that takes the path of a configuration
+// file.
+func (t *T) WriteTempJSON(v interface{}) string {
+	data, err := json.MarshalIndent(v, "", "  ")
+	t.ExpectSuccess(err, "Unable to marshal JSON")
+	return t.writeTempSuffix(string(data)+"\n", ".json")
+}
+
+// Like WriteTempJSON except that v is written as YAML to a file ending in
+// .yaml. Since this library has no dependencies the value is encoded via
+// encoding/json, so field names come from json struct tags (not yaml tags)
+// and types implementing json.Marshaler are respected. Struct fields keep
+// their order while map keys are sorted.
+func (t *T) WriteTempYAML(v interface{}) string {
+	data, err := json.Marshal(v)
+	t.ExpectSuccess(err, "Unable to marshal YAML")
+	node, err := decodeYAMLNode(json.NewDecoder(bytes.NewReader(data)))
+	t.ExpectSuccess(err, "Unable to marshal YAML")
+	var buffer bytes.Buffer
+	node.write(&buffer, 0)
+	return t.writeTempSuffix(buffer.String(), ".yaml")
+}
+
+// Writes contents to a temporary file whose name ends with suffix.
+func (t *T) writeTempSuffix(contents, suffix string) string {
+	f := t.tempFile(0644, suffix)
+	name := f.Name()
+	_, err := io.WriteString(f, contents)
+	t.ExpectSuccess(err)
+	t.ExpectSuccess(f.Close())
+	return name
+}
+
+// A value being encoded as YAML. Exactly one of the fields is used: scalar
+// for strings, numbers, booleans and null, list for arrays, and fields for
+// objects (which is non nil, even when empty).
+type yamlNode struct {
+	scalar string
+	list   []*yamlNode
+	isList bool
+	fields []yamlField
+}
+
+// A single key of a YAML mapping.
+type yamlField struct {
+	key   string
+	value *yamlNode
+}
+
+// Reads the next JSON value from d, preserving the order of object keys.
+func decodeYAMLNode(d *json.Decoder) (*yamlNode, error) {
+	d.UseNumber()
+	token, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := token.(type) {
+	case json.Delim:
+		node := &yamlNode{}
+		if tok == '[' {
+			node.isList = true
+			for d.More() {
+				item, err := decodeYAMLNode(d)
+				if err != nil {
+					return nil, err
+				}
+				node.list = append(node.list, item)
+			}
+		} else {
+			node.fields = []yamlField{}
+			for d.More() {
+				key, err := d.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeYAMLNode(d)
+				if err != nil {
+					return nil, err
+				}
+				node.fields = append(node.fields,
+					yamlField{key: yamlScalar(key.(string)), value: value})
+			}
+		}
+		// Consume the closing delimiter.
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &yamlNode{scalar: yamlScalar(tok)}, nil
+	case nil:
+		return &yamlNode{scalar: "null"}, nil
+	default:
+		return &yamlNode{scalar: fmt.Sprint(tok)}, nil
+	}
+}
+
+// Strings matching this can be written without quotes.
+var yamlPlainString = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_./-]*( [A-Za-z0-9_./-]+)*$`)
+
+// Strings that YAML parsers may treat as something other than a string.
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true,
+	"off": true, "y": true, "n": true, "null": true, "~": true,
+}
+
+// Returns s as a YAML scalar, quoting it if necessary. A JSON string is a
+// valid YAML double quoted scalar so encoding/json does the quoting.
+func yamlScalar(s string) string {
+	if yamlPlainString.MatchString(s) && !yamlReserved[strings.ToLower(s)] {
+		return s
+	}
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// Returns true if the node is written on the same line as its key.
+func (n *yamlNode) inline() bool {
+	return (n.isList && len(n.list) == 0) ||
+		(n.fields != nil && len(n.fields) == 0) ||
+		(!n.isList && n.fields == nil)
+}
+
+// Returns the inline form of the node.
+func (n *yamlNode) inlineString() string {
+	switch {
+	case n.isList:
+		return "[]"
+	case n.fields != nil:
+		return "{}"
+	}
+	return n.scalar
+}
+
+// Writes the node in block style with each line indented by indent spaces.
+func (n *yamlNode) write(b *bytes.Buffer, indent int) {
+	pad := strings.Repeat(" ", indent)
+	if n.inline() {
+		b.WriteString(pad + n.inlineString() + "\n")
+		return
+	}
+	if n.isList {
+		for _, item := range n.list {
+			if item.inline() {
+				b.WriteString(pad + "- " + item.inlineString() + "\n")
+				continue
+			}
+			// The item is written two spaces deeper and then the start of
+			// its first line is replaced with the list marker.
+			var child bytes.Buffer
+			item.write(&child, indent+2)
+			data := child.Bytes()
+			copy(data[indent:], "- ")
+			b.Write(data)
+		}
+		return
+	}
+	for _, field := range n.fields {
+		if field.value.inline() {
+			b.WriteString(pad + field.key + ": " +
+				field.value.inlineString() + "\n")
+		} else if field.value.isList {
+			// Lists are conventionally not indented under their key.
+			b.WriteString(pad + field.key + ":\n")
+			field.value.write(b, indent)
+		} else {
+			b.WriteString(pad + field.key + ":\n")
+			field.value.write(b, indent+2)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+type marshalConfig struct {
+	Name    string            `json:"name"`
+	Port    int               `json:"port"`
+	Debug   bool              `json:"debug"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Servers []marshalServer   `json:"servers"`
+	Matrix  [][]int           `json:"matrix"`
+	Empty   []int             `json:"empty"`
+	Missing *marshalServer    `json:"missing"`
+}
+
+type marshalServer struct {
+	Host string   `json:"host"`
+	Args []string `json:"args,omitempty"`
+}
+
+func TestT_WriteTempJSON(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+	defer T.Finish()
+
+	want := marshalConfig{Name: "svc", Port: 80, Tags: []string{"a"}}
+	var path string
+	m.CheckPass(t, func() { path = T.WriteTempJSON(want) })
+	if !strings.HasSuffix(path, ".json") {
+		t.Fatalf("The file does not end in .json: %s", path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read the file: %s", err)
+	}
+	var have marshalConfig
+	if err := json.Unmarshal(data, &have); err != nil {
+		t.Fatalf("Unable to decode the file: %s", err)
+	} else if have.Name != "svc" || have.Port != 80 || len(have.Tags) != 1 {
+		t.Fatalf("Unexpected contents: %s", data)
+	}
+
+	m.CheckFail(t, func() { T.WriteTempJSON(make(chan int)) })
+}
+
+func TestT_WriteTempYAML(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+	defer T.Finish()
+
+	v := marshalConfig{
+		Name:   "my service",
+		Port:   8080,
+		Debug:  true,
+		Tags:   []string{"web", "yes", "1.5", "a: b", ""},
+		Labels: map[string]string{"z": "last", "a": "line\none"},
+		Servers: []marshalServer{
+			{Host: "one.example.com", Args: []string{"-v"}},
+			{Host: "two"},
+		},
+		Matrix: [][]int{{1, 2}, {}},
+		Empty:  []int{},
+	}
+	var path string
+	m.CheckPass(t, func() { path = T.WriteTempYAML(v) })
+	if !strings.HasSuffix(path, ".yaml") {
+		t.Fatalf("The file does not end in .yaml: %s", path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read the file: %s", err)
+	}
+	want := strings.Join([]string{
+		`name: my service`,
+		`port: 8080`,
+		`debug: true`,
+		`tags:`,
+		`- web`,
+		`- "yes"`,
+		`- "1.5"`,
+		`- "a: b"`,
+		`- ""`,
+		`labels:`,
+		`  a: "line\none"`,
+		`  z: last`,
+		`servers:`,
+		`- host: one.example.com`,
+		`  args:`,
+		`  - "-v"`,
+		`- host: two`,
+		`matrix:`,
+		`- - 1`,
+		`  - 2`,
+		`- []`,
+		`empty: []`,
+		`missing: null`,
+		``,
+	}, "\n")
+	if string(data) != want {
+		t.Fatalf("Unexpected YAML:\n%s\nwant:\n%s", data, want)
+	}
+
+	m.CheckPass(t, func() { path = T.WriteTempYAML("plain") })
+	if data, _ := ioutil.ReadFile(path); string(data) != "plain\n" {
+		t.Fatalf("Unexpected YAML for a scalar: %q", data)
+	}
+	m.CheckFail(t, func() { T.WriteTempYAML(make(chan int)) })
+}
@@ -0,0 +1,293 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file contains matchers and placeholder values that can be used in
+// the want side of Equal() to match values loosely.
+
+// A Matcher can be used anywhere within the want value passed to Equal(),
+// including nested inside of structures, as long as the field, element or
+// map value holding it is an interface type (such as interface{}). Rather
+// than being compared directly the matcher is called with the have value
+// found at the same position. Match returns true if the value is
+// acceptable and a description of what was expected, which is used in the
+// failure message when the value does not match.
+type Matcher interface {
+	Match(v interface{}) (bool, string)
+}
+
+// The reflect.Type of the Matcher interface.
+var matcherType = reflect.TypeOf((*Matcher)(nil)).Elem()
+
+// A Matcher built from a function.
+type funcMatcher struct {
+	desc string
+	f    func(v interface{}) bool
+}
+
+// Implements Matcher.
+func (m *funcMatcher) Match(v interface{}) (bool, string) {
+	return m.f(v), m.desc
+}
+
+// Returns a Matcher that matches any value, including nil.
+func Any() Matcher {
+	return &funcMatcher{"anything", func(v interface{}) bool { return true }}
+}
+
+// Returns a Matcher that matches strings, []byte and fmt.Stringer values
+// that match the given regular expression. This panics if the expression is
+// not valid.
+func Regex(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+	return &funcMatcher{
+		fmt.Sprintf("a value matching regexp %q", pattern),
+		func(v interface{}) bool {
+			s, ok := matchString(v)
+			return ok && re.MatchString(s)
+		},
+	}
+}
+
+// Returns a Matcher that matches any integer or floating point value that is
+// greater than or equal to min and less than or equal to max.
+func Range(min, max float64) Matcher {
+	return &funcMatcher{
+		fmt.Sprintf("a number between %v and %v", min, max),
+		func(v interface{}) bool {
+			f, ok := matchNumber(v)
+			return ok && f >= min && f <= max
+		},
+	}
+}
+
+// Returns a Matcher that matches any string, slice, array, map or channel
+// with a length of n.
+func Len(n int) Matcher {
+	return &funcMatcher{
+		fmt.Sprintf("a value with length %d", n),
+		func(v interface{}) bool {
+			value := reflect.ValueOf(v)
+			switch value.Kind() {
+			case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+				reflect.Chan:
+				return value.Len() == n
+			}
+			return false
+		},
+	}
+}
+
+// Returns a Matcher that matches strings containing the string x, slices and
+// arrays with an element deeply equal to x, and maps with the key x.
+func Contains(x interface{}) Matcher {
+	return &funcMatcher{
+		fmt.Sprintf("a value containing %#v", x),
+		func(v interface{}) bool {
+			if s, ok := matchString(v); ok {
+				sub, ok := x.(string)
+				return ok && strings.Contains(s, sub)
+			}
+			value := reflect.ValueOf(v)
+			switch value.Kind() {
+			case reflect.Slice, reflect.Array:
+				for i := 0; i < value.Len(); i++ {
+					if reflect.DeepEqual(value.Index(i).Interface(), x) {
+						return true
+					}
+				}
+			case reflect.Map:
+				key := reflect.ValueOf(x)
+				if key.IsValid() && key.Type().AssignableTo(value.Type().Key()) {
+					return value.MapIndex(key).IsValid()
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Returns the value as a string if it is a string, []byte or fmt.Stringer.
+func matchString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case fmt.Stringer:
+		return s.String(), true
+	case []byte:
+		return string(s), true
+	}
+	return kindString(v)
+}
+
+// Returns the value as a string if its underlying type is a string.
+func kindString(v interface{}) (string, bool) {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.String {
+		return value.String(), true
+	}
+	return "", false
+}
+
+// Returns the value as a float64 if it is any integer or float type.
+func matchNumber(v interface{}) (float64, bool) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	return 0, false
+}
+
+// When used as a string anywhere in the want value passed to Equal() this
+// matches any string that is a UUID in its canonical, hyphenated form.
+const AnyUUID = "\x00testlib:AnyUUID\x00"
+
+// When used as a string anywhere in the want value passed to Equal() this
+// matches any string that is a ULID.
+const AnyULID = "\x00testlib:AnyULID\x00"
+
+// When used as a string anywhere in the want value passed to Equal() this
+// matches any string that is a snowflake ID: a positive 64 bit integer
+// written in decimal. Where the want value is an interface (for example in
+// a map[string]interface{}) it also matches positive integer values, but
+// since an integer field can not hold a string this can not be used for
+// int64 fields directly.
+const AnySnowflake = "\x00testlib:AnySnowflake\x00"
+
+// When used as a string anywhere in the want value passed to Equal() this
+// matches any string that is not empty.
+const NonEmptyString = "\x00testlib:NonEmptyString\x00"
+
+// The location used to mark AnyTime.
+var anyTimeLocation = time.FixedZone("testlib.AnyTime", 0)
+
+// When used as a time.Time anywhere in the want value passed to Equal() this
+// matches any time that is not the zero time.
+var AnyTime = time.Date(1, time.January, 1, 0, 0, 0, 0, anyTimeLocation)
+
+// The Matchers used for each string placeholder.
+var stringPlaceholders = map[string]Matcher{
+	AnyUUID: &funcMatcher{"any UUID", func(v interface{}) bool {
+		s, ok := kindString(v)
+		return ok && uuidRegexp.MatchString(s)
+	}},
+	AnyULID: &funcMatcher{"any ULID", func(v interface{}) bool {
+		s, ok := kindString(v)
+		return ok && ulidRegexp.MatchString(s)
+	}},
+	AnySnowflake: &funcMatcher{"any snowflake ID", isSnowflake},
+	NonEmptyString: &funcMatcher{"any non empty string", func(v interface{}) bool {
+		s, ok := kindString(v)
+		return ok && s != ""
+	}},
+}
+
+// The Matcher used for AnyTime.
+var anyTimeMatcher = &funcMatcher{"any non zero time", func(v interface{}) bool {
+	tm, ok := v.(time.Time)
+	return ok && !tm.IsZero()
+}}
+
+var (
+	uuidRegexp = regexp.MustCompile(
+		`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-` +
+			`[0-9a-fA-F]{12}$`)
+	ulidRegexp = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Za-hjkmnp-tv-z]{25}$`)
+)
+
+// Returns true if v is a positive integer that fits in an int64, or a
+// string holding one in canonical decimal form.
+func isSnowflake(v interface{}) bool {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int64:
+		return value.Int() > 0
+	case reflect.Uint, reflect.Uint64:
+		return value.Uint() > 0 && value.Uint() <= math.MaxInt64
+	case reflect.String:
+		n, err := strconv.ParseInt(value.String(), 10, 64)
+		return err == nil && n > 0 &&
+			strconv.FormatInt(n, 10) == value.String()
+	}
+	return false
+}
+
+// Returns the Matcher that want represents, either because it implements
+// Matcher or because it is one of the placeholder values, or nil if want
+// should be compared normally.
+func wantMatcher(want reflect.Value) Matcher {
+	if want.Kind() == reflect.Interface && !want.IsNil() {
+		want = want.Elem()
+	}
+	if !want.IsValid() {
+		return nil
+	} else if want.Type().Implements(matcherType) && want.CanInterface() {
+		if want.Kind() == reflect.Ptr && want.IsNil() {
+			return nil
+		}
+		return want.Interface().(Matcher)
+	} else if want.Kind() == reflect.String {
+		return stringPlaceholders[want.String()]
+	} else if want.Type() == reflect.TypeOf(time.Time{}) && want.CanInterface() {
+		if want.Interface().(time.Time).Location() == anyTimeLocation {
+			return anyTimeMatcher
+		}
+	}
+	return nil
+}
+
+// If want is a Matcher or a placeholder then this checks have against it
+// and returns true along with any differences found. Otherwise this returns
+// false and the values should be compared normally.
+func (t *T) matchWant(
+	desc string, have, want reflect.Value,
+) ([]string, bool) {
+	m := wantMatcher(want)
+	if m == nil {
+		return nil, false
+	}
+	var v interface{}
+	if have.IsValid() && have.CanInterface() {
+		v = have.Interface()
+	} else if have.IsValid() && have.Kind() == reflect.String {
+		// Unexported string fields can still be matched against the
+		// string placeholders.
+		v = have.String()
+	}
+	ok, expected := m.Match(v)
+	if ok {
+		return nil, true
+	}
+	return []string{
+		fmt.Sprintf("%s: not equal.", desc),
+		fmt.Sprintf("  have: %s", t.stringValue(have)),
+		fmt.Sprintf("  want: %s", expected),
+	}, true
+}
@@ -0,0 +1,192 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlaceholders(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	type ID string
+	type record struct {
+		ID      ID
+		Trace   string
+		Order   string
+		Name    string
+		Created time.Time
+		Extra   map[string]interface{}
+	}
+	have := record{
+		ID:      "0b0c6c0e-5d4a-4a4e-9b0a-3f6f1f0d8a11",
+		Trace:   "01ARZ3NDEKTSV4RRFFQ69G5FAV",
+		Order:   "1541815603606036480",
+		Name:    "bob",
+		Created: time.Now(),
+		Extra: map[string]interface{}{
+			"request": "abc",
+			"user":    int64(1541815603606036481),
+		},
+	}
+	want := record{
+		ID:      AnyUUID,
+		Trace:   AnyULID,
+		Order:   AnySnowflake,
+		Name:    NonEmptyString,
+		Created: AnyTime,
+		Extra: map[string]interface{}{
+			"request": NonEmptyString,
+			"user":    AnySnowflake,
+		},
+	}
+	m.CheckPass(t, func() { T.Equal(have, want) })
+
+	bad := record{Order: "-1", Extra: map[string]interface{}{
+		"request": 1,
+		"user":    "12ab",
+	}}
+	m.CheckFail(t, func() { T.Equal(bad, want) })
+	for _, want := range []string{
+		"want: any UUID",
+		"want: any ULID",
+		"want: any snowflake ID",
+		"want: any non empty string",
+		"want: any non zero time",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("The message did not contain %q: %s", want, msg)
+		}
+	}
+
+	// Placeholders only apply to the want side.
+	m.CheckFail(t, func() { T.Equal(NonEmptyString, "abc") })
+	m.CheckFail(t, func() { T.Equal(AnyTime, time.Now()) })
+}
+
+func TestIsSnowflake(t *testing.T) {
+	t.Parallel()
+	tests := map[interface{}]bool{
+		"1541815603606036480":  true,
+		"9223372036854775807":  true,
+		"9223372036854775808":  false,
+		"0":                    false,
+		"-5":                   false,
+		"+5":                   false,
+		"005":                  false,
+		"abc":                  false,
+		int64(42):              true,
+		int(-1):                false,
+		uint64(math.MaxUint64): false,
+		uint64(7):              true,
+		3.5:                    false,
+		nil:                    false,
+	}
+	for v, want := range tests {
+		if have := isSnowflake(v); have != want {
+			t.Errorf("isSnowflake(%#v): have %v, want %v", v, have, want)
+		}
+	}
+}
+
+type testStringer string
+
+func (s testStringer) String() string { return string(s) }
+
+func TestMatchers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		matcher Matcher
+		value   interface{}
+		match   bool
+	}{
+		{Any(), nil, true},
+		{Any(), 1, true},
+		{Regex(`^a+b$`), "aab", true},
+		{Regex(`^a+b$`), []byte("ab"), true},
+		{Regex(`^a+b$`), testStringer("ab"), true},
+		{Regex(`^a+b$`), "abc", false},
+		{Regex(`^a+b$`), 1, false},
+		{Range(1, 2), 1, true},
+		{Range(1, 2), uint8(2), true},
+		{Range(1, 2), 1.5, true},
+		{Range(1, 2), 3, false},
+		{Range(1, 2), "1", false},
+		{Len(2), "ab", true},
+		{Len(2), []int{1, 2}, true},
+		{Len(2), map[int]int{1: 1}, false},
+		{Len(2), 2, false},
+		{Contains("b"), "abc", true},
+		{Contains("x"), "abc", false},
+		{Contains(2), []int{1, 2}, true},
+		{Contains(3), [2]int{1, 2}, false},
+		{Contains("k"), map[string]int{"k": 1}, true},
+		{Contains(1), map[string]int{"k": 1}, false},
+		{Contains(1), nil, false},
+	}
+	for i, test := range tests {
+		if ok, desc := test.matcher.Match(test.value); ok != test.match {
+			t.Errorf("Test %d (%s): have %v, want %v", i, desc, ok, test.match)
+		}
+	}
+}
+
+func TestT_EqualMatchers(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	have := map[string]interface{}{
+		"id":    "user-123",
+		"age":   42,
+		"tags":  []string{"a", "b"},
+		"extra": nil,
+		"nested": []interface{}{
+			map[string]interface{}{"name": "bob"},
+		},
+	}
+	want := map[string]interface{}{
+		"id":    Regex(`^user-\d+$`),
+		"age":   Range(18, 100),
+		"tags":  Contains("b"),
+		"extra": Any(),
+		"nested": []interface{}{
+			map[string]interface{}{"name": Len(3)},
+		},
+	}
+	m.CheckPass(t, func() { T.Equal(have, want) })
+	m.CheckPass(t, func() { T.Equal("abc", Regex("b")) })
+
+	have["age"] = 7
+	m.CheckFail(t, func() { T.Equal(have, want) })
+	if !strings.Contains(msg, "  have: 7\n  want: a number between 18 and 100") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"testing/fstest"
+	"time"
+)
+
+// This file contains an in memory file system for tests that work against
+// io/fs.
+
+// Returns a new, empty, in memory file system. The returned MemFS
+// implements fs.FS (and the other io/fs interfaces supported by
+// testing/fstest.MapFS) and can also be written to, so the code under test
+// never needs to touch the disk.
+func (t *T) MemFS() *MemFS {
+	return &MemFS{files: fstest.MapFS{}}
+}
+
+// An in memory, writable file system. This is returned from T.MemFS() and
+// is safe to use from multiple goroutines. All names use forward slashes and
+// must be valid according to fs.ValidPath().
+type MemFS struct {
+	lock  sync.Mutex
+	files fstest.MapFS
+	limit int64
+}
+
+// Implements fs.FS.
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.files.Open(name)
+}
+
+// Implements fs.ReadFileFS.
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.files.ReadFile(name)
+}
+
+// Implements fs.ReadDirFS.
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.files.ReadDir(name)
+}
+
+// Implements fs.StatFS.
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.files.Stat(name)
+}
+
+// Writes data to the named file, replacing it if it already exists. Any
+// missing parent directories are created.
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if f, ok := m.files[name]; ok && f.Mode.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrExist}
+	}
+	if m.limit > 0 {
+		used := int64(len(data))
+		for file, f := range m.files {
+			if file != name {
+				used += int64(len(f.Data))
+			}
+		}
+		if used > m.limit {
+			return &fs.PathError{Op: "write", Path: name, Err: syscall.ENOSPC}
+		}
+	}
+	if err := m.mkdirAll(path.Dir(name), 0755); err != nil {
+		return err
+	}
+	m.files[name] = &fstest.MapFile{
+		Data:    append([]byte(nil), data...),
+		Mode:    perm.Perm(),
+		ModTime: time.Now(),
+	}
+	return nil
+}
+
+// Limits the total size of the files in the file system to size bytes. A
+// WriteFile() that would go past the limit fails with an error wrapping
+// syscall.ENOSPC, simulating a full disk. A size of zero removes the limit.
+func (m *MemFS) SetSizeLimit(size int64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.limit = size
+}
+
+// Creates the named directory along with any missing parents.
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.mkdirAll(name, perm)
+}
+
+// Like MkdirAll except the lock must already be held.
+func (m *MemFS) mkdirAll(name string, perm fs.FileMode) error {
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if f, ok := m.files[dir]; !ok {
+			m.files[dir] = &fstest.MapFile{
+				Mode:    fs.ModeDir | perm.Perm(),
+				ModTime: time.Now(),
+			}
+		} else if !f.Mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: fs.ErrExist}
+		}
+	}
+	return nil
+}
+
+// Removes the named file or directory along with everything under it.
+func (m *MemFS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for file := range m.files {
+		if name == "." || file == name || strings.HasPrefix(file, name+"/") {
+			delete(m.files, file)
+		}
+	}
+	return nil
+}
+
+// Fails the test if the regular files in fsys do not exactly match want,
+// which maps each file's slash separated path to its expected contents.
+// Directories are not compared directly, only the files within them.
+func (t *T) EqualFS(fsys fs.FS, want map[string]string, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	have := map[string]string{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		have[name] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%sUnable to walk the file system: %s", prefix, err)
+	}
+	t.Equal(have, want, append(desc, "file system contents")...)
+}
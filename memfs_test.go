@@ -0,0 +1,107 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/fstest"
+)
+
+func TestT_MemFS(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+
+	fsys := T.MemFS()
+	T.ExpectSuccess(fsys.WriteFile("a/b/c.txt", []byte("c"), 0644))
+	T.ExpectSuccess(fsys.WriteFile("top.txt", []byte("top"), 0600))
+	T.ExpectSuccess(fsys.MkdirAll("empty/dir", 0755))
+	T.ExpectError(fsys.WriteFile("a/b", []byte("x"), 0644))
+	T.ExpectError(fsys.WriteFile("../x", []byte("x"), 0644))
+	T.ExpectError(fsys.MkdirAll("top.txt/x", 0755))
+
+	if err := fstest.TestFS(fsys, "a/b/c.txt", "top.txt", "empty/dir"); err != nil {
+		t.Fatalf("The file system is not valid: %s", err)
+	}
+	data, err := fs.ReadFile(fsys, "a/b/c.txt")
+	T.ExpectSuccess(err)
+	T.Equal(string(data), "c")
+	info, err := fs.Stat(fsys, "top.txt")
+	T.ExpectSuccess(err)
+	T.Equal(info.Mode(), fs.FileMode(0600))
+
+	T.ExpectSuccess(fsys.RemoveAll("a"))
+	if _, err := fs.Stat(fsys, "a/b/c.txt"); err == nil {
+		t.Fatalf("The file was not removed.")
+	}
+}
+
+func TestMemFS_SetSizeLimit(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+	defer T.Finish()
+
+	fsys := T.MemFS()
+	fsys.SetSizeLimit(10)
+	T.ExpectSuccess(fsys.WriteFile("a", []byte("12345"), 0644))
+	T.ExpectSuccess(fsys.WriteFile("b", []byte("12345"), 0644))
+	err := fsys.WriteFile("c", []byte("1"), 0644)
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("Expected ENOSPC, got: %v", err)
+	} else if _, err := fsys.Stat("c"); err == nil {
+		t.Fatalf("The failed write created the file.")
+	}
+
+	// Replacing a file only counts the new contents.
+	T.ExpectSuccess(fsys.WriteFile("a", []byte("123"), 0644))
+	T.ExpectSuccess(fsys.WriteFile("c", []byte("12"), 0644))
+
+	fsys.SetSizeLimit(0)
+	T.ExpectSuccess(fsys.WriteFile("d", make([]byte, 100), 0644))
+}
+
+func TestT_EqualFS(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	fsys := T.MemFS()
+	T.ExpectSuccess(fsys.WriteFile("a/b.txt", []byte("b"), 0644))
+	T.ExpectSuccess(fsys.WriteFile("c.txt", []byte("c"), 0644))
+	T.ExpectSuccess(fsys.MkdirAll("empty", 0755))
+
+	m.CheckPass(t, func() {
+		T.EqualFS(fsys, map[string]string{"a/b.txt": "b", "c.txt": "c"})
+	})
+	m.CheckFail(t, func() {
+		T.EqualFS(fsys, map[string]string{"a/b.txt": "x", "d.txt": "d"}, "prefix")
+	})
+	if !strings.HasPrefix(msg, "prefix file system contents: ") {
+		t.Fatalf("The prefix was not prepended to the message: '''%s'''", msg)
+	}
+	for _, want := range []string{`["a/b.txt"]`, `["c.txt"]`, `["d.txt"]`} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("Message did not contain %s: %s", want, msg)
+		}
+	}
+}
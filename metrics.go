@@ -0,0 +1,225 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bufio"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file contains functions for asserting on the metrics exported by
+// instrumented code.
+
+// A function that returns the current value of every metric, keyed by the
+// metric name and labels as returned by metricKey().
+type MetricsSource func() (map[string]float64, error)
+
+// Returns a MetricsSource that reads every numeric variable published via
+// the expvar package. Variables holding a JSON object (such as an
+// expvar.Map) are flattened so that each numeric entry becomes a metric with
+// a single label named "key".
+func ExpvarMetrics() MetricsSource {
+	return func() (map[string]float64, error) {
+		metrics := map[string]float64{}
+		expvar.Do(func(kv expvar.KeyValue) {
+			var value interface{}
+			if json.Unmarshal([]byte(kv.Value.String()), &value) != nil {
+				return
+			}
+			switch v := value.(type) {
+			case float64:
+				metrics[metricKey(kv.Key, nil)] = v
+			case map[string]interface{}:
+				for key, entry := range v {
+					if f, ok := entry.(float64); ok {
+						labels := map[string]string{"key": key}
+						metrics[metricKey(kv.Key, labels)] = f
+					}
+				}
+			}
+		})
+		return metrics, nil
+	}
+}
+
+// Returns a MetricsSource that scrapes the given handler, which must serve
+// metrics in the Prometheus text exposition format. This works with the
+// handler returned from promhttp.HandlerFor() without this library needing
+// to depend on the Prometheus client.
+func PrometheusMetrics(h http.Handler) MetricsSource {
+	return func() (map[string]float64, error) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		if w.Code != http.StatusOK {
+			return nil, fmt.Errorf("metrics handler returned status %d", w.Code)
+		}
+		return parsePrometheusText(w.Body.String())
+	}
+}
+
+// Snapshots the metrics from source, calls fn and then snapshots them
+// again. The change in every metric is recorded so it can be checked with
+// ExpectMetricDelta().
+func (t *T) RecordMetrics(source MetricsSource, fn func()) {
+	before, err := source()
+	t.ExpectSuccess(err, "Unable to read metrics")
+	fn()
+	after, err := source()
+	t.ExpectSuccess(err, "Unable to read metrics")
+
+	deltas := make(map[string]float64, len(after))
+	for key, value := range after {
+		deltas[key] = value - before[key]
+	}
+	for key, value := range before {
+		if _, ok := after[key]; !ok {
+			deltas[key] = -value
+		}
+	}
+	t.lock.Lock()
+	t.metricDeltas = deltas
+	t.lock.Unlock()
+}
+
+// Fails the test if the metric with the given name and labels did not
+// change by exactly delta during the last call to RecordMetrics(). Metrics
+// that did not exist before or after are treated as having been zero.
+func (t *T) ExpectMetricDelta(
+	name string, labels map[string]string, delta float64, desc ...string,
+) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	t.lock.Lock()
+	deltas := t.metricDeltas
+	t.lock.Unlock()
+	if deltas == nil {
+		t.Fatalf("%sRecordMetrics() has not been called.", prefix)
+	}
+	key := metricKey(name, labels)
+	if have := deltas[key]; have != delta {
+		changed := []string{}
+		for k, v := range deltas {
+			if v != 0 {
+				changed = append(changed, fmt.Sprintf("  %s: %+g", k, v))
+			}
+		}
+		sort.Strings(changed)
+		t.Fatalf("%sUnexpected change in %s.\n  have: %+g\n  want: %+g\n"+
+			"Metrics that changed:\n%s", prefix, key, have, delta,
+			strings.Join(changed, "\n"))
+	}
+}
+
+// Returns the canonical form of a metric name and labels, for example
+// `requests{code="200",method="GET"}`.
+func metricKey(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Parses metrics in the Prometheus text exposition format.
+func parsePrometheusText(text string) (map[string]float64, error) {
+	metrics := map[string]float64{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, labels, rest, err := parsePrometheusSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("line %d: missing value", n)
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		metrics[metricKey(name, labels)] = value
+	}
+	return metrics, scanner.Err()
+}
+
+// Splits a single sample line into its name, labels and the remainder of
+// the line (the value and optional timestamp).
+func parsePrometheusSample(line string) (string, map[string]string, string, error) {
+	end := strings.IndexAny(line, "{ \t")
+	if end < 0 {
+		return "", nil, "", fmt.Errorf("missing value")
+	}
+	name := line[:end]
+	line = line[end:]
+	if line[0] != '{' {
+		return name, nil, line, nil
+	}
+
+	labels := map[string]string{}
+	line = line[1:]
+	for {
+		line = strings.TrimLeft(line, " \t,")
+		if strings.HasPrefix(line, "}") {
+			return name, labels, line[1:], nil
+		}
+		eq := strings.Index(line, "=\"")
+		if eq < 0 {
+			return "", nil, "", fmt.Errorf("malformed labels")
+		}
+		key := strings.TrimSpace(line[:eq])
+		line = line[eq+2:]
+		value := []byte{}
+		closed := false
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			if c == '\\' && i+1 < len(line) {
+				i++
+				switch line[i] {
+				case 'n':
+					value = append(value, '\n')
+				default:
+					value = append(value, line[i])
+				}
+			} else if c == '"' {
+				line = line[i+1:]
+				closed = true
+				break
+			} else {
+				value = append(value, c)
+			}
+		}
+		if !closed {
+			return "", nil, "", fmt.Errorf("unterminated label value")
+		}
+		labels[key] = string(value)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var (
+	testMetricsCounter = expvar.NewInt("testlib_test_counter")
+	testMetricsMap     = expvar.NewMap("testlib_test_map")
+)
+
+func TestT_RecordMetricsExpvar(t *testing.T) {
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	m.CheckFail(t, func() { T.ExpectMetricDelta("testlib_test_counter", nil, 0) })
+
+	T.RecordMetrics(ExpvarMetrics(), func() {
+		testMetricsCounter.Add(2)
+		testMetricsMap.Add("hits", 3)
+	})
+	m.CheckPass(t, func() { T.ExpectMetricDelta("testlib_test_counter", nil, 2) })
+	m.CheckPass(t, func() {
+		T.ExpectMetricDelta("testlib_test_map", map[string]string{"key": "hits"}, 3)
+	})
+	m.CheckPass(t, func() { T.ExpectMetricDelta("missing", nil, 0) })
+	m.CheckFail(t, func() { T.ExpectMetricDelta("testlib_test_counter", nil, 1, "prefix") })
+	if !strings.HasPrefix(msg, "prefix: Unexpected change in testlib_test_counter.") {
+		t.Fatalf("Unexpected message: %s", msg)
+	} else if !strings.Contains(msg, "  testlib_test_map{key=\"hits\"}: +3") {
+		t.Fatalf("The changed metrics were not listed: %s", msg)
+	}
+}
+
+func TestT_RecordMetricsPrometheus(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	requests := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "# HELP http_requests_total Requests.\n")
+		fmt.Fprintf(w, "# TYPE http_requests_total counter\n")
+		fmt.Fprintf(w, "http_requests_total{method=\"GET\",code=\"200\"} %d\n", requests)
+		fmt.Fprintf(w, "http_requests_total{code=\"500\", method=\"GET\"} 1 1600000000\n")
+		fmt.Fprintf(w, "odd_label{path=\"a\\\"b\"} %d\n", requests*2)
+		fmt.Fprintf(w, "up 1\n")
+	})
+
+	T.RecordMetrics(PrometheusMetrics(handler), func() { requests += 5 })
+	labels := map[string]string{"method": "GET", "code": "200"}
+	m.CheckPass(t, func() { T.ExpectMetricDelta("http_requests_total", labels, 5) })
+	labels["code"] = "500"
+	m.CheckPass(t, func() { T.ExpectMetricDelta("http_requests_total", labels, 0) })
+	m.CheckPass(t, func() {
+		T.ExpectMetricDelta("odd_label", map[string]string{"path": `a"b`}, 10)
+	})
+	m.CheckPass(t, func() { T.ExpectMetricDelta("up", nil, 0) })
+
+	// Errors from the source fail the test.
+	broken := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "bad{label} 1\n")
+	})
+	m.CheckFail(t, func() { T.RecordMetrics(PrometheusMetrics(broken), func() {}) })
+	notFound := http.NotFoundHandler()
+	m.CheckFail(t, func() { T.RecordMetrics(PrometheusMetrics(notFound), func() {}) })
+}
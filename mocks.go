@@ -19,13 +19,50 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 )
 
+// This file contains the functions that this library calls through
+// variables so that its tests can replace them to simulate failures. See
+// T.Override() for restoring them automatically.
+//
+// Each variable is also exported as a pointer (the name with a "Var"
+// suffix) so that tests outside of this package can replace it too, for
+// example to see how code that uses TempFile() copes with a full disk:
+//
+//	T.Override(testlib.IoutilTempFileVar, func(string, string) (*os.File, error) {
+//		return nil, syscall.ENOSPC
+//	})
+//
+// These are shared by every test in the binary so they should not be
+// overridden by tests that call t.Parallel().
+
+var execCommand func(string, ...string) *exec.Cmd = exec.Command
 var fmtFprintf func(io.Writer, string, ...interface{}) (int, error) = fmt.Fprintf
 var ioutilTempDir func(string, string) (string, error) = ioutil.TempDir
 var ioutilTempFile func(string, string) (*os.File, error) = ioutil.TempFile
 var osChmod func(string, os.FileMode) error = os.Chmod
 var osExit func(int) = os.Exit
+var osGetenv func(string) string = os.Getenv
+var osGeteuid func() int = os.Geteuid
+var osMkdirAll func(string, os.FileMode) error = os.MkdirAll
+var osOpen func(string) (*os.File, error) = os.Open
 var osRemoveAll func(string) error = os.RemoveAll
 var osRemove func(string) error = os.Remove
 var osTempDir func() string = os.TempDir
+
+var (
+	ExecCommandVar    = &execCommand
+	FmtFprintfVar     = &fmtFprintf
+	IoutilTempDirVar  = &ioutilTempDir
+	IoutilTempFileVar = &ioutilTempFile
+	OSChmodVar        = &osChmod
+	OSExitVar         = &osExit
+	OSGetenvVar       = &osGetenv
+	OSGeteuidVar      = &osGeteuid
+	OSMkdirAllVar     = &osMkdirAll
+	OSOpenVar         = &osOpen
+	OSRemoveAllVar    = &osRemoveAll
+	OSRemoveVar       = &osRemove
+	OSTempDirVar      = &osTempDir
+)
@@ -0,0 +1,279 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// This file contains helpers for tests that use the network.
+
+// Returns a TCP port on the loopback interface that was free at the time of
+// the call. There is an unavoidable window between this returning and the
+// port being used in which something else may take it, so this should only
+// be used when a port must be chosen before a process is started, such as
+// when rendering a configuration file.
+func (t *T) FreePort() int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	t.ExpectSuccess(err, "Unable to find a free port")
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// Used to give each forwarding socket a unique, short, name since unix
+// socket paths are limited to around 100 bytes.
+var forwardSocketCount int64
+
+// Listens on a random TCP port on the loopback interface and forwards every
+// connection to the unix socket at socketPath. This allows a client that
+// only speaks TCP to be tested against a server that only listens on a unix
+// socket. The listener and any open connections are closed when the test
+// finishes.
+func (t *T) ForwardTCPToUnix(socketPath string) *Forwarder {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	t.ExpectSuccess(err, "Unable to listen")
+	return t.forward(l, "unix", socketPath)
+}
+
+// Listens on a new unix socket in RootTempDir() and forwards every
+// connection to the TCP address addr. This is the reverse of
+// ForwardTCPToUnix(). The socket is removed when the test finishes.
+func (t *T) ForwardUnixToTCP(addr string) *Forwarder {
+	path := filepath.Join(t.RootTempDir(), fmt.Sprintf(
+		"fwd%d.sock", atomic.AddInt64(&forwardSocketCount, 1)))
+	l, err := net.Listen("unix", path)
+	t.ExpectSuccess(err, "Unable to listen")
+	t.AddFinalizer(func() {
+		osRemove(path)
+	})
+	return t.forward(l, "tcp", addr)
+}
+
+// Starts forwarding connections accepted from l to the given address.
+func (t *T) forward(l net.Listener, network, addr string) *Forwarder {
+	f := &Forwarder{
+		Addr:     l.Addr().String(),
+		listener: l,
+		network:  network,
+		target:   addr,
+		conns:    map[net.Conn]bool{},
+	}
+	f.wg.Add(1)
+	go f.accept()
+	t.AddNamedFinalizer("forwarder "+f.Addr, f.close)
+	return f
+}
+
+// Forwards connections from one address to another while counting the
+// traffic that passes through. This is returned from T.ForwardTCPToUnix()
+// and T.ForwardUnixToTCP().
+type Forwarder struct {
+	// The address that clients should connect to. For a TCP listener this
+	// is a host:port pair and for a unix listener it is the socket path.
+	Addr string
+
+	listener    net.Listener
+	network     string
+	target      string
+	wg          sync.WaitGroup
+	lock        sync.Mutex
+	conns       map[net.Conn]bool
+	connections int64
+	sent        int64
+	received    int64
+}
+
+// Returns the number of connections accepted so far.
+func (f *Forwarder) Connections() int64 {
+	return atomic.LoadInt64(&f.connections)
+}
+
+// Returns the number of bytes forwarded from clients to the target.
+func (f *Forwarder) BytesSent() int64 {
+	return atomic.LoadInt64(&f.sent)
+}
+
+// Returns the number of bytes forwarded from the target back to clients.
+func (f *Forwarder) BytesReceived() int64 {
+	return atomic.LoadInt64(&f.received)
+}
+
+// Accepts connections until the listener is closed.
+func (f *Forwarder) accept() {
+	defer f.wg.Done()
+	for {
+		client, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt64(&f.connections, 1)
+		server, err := net.Dial(f.network, f.target)
+		if err != nil {
+			client.Close()
+			continue
+		}
+		if !f.track(client, server) {
+			return
+		}
+		f.wg.Add(2)
+		go f.pipe(server, client, &f.sent)
+		go f.pipe(client, server, &f.received)
+	}
+}
+
+// Records the connections so they can be closed by close(). This returns
+// false, closing both, if the forwarder has already been closed.
+func (f *Forwarder) track(conns ...net.Conn) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.conns == nil {
+		for _, c := range conns {
+			c.Close()
+		}
+		return false
+	}
+	for _, c := range conns {
+		f.conns[c] = true
+	}
+	return true
+}
+
+// Copies data from src to dst, counting the bytes into counter. When either
+// side finishes both connections are closed.
+func (f *Forwarder) pipe(dst, src net.Conn, counter *int64) {
+	defer f.wg.Done()
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			atomic.AddInt64(counter, int64(n))
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	dst.Close()
+	src.Close()
+}
+
+// Stops the listener, closes all connections and waits for the forwarding
+// goroutines to exit.
+func (f *Forwarder) close() {
+	f.listener.Close()
+	f.lock.Lock()
+	for c := range f.conns {
+		c.Close()
+	}
+	f.conns = nil
+	f.lock.Unlock()
+	f.wg.Wait()
+}
+
+// Returned from connections blocked by T.ForbidNetwork().
+var ErrNetworkForbidden = errors.New("testlib: network access is forbidden")
+
+// Forbids the test from making network connections to anything other than
+// the loopback interface or unix sockets. Any attempt fails the test and
+// returns ErrNetworkForbidden. This helps keep unit tests hermetic while
+// still allowing local test servers (like httptest.Server) to be used.
+//
+// Go provides no way to intercept every connection so this covers two
+// paths: http.DefaultTransport (and so http.DefaultClient) is replaced until
+// the test finishes, and the returned guard provides Dial and DialContext
+// functions that should be injected into any code under test that accepts
+// a dialer. The HTTP client reads http.DefaultTransport without any
+// locking, so replacing it is a data race with every other goroutine that
+// makes HTTP requests. This must not be used in tests that call
+// t.Parallel(), or while any other test's goroutines might be making
+// requests.
+func (t *T) ForbidNetwork() *NetworkGuard {
+	g := &NetworkGuard{t: t}
+	t.Override(&http.DefaultTransport,
+		&guardedTransport{guard: g, base: http.DefaultTransport})
+	return g
+}
+
+// Blocks connections to anything other than the loopback interface. This
+// is returned from T.ForbidNetwork().
+type NetworkGuard struct {
+	t      *T
+	dialer net.Dialer
+}
+
+// Like net.Dial except that non local connections fail the test.
+func (g *NetworkGuard) Dial(network, addr string) (net.Conn, error) {
+	return g.DialContext(context.Background(), network, addr)
+}
+
+// Like net.Dialer.DialContext except that non local connections fail the
+// test.
+func (g *NetworkGuard) DialContext(
+	ctx context.Context, network, addr string,
+) (net.Conn, error) {
+	if err := g.check(network, addr); err != nil {
+		return nil, err
+	}
+	return g.dialer.DialContext(ctx, network, addr)
+}
+
+// Fails the test and returns an error if the address is not local.
+func (g *NetworkGuard) check(network, addr string) error {
+	if strings.HasPrefix(network, "unix") || isLoopback(addr) {
+		return nil
+	}
+	g.t.Errorf("Attempted a %s connection to %s while the network is "+
+		"forbidden.", network, addr)
+	return ErrNetworkForbidden
+}
+
+// Returns true if the host portion of addr is on the loopback interface.
+func isLoopback(addr string) bool {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Wraps a RoundTripper so requests to non local hosts are blocked.
+type guardedTransport struct {
+	guard *NetworkGuard
+	base  http.RoundTripper
+}
+
+func (g *guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := g.guard.check("tcp", req.URL.Host); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return g.base.RoundTrip(req)
+}
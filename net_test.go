@@ -0,0 +1,178 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestT_FreePort(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+
+	port := T.FreePort()
+	if port <= 0 {
+		t.Fatalf("Invalid port: %d", port)
+	}
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatalf("The port was not free: %s", err)
+	}
+	l.Close()
+}
+
+// Starts an echo server on the given listener which replies to each line
+// with the line prefixed by "echo: ".
+func startEchoServer(l net.Listener) {
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				scanner := bufio.NewScanner(c)
+				for scanner.Scan() {
+					fmt.Fprintf(c, "echo: %s\n", scanner.Text())
+				}
+			}()
+		}
+	}()
+}
+
+// Sends a line over a new connection and returns the reply.
+func echoRoundTrip(t *testing.T, network, addr string) string {
+	c, err := net.Dial(network, addr)
+	if err != nil {
+		t.Fatalf("Unable to connect: %s", err)
+	}
+	defer c.Close()
+	fmt.Fprintf(c, "hello\n")
+	reply, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Unable to read the reply: %s", err)
+	}
+	return reply
+}
+
+func TestT_ForwardTCPToUnix(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+	defer T.Finish()
+
+	path := filepath.Join(T.TempDir(), "s")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	defer l.Close()
+	startEchoServer(l)
+
+	f := T.ForwardTCPToUnix(path)
+	if reply := echoRoundTrip(t, "tcp", f.Addr); reply != "echo: hello\n" {
+		t.Fatalf("Unexpected reply: %q", reply)
+	}
+	T.TryUntil(func() bool { return f.BytesReceived() == 12 }, time.Second)
+	T.Equal(f.Connections(), int64(1))
+	T.Equal(f.BytesSent(), int64(6))
+}
+
+func TestT_ForwardUnixToTCP(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	defer l.Close()
+	startEchoServer(l)
+
+	f := T.ForwardUnixToTCP(l.Addr().String())
+	if reply := echoRoundTrip(t, "unix", f.Addr); reply != "echo: hello\n" {
+		t.Fatalf("Unexpected reply: %q", reply)
+	}
+	T.Equal(f.Connections(), int64(1))
+
+	// Finishing closes the listener and removes the socket.
+	T.Finish()
+	if _, err := net.Dial("unix", f.Addr); err == nil {
+		t.Fatalf("The forwarder was not closed.")
+	}
+}
+
+func TestT_ForbidNetwork(t *testing.T) {
+	m, T := testSetup()
+	original := http.DefaultTransport
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	g := T.ForbidNetwork()
+	m.CheckPass(t, func() {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Local request failed: %s", err)
+		}
+		resp.Body.Close()
+	})
+	m.CheckFail(t, func() {
+		if _, err := http.Get("http://192.0.2.1/"); err == nil {
+			t.Fatalf("Remote request did not fail.")
+		}
+	})
+	m.CheckPass(t, func() {
+		c, err := g.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Local dial failed: %s", err)
+		}
+		c.Close()
+	})
+	m.CheckFail(t, func() {
+		if _, err := g.Dial("tcp", "192.0.2.1:80"); err != ErrNetworkForbidden {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	T.Finish()
+	if http.DefaultTransport != original {
+		t.Fatalf("http.DefaultTransport was not restored.")
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	t.Parallel()
+	for addr, want := range map[string]bool{
+		"127.0.0.1:80":   true,
+		"[::1]:80":       true,
+		"localhost:1234": true,
+		"127.1.2.3":      true,
+		"10.0.0.1:80":    false,
+		"example.com:80": false,
+	} {
+		if have := isLoopback(addr); have != want {
+			t.Fatalf("isLoopback(%q) = %t", addr, have)
+		}
+	}
+}
@@ -0,0 +1,447 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package objectstore provides an HTTP server that speaks a minimal subset
+// of the S3 API so that storage code can be tested without external
+// services. Objects are stored as files in a test managed temporary
+// directory.
+//
+// Only path style requests (http://host/bucket/key) are supported. The
+// operations implemented are ListBuckets, CreateBucket, DeleteBucket,
+// HeadBucket, ListObjectsV2, PutObject, GetObject (including ranges),
+// HeadObject and DeleteObject. Request signatures are not checked.
+package objectstore
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liquidgecka/testlib"
+)
+
+// This file contains the object store server.
+
+// The namespace of every S3 response document.
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// The largest number of keys returned from a single listing.
+const maxListKeys = 1000
+
+// An S3 compatible object store. The server is shut down when the test
+// finishes.
+type Store struct {
+	// The endpoint clients should be configured with, for example
+	// http://127.0.0.1:12345.
+	URL string
+
+	// The directory that contains a sub directory for every bucket.
+	Dir string
+
+	t      *testlib.T
+	server *httptest.Server
+	lock   sync.Mutex
+}
+
+// Starts a new object store containing the given (empty) buckets.
+func New(t *testlib.T, buckets ...string) *Store {
+	s := &Store{Dir: t.TempDir(), t: t}
+	for _, bucket := range buckets {
+		s.CreateBucket(bucket)
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	t.AddNamedFinalizer("object store "+s.URL, s.server.Close)
+	return s
+}
+
+// Creates a bucket, failing the test if it can not be created.
+func (s *Store) CreateBucket(bucket string) {
+	s.t.ExpectSuccess(os.Mkdir(filepath.Join(s.Dir, bucket), 0755),
+		"Unable to create bucket "+bucket)
+}
+
+// Stores an object directly, bypassing the HTTP API. This is intended for
+// seeding the store before the code under test runs.
+func (s *Store) PutObject(bucket, key string, data []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.t.ExpectSuccess(os.WriteFile(s.objectPath(bucket, key), data, 0644),
+		"Unable to write object "+bucket+"/"+key)
+}
+
+// Returns the contents of an object, failing the test if it does not
+// exist.
+func (s *Store) Object(bucket, key string) []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	data, err := os.ReadFile(s.objectPath(bucket, key))
+	s.t.ExpectSuccess(err, "Unable to read object "+bucket+"/"+key)
+	return data
+}
+
+// Fails the test if the object does not exist or does not contain want.
+func (s *Store) ExpectObject(bucket, key, want string, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	s.lock.Lock()
+	data, err := os.ReadFile(s.objectPath(bucket, key))
+	s.lock.Unlock()
+	if os.IsNotExist(err) {
+		s.t.Fatalf("%sObject %s/%s does not exist.", prefix, bucket, key)
+	}
+	s.t.ExpectSuccess(err, prefix+"Unable to read object "+bucket+"/"+key)
+	s.t.Equal(string(data), want, prefix+"object "+bucket+"/"+key)
+}
+
+// Fails the test if the object exists.
+func (s *Store) ExpectNoObject(bucket, key string, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	s.lock.Lock()
+	_, err := os.Stat(s.objectPath(bucket, key))
+	s.lock.Unlock()
+	if err == nil {
+		s.t.Fatalf("%sObject %s/%s exists.", prefix, bucket, key)
+	}
+}
+
+// Returns the file an object is stored in. Keys are escaped so that every
+// object is a single file directly inside of the bucket directory.
+func (s *Store) objectPath(bucket, key string) string {
+	name := url.PathEscape(key)
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + name[1:]
+	}
+	return filepath.Join(s.Dir, bucket, name)
+}
+
+// Returns true if the bucket name is one this store can represent.
+func validBucket(bucket string) bool {
+	return bucket != "" && bucket != "." && bucket != ".." &&
+		!strings.ContainsAny(bucket, `/\`)
+}
+
+// An S3 error response.
+type s3Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string
+	Message  string
+	Resource string
+}
+
+// Writes an S3 XML document.
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	io.WriteString(w, xml.Header)
+	w.Write(data)
+}
+
+// Writes an S3 error response.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	writeXML(w, status, s3Error{Code: code, Message: msg, Resource: r.URL.Path})
+}
+
+// Dispatches a request to the matching S3 operation.
+func (s *Store) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		bucket, key = path[:i], path[i+1:]
+	}
+	if bucket == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed",
+				"The specified method is not allowed.")
+			return
+		}
+		s.listBuckets(w)
+		return
+	} else if !validBucket(bucket) {
+		writeError(w, r, http.StatusBadRequest, "InvalidBucketName",
+			"The specified bucket is not valid.")
+		return
+	}
+
+	dir := filepath.Join(s.Dir, bucket)
+	if key == "" && r.Method == http.MethodPut {
+		if err := os.Mkdir(dir, 0755); os.IsExist(err) {
+			writeError(w, r, http.StatusConflict, "BucketAlreadyOwnedByYou",
+				"The bucket already exists.")
+		} else if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		}
+		return
+	} else if _, err := os.Stat(dir); err != nil {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket",
+			"The specified bucket does not exist.")
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+	case key == "" && r.Method == http.MethodGet:
+		s.listObjects(w, r, bucket)
+	case key == "" && r.Method == http.MethodDelete:
+		if err := os.Remove(dir); err != nil {
+			writeError(w, r, http.StatusConflict, "BucketNotEmpty",
+				"The bucket you tried to delete is not empty.")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		s.putObject(w, r, bucket, key)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.getObject(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		if err := os.Remove(s.objectPath(bucket, key)); err != nil &&
+			!os.IsNotExist(err) {
+			writeError(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed",
+			"The specified method is not allowed.")
+	}
+}
+
+// Returns the quoted ETag of an object's contents.
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// Implements PutObject.
+func (s *Store) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if err := os.WriteFile(s.objectPath(bucket, key), data, 0644); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	w.Header().Set("ETag", etag(data))
+}
+
+// Implements GetObject and HeadObject.
+func (s *Store) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	path := s.objectPath(bucket, key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "NoSuchKey",
+			"The specified key does not exist.")
+		return
+	}
+	var modified time.Time
+	if info, err := os.Stat(path); err == nil {
+		modified = info.ModTime()
+	}
+	w.Header().Set("ETag", etag(data))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, key, modified, bytes.NewReader(data))
+}
+
+// The response to ListBuckets.
+type listBucketsResult struct {
+	XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Owner   struct {
+		ID          string
+		DisplayName string
+	}
+	Buckets []bucketInfo `xml:"Buckets>Bucket"`
+}
+
+// A single bucket in a ListBuckets response.
+type bucketInfo struct {
+	Name         string
+	CreationDate string
+}
+
+// Implements ListBuckets.
+func (s *Store) listBuckets(w http.ResponseWriter) {
+	result := listBucketsResult{Xmlns: s3Namespace}
+	result.Owner.ID = "testlib"
+	result.Owner.DisplayName = "testlib"
+	infos, _ := os.ReadDir(s.Dir)
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		created := time.Time{}
+		if fi, err := info.Info(); err == nil {
+			created = fi.ModTime()
+		}
+		result.Buckets = append(result.Buckets, bucketInfo{
+			Name:         info.Name(),
+			CreationDate: created.UTC().Format(time.RFC3339),
+		})
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+// The response to ListObjectsV2.
+type listObjectsResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Xmlns                 string   `xml:"xmlns,attr"`
+	Name                  string
+	Prefix                string
+	Delimiter             string `xml:",omitempty"`
+	StartAfter            string `xml:",omitempty"`
+	ContinuationToken     string `xml:",omitempty"`
+	NextContinuationToken string `xml:",omitempty"`
+	KeyCount              int
+	MaxKeys               int
+	IsTruncated           bool
+	Contents              []objectInfo
+	CommonPrefixes        []commonPrefix
+}
+
+// A single object in a ListObjectsV2 response.
+type objectInfo struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+// A key prefix rolled up by the delimiter in a ListObjectsV2 response.
+type commonPrefix struct {
+	Prefix string
+}
+
+// Implements ListObjectsV2. Continuation tokens are simply the last key
+// that was returned.
+func (s *Store) listObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	result := listObjectsResult{
+		Xmlns:             s3Namespace,
+		Name:              bucket,
+		Prefix:            query.Get("prefix"),
+		Delimiter:         query.Get("delimiter"),
+		StartAfter:        query.Get("start-after"),
+		ContinuationToken: query.Get("continuation-token"),
+		MaxKeys:           maxListKeys,
+	}
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument",
+				"Invalid max-keys.")
+			return
+		} else if n < maxListKeys {
+			result.MaxKeys = n
+		}
+	}
+	after := result.StartAfter
+	if result.ContinuationToken > after {
+		after = result.ContinuationToken
+	}
+
+	infos, err := os.ReadDir(filepath.Join(s.Dir, bucket))
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	keys := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if key, err := url.PathUnescape(info.Name()); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if key <= after || !strings.HasPrefix(key, result.Prefix) {
+			continue
+		}
+		// Keys rolled up into a common prefix that ended the previous
+		// page are also skipped.
+		d := result.Delimiter
+		if d != "" && strings.HasSuffix(after, d) && strings.HasPrefix(key, after) {
+			continue
+		}
+		rest := key[len(result.Prefix):]
+		if d != "" && strings.Contains(rest, d) {
+			p := result.Prefix + rest[:strings.Index(rest, d)+len(d)]
+			if seen[p] {
+				continue
+			}
+			if result.KeyCount == result.MaxKeys {
+				result.IsTruncated = true
+				break
+			}
+			seen[p] = true
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{p})
+			result.KeyCount++
+			result.NextContinuationToken = p
+			continue
+		}
+		if result.KeyCount == result.MaxKeys {
+			result.IsTruncated = true
+			break
+		}
+		path := s.objectPath(bucket, key)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		info := objectInfo{
+			Key:          key,
+			ETag:         etag(data),
+			Size:         int64(len(data)),
+			StorageClass: "STANDARD",
+		}
+		if fi, err := os.Stat(path); err == nil {
+			info.LastModified = fi.ModTime().UTC().Format(time.RFC3339)
+		}
+		result.Contents = append(result.Contents, info)
+		result.KeyCount++
+		result.NextContinuationToken = key
+	}
+	if !result.IsTruncated {
+		result.NextContinuationToken = ""
+	}
+	writeXML(w, http.StatusOK, result)
+}
@@ -0,0 +1,166 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/liquidgecka/testlib"
+)
+
+// Performs a request against the store returning the status and body.
+func do(t *testing.T, method, url, body string, header ...string) (int, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Unable to create request: %s", err)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Unable to read response: %s", err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestStore_Objects(t *testing.T) {
+	t.Parallel()
+	T := testlib.NewT(t)
+	defer T.Finish()
+
+	s := New(T, "bucket")
+	if status, _ := do(t, "PUT", s.URL+"/bucket/dir/a.txt", "hello"); status != 200 {
+		t.Fatalf("Unexpected status for PUT: %d", status)
+	}
+	s.ExpectObject("bucket", "dir/a.txt", "hello")
+
+	status, body := do(t, "GET", s.URL+"/bucket/dir/a.txt", "")
+	if status != 200 || body != "hello" {
+		t.Fatalf("Unexpected GET response: %d %q", status, body)
+	}
+	status, body = do(t, "GET", s.URL+"/bucket/dir/a.txt", "", "Range", "bytes=1-3")
+	if status != 206 || body != "ell" {
+		t.Fatalf("Unexpected ranged GET response: %d %q", status, body)
+	}
+	if status, _ := do(t, "HEAD", s.URL+"/bucket/dir/a.txt", ""); status != 200 {
+		t.Fatalf("Unexpected status for HEAD: %d", status)
+	}
+	status, body = do(t, "GET", s.URL+"/bucket/missing", "")
+	if status != 404 || !strings.Contains(body, "<Code>NoSuchKey</Code>") {
+		t.Fatalf("Unexpected response for a missing key: %d %q", status, body)
+	}
+	status, body = do(t, "GET", s.URL+"/nobucket/key", "")
+	if status != 404 || !strings.Contains(body, "<Code>NoSuchBucket</Code>") {
+		t.Fatalf("Unexpected response for a missing bucket: %d %q", status, body)
+	}
+
+	// Keys that would otherwise be special file names.
+	s.PutObject("bucket", "..", []byte("dots"))
+	if string(s.Object("bucket", "..")) != "dots" {
+		t.Fatalf("Unable to read back a dot key.")
+	}
+
+	if status, _ := do(t, "DELETE", s.URL+"/bucket/dir/a.txt", ""); status != 204 {
+		t.Fatalf("Unexpected status for DELETE: %d", status)
+	}
+	s.ExpectNoObject("bucket", "dir/a.txt")
+}
+
+func TestStore_Buckets(t *testing.T) {
+	t.Parallel()
+	T := testlib.NewT(t)
+	defer T.Finish()
+
+	s := New(T)
+	if status, _ := do(t, "PUT", s.URL+"/one", ""); status != 200 {
+		t.Fatalf("Unexpected status creating a bucket: %d", status)
+	}
+	if status, _ := do(t, "PUT", s.URL+"/one", ""); status != 409 {
+		t.Fatalf("Unexpected status recreating a bucket: %d", status)
+	}
+	s.CreateBucket("two")
+	status, body := do(t, "GET", s.URL+"/", "")
+	var result listBucketsResult
+	if err := xml.Unmarshal([]byte(body), &result); err != nil || status != 200 {
+		t.Fatalf("Unexpected response: %d %q (%v)", status, body, err)
+	} else if len(result.Buckets) != 2 || result.Buckets[0].Name != "one" ||
+		result.Buckets[1].Name != "two" {
+		t.Fatalf("Unexpected buckets: %#v", result.Buckets)
+	}
+
+	s.PutObject("one", "key", nil)
+	if status, _ := do(t, "DELETE", s.URL+"/one", ""); status != 409 {
+		t.Fatalf("Unexpected status deleting a non empty bucket: %d", status)
+	}
+	if status, _ := do(t, "DELETE", s.URL+"/two", ""); status != 204 {
+		t.Fatalf("Unexpected status deleting a bucket: %d", status)
+	}
+	if status, _ := do(t, "HEAD", s.URL+"/two", ""); status != 404 {
+		t.Fatalf("Unexpected status for a deleted bucket: %d", status)
+	}
+}
+
+func TestStore_ListObjects(t *testing.T) {
+	t.Parallel()
+	T := testlib.NewT(t)
+	defer T.Finish()
+
+	s := New(T, "b")
+	for _, key := range []string{"a", "d/1", "d/2", "e/1", "f"} {
+		s.PutObject("b", key, []byte(key))
+	}
+	list := func(query string) (keys []string, token string) {
+		status, body := do(t, "GET", s.URL+"/b?list-type=2"+query, "")
+		var result listObjectsResult
+		if err := xml.Unmarshal([]byte(body), &result); err != nil || status != 200 {
+			t.Fatalf("Unexpected response: %d %q (%v)", status, body, err)
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		for _, p := range result.CommonPrefixes {
+			keys = append(keys, p.Prefix)
+		}
+		if result.KeyCount != len(keys) {
+			t.Fatalf("Wrong KeyCount in %q", body)
+		}
+		return keys, result.NextContinuationToken
+	}
+
+	keys, token := list("")
+	T.Equal(keys, []string{"a", "d/1", "d/2", "e/1", "f"})
+	T.Equal(token, "")
+	keys, _ = list("&prefix=d/")
+	T.Equal(keys, []string{"d/1", "d/2"})
+	keys, _ = list("&delimiter=/")
+	T.Equal(keys, []string{"a", "f", "d/", "e/"})
+
+	// Page through the rolled up listing two entries at a time.
+	keys, token = list("&delimiter=/&max-keys=2")
+	T.Equal(keys, []string{"a", "d/"})
+	keys, token = list("&delimiter=/&max-keys=2&continuation-token=" + token)
+	T.Equal(keys, []string{"f", "e/"})
+	T.Equal(token, "")
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// This file contains functions for temporarily replacing variables.
+
+// Sets the variable that target points to to value, restoring the original
+// value when the test finishes. This is the technique this library uses to
+// test its own error handling: calls that can fail (like os.Chmod) are made
+// through package level function variables (see mocks.go) which tests
+// replace with versions that fail. For example:
+//
+//	var osRemove = os.Remove
+//
+//	func TestCleanupError(t *testing.T) {
+//		T := testlib.NewT(t)
+//		defer T.Finish()
+//		T.Override(&osRemove, func(string) error {
+//			return syscall.EACCES
+//		})
+//		...
+//	}
+//
+// The variables this library calls through are exported as pointers (for
+// example OSChmodVar) so that they can be overridden from other packages:
+//
+//	T.Override(testlib.OSChmodVar, func(string, os.FileMode) error {
+//		return syscall.EPERM
+//	})
+//
+// Any variable can be overridden, not just functions. The value must be
+// assignable to the variable's type, or nil if the type can be nil. Since
+// the variable is usually shared by the whole package this should not be
+// used in tests that call t.Parallel().
+func (t *T) Override(target interface{}, value interface{}) {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		t.Fatalf("Override requires a non nil pointer to a variable, not %T.",
+			target)
+	}
+	elem := ptr.Elem()
+	var v reflect.Value
+	if value == nil {
+		switch elem.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map,
+			reflect.Ptr, reflect.Slice:
+			v = reflect.Zero(elem.Type())
+		default:
+			t.Fatalf("Can not override a %s with nil.", elem.Type())
+		}
+	} else if v = reflect.ValueOf(value); !v.Type().AssignableTo(elem.Type()) {
+		t.Fatalf("Can not override a %s with a %s.", elem.Type(), v.Type())
+	}
+
+	old := reflect.New(elem.Type()).Elem()
+	old.Set(elem)
+	elem.Set(v)
+	t.AddNamedFinalizer(fmt.Sprintf("restore %s", elem.Type()), func() {
+		elem.Set(old)
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/liquidgecka/testlib"
+)
+
+func TestOverride_External(t *testing.T) {
+	T := testlib.NewT(t)
+	defer T.Finish()
+
+	chmods := []string{}
+	T.Override(testlib.OSChmodVar, func(path string, mode os.FileMode) error {
+		chmods = append(chmods, path)
+		return nil
+	})
+	dir := T.TempDir()
+	T.ReadOnlyDir(dir)
+	if len(chmods) == 0 || chmods[len(chmods)-1] != dir {
+		t.Fatalf("The override was not used: %#v", chmods)
+	}
+
+	T.Override(testlib.OSGetenvVar, func(key string) string {
+		if key == "TESTLIB_TAGS" {
+			return "fast"
+		}
+		return os.Getenv(key)
+	})
+	skipped := false
+	t.Run("slow", func(t *testing.T) {
+		defer func() { skipped = t.Skipped() }()
+		sub := testlib.NewT(t)
+		defer sub.Finish()
+		sub.Tag("slow")
+	})
+	if !skipped {
+		t.Fatalf("The overridden environment was not used.")
+	}
+
+	expected := errors.New("EXPECTED")
+	T.Override(testlib.IoutilTempFileVar,
+		func(string, string) (*os.File, error) { return nil, expected })
+	if _, err := (*testlib.IoutilTempFileVar)("", ""); err != expected {
+		t.Fatalf("The override was not applied: %v", err)
+	}
+}
+
+func TestOverride_ExternalRestored(t *testing.T) {
+	T := testlib.NewT(t)
+	func() {
+		defer T.Finish()
+		T.Override(testlib.OSRemoveVar, func(string) error {
+			return errors.New("EXPECTED")
+		})
+	}()
+	err := (*testlib.OSRemoveVar)("/nonexistent")
+	if err == nil || strings.Contains(err.Error(), "EXPECTED") {
+		t.Fatalf("The original function was not restored: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestT_Override(t *testing.T) {
+	m, T := testSetup()
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	expected := errors.New("EXPECTED")
+	number := 1
+	var ptr *int = &number
+	m.CheckPass(t, func() {
+		T.Override(&osRemove, func(string) error { return expected })
+		T.Override(&number, 2)
+		T.Override(&ptr, nil)
+	})
+	if err := osRemove("/nonexistent"); err != expected {
+		t.Fatalf("The function was not overridden: %v", err)
+	} else if number != 2 {
+		t.Fatalf("The int was not overridden: %d", number)
+	} else if ptr != nil {
+		t.Fatalf("The pointer was not set to nil.")
+	}
+
+	m.CheckFail(t, func() { T.Override(number, 3) })
+	if !strings.Contains(msg, "non nil pointer to a variable, not int") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { T.Override(&number, "3") })
+	if !strings.Contains(msg, "Can not override a int with a string.") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { T.Override(&number, nil) })
+	if !strings.Contains(msg, "Can not override a int with nil.") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	T.Finish()
+	if err := osRemove("/nonexistent"); !os.IsNotExist(err) {
+		t.Fatalf("The function was not restored: %v", err)
+	} else if number != 1 {
+		t.Fatalf("The int was not restored: %d", number)
+	} else if ptr != &number {
+		t.Fatalf("The pointer was not restored.")
+	}
+}
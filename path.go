@@ -0,0 +1,88 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// This file contains the grammar used to address a value inside of a
+// structure. The same paths are used in Equal failures, in the ignores list
+// given to EqualWithIgnores() and in the results of DiffPaths().
+//
+// A path is a list of segments, one for each step taken from the root value:
+//
+//   Field        a struct field of the root value
+//   .Field       a struct field of any other value
+//   [3]          an element of a slice or array
+//   ["key"]      an entry in a map keyed by strings
+//   [42]         an entry in a map keyed by numbers, likewise for bools
+//
+// Pointers and interfaces are followed without adding a segment. Map keys
+// are rendered as Go literals so string keys are always double quoted and
+// can never be confused with an index. For example a path might look like:
+//
+//   Users["bob"].Groups[2].Name
+
+// Renders a map key as it appears inside of the brackets of a path.
+func mapKeyPath(k reflect.Value) string {
+	switch k.Kind() {
+	case reflect.Interface:
+		if k.IsNil() {
+			return "nil"
+		}
+		return mapKeyPath(k.Elem())
+	case reflect.String:
+		return strconv.Quote(k.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(k.Float(), 'g', -1, k.Type().Bits())
+	case reflect.Bool:
+		return strconv.FormatBool(k.Bool())
+	}
+	return fmt.Sprintf("%#v", k)
+}
+
+// Matches a map segment in the format used by older versions of this
+// library, which quoted keys with %q and added a trailing space. Numeric
+// keys were rendered as rune literals.
+var legacyMapSegment = regexp.MustCompile(
+	`\[('(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*")\] `)
+
+// Converts an ignore path written for older versions of this library into
+// the current grammar. Paths already in the current grammar are returned
+// unchanged. A leading dot is also accepted on the first field.
+func normalizePath(path string) string {
+	path = strings.TrimPrefix(path, ".")
+	return legacyMapSegment.ReplaceAllStringFunc(path, func(s string) string {
+		key := s[1 : len(s)-2]
+		if key[0] == '\'' {
+			if r, _, tail, err := strconv.UnquoteChar(
+				key[1:len(key)-1], '\''); err == nil && tail == "" {
+				key = strconv.Itoa(int(r))
+			}
+		}
+		return "[" + key + "]"
+	})
+}
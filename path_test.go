@@ -0,0 +1,108 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMapKeyPath(t *testing.T) {
+	t.Parallel()
+	type key struct{ A int }
+	tests := []struct {
+		key  interface{}
+		want string
+	}{
+		{"a", `"a"`},
+		{"with \"quotes\"] ", `"with \"quotes\"] "`},
+		{int8(-3), "-3"},
+		{uint(7), "7"},
+		{1.5, "1.5"},
+		{true, "true"},
+		{key{1}, "testlib.key{A:1}"},
+	}
+	for _, test := range tests {
+		if have := mapKeyPath(reflect.ValueOf(test.key)); have != test.want {
+			t.Fatalf("mapKeyPath(%#v) = %s, want %s", test.key, have, test.want)
+		}
+	}
+
+	// Interface keys render the dynamic value.
+	m := reflect.ValueOf(map[interface{}]int{"a": 1})
+	if have := mapKeyPath(m.MapKeys()[0]); have != `"a"` {
+		t.Fatalf("Unexpected interface key path: %s", have)
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"":                       "",
+		"Field":                  "Field",
+		".Field":                 "Field",
+		`m["a"]`:                 `m["a"]`,
+		`m["a"] `:                `m["a"]`,
+		`m["a"] .Field[2]`:       `m["a"].Field[2]`,
+		`m['\x01'] `:             `m[1]`,
+		`m['a'] ["b"] `:          `m[97]["b"]`,
+		`m["] "] `:               `m["] "]`,
+		`Users["bob"].Groups[2]`: `Users["bob"].Groups[2]`,
+	}
+	for in, want := range tests {
+		if have := normalizePath(in); have != want {
+			t.Fatalf("normalizePath(%q) = %q, want %q", in, have, want)
+		}
+	}
+}
+
+func TestPaths_Consistent(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	type group struct{ Name string }
+	type user struct{ Groups []group }
+	have := map[string]user{
+		"bob": {Groups: []group{{"a"}, {"b"}, {"c"}}},
+	}
+	want := map[string]user{
+		"bob": {Groups: []group{{"a"}, {"b"}, {"d"}}},
+	}
+	paths := T.DiffPaths(have, want)
+	if strings.Join(paths, ",") != `["bob"].Groups[2].Name` {
+		t.Fatalf("Unexpected paths returned: %#v", paths)
+	}
+	m.CheckPass(t, func() { T.EqualWithIgnores(have, want, paths) })
+
+	// Integer keys and missing entries.
+	haveInts := map[int]string{1: "a", 2: "b"}
+	wantInts := map[int]string{1: "a", 3: "c"}
+	paths = T.DiffPaths(haveInts, wantInts)
+	sort.Strings(paths)
+	if strings.Join(paths, ",") != "[2],[3]" {
+		t.Fatalf("Unexpected paths returned: %#v", paths)
+	}
+
+	// Ignore strings from older versions are still honored.
+	m.CheckPass(t, func() {
+		T.EqualWithIgnores(have, want, []string{`["bob"] .Groups[2]`})
+	})
+	m.CheckPass(t, func() {
+		T.EqualWithIgnores(haveInts, wantInts,
+			[]string{`['\x02'] `, `['\x03'] `})
+	})
+}
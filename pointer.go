@@ -0,0 +1,77 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// This file contains functions for comparing the memory that raw pointers
+// point at.
+
+// Registers the type of the memory that values of typ point at. typ must be
+// a uintptr or unsafe.Pointer type, usually a named one like:
+//
+//	type cBuffer unsafe.Pointer
+//
+// By default Equal compares raw pointers by address, which is meaningless
+// for pointers handed across a cgo boundary since two equal buffers almost
+// never share an address. Once a hint is registered two differing, non
+// zero, pointers of type typ are instead compared by reading the memory
+// they point at as a value of type elem, so differences are reported field
+// by field. To compare a fixed number of raw bytes use an array type:
+//
+//	T.RegisterPointerHint(
+//		reflect.TypeOf(cBuffer(nil)),
+//		reflect.ArrayOf(64, reflect.TypeOf(byte(0))))
+//
+// The pointers must point at valid memory of at least elem.Size() bytes,
+// otherwise comparing them may crash the test binary.
+func (t *T) RegisterPointerHint(typ, elem reflect.Type) {
+	if k := typ.Kind(); k != reflect.Uintptr && k != reflect.UnsafePointer {
+		t.Fatalf("Pointer hints can only be registered for uintptr or "+
+			"unsafe.Pointer types, not %s.", typ)
+	}
+	if t.pointerHints == nil {
+		t.pointerHints = make(map[reflect.Type]reflect.Type)
+	}
+	t.pointerHints[typ] = elem
+}
+
+// If a pointer hint is registered for the type of have and want, and both
+// are non zero, then this returns the values that they point at.
+func (t *T) pointedValues(have, want reflect.Value) (
+	hv, wv reflect.Value, ok bool,
+) {
+	elem, ok := t.pointerHints[want.Type()]
+	if !ok {
+		return hv, wv, false
+	}
+	hp, wp := rawPointer(have), rawPointer(want)
+	if hp == nil || wp == nil {
+		return hv, wv, false
+	}
+	return reflect.NewAt(elem, hp).Elem(), reflect.NewAt(elem, wp).Elem(), true
+}
+
+// Returns the address held in a uintptr or unsafe.Pointer value.
+func rawPointer(v reflect.Value) unsafe.Pointer {
+	if v.Kind() == reflect.UnsafePointer {
+		return unsafe.Pointer(v.Pointer())
+	}
+	addr := uintptr(v.Uint())
+	return *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+}
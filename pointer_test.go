@@ -0,0 +1,91 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+type testCBuffer unsafe.Pointer
+type testCAddr uintptr
+
+type testCPoint struct {
+	X, Y int32
+}
+
+func TestT_RegisterPointerHint(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+
+	type wrapper struct {
+		Buf  testCBuffer
+		Addr testCAddr
+	}
+	a := &[4]byte{1, 2, 3, 4}
+	b := &[4]byte{1, 2, 3, 4}
+	c := &[4]byte{1, 2, 9, 4}
+	pa := &testCPoint{1, 2}
+	pb := &testCPoint{1, 2}
+	pc := &testCPoint{1, 3}
+	addr := func(p *testCPoint) testCAddr {
+		return testCAddr(uintptr(unsafe.Pointer(p)))
+	}
+
+	// Without hints the pointers are compared by address.
+	m.CheckFail(t, func() {
+		T.Equal(
+			wrapper{testCBuffer(a), addr(pa)},
+			wrapper{testCBuffer(b), addr(pb)})
+	})
+
+	T.RegisterPointerHint(
+		reflect.TypeOf(testCBuffer(nil)),
+		reflect.ArrayOf(4, reflect.TypeOf(byte(0))))
+	T.RegisterPointerHint(
+		reflect.TypeOf(testCAddr(0)), reflect.TypeOf(testCPoint{}))
+	m.CheckPass(t, func() {
+		T.Equal(
+			wrapper{testCBuffer(a), addr(pa)},
+			wrapper{testCBuffer(b), addr(pb)})
+	})
+
+	m.CheckFail(t, func() {
+		T.Equal(
+			wrapper{testCBuffer(a), addr(pa)},
+			wrapper{testCBuffer(c), addr(pc)})
+	})
+	if !strings.Contains(msg, "Buf[2]: not equal") {
+		t.Fatalf("Unexpected message: %s", msg)
+	} else if !strings.Contains(msg, "Addr.Y: not equal") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	// Nil pointers are still compared by address.
+	m.CheckFail(t, func() {
+		T.Equal(wrapper{Buf: testCBuffer(a)}, wrapper{})
+	})
+
+	// Only pointer types can have hints.
+	m.CheckFail(t, func() {
+		T.RegisterPointerHint(reflect.TypeOf(0), reflect.TypeOf(0))
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file contains functions for profiling failing tests.
+
+// If this environment variable is set then profiles are written to the
+// named directory rather than RootTempDir(), which is removed when the test
+// binary exits.
+const profileDirEnv = "TESTLIB_PROFILE_DIR"
+
+// Starts a CPU profile for the test. If the test fails, or is still running
+// after timeout, then the CPU profile and a snapshot of the heap are written
+// to files whose paths are included in the test output so they can be
+// examined with "go tool pprof". A timeout of zero disables the timeout.
+// Profiles of passing tests are thrown away.
+//
+// The files are written to RootTempDir(), or to the directory named by the
+// TESTLIB_PROFILE_DIR environment variable if it is set. Since only one CPU
+// profile can run per process this should not be used in tests that call
+// t.Parallel(). If CPU profiling is unavailable (for example when the test
+// binary was run with -cpuprofile) then only the heap is captured.
+func (t *T) ProfileOnFailure(timeout time.Duration) {
+	cpu := &bytes.Buffer{}
+	cpuErr := pprof.StartCPUProfile(cpu)
+	if cpuErr != nil {
+		t.Logf("Unable to start CPU profiling, only the heap will be "+
+			"captured: %s", cpuErr)
+	}
+	stop := func() {
+		if cpuErr == nil {
+			pprof.StopCPUProfile()
+		}
+	}
+
+	var once sync.Once
+	paths := ""
+	write := func() string {
+		once.Do(func() {
+			stop()
+			paths = t.writeProfiles(cpu, cpuErr == nil)
+		})
+		return paths
+	}
+
+	var timer *time.Timer
+	reported := make(chan struct{})
+	if timeout > 0 {
+		timer = time.AfterFunc(timeout, func() {
+			defer close(reported)
+			t.Errorf("Test is still running after %s. Profiles:\n%s",
+				timeout, write())
+		})
+	}
+	t.AddNamedFinalizer("profile on failure", func() {
+		if timer != nil && !timer.Stop() {
+			<-reported
+			return
+		} else if !t.Failed() {
+			once.Do(stop)
+			return
+		}
+		t.Logf("Profiles of the failed test:\n%s", write())
+	})
+}
+
+// Writes the CPU profile (if enabled) and a heap profile to files, returning
+// a description of where each was written.
+func (t *T) writeProfiles(cpu *bytes.Buffer, cpuEnabled bool) string {
+	dir := osGetenv(profileDirEnv)
+	if dir == "" {
+		dir = t.RootTempDir()
+	}
+	lines := make([]string, 0, 2)
+	save := func(kind string, data []byte) {
+		f, err := ioutilTempFile(dir, t.tempPrefix()+"-"+kind+"-*.pprof")
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("  %s: unable to write: %s",
+				kind, err))
+			return
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("  %s: unable to write %s: %s",
+				kind, f.Name(), err))
+			return
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", kind, f.Name()))
+	}
+
+	if cpuEnabled {
+		save("cpu", cpu.Bytes())
+	}
+	// Collect garbage first so the heap profile reflects live objects.
+	runtime.GC()
+	heap := &bytes.Buffer{}
+	if err := pprof.WriteHeapProfile(heap); err != nil {
+		lines = append(lines, fmt.Sprintf("  heap: unable to profile: %s", err))
+	} else {
+		save("heap", heap.Bytes())
+	}
+	return strings.Join(lines, "\n")
+}
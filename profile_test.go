@@ -0,0 +1,89 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Checks that each profile kind is listed in msg and that the file exists
+// and is not empty.
+func checkProfiles(t *testing.T, msg string, kinds ...string) {
+	for _, kind := range kinds {
+		match := regexp.MustCompile(kind + `: (\S+\.pprof)`).FindStringSubmatch(msg)
+		if match == nil {
+			t.Fatalf("The %s profile was not reported: %s", kind, msg)
+		}
+		info, err := os.Stat(match[1])
+		if err != nil {
+			t.Fatalf("The %s profile was not written: %s", kind, err)
+		} else if info.Size() == 0 {
+			t.Fatalf("The %s profile is empty.", kind)
+		}
+	}
+}
+
+func TestT_ProfileOnFailure(t *testing.T) {
+	// CPU profiling is process wide so this test can not be parallel.
+
+	// Profiles of passing tests are discarded.
+	m, T := testSetup()
+	msg := ""
+	m.funcLogf = func(f string, args ...interface{}) {
+		msg += fmt.Sprintf(f, args...)
+	}
+	T.ProfileOnFailure(0)
+	m.CheckPass(t, func() { T.Finish() })
+	if strings.Contains(msg, "pprof") {
+		t.Fatalf("Profiles were written for a passing test: %s", msg)
+	}
+
+	// A failing test reports both profiles.
+	m, T = testSetup()
+	msg = ""
+	m.funcLogf = func(f string, args ...interface{}) {
+		msg += fmt.Sprintf(f, args...)
+	}
+	T.ProfileOnFailure(0)
+	m.failed = true
+	T.Finish()
+	if !strings.Contains(msg, "Profiles of the failed test") {
+		t.Fatalf("The profiles were not logged: %s", msg)
+	}
+	checkProfiles(t, msg, "cpu", "heap")
+
+	// A test that runs past the timeout reports the profiles as an error.
+	m, T = testSetup()
+	errors := make(chan string, 1)
+	m.funcError = func(args ...interface{}) {
+		errors <- fmt.Sprint(args...)
+	}
+	T.ProfileOnFailure(time.Millisecond * 10)
+	select {
+	case msg = <-errors:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("The timeout was never reported.")
+	}
+	T.Finish()
+	if !strings.Contains(msg, "still running after 10ms") {
+		t.Fatalf("The timeout was not reported: %s", msg)
+	}
+	checkProfiles(t, msg, "cpu", "heap")
+}
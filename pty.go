@@ -0,0 +1,151 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file contains a pseudo terminal harness for testing interactive
+// command line tools.
+
+// Returned on platforms that do not support pseudo terminals.
+var errNoPTY = errors.New("pseudo terminals are only supported on Linux")
+
+// Allocates a new pseudo terminal for the test. The terminal side (Tty)
+// should be given to the process under test as its standard input, output
+// and error, after which the test can type into it with Send() and wait for
+// prompts with ExpectOutput(). Everything is closed when the test finishes.
+// If the platform does not support pseudo terminals then the test is
+// skipped.
+//
+// The terminal starts in the default (cooked) mode, so input is echoed and
+// is only delivered to the process a line at a time, just as it would be
+// for a user typing into a real terminal.
+func (t *T) PTY() *PTY {
+	master, tty, err := openPTY()
+	if err == errNoPTY {
+		t.Skipf("%s", err)
+	}
+	t.ExpectSuccess(err, "Unable to allocate a pseudo terminal")
+	p := &PTY{Tty: tty, t: t, master: master, done: make(chan struct{})}
+	go p.read()
+	t.AddNamedFinalizer("close pty "+tty.Name(), p.close)
+	return p
+}
+
+// A pseudo terminal returned from T.PTY().
+type PTY struct {
+	// The terminal side of the pair. This is what a process under test
+	// should use as its stdin, stdout and stderr.
+	Tty *os.File
+
+	t      *T
+	master *os.File
+	done   chan struct{}
+
+	// Everything written to the terminal and how much of it has been
+	// consumed by ExpectOutput(). Both are protected by lock.
+	lock     sync.Mutex
+	output   []byte
+	consumed int
+}
+
+// Copies everything written to the terminal into output until the master
+// side is closed.
+func (p *PTY) read() {
+	defer close(p.done)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := p.master.Read(buf)
+		p.lock.Lock()
+		p.output = append(p.output, buf[:n]...)
+		p.lock.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Closes both sides of the terminal.
+func (p *PTY) close() {
+	p.master.Close()
+	<-p.done
+	p.Tty.Close()
+}
+
+// Writes p to the terminal as if it had been typed.
+func (p *PTY) Write(b []byte) (int, error) {
+	return p.master.Write(b)
+}
+
+// Types keys into the terminal, failing the test if they can not be
+// written. Use "\n" to press enter and "\x03" (Ctrl-C), "\x04" (Ctrl-D)
+// and so on for control keys.
+func (p *PTY) Send(keys string) {
+	_, err := p.master.Write([]byte(keys))
+	p.t.ExpectSuccess(err, "Unable to write to the pseudo terminal")
+}
+
+// Returns everything that has been written to the terminal so far,
+// including input that was echoed.
+func (p *PTY) Output() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return string(p.output)
+}
+
+// Waits for output matching the regular expression re to be written to the
+// terminal and returns the match. Output is consumed up to the end of the
+// match, so each call starts searching after the previous match. If nothing
+// matches within timeout then the test fails with the output that was seen
+// while waiting.
+func (p *PTY) ExpectOutput(
+	re string, timeout time.Duration, desc ...string,
+) string {
+	defer p.t.trackWait("PTY.ExpectOutput")()
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	r, err := regexp.Compile(re)
+	if err != nil {
+		p.t.Fatalf("%sInvalid regular expression %q: %s", prefix, re, err)
+	}
+	end := time.Now().Add(timeout)
+	for {
+		p.lock.Lock()
+		pending := p.output[p.consumed:]
+		if loc := r.FindIndex(pending); loc != nil {
+			match := string(pending[loc[0]:loc[1]])
+			p.consumed += loc[1]
+			p.lock.Unlock()
+			return match
+		}
+		seen := string(pending)
+		p.lock.Unlock()
+		if !time.Now().Before(end) {
+			p.t.Fatalf("%sNo output matching %q was written to the "+
+				"terminal within %s.\nOutput seen: %q",
+				prefix, re, timeout, seen)
+		}
+		time.Sleep(tailPollInterval)
+	}
+}
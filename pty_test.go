@@ -0,0 +1,74 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestT_PTY(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	var p *PTY
+	m.CheckPass(t, func() { p = T.PTY() })
+	if p == nil {
+		t.Skip("Pseudo terminals are not supported.")
+	}
+
+	cmd := exec.Command("sh", "-c", `printf "Name? "; read n; echo "hello $n"`)
+	cmd.Stdin = p.Tty
+	cmd.Stdout = p.Tty
+	cmd.Stderr = p.Tty
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Unable to start sh: %s", err)
+	}
+	m.CheckPass(t, func() {
+		have := p.ExpectOutput(`Name\? `, 5*time.Second)
+		if have != "Name? " {
+			t.Errorf("Unexpected match: %q", have)
+		}
+		p.Send("bob\n")
+		have = p.ExpectOutput(`hello \w+`, 5*time.Second)
+		if have != "hello bob" {
+			t.Errorf("Unexpected match: %q", have)
+		}
+	})
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("sh failed: %s", err)
+	}
+	if out := p.Output(); !strings.Contains(out, "bob\r\n") {
+		t.Fatalf("The input was not echoed: %q", out)
+	}
+
+	// Output that was already matched is consumed.
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	m.CheckFail(t, func() { p.ExpectOutput("hello", 50*time.Millisecond) })
+	if !strings.HasPrefix(msg, `No output matching "hello" was written`) {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { p.ExpectOutput("(", time.Second) })
+
+	// Finish closes both sides.
+	T.Finish()
+	if _, err := p.Tty.Write([]byte("x")); err == nil {
+		t.Fatalf("The terminal was not closed.")
+	}
+}
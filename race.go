@@ -0,0 +1,94 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file contains functions that help provoke race conditions.
+
+// The number of times Race() runs the functions it is given.
+var raceIterations = 100
+
+// The maximum number of failures Race() includes in its failure message.
+const maxRaceFailures = 10
+
+// Runs all of the given functions concurrently, many times over, while
+// perturbing the scheduler in order to shake out race conditions. Before each
+// iteration GOMAXPROCS is set to a random value and each function sleeps for
+// a small random amount of time before it starts. This is most useful when
+// the test binary is run with -race.
+//
+// The functions must not call Fatal themselves since they are run on other
+// goroutines. Instead any panic is recovered and, once every iteration has
+// completed, all of the panics are reported as a single failure.
+//
+// Since GOMAXPROCS is process wide this should not be used in tests that
+// call t.Parallel(). The original value is restored before returning.
+func (t *T) Race(fns ...func()) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	cpus := runtime.NumCPU()
+	if cpus < 2 {
+		cpus = 2
+	}
+
+	var lock sync.Mutex
+	failures := []string{}
+	total := 0
+	for i := 0; i < raceIterations; i++ {
+		runtime.GOMAXPROCS(rand.Intn(cpus) + 1)
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for j, fn := range fns {
+			wg.Add(1)
+			delay := time.Duration(rand.Intn(100)) * time.Microsecond
+			go func(iteration, index int, fn func()) {
+				defer wg.Done()
+				defer func() {
+					if r := recover(); r != nil {
+						lock.Lock()
+						total++
+						if len(failures) < maxRaceFailures {
+							failures = append(failures, fmt.Sprintf(
+								"  iteration %d, function %d: %v",
+								iteration, index, r))
+						}
+						lock.Unlock()
+					}
+				}()
+				<-start
+				if delay > 50*time.Microsecond {
+					time.Sleep(delay)
+				} else {
+					runtime.Gosched()
+				}
+				fn()
+			}(i, j, fn)
+		}
+		close(start)
+		wg.Wait()
+	}
+
+	if total > 0 {
+		t.Fatalf("%d of %d function call(s) panicked:\n%s", total,
+			raceIterations*len(fns), strings.Join(failures, "\n"))
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestT_Race(t *testing.T) {
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	procs := runtime.GOMAXPROCS(0)
+	var calls int32
+	inc := func() { atomic.AddInt32(&calls, 1) }
+	m.CheckPass(t, func() { T.Race(inc, inc) })
+	if calls != int32(2*raceIterations) {
+		t.Fatalf("Unexpected number of calls: %d", calls)
+	} else if runtime.GOMAXPROCS(0) != procs {
+		t.Fatalf("GOMAXPROCS was not restored.")
+	}
+
+	var panics int32
+	m.CheckFail(t, func() {
+		T.Race(inc, func() {
+			if atomic.AddInt32(&panics, 1)%2 == 0 {
+				panic("boom")
+			}
+		})
+	})
+	want := fmt.Sprintf("%d of %d function call(s) panicked:\n",
+		raceIterations/2, 2*raceIterations)
+	if !strings.HasPrefix(msg, want) {
+		t.Fatalf("Unexpected message: %s", msg)
+	} else if strings.Count(msg, ": boom") != maxRaceFailures {
+		t.Fatalf("The failures were not limited: %s", msg)
+	}
+}
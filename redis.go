@@ -0,0 +1,102 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file contains a helper for running a private redis server.
+
+// The redis server binary that is executed. This is a variable so tests can
+// replace it.
+var redisServerCommand = "redis-server"
+
+// How long to wait for the redis server to start answering requests.
+var redisStartTimeout = 10 * time.Second
+
+// Starts a private redis-server listening on a random port of the loopback
+// interface, with its data directory in a temporary directory, and returns
+// the host:port address it is listening on. Persistence is disabled. The
+// server is killed when the test finishes. If redis-server is not installed
+// then the test is skipped.
+func (t *T) RedisServer() string {
+	path, err := exec.LookPath(redisServerCommand)
+	if err != nil {
+		t.Skipf("redis-server is not installed: %s", err)
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(t.FreePort()))
+	host, port, _ := net.SplitHostPort(addr)
+	cmd := exec.Command(path,
+		"--bind", host,
+		"--port", port,
+		"--dir", t.TempDir(),
+		"--save", "",
+		"--appendonly", "no",
+		"--daemonize", "no")
+	output := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	t.ExpectSuccess(cmd.Start(), "Unable to start redis-server")
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	t.AddNamedFinalizer("redis-server "+addr, func() {
+		cmd.Process.Kill()
+		<-exited
+	})
+
+	defer t.trackWait("RedisServer")()
+	end := time.Now().Add(redisStartTimeout)
+	for !redisPing(addr) {
+		select {
+		case <-exited:
+			t.Fatalf("redis-server exited before it was ready: %s\n%s",
+				cmd.ProcessState, output)
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(end) {
+			cmd.Process.Kill()
+			<-exited
+			t.Fatalf("redis-server did not start within %s:\n%s",
+				redisStartTimeout, output)
+		}
+	}
+	return addr
+}
+
+// Returns true if the redis server at addr replies to a PING.
+func redisPing(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := fmt.Fprintf(conn, "PING\r\n"); err != nil {
+		return false
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	return err == nil && strings.TrimSpace(line) == "+PONG"
+}
@@ -0,0 +1,129 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// If the test binary is started with this as its first argument then it
+// pretends to be a redis-server, answering PING on the --port given.
+const fakeRedisArg = "fake-redis-server-sd8f7"
+
+func init() {
+	if len(os.Args) < 2 || os.Args[1] != fakeRedisArg {
+		return
+	}
+	port := ""
+	for i, arg := range os.Args {
+		if arg == "--port" && i+1 < len(os.Args) {
+			port = os.Args[i+1]
+		}
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:"+port)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fake redis: %s\n", err)
+		os.Exit(1)
+	}
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			os.Exit(1)
+		}
+		go func() {
+			defer c.Close()
+			scanner := bufio.NewScanner(c)
+			for scanner.Scan() {
+				fmt.Fprintf(c, "+PONG\r\n")
+			}
+		}()
+	}
+}
+
+// Replaces redisServerCommand with a shell script with the given body. The
+// returned function restores the original command.
+func fakeRedisServer(t *testing.T, dir, body string) func() {
+	script := filepath.Join(dir, "redis-server")
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatalf("Unable to write the fake redis-server: %s", err)
+	}
+	old := redisServerCommand
+	redisServerCommand = script
+	return func() { redisServerCommand = old }
+}
+
+func TestT_RedisServer(t *testing.T) {
+	_, T := testSetup()
+	defer T.Finish()
+	defer fakeRedisServer(t, T.TempDir(), fmt.Sprintf(
+		`exec %q %s "$@"`, os.Args[0], fakeRedisArg))()
+
+	_, T2 := testSetup()
+	addr := T2.RedisServer()
+	if !redisPing(addr) {
+		t.Fatalf("The server is not running at %s", addr)
+	}
+	T2.Finish()
+	if redisPing(addr) {
+		t.Fatalf("The server was not killed.")
+	}
+}
+
+func TestT_RedisServer_Exits(t *testing.T) {
+	_, T := testSetup()
+	defer T.Finish()
+	defer fakeRedisServer(t, T.TempDir(), "echo bad config; exit 3")()
+
+	m, T2 := testSetup()
+	defer T2.Finish()
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	m.CheckFail(t, func() { T2.RedisServer() })
+	if !strings.Contains(msg, "exited before it was ready") ||
+		!strings.Contains(msg, "bad config") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
+
+func TestT_RedisServer_Timeout(t *testing.T) {
+	_, T := testSetup()
+	defer T.Finish()
+	defer fakeRedisServer(t, T.TempDir(), "exec sleep 60")()
+	oldTimeout := redisStartTimeout
+	defer func() { redisStartTimeout = oldTimeout }()
+	redisStartTimeout = 50 * time.Millisecond
+
+	m, T2 := testSetup()
+	defer T2.Finish()
+	m.CheckFail(t, func() { T2.RedisServer() })
+}
+
+func TestT_RedisServer_Missing(t *testing.T) {
+	old := redisServerCommand
+	defer func() { redisServerCommand = old }()
+	redisServerCommand = "/nonexistent/redis-server"
+
+	m, T := testSetup()
+	defer T.Finish()
+	m.CheckSkips(t, func() { T.RedisServer() })
+}
@@ -0,0 +1,195 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file contains declarative prerequisites for tests.
+
+// Something a test needs in order to run, checked by T.Requires().
+type Requirement struct {
+	// A short name for the requirement used in the skip message.
+	Name string
+
+	// Returns nil if the requirement is met, otherwise an error explaining
+	// why it is not.
+	Check func() error
+}
+
+// The address that is dialed to check for network access.
+var networkProbeAddr = "example.com:443"
+
+// Built in requirements.
+var (
+	// Requires access to the internet.
+	Network = Requirement{Name: "network", Check: onceCheck(checkNetwork)}
+
+	// Requires a working docker installation.
+	Docker = Requirement{Name: "docker", Check: onceCheck(checkDocker)}
+
+	// Requires the test to be running as root.
+	Root = Requirement{Name: "root", Check: checkRoot}
+
+	// Requires the test to be running on Linux.
+	Linux = Requirement{Name: "linux", Check: checkGOOS("linux")}
+)
+
+// Skips the test if any of the given requirements are not met. Every
+// requirement is checked so the skip message lists all of the unmet ones,
+// in the form:
+//
+//	Requirements not met: docker (exec: "docker": not found), env FOO (not set)
+func (t *T) Requires(reqs ...Requirement) {
+	unmet := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		if err := req.Check(); err != nil {
+			unmet = append(unmet, fmt.Sprintf("%s (%s)", req.Name, err))
+		}
+	}
+	if len(unmet) > 0 {
+		t.Skipf("Requirements not met: %s", strings.Join(unmet, ", "))
+	}
+}
+
+// Requires the named environment variable to be set to a non empty value.
+func EnvVar(name string) Requirement {
+	return Requirement{
+		Name: "env " + name,
+		Check: func() error {
+			if osGetenv(name) == "" {
+				return fmt.Errorf("not set")
+			}
+			return nil
+		},
+	}
+}
+
+// Requires the named binary to be in the PATH.
+func Binary(name string) Requirement {
+	return Requirement{
+		Name: "binary " + name,
+		Check: func() error {
+			_, err := exec.LookPath(name)
+			return err
+		},
+	}
+}
+
+// Requires the test to be built with at least the given Go version, for
+// example "1.21". Development builds of Go always meet this requirement.
+func MinGoVersion(version string) Requirement {
+	return Requirement{
+		Name: "go " + version,
+		Check: func() error {
+			have := runtime.Version()
+			if !strings.HasPrefix(have, "go") {
+				return nil
+			} else if compareGoVersions(have[2:], version) < 0 {
+				return fmt.Errorf("running %s", have)
+			}
+			return nil
+		},
+	}
+}
+
+// Compares two dotted version numbers returning -1, 0 or 1. Any suffix on
+// a component (like "rc1") is ignored.
+func compareGoVersions(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		an, bn := 0, 0
+		if i < len(as) {
+			an = leadingInt(as[i])
+		}
+		if i < len(bs) {
+			bn = leadingInt(bs[i])
+		}
+		if an < bn {
+			return -1
+		} else if an > bn {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Returns the integer at the start of s, or 0 if there is none.
+func leadingInt(s string) int {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
+
+// Wraps a check so it is only run once per process, since checks like
+// network access are slow and unlikely to change during a test run.
+func onceCheck(check func() error) func() error {
+	var once sync.Once
+	var err error
+	return func() error {
+		once.Do(func() { err = check() })
+		return err
+	}
+}
+
+// Checks that the network probe address can be reached.
+func checkNetwork() error {
+	conn, err := net.DialTimeout("tcp", networkProbeAddr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Checks that the docker daemon is reachable.
+func checkDocker() error {
+	if _, err := exec.LookPath(dockerCommand); err != nil {
+		return err
+	} else if err := exec.Command(dockerCommand, "info").Run(); err != nil {
+		return fmt.Errorf("docker info failed: %s", err)
+	}
+	return nil
+}
+
+// Checks that the process is running as root.
+func checkRoot() error {
+	if uid := os.Geteuid(); uid != 0 {
+		return fmt.Errorf("running as uid %d", uid)
+	}
+	return nil
+}
+
+// Returns a check that the process is running on the given OS.
+func checkGOOS(goos string) func() error {
+	return func() error {
+		if runtime.GOOS != goos {
+			return fmt.Errorf("running on %s", runtime.GOOS)
+		}
+		return nil
+	}
+}
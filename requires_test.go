@@ -0,0 +1,90 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestT_Requires(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	met := Requirement{Name: "met", Check: func() error { return nil }}
+	unmet := func(name string) Requirement {
+		return Requirement{Name: name, Check: func() error {
+			return fmt.Errorf("%s is missing", name)
+		}}
+	}
+	msg := ""
+	m.funcSkip = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+
+	m.CheckPass(t, func() { T.Requires() })
+	m.CheckPass(t, func() { T.Requires(met, met) })
+	m.CheckSkips(t, func() { T.Requires(met, unmet("a"), unmet("b")) })
+	if !strings.HasPrefix(msg, "Requirements not met: a (a is missing), b (b is missing)") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
+
+func TestRequirements(t *testing.T) {
+	t.Parallel()
+
+	if err := Binary("sh").Check(); err != nil {
+		t.Fatalf("sh was not found: %s", err)
+	} else if err := Binary("testlib-nonexistent").Check(); err == nil {
+		t.Fatalf("A missing binary was found.")
+	}
+	if err := EnvVar("PATH").Check(); err != nil {
+		t.Fatalf("PATH was not set: %s", err)
+	} else if err := EnvVar("TESTLIB_NONEXISTENT").Check(); err == nil {
+		t.Fatalf("A missing variable was found.")
+	}
+	if err := Linux.Check(); (err == nil) != (runtime.GOOS == "linux") {
+		t.Fatalf("Unexpected result: %v", err)
+	}
+	if err := Root.Check(); (err == nil) != (os.Geteuid() == 0) {
+		t.Fatalf("Unexpected result: %v", err)
+	}
+	if err := MinGoVersion("1.0").Check(); err != nil {
+		t.Fatalf("Unexpected result: %v", err)
+	} else if err := MinGoVersion("999.0").Check(); err == nil &&
+		strings.HasPrefix(runtime.Version(), "go") {
+		t.Fatalf("A future version was met.")
+	}
+}
+
+func TestCompareGoVersions(t *testing.T) {
+	t.Parallel()
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.21", "1.21", 0},
+		{"1.21.3", "1.21", 1},
+		{"1.9", "1.21", -1},
+		{"1.22rc1", "1.22", 0},
+		{"2", "1.99", 1},
+	} {
+		if have := compareGoVersions(c.a, c.b); have != c.want {
+			t.Fatalf("compareGoVersions(%q, %q) = %d, want %d",
+				c.a, c.b, have, c.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// This file contains functions for tracking the resources used by a test.
+
+// How often the goroutine count is sampled while resources are tracked.
+const goroutineSampleInterval = 10 * time.Millisecond
+
+// Limits on the resources a test may use. A zero value means no limit.
+type ResourceBudget struct {
+	// Wall clock time between NewT() and Finish().
+	Wall time.Duration
+
+	// CPU time (user and system) used by the process. This is process wide
+	// so it includes any tests running in parallel.
+	CPU time.Duration
+
+	// The largest number of goroutines running at once. This is also
+	// process wide.
+	Goroutines int
+
+	// The total size of the files in the temporary directories and files
+	// created by this test, measured just before they are removed.
+	TempBytes int64
+}
+
+// The resources used by a test, as measured by TrackResources().
+type resourceUsage struct {
+	wall       time.Duration
+	cpu        time.Duration
+	goroutines int
+	tempBytes  int64
+}
+
+// Records the resources used by the test from NewT() until Finish(), at
+// which point a summary is logged. If any usage exceeds the given budget
+// then the test fails. CPU time is only available on platforms that
+// provide getrusage().
+func (t *T) TrackResources(budget ResourceBudget) {
+	cpuStart, cpuOK := processCPUTime()
+	t.lock.Lock()
+	t.trackTemp = true
+	t.lock.Unlock()
+
+	peak := runtime.NumGoroutine()
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(goroutineSampleInterval)
+		defer ticker.Stop()
+		for {
+			// The sampling goroutine itself is not counted.
+			if n := runtime.NumGoroutine() - 1; n > peak {
+				peak = n
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	t.AddNamedFinalizer("resource usage", func() {
+		close(stop)
+		<-stopped
+		usage := resourceUsage{wall: time.Since(t.start), goroutines: peak}
+		if cpuEnd, ok := processCPUTime(); ok && cpuOK {
+			usage.cpu = cpuEnd - cpuStart
+		}
+		t.lock.Lock()
+		usage.tempBytes = t.tempBytes
+		t.lock.Unlock()
+
+		cpu := "unavailable"
+		if cpuOK {
+			cpu = usage.cpu.String()
+		}
+		t.Logf("Resource usage: wall %s, cpu %s, peak goroutines %d, "+
+			"temp space %s", usage.wall, cpu, usage.goroutines,
+			formatBytes(usage.tempBytes))
+		if exceeded := budget.exceeded(usage); len(exceeded) > 0 {
+			t.Errorf("Resource budget exceeded:\n  %s",
+				strings.Join(exceeded, "\n  "))
+		}
+	})
+}
+
+// Returns a line for each resource in usage that is over the budget.
+func (b ResourceBudget) exceeded(usage resourceUsage) []string {
+	lines := []string{}
+	if b.Wall > 0 && usage.wall > b.Wall {
+		lines = append(lines, fmt.Sprintf("wall: %s > %s", usage.wall, b.Wall))
+	}
+	if b.CPU > 0 && usage.cpu > b.CPU {
+		lines = append(lines, fmt.Sprintf("cpu: %s > %s", usage.cpu, b.CPU))
+	}
+	if b.Goroutines > 0 && usage.goroutines > b.Goroutines {
+		lines = append(lines, fmt.Sprintf("goroutines: %d > %d",
+			usage.goroutines, b.Goroutines))
+	}
+	if b.TempBytes > 0 && usage.tempBytes > b.TempBytes {
+		lines = append(lines, fmt.Sprintf("temp space: %s > %s",
+			formatBytes(usage.tempBytes), formatBytes(b.TempBytes)))
+	}
+	return lines
+}
+
+// Adds the size of the temporary file or directory at path to the test's
+// temp space usage if resources are being tracked. This is called just
+// before the path is removed.
+func (t *T) noteTempUsage(path string) {
+	t.lock.Lock()
+	tracking := t.trackTemp
+	t.lock.Unlock()
+	if !tracking {
+		return
+	}
+	size := int64(0)
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	t.lock.Lock()
+	t.tempBytes += size
+	t.lock.Unlock()
+}
+
+// Renders a byte count using binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,84 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestT_TrackResources(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	logs := []string{}
+	m.funcLogf = func(f string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(f, args...))
+	}
+	T.TrackResources(ResourceBudget{})
+	dir := T.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "f"), make([]byte, 2048), 0644); err != nil {
+		t.Fatalf("Unable to write a file: %s", err)
+	}
+	T.WriteTempFile("hello")
+	m.CheckPass(t, T.Finish)
+
+	if len(logs) != 1 || !strings.HasPrefix(logs[0], "Resource usage: wall ") {
+		t.Fatalf("Unexpected logs: %#v", logs)
+	} else if !strings.HasSuffix(logs[0], "temp space 2.0 KiB") {
+		t.Fatalf("Temp space was not measured: %s", logs[0])
+	}
+}
+
+func TestT_TrackResources_Budget(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcError = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	T.TrackResources(ResourceBudget{
+		Wall:       time.Nanosecond,
+		Goroutines: 100000,
+		TempBytes:  1,
+	})
+	T.WriteTempFile("hello")
+	time.Sleep(time.Millisecond)
+	m.CheckFail(t, T.Finish)
+	if !strings.HasPrefix(msg, "Resource budget exceeded:\n  wall: ") ||
+		!strings.Contains(msg, "\n  temp space: 5 B > 1 B") ||
+		strings.Contains(msg, "goroutines") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	t.Parallel()
+	for n, want := range map[int64]string{
+		0:       "0 B",
+		1023:    "1023 B",
+		1024:    "1.0 KiB",
+		1536:    "1.5 KiB",
+		5 << 20: "5.0 MiB",
+		3 << 30: "3.0 GiB",
+	} {
+		if have := formatBytes(n); have != want {
+			t.Fatalf("formatBytes(%d) = %q, want %q", n, have, want)
+		}
+	}
+}
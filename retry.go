@@ -0,0 +1,161 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file contains functions for retrying flaky blocks of a test.
+
+// Runs fn up to attempts times with a fresh child T each time, stopping as
+// soon as an attempt passes. Failures in every attempt but the last are
+// logged rather than failing the test; the last attempt reports its
+// failures normally. The child's finalizers are run at the end of each
+// attempt.
+//
+// Retrying hides flakiness rather than fixing it, so every failed attempt
+// is still written to the failure log (see SetFailureLog()) with its
+// attempt number, and a run that passes after failing is recorded as
+// retried_then_passed.
+func (t *T) Retry(attempts int, fn func(*T)) {
+	for i := 1; i < attempts; i++ {
+		tb := &retryTB{parent: t}
+		child := t.derive(tb)
+		child.attempt = i
+		done := make(chan struct{})
+		// Run in a new goroutine so that Fatal can stop the attempt
+		// without stopping the test.
+		go func() {
+			defer close(done)
+			defer child.Finish()
+			fn(child)
+		}()
+		<-done
+		if !tb.Failed() {
+			t.retryPassed(i, attempts)
+			return
+		}
+		t.Logf("Attempt %d of %d failed:\n%s", i, attempts,
+			strings.Join(tb.messages, "\n"))
+	}
+
+	// The final attempt reports directly to the test.
+	failedBefore := t.Failed()
+	child := t.derive(t.t)
+	child.attempt = attempts
+	defer func() {
+		child.Finish()
+		if !failedBefore && !t.Failed() {
+			t.retryPassed(attempts, attempts)
+		}
+	}()
+	fn(child)
+}
+
+// Logs and records that the attempt passed after earlier attempts failed.
+func (t *T) retryPassed(attempt, attempts int) {
+	if attempt == 1 {
+		return
+	}
+	t.Logf("Passed on attempt %d of %d.", attempt, attempts)
+	writeFailureRecord(failureRecord{
+		Time:              time.Now(),
+		Test:              t.Name(),
+		Assertion:         "T.Retry",
+		Message:           fmt.Sprintf("Passed on attempt %d of %d.", attempt, attempts),
+		Seed:              t.Seed(),
+		Duration:          time.Since(t.start).Seconds(),
+		Attempt:           attempt,
+		RetriedThenPassed: true,
+	})
+}
+
+// The testingTB used by the child T in every attempt but the last made by
+// Retry(). Failures are collected rather than reported, and fatal failures
+// stop the attempt's goroutine.
+type retryTB struct {
+	parent   *T
+	lock     sync.Mutex
+	failed   bool
+	messages []string
+}
+
+func (r *retryTB) Error(args ...interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.failed = true
+	r.messages = append(r.messages, fmt.Sprint(args...))
+}
+
+func (r *retryTB) Errorf(format string, args ...interface{}) {
+	r.Error(fmt.Sprintf(format, args...))
+}
+
+func (r *retryTB) Fail() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.failed = true
+}
+
+func (r *retryTB) FailNow() {
+	r.Fail()
+	runtime.Goexit()
+}
+
+func (r *retryTB) Failed() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.failed
+}
+
+func (r *retryTB) Fatal(args ...interface{}) {
+	r.Error(args...)
+	runtime.Goexit()
+}
+
+func (r *retryTB) Fatalf(format string, args ...interface{}) {
+	r.Fatal(fmt.Sprintf(format, args...))
+}
+
+func (r *retryTB) Log(args ...interface{}) {
+	r.parent.t.Log(args...)
+}
+
+func (r *retryTB) Logf(format string, args ...interface{}) {
+	r.parent.t.Logf(format, args...)
+}
+
+// Skipping from a retry goroutine is treated as a failed attempt since
+// the testing package only allows skipping from the test's goroutine.
+func (r *retryTB) Skip(args ...interface{}) {
+	r.Fatal(append([]interface{}{"Skip called during Retry: "}, args...)...)
+}
+
+func (r *retryTB) SkipNow() {
+	r.Fatal("SkipNow called during Retry.")
+}
+
+func (r *retryTB) Skipf(format string, args ...interface{}) {
+	r.Fatal("Skip called during Retry: " + fmt.Sprintf(format, args...))
+}
+
+func (r *retryTB) Skipped() bool {
+	return false
+}
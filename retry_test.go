@@ -0,0 +1,89 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestT_Retry(t *testing.T) {
+	_, outer := testSetup()
+	defer outer.Finish()
+	read, disable := enableFailureLog(t, outer)
+	defer disable()
+
+	// Passes on the third attempt, with the earlier failures only logged.
+	m, T2 := testSetup()
+	logs := []string{}
+	m.funcLogf = func(f string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(f, args...))
+	}
+	calls := 0
+	finalized := 0
+	m.CheckPass(t, func() {
+		T2.Retry(5, func(r *T) {
+			calls++
+			r.AddFinalizer(func() { finalized++ })
+			r.Equal(calls, 3)
+			if calls != 3 {
+				t.Errorf("The attempt continued after a fatal failure.")
+			}
+		})
+	})
+	if calls != 3 || finalized != 3 {
+		t.Fatalf("Unexpected calls: %d, finalized: %d", calls, finalized)
+	} else if len(logs) != 3 || !strings.HasPrefix(logs[0], "Attempt 1 of 5 failed:\n") ||
+		logs[2] != "Passed on attempt 3 of 5." {
+		t.Fatalf("Unexpected logs: %#v", logs)
+	}
+	records := read()
+	if len(records) != 3 {
+		t.Fatalf("Unexpected records: %#v", records)
+	} else if records[0].Attempt != 1 || records[0].Assertion != "T.Equal" ||
+		records[1].Attempt != 2 || records[2].Attempt != 3 ||
+		!records[2].RetriedThenPassed {
+		t.Fatalf("Unexpected records: %#v", records)
+	}
+}
+
+func TestT_Retry_Fails(t *testing.T) {
+	t.Parallel()
+	m, parent := testSetup()
+
+	// The last attempt reports to the test and its Fatal ends the test.
+	calls := 0
+	reached := false
+	m.CheckFail(t, func() {
+		parent.Retry(3, func(r *T) {
+			calls++
+			r.Fatalf("always")
+		})
+		reached = true
+	})
+	if calls != 3 {
+		t.Fatalf("Unexpected calls: %d", calls)
+	} else if reached {
+		t.Fatalf("The final Fatal did not stop the test.")
+	}
+
+	// A single attempt behaves just like calling fn.
+	calls = 0
+	m.CheckPass(t, func() { parent.Retry(1, func(r *T) { calls++ }) })
+	if calls != 1 {
+		t.Fatalf("Unexpected calls: %d", calls)
+	}
+}
@@ -17,6 +17,7 @@ package testlib
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"strings"
@@ -205,6 +206,14 @@ func TestRoomTempDirInit(t *testing.T) {
 		t.Fatalf("initRootTempDir should have exited with code 3.")
 	}
 
+	// Directories under the fallback locations are also accepted.
+	exited = -1
+	initRootTempDir([]string{"argv0", testInterceptorArg,
+		fallbackTempDirs[0] + "/golang-testlib"}, r)
+	if exited != 3 {
+		t.Fatalf("initRootTempDir should have exited with code 3.")
+	}
+
 	// And lastly check that all the right stuff workd.
 	exited = -1
 	pw.CloseWithError(fmt.Errorf("expected"))
@@ -214,3 +223,72 @@ func TestRoomTempDirInit(t *testing.T) {
 	}
 
 }
+
+func TestCreateRootTempDir(t *testing.T) {
+	defer func() {
+		ioutilTempDir = ioutil.TempDir
+		osTempDir = os.TempDir
+		fallbackTempDirs = []string{"/var/tmp", "/dev/shm"}
+	}()
+	base, err := ioutil.TempDir("", "testlib-fallback")
+	if err != nil {
+		t.Fatalf("Unable to create a directory: %s", err)
+	}
+	defer os.RemoveAll(base)
+
+	// Duplicate and empty candidates are skipped.
+	osTempDir = func() string { return "/unwritable" }
+	fallbackTempDirs = []string{"", "/unwritable", base}
+	if have := tempDirCandidates(); strings.Join(have, ",") !=
+		"/unwritable,"+base {
+		t.Fatalf("Unexpected candidates: %v", have)
+	}
+
+	// The fallback is used when the default location fails.
+	ioutilTempDir = func(dir, prefix string) (string, error) {
+		if dir == "/unwritable" {
+			return "", fmt.Errorf("read-only file system")
+		}
+		return ioutil.TempDir(dir, prefix)
+	}
+	dir, err := createRootTempDir()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	} else if !strings.HasPrefix(dir, base) {
+		t.Fatalf("The fallback was not used: %s", dir)
+	}
+
+	// If every location fails the error lists each of them.
+	fallbackTempDirs = []string{"/unwritable2"}
+	ioutilTempDir = func(dir, prefix string) (string, error) {
+		return "", fmt.Errorf("read-only file system")
+	}
+	_, err = createRootTempDir()
+	if _, ok := err.(*tempUnavailableError); !ok {
+		t.Fatalf("Unexpected error: %#v", err)
+	} else if err.Error() != "temp storage unavailable: "+
+		"/unwritable (read-only file system), "+
+		"/unwritable2 (read-only file system)" {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// And RootTempDir() turns that into a skip.
+	testLibRootDirLock.Lock()
+	oldDir, oldState, oldErr :=
+		testLibRootDir, testLibCleanupState, testLibCleanupErr
+	testLibRootDir = ""
+	testLibRootDirLock.Unlock()
+	defer func() {
+		testLibRootDirLock.Lock()
+		testLibRootDir, testLibCleanupState, testLibCleanupErr =
+			oldDir, oldState, oldErr
+		testLibRootDirLock.Unlock()
+	}()
+	m, T := testSetup()
+	msg := ""
+	m.funcSkip = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	m.CheckSkips(t, func() { T.RootTempDir() })
+	if !strings.HasPrefix(msg, "temp storage unavailable: ") {
+		t.Fatalf("Unexpected skip message: %s", msg)
+	}
+}
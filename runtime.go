@@ -0,0 +1,103 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// This file contains functions for controlling the Go runtime during a
+// test.
+
+// Calls fn with GOMAXPROCS set to n, restoring the previous value
+// afterwards. This allows code whose behavior depends on the available
+// parallelism (worker pools, shard counts and so on) to be tested with a
+// specific number of CPUs regardless of the machine the test runs on. The
+// test fails if n is less than 1.
+//
+// Since GOMAXPROCS is shared by the whole process this should not be used
+// in tests that call t.Parallel().
+func (t *T) WithGOMAXPROCS(n int, fn func()) {
+	if n < 1 {
+		t.Fatalf("GOMAXPROCS must be at least 1, not %d.", n)
+	}
+	old := runtime.GOMAXPROCS(n)
+	defer runtime.GOMAXPROCS(old)
+	fn()
+}
+
+// Runs the garbage collector until finalizers queued by earlier cycles have
+// had a chance to run. An object with a finalizer takes at least two cycles
+// to be freed: one to queue its finalizer and another, after the finalizer
+// has run, to reclaim it.
+func (t *T) ForceGC() {
+	runtime.GC()
+	runtime.Gosched()
+	runtime.GC()
+}
+
+// How often ExpectFinalized() runs the garbage collector.
+const finalizedPollInterval = 10 * time.Millisecond
+
+// Fails the test unless the object that obj points to is garbage collected
+// within timeout. This is useful for checking that caches, pools and
+// registries do not leak references to the objects they manage:
+//
+//	conn := pool.Get()
+//	pool.Put(conn)
+//	pool.Close()
+//	T.ExpectFinalized(conn, time.Second)
+//
+// The caller must not use obj after this call since any live reference,
+// including one from the calling function, keeps the object alive. obj must
+// be a pointer to the start of an allocation that does not already have a
+// finalizer set via runtime.SetFinalizer(). Very small objects without
+// pointers may be batched together by the allocator and never be collected
+// individually, so they should not be checked this way.
+func (t *T) ExpectFinalized(
+	obj interface{}, timeout time.Duration, desc ...string,
+) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	if obj == nil || reflect.ValueOf(obj).Kind() != reflect.Ptr {
+		t.Fatalf("%sExpectFinalized requires a pointer, not %T.", prefix, obj)
+	}
+	name := reflect.TypeOf(obj).String()
+	defer t.trackWait("ExpectFinalized")()
+	finalized := make(chan struct{})
+	runtime.SetFinalizer(obj, func(interface{}) { close(finalized) })
+	obj = nil
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(finalizedPollInterval)
+	defer ticker.Stop()
+	for {
+		t.ForceGC()
+		select {
+		case <-finalized:
+			return
+		case <-deadline.C:
+			t.Fatalf("%sThe %s was not garbage collected within %s, "+
+				"something still references it.", prefix, name, timeout)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestT_WithGOMAXPROCS(t *testing.T) {
+	m, T := testSetup()
+	old := runtime.GOMAXPROCS(0)
+
+	have := 0
+	m.CheckPass(t, func() {
+		T.WithGOMAXPROCS(3, func() { have = runtime.GOMAXPROCS(0) })
+	})
+	if have != 3 {
+		t.Fatalf("GOMAXPROCS was %d inside of the function.", have)
+	} else if runtime.GOMAXPROCS(0) != old {
+		t.Fatalf("GOMAXPROCS was not restored: %d", runtime.GOMAXPROCS(0))
+	}
+
+	// The value is restored even if the function fails the test.
+	m.CheckFail(t, func() {
+		T.WithGOMAXPROCS(1, func() { T.Fatalf("failure") })
+	})
+	if runtime.GOMAXPROCS(0) != old {
+		t.Fatalf("GOMAXPROCS was not restored: %d", runtime.GOMAXPROCS(0))
+	}
+
+	m.CheckFail(t, func() { T.WithGOMAXPROCS(0, func() {}) })
+}
+
+type testFinalizedObject struct {
+	next *testFinalizedObject
+	data [128]byte
+}
+
+// Holds a reference so the object can not be collected.
+var testFinalizedLeak *testFinalizedObject
+
+func TestT_ExpectFinalized(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	m.CheckPass(t, func() {
+		T.ExpectFinalized(&testFinalizedObject{}, 5*time.Second)
+	})
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	testFinalizedLeak = &testFinalizedObject{}
+	m.CheckFail(t, func() {
+		T.ExpectFinalized(testFinalizedLeak, 50*time.Millisecond, "pool")
+	})
+	if !strings.HasPrefix(msg, "pool: The *testlib.testFinalizedObject "+
+		"was not garbage collected within 50ms") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	m.CheckFail(t, func() {
+		T.ExpectFinalized(testFinalizedObject{}, time.Second)
+	})
+	m.CheckFail(t, func() { T.ExpectFinalized(nil, time.Second) })
+
+	// ForceGC runs pending finalizers.
+	ran := make(chan struct{})
+	obj := &testFinalizedObject{}
+	runtime.SetFinalizer(obj, func(*testFinalizedObject) { close(ran) })
+	obj = nil
+	T.ForceGC()
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("The finalizer did not run.")
+	}
+}
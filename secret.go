@@ -0,0 +1,63 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"sort"
+	"strings"
+)
+
+// This file contains functions for keeping secrets out of test output.
+
+// The text that replaces a secret in output.
+const secretMask = "******"
+
+// Registers a value, like a password or API token used by an integration
+// test, that must never appear in the output of the test. Any occurrence of
+// the value in failure messages (including diffs and stack traces), skip
+// messages, logged output and the failure log is replaced with "******"
+// before it is written. Empty values are ignored.
+func (t *T) AddSecret(value string) {
+	if value == "" {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.secrets = append(t.secrets, value)
+
+	// Longer secrets are replaced first so a secret that contains another
+	// is masked completely.
+	sort.SliceStable(t.secrets, func(i, j int) bool {
+		return len(t.secrets[i]) > len(t.secrets[j])
+	})
+}
+
+// Returns true if any secrets have been registered.
+func (t *T) hasSecrets() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.secrets) > 0
+}
+
+// Returns s with every registered secret masked.
+func (t *T) scrub(s string) string {
+	t.lock.Lock()
+	secrets := t.secrets
+	t.lock.Unlock()
+	for _, secret := range secrets {
+		s = strings.Replace(s, secret, secretMask, -1)
+	}
+	return s
+}
@@ -0,0 +1,71 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestT_AddSecret(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	m.funcError = m.funcFatal
+	m.funcSkip = m.funcFatal
+	logs := []string{}
+	m.funcLog = func(args ...interface{}) { logs = append(logs, fmt.Sprint(args...)) }
+	m.funcLogf = func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+
+	// Nothing is masked before a secret is added.
+	m.CheckPass(t, func() { T.Logf("token=%s", "hunter2") })
+	if logs[0] != "token=hunter2" {
+		t.Fatalf("Unexpected log: %s", logs[0])
+	}
+
+	T.AddSecret("")
+	T.AddSecret("hunter2")
+	T.AddSecret("hunter2-extended")
+
+	m.CheckFail(t, func() { T.Equal("password hunter2", "password other") })
+	if strings.Contains(msg, "hunter2") {
+		t.Fatalf("The secret was not masked: %s", msg)
+	} else if !strings.Contains(msg, `"password ******"`) {
+		t.Fatalf("Unexpected failure: %s", msg)
+	}
+
+	m.CheckFail(t, func() { T.Errorf("bad token %s", "hunter2-extended") })
+	if !strings.HasPrefix(msg, "bad token ******\n") {
+		t.Fatalf("Unexpected failure: %s", msg)
+	}
+
+	m.CheckSkips(t, func() { T.Skip("no access with", "hunter2") })
+	if strings.Contains(msg, "hunter2") {
+		t.Fatalf("The secret was not masked: %s", msg)
+	}
+
+	m.CheckPass(t, func() {
+		T.Log("token", "hunter2")
+		T.Logf("token=%s", "hunter2")
+	})
+	if logs[1] != "token ******" || logs[2] != "token=******" {
+		t.Fatalf("Unexpected logs: %#v", logs)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// This file contains functions for limiting how long a test spends waiting
+// in real time.
+
+// A single call to one of the wait helpers.
+type waitRecord struct {
+	// The name of the helper, for example "TryUntil".
+	helper string
+
+	// The file:line that called the helper.
+	caller string
+
+	// How long the call waited for.
+	duration time.Duration
+}
+
+// Limits the total amount of real time the test may spend waiting inside
+// of the testlib wait helpers (TryUntil, WaitForHTTP, Latch.WaitTimeout,
+// and so on). If the limit is exceeded then the test fails when it
+// finishes with a report of every wait, longest first, along with where it
+// was called from. Tests that rely on lots of real sleeping are slow and
+// often flaky; FakeClock() is usually a better choice.
+func (t *T) ForbidSleep(maxTotal time.Duration) {
+	t.lock.Lock()
+	t.trackWaits = true
+	t.lock.Unlock()
+	t.AddNamedFinalizer("sleep report", func() {
+		t.lock.Lock()
+		waits := append([]waitRecord(nil), t.waits...)
+		t.lock.Unlock()
+		total := time.Duration(0)
+		for _, w := range waits {
+			total += w.duration
+		}
+		if total <= maxTotal {
+			return
+		}
+		sort.SliceStable(waits, func(i, j int) bool {
+			return waits[i].duration > waits[j].duration
+		})
+		lines := make([]string, len(waits))
+		for i, w := range waits {
+			lines[i] = fmt.Sprintf("  %-12s %s at %s", w.duration, w.helper, w.caller)
+		}
+		t.Errorf("Spent %s waiting in real time, more than the %s allowed. "+
+			"Consider using FakeClock().\n%s",
+			total, maxTotal, strings.Join(lines, "\n"))
+	})
+}
+
+// Records the time spent in a wait helper if ForbidSleep() has been
+// called. This is used as:
+//
+//	defer t.trackWait("TryUntil")()
+//
+// Waits inside of another tracked wait (for example a TryUntil() called
+// by Container()) are counted as part of the outer one only.
+func (t *T) trackWait(helper string) func() {
+	t.lock.Lock()
+	tracking := t.trackWaits
+	if tracking {
+		t.waitDepth++
+	}
+	t.lock.Unlock()
+	if !tracking {
+		return func() {}
+	}
+	start := time.Now()
+	caller := callerLocation()
+	return func() {
+		t.lock.Lock()
+		t.waitDepth--
+		if t.waitDepth == 0 {
+			t.waits = append(t.waits, waitRecord{
+				helper:   helper,
+				caller:   caller,
+				duration: time.Since(start),
+			})
+		}
+		t.lock.Unlock()
+	}
+}
+
+// Returns the file:line of the first caller outside of this library, the
+// Go runtime and the testing package.
+func callerLocation() string {
+	_, thisfile, _, _ := runtime.Caller(0)
+	thisdir := path.Dir(thisfile)
+	for i := 1; true; i++ {
+		pc, file, line, ok := runtime.Caller(i)
+		if !ok {
+			break
+		} else if path.Dir(file) == thisdir || isRuntimeFrame(pc) {
+			continue
+		}
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return "unknown"
+}
@@ -0,0 +1,101 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns a function for TryUntil that succeeds after d has passed.
+func succeedAfter(d time.Duration) func() bool {
+	end := time.Now().Add(d)
+	return func() bool { return time.Now().After(end) }
+}
+
+func TestT_ForbidSleep(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcError = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+
+	T.ForbidSleep(10 * time.Millisecond)
+	T.TryUntil(succeedAfter(20*time.Millisecond), time.Second)
+	l := T.NewLatch(1)
+	l.CountDown()
+	l.WaitTimeout(time.Second)
+	m.CheckFail(t, T.Finish)
+
+	lines := strings.Split(msg, "\n")
+	if !strings.HasPrefix(lines[0], "Spent ") ||
+		!strings.Contains(lines[0], "more than the 10ms allowed") {
+		t.Fatalf("Unexpected message: %s", msg)
+	} else if !strings.Contains(lines[1], " TryUntil at ") {
+		t.Fatalf("The longest wait was not listed first: %s", msg)
+	} else if !strings.Contains(lines[2], " Latch.WaitTimeout at ") {
+		t.Fatalf("The latch wait was not listed: %s", msg)
+	}
+}
+
+func TestT_ForbidSleep_UnderLimit(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	T.ForbidSleep(time.Second)
+	T.TryUntil(succeedAfter(time.Millisecond), time.Second)
+	m.CheckPass(t, T.Finish)
+}
+
+func TestT_trackWait(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+
+	// Nothing is recorded unless ForbidSleep() has been called.
+	T.TryUntil(succeedAfter(time.Millisecond), time.Second)
+	if len(T.waits) != 0 {
+		t.Fatalf("Unexpected waits recorded: %#v", T.waits)
+	}
+}
+
+func TestT_ForbidSleep_ExpectFinalized(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	T.ForbidSleep(time.Minute)
+	m.CheckPass(t, func() {
+		T.ExpectFinalized(&testFinalizedObject{}, 5*time.Second)
+	})
+	if len(T.waits) != 1 || T.waits[0].helper != "ExpectFinalized" {
+		t.Fatalf("ExpectFinalized was not recorded: %#v", T.waits)
+	}
+	m.CheckPass(t, T.Finish)
+}
+
+func TestT_trackWait_Nested(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+
+	// A wait helper built on another is only recorded once.
+	T.ForbidSleep(time.Minute)
+	done := T.trackWait("Outer")
+	T.TryUntil(succeedAfter(time.Millisecond), time.Second)
+	done()
+	if len(T.waits) != 1 || T.waits[0].helper != "Outer" {
+		t.Fatalf("Unexpected waits recorded: %#v", T.waits)
+	}
+}
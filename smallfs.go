@@ -0,0 +1,43 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+)
+
+// This file contains functions for simulating a full disk.
+
+// Mounts a tmpfs file system limited to size bytes on a new temporary
+// directory and returns its path. Writes that go past the limit fail with
+// ENOSPC, so the handling of a full disk can actually be exercised. The
+// file system is unmounted and removed when the test finishes. The kernel
+// rounds the size up to a whole number of pages.
+//
+// Mounting requires Linux and root (or CAP_SYS_ADMIN); if either is missing
+// the test is skipped. Code that writes through a MemFS can instead use
+// MemFS.SetSizeLimit() which works everywhere.
+func (t *T) SmallTempFS(size int64) string {
+	dir := t.TempDir()
+	if err := mountTmpfs(dir, size); err != nil {
+		t.Skipf("Unable to mount a %s tmpfs: %s", formatBytes(size), err)
+	}
+	t.AddNamedFinalizer(fmt.Sprintf("unmount %s", dir), func() {
+		if err := unmountTmpfs(dir); err != nil {
+			t.Errorf("Unable to unmount %s: %s", dir, err)
+		}
+	})
+	return dir
+}
@@ -0,0 +1,34 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package testlib
+
+import (
+	"errors"
+)
+
+// Returned on platforms that do not support tmpfs.
+var errNoTmpfs = errors.New("tmpfs is only supported on Linux")
+
+// Mounts a tmpfs limited to size bytes on dir.
+func mountTmpfs(dir string, size int64) error {
+	return errNoTmpfs
+}
+
+// Unmounts the file system mounted on dir.
+func unmountTmpfs(dir string) error {
+	return errNoTmpfs
+}
@@ -0,0 +1,55 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestT_SmallTempFS(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	// Skipping ends the goroutine so SmallTempFS is called in its own.
+	var dir string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dir = T.SmallTempFS(64 * 1024)
+	}()
+	<-done
+	if m.skipped {
+		T.Finish()
+		t.Skip("Unable to mount a tmpfs here.")
+	}
+
+	path := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(path, make([]byte, 1024), 0644); err != nil {
+		t.Fatalf("A small write failed: %s", err)
+	}
+	err := ioutil.WriteFile(path, make([]byte, 1024*1024), 0644)
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("Expected ENOSPC, got: %v", err)
+	}
+	m.CheckPass(t, func() { T.Finish() })
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("The mount point was not removed: %v", err)
+	}
+}
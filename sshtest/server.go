@@ -0,0 +1,495 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sshtest provides a small SSH server for testing code that runs
+// commands on remote hosts. It is kept separate from testlib so that tests
+// which do not need it do not pay for it.
+//
+// The server only implements what is needed to run scripted commands: a
+// single modern key exchange, cipher and MAC, password (or no)
+// authentication and exec requests on session channels. Shells, terminals
+// and port forwarding are refused.
+//
+// The protocol is implemented here rather than with golang.org/x/crypto/ssh
+// so that testlib has no dependencies outside of the standard library. It
+// exists only so that a real ssh client can talk to a server a test
+// controls: it has not been reviewed as cryptographic code, makes no
+// attempt to resist an attacker and must never be used outside of tests.
+// The key exchange uses crypto/ecdh so this package requires Go 1.20 or
+// later, like the rest of testlib.
+package sshtest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sync"
+
+	"github.com/liquidgecka/testlib"
+)
+
+// This file contains the server and the connection (authentication and
+// channel) protocol.
+
+// The exit status returned for commands that have no scripted response.
+const unknownCommandStatus = 127
+
+// The response a scripted command produces.
+type Response struct {
+	Stdout     string
+	Stderr     string
+	ExitStatus int
+}
+
+// Configures the server returned from NewServer().
+type Options struct {
+	// Maps a command line to the response that running it produces.
+	Commands map[string]Response
+
+	// If set this is called for any command that is not in Commands. If
+	// nil then unknown commands write an error to stderr and exit 127.
+	Handler func(s Session) Response
+
+	// If non empty clients must authenticate as one of these users with
+	// the matching password. Otherwise every user is accepted without
+	// authentication.
+	Passwords map[string]string
+}
+
+// A captured command execution.
+type Session struct {
+	// The user the client authenticated as.
+	User string
+
+	// The command that was executed.
+	Command string
+
+	// Environment variables the client sent before the command.
+	Env map[string]string
+
+	// Any data the client sent on stdin before the channel was closed.
+	Stdin []byte
+}
+
+// An SSH server listening on the loopback interface. The server, and any
+// open connections, are closed when the test finishes.
+type Server struct {
+	// The host:port address the server is listening on.
+	Addr string
+
+	// The host and port portions of Addr.
+	Host string
+	Port int
+
+	// Paths to the host private key (PKCS #8 PEM), host public key (in
+	// authorized_keys format) and a known_hosts file that trusts the host
+	// key for Addr. These are all in the test's temporary directory.
+	HostKeyFile    string
+	PublicKeyFile  string
+	KnownHostsFile string
+
+	t        *testlib.T
+	opts     Options
+	hostKey  ed25519.PrivateKey
+	listener net.Listener
+	wg       sync.WaitGroup
+	lock     sync.Mutex
+	conns    map[net.Conn]bool
+	sessions []*Session
+}
+
+// Starts a new SSH server on a random port with a freshly generated ed25519
+// host key.
+func NewServer(t *testlib.T, opts Options) *Server {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	t.ExpectSuccess(err, "Unable to generate a host key")
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	t.ExpectSuccess(err, "Unable to listen")
+	s := &Server{
+		Addr:     l.Addr().String(),
+		Host:     "127.0.0.1",
+		Port:     l.Addr().(*net.TCPAddr).Port,
+		t:        t,
+		opts:     opts,
+		hostKey:  private,
+		listener: l,
+		conns:    map[net.Conn]bool{},
+	}
+	t.AddNamedFinalizer("ssh server "+s.Addr, s.close)
+
+	// Write the keys out so that clients can be configured with them.
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	t.ExpectSuccess(err, "Unable to marshal the host key")
+	dir := t.TempDir()
+	authorized := "ssh-ed25519 " +
+		base64.StdEncoding.EncodeToString(publicKeyBlob(public))
+	s.HostKeyFile = filepath.Join(dir, "ssh_host_ed25519_key")
+	s.PublicKeyFile = s.HostKeyFile + ".pub"
+	s.KnownHostsFile = filepath.Join(dir, "known_hosts")
+	files := map[string]string{
+		s.HostKeyFile: string(pem.EncodeToMemory(
+			&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		s.PublicKeyFile: authorized + "\n",
+		s.KnownHostsFile: fmt.Sprintf(
+			"[%s]:%d %s\n", s.Host, s.Port, authorized),
+	}
+	for name, contents := range files {
+		t.ExpectSuccess(ioutil.WriteFile(name, []byte(contents), 0600),
+			"Unable to write "+name)
+	}
+
+	s.wg.Add(1)
+	go s.accept()
+	return s
+}
+
+// Returns a copy of every command executed so far, in the order that they
+// were started.
+func (s *Server) Sessions() []Session {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sessions := make([]Session, len(s.sessions))
+	for i, session := range s.sessions {
+		sessions[i] = *session
+		sessions[i].Stdin = append([]byte(nil), session.Stdin...)
+		sessions[i].Env = make(map[string]string, len(session.Env))
+		for k, v := range session.Env {
+			sessions[i].Env[k] = v
+		}
+	}
+	return sessions
+}
+
+// Accepts connections until the listener is closed.
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		if s.conns == nil {
+			s.lock.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.lock.Unlock()
+		go s.serve(conn)
+	}
+}
+
+// Stops the listener, closes all connections and waits for the connection
+// goroutines to exit.
+func (s *Server) close() {
+	s.listener.Close()
+	s.lock.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+	s.lock.Unlock()
+	s.wg.Wait()
+}
+
+// The state of a single session channel.
+type channel struct {
+	remoteID  uint32
+	maxPacket uint32
+	session   *Session
+	closed    bool
+}
+
+// The state of a single client connection.
+type connection struct {
+	server   *Server
+	tr       *transport
+	user     string
+	channels map[uint32]*channel
+	nextID   uint32
+}
+
+// Serves a single client connection until it disconnects.
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+	c := &connection{
+		server:   s,
+		tr:       newTransport(conn),
+		channels: map[uint32]*channel{},
+	}
+	err := c.tr.serverHandshake(s.hostKey)
+	for err == nil {
+		var p []byte
+		if p, err = c.tr.readMessage(); err == nil {
+			err = c.handle(p)
+		}
+	}
+	s.lock.Lock()
+	closed := s.conns == nil
+	delete(s.conns, conn)
+	s.lock.Unlock()
+	if !closed && err != io.EOF && err != errDisconnect {
+		s.t.Logf("sshtest: connection from %s failed: %s",
+			conn.RemoteAddr(), err)
+	}
+}
+
+// Returned from handle() when the client disconnects cleanly.
+var errDisconnect = fmt.Errorf("sshtest: client disconnected")
+
+// Handles a single message from the client.
+func (c *connection) handle(p []byte) error {
+	r := parser{data: p[1:]}
+	switch p[0] {
+	case msgDisconnect:
+		return errDisconnect
+
+	case msgUnimplemented, msgChannelWindowAdjust, msgChannelEOF:
+		return nil
+
+	case msgServiceRequest:
+		reply := message{msgServiceAccept}
+		reply.string(r.string())
+		if r.err != nil {
+			return r.err
+		}
+		return c.tr.writePacket(reply)
+
+	case msgUserAuthRequest:
+		user := string(r.string())
+		r.string()
+		method := string(r.string())
+		if r.err != nil {
+			return r.err
+		}
+		return c.authenticate(user, method, &r)
+
+	case msgGlobalRequest:
+		r.string()
+		if r.bool() {
+			return c.tr.writePacket(message{msgRequestFailure})
+		}
+		return r.err
+
+	case msgChannelOpen:
+		kind := string(r.string())
+		remoteID := r.uint32()
+		r.uint32()
+		maxPacket := r.uint32()
+		if r.err != nil {
+			return r.err
+		}
+		if c.user == "" || kind != "session" {
+			reply := message{msgChannelOpenFailure}
+			reply.uint32(remoteID)
+			reply.uint32(3)
+			reply.string([]byte("unsupported channel type"))
+			reply.string(nil)
+			return c.tr.writePacket(reply)
+		}
+		id := c.nextID
+		c.nextID++
+		c.channels[id] = &channel{
+			remoteID:  remoteID,
+			maxPacket: maxPacket,
+			session:   &Session{User: c.user, Env: map[string]string{}},
+		}
+		reply := message{msgChannelOpenConfirm}
+		reply.uint32(remoteID)
+		reply.uint32(id)
+		reply.uint32(1 << 21)
+		reply.uint32(32 * 1024)
+		return c.tr.writePacket(reply)
+
+	case msgChannelData:
+		ch := c.channels[r.uint32()]
+		data := r.string()
+		if ch != nil && r.err == nil {
+			c.server.lock.Lock()
+			ch.session.Stdin = append(ch.session.Stdin, data...)
+			c.server.lock.Unlock()
+		}
+		return r.err
+
+	case msgChannelClose:
+		id := r.uint32()
+		ch := c.channels[id]
+		if ch == nil || r.err != nil {
+			return r.err
+		}
+		delete(c.channels, id)
+		if ch.closed {
+			return nil
+		}
+		reply := message{msgChannelClose}
+		reply.uint32(ch.remoteID)
+		return c.tr.writePacket(reply)
+
+	case msgChannelRequest:
+		ch := c.channels[r.uint32()]
+		kind := string(r.string())
+		wantReply := r.bool()
+		if r.err != nil {
+			return r.err
+		} else if ch == nil {
+			return fmt.Errorf("sshtest: request %q for unknown channel", kind)
+		}
+		return c.channelRequest(ch, kind, wantReply, &r)
+	}
+
+	reply := message{msgUnimplemented}
+	reply.uint32(c.tr.readSeq - 1)
+	return c.tr.writePacket(reply)
+}
+
+// Handles a user authentication request.
+func (c *connection) authenticate(user, method string, r *parser) error {
+	passwords := c.server.opts.Passwords
+	ok := false
+	switch {
+	case len(passwords) == 0:
+		ok = true
+	case method == "password":
+		r.bool()
+		password := string(r.string())
+		want, found := passwords[user]
+		ok = r.err == nil && found && password == want
+	}
+	if !ok {
+		reply := message{msgUserAuthFailure}
+		reply.nameList([]string{"password"})
+		reply.bool(false)
+		return c.tr.writePacket(reply)
+	}
+	c.user = user
+	return c.tr.writePacket(message{msgUserAuthSuccess})
+}
+
+// Handles a request on a session channel.
+func (c *connection) channelRequest(
+	ch *channel, kind string, wantReply bool, r *parser,
+) error {
+	reply := func(ok bool) error {
+		if !wantReply {
+			return nil
+		}
+		m := message{msgChannelFailure}
+		if ok {
+			m[0] = msgChannelSuccess
+		}
+		m.uint32(ch.remoteID)
+		return c.tr.writePacket(m)
+	}
+
+	switch kind {
+	case "env":
+		name := string(r.string())
+		value := string(r.string())
+		if r.err != nil {
+			return r.err
+		}
+		c.server.lock.Lock()
+		ch.session.Env[name] = value
+		c.server.lock.Unlock()
+		return reply(true)
+
+	case "exec":
+		command := string(r.string())
+		if r.err != nil {
+			return r.err
+		}
+		c.server.lock.Lock()
+		ch.session.Command = command
+		c.server.sessions = append(c.server.sessions, ch.session)
+		session := *ch.session
+		c.server.lock.Unlock()
+		if err := reply(true); err != nil {
+			return err
+		}
+		return c.run(ch, session)
+	}
+	return reply(false)
+}
+
+// Runs a command, sending its output and exit status and then closing the
+// channel.
+func (c *connection) run(ch *channel, session Session) error {
+	response, ok := c.server.opts.Commands[session.Command]
+	if !ok && c.server.opts.Handler != nil {
+		response = c.server.opts.Handler(session)
+	} else if !ok {
+		response = Response{
+			Stderr:     fmt.Sprintf("sshtest: %s: command not found\n", session.Command),
+			ExitStatus: unknownCommandStatus,
+		}
+	}
+	if err := c.send(ch, 0, response.Stdout); err != nil {
+		return err
+	} else if err := c.send(ch, 1, response.Stderr); err != nil {
+		return err
+	}
+	status := message{msgChannelRequest}
+	status.uint32(ch.remoteID)
+	status.string([]byte("exit-status"))
+	status.bool(false)
+	status.uint32(uint32(response.ExitStatus))
+	eof := message{msgChannelEOF}
+	eof.uint32(ch.remoteID)
+	closing := message{msgChannelClose}
+	closing.uint32(ch.remoteID)
+	for _, m := range []message{status, eof, closing} {
+		if err := c.tr.writePacket(m); err != nil {
+			return err
+		}
+	}
+	ch.closed = true
+	return nil
+}
+
+// Sends data on the channel, split into packets no larger than the client
+// allows. Stream 0 is stdout and 1 is stderr.
+func (c *connection) send(ch *channel, stream uint32, data string) error {
+	size := int(ch.maxPacket) - 64
+	if size <= 0 || size > 32*1024 {
+		size = 32 * 1024
+	}
+	for len(data) > 0 {
+		n := len(data)
+		if n > size {
+			n = size
+		}
+		m := message{msgChannelData}
+		m.uint32(ch.remoteID)
+		if stream != 0 {
+			m[0] = msgChannelExtendedData
+			m.uint32(stream)
+		}
+		m.string([]byte(data[:n]))
+		if err := c.tr.writePacket(m); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshtest
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// This file contains the server side of the SSH transport layer (RFC 4253).
+// Only a single set of modern algorithms is supported, which is enough for
+// any recent OpenSSH or Go client.
+
+// The version string this server identifies itself with.
+const serverVersion = "SSH-2.0-testlib_sshtest"
+
+// The largest packet that will be accepted.
+const maxPacket = 256 * 1024
+
+// The algorithms supported, in order of preference.
+var (
+	kexAlgorithms     = []string{"curve25519-sha256", "curve25519-sha256@libssh.org"}
+	hostKeyAlgorithms = []string{"ssh-ed25519"}
+	cipherAlgorithms  = []string{"aes128-ctr", "aes192-ctr", "aes256-ctr"}
+	macAlgorithms     = []string{"hmac-sha2-256"}
+	compAlgorithms    = []string{"none"}
+)
+
+// The key sizes of each supported cipher.
+var cipherKeySizes = map[string]int{
+	"aes128-ctr": 16,
+	"aes192-ctr": 24,
+	"aes256-ctr": 32,
+}
+
+// Reads and writes SSH binary packets over a connection.
+type transport struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// Protects the write side, which is used by every channel.
+	writeLock sync.Mutex
+
+	readSeq     uint32
+	writeSeq    uint32
+	readCipher  cipher.Stream
+	writeCipher cipher.Stream
+	readMAC     hash.Hash
+	writeMAC    hash.Hash
+}
+
+// Returns a new transport for the connection.
+func newTransport(conn net.Conn) *transport {
+	return &transport{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// Reads a single packet and returns its payload.
+func (t *transport) readPacket() ([]byte, error) {
+	blockSize := 8
+	if t.readCipher != nil {
+		blockSize = aes.BlockSize
+	}
+	first := make([]byte, blockSize)
+	if _, err := io.ReadFull(t.reader, first); err != nil {
+		return nil, err
+	}
+	if t.readCipher != nil {
+		t.readCipher.XORKeyStream(first, first)
+	}
+	length := binary.BigEndian.Uint32(first)
+	if length > maxPacket || int(length)+4 < blockSize ||
+		(int(length)+4)%blockSize != 0 {
+		return nil, fmt.Errorf("sshtest: invalid packet length %d", length)
+	}
+	packet := make([]byte, length+4)
+	copy(packet, first)
+	if _, err := io.ReadFull(t.reader, packet[blockSize:]); err != nil {
+		return nil, err
+	}
+	if t.readCipher != nil {
+		t.readCipher.XORKeyStream(packet[blockSize:], packet[blockSize:])
+	}
+	if t.readMAC != nil {
+		mac := make([]byte, t.readMAC.Size())
+		if _, err := io.ReadFull(t.reader, mac); err != nil {
+			return nil, err
+		}
+		if !hmac.Equal(mac, t.computeMAC(t.readMAC, t.readSeq, packet)) {
+			return nil, errors.New("sshtest: invalid packet MAC")
+		}
+	}
+	t.readSeq++
+	padding := int(packet[4])
+	if padding+1 > int(length) {
+		return nil, errors.New("sshtest: invalid packet padding")
+	}
+	return packet[5 : 4+int(length)-padding], nil
+}
+
+// Writes a single packet containing payload.
+func (t *transport) writePacket(payload []byte) error {
+	t.writeLock.Lock()
+	defer t.writeLock.Unlock()
+	blockSize := 8
+	if t.writeCipher != nil {
+		blockSize = aes.BlockSize
+	}
+	padding := blockSize - (5+len(payload))%blockSize
+	if padding < 4 {
+		padding += blockSize
+	}
+	packet := make([]byte, 5+len(payload)+padding)
+	binary.BigEndian.PutUint32(packet, uint32(len(packet)-4))
+	packet[4] = byte(padding)
+	copy(packet[5:], payload)
+	if _, err := rand.Read(packet[5+len(payload):]); err != nil {
+		return err
+	}
+	var mac []byte
+	if t.writeMAC != nil {
+		mac = t.computeMAC(t.writeMAC, t.writeSeq, packet)
+	}
+	if t.writeCipher != nil {
+		t.writeCipher.XORKeyStream(packet, packet)
+	}
+	t.writeSeq++
+	_, err := t.conn.Write(append(packet, mac...))
+	return err
+}
+
+// Returns the MAC of the unencrypted packet with the given sequence number.
+func (t *transport) computeMAC(mac hash.Hash, seq uint32, packet []byte) []byte {
+	mac.Reset()
+	var s [4]byte
+	binary.BigEndian.PutUint32(s[:], seq)
+	mac.Write(s[:])
+	mac.Write(packet)
+	return mac.Sum(nil)
+}
+
+// Reads the next packet that is not an ignore or debug message.
+func (t *transport) readMessage() ([]byte, error) {
+	for {
+		p, err := t.readPacket()
+		if err != nil {
+			return nil, err
+		} else if len(p) == 0 {
+			return nil, errShortMessage
+		} else if p[0] != msgIgnore && p[0] != msgDebug {
+			return p, nil
+		}
+	}
+}
+
+// Performs the version exchange and key exchange as the server, after which
+// all packets are encrypted.
+func (t *transport) serverHandshake(hostKey ed25519.PrivateKey) error {
+	// Version exchange. Clients may send other lines before their version.
+	if _, err := fmt.Fprintf(t.conn, "%s\r\n", serverVersion); err != nil {
+		return err
+	}
+	var clientVersion string
+	for {
+		line, err := t.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(line, "SSH-") {
+			clientVersion = strings.TrimRight(line, "\r\n")
+			break
+		}
+	}
+	if !strings.HasPrefix(clientVersion, "SSH-2.0-") {
+		return fmt.Errorf("sshtest: unsupported client version %q", clientVersion)
+	}
+
+	// Algorithm negotiation.
+	serverInit := message{msgKexInit}
+	cookie := make([]byte, 16)
+	if _, err := rand.Read(cookie); err != nil {
+		return err
+	}
+	serverInit = append(serverInit, cookie...)
+	serverInit.nameList(kexAlgorithms)
+	serverInit.nameList(hostKeyAlgorithms)
+	serverInit.nameList(cipherAlgorithms)
+	serverInit.nameList(cipherAlgorithms)
+	serverInit.nameList(macAlgorithms)
+	serverInit.nameList(macAlgorithms)
+	serverInit.nameList(compAlgorithms)
+	serverInit.nameList(compAlgorithms)
+	serverInit.nameList(nil)
+	serverInit.nameList(nil)
+	serverInit.bool(false)
+	serverInit.uint32(0)
+	if err := t.writePacket(serverInit); err != nil {
+		return err
+	}
+	clientInit, err := t.readMessage()
+	if err != nil {
+		return err
+	} else if clientInit[0] != msgKexInit {
+		return fmt.Errorf("sshtest: expected KEXINIT, got message %d", clientInit[0])
+	}
+	p := parser{data: clientInit[17:]}
+	lists := make([][]string, 10)
+	for i := range lists {
+		lists[i] = p.nameList()
+	}
+	if p.err != nil {
+		return p.err
+	}
+	chosen := make([]string, 0, 8)
+	for i, supported := range [][]string{
+		kexAlgorithms, hostKeyAlgorithms, cipherAlgorithms, cipherAlgorithms,
+		macAlgorithms, macAlgorithms, compAlgorithms, compAlgorithms,
+	} {
+		name := negotiate(lists[i], supported)
+		if name == "" {
+			return fmt.Errorf("sshtest: no common algorithm in %v", lists[i])
+		}
+		chosen = append(chosen, name)
+	}
+
+	// Elliptic curve Diffie-Hellman using curve25519 (RFC 8731).
+	kexInit, err := t.readMessage()
+	if err != nil {
+		return err
+	} else if kexInit[0] != msgKexECDHInit {
+		return fmt.Errorf("sshtest: expected KEX_ECDH_INIT, got message %d", kexInit[0])
+	}
+	p = parser{data: kexInit[1:]}
+	clientPublic := p.string()
+	if p.err != nil {
+		return p.err
+	}
+	curve := ecdh.X25519()
+	ephemeral, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	peer, err := curve.NewPublicKey(clientPublic)
+	if err != nil {
+		return err
+	}
+	secret, err := ephemeral.ECDH(peer)
+	if err != nil {
+		return err
+	}
+	var k message
+	k.mpint(secret)
+
+	hostKeyBlob := publicKeyBlob(hostKey.Public().(ed25519.PublicKey))
+	var exchange message
+	exchange.string([]byte(clientVersion))
+	exchange.string([]byte(serverVersion))
+	exchange.string(clientInit)
+	exchange.string(serverInit)
+	exchange.string(hostKeyBlob)
+	exchange.string(clientPublic)
+	exchange.string(ephemeral.PublicKey().Bytes())
+	exchange = append(exchange, k...)
+	h := sha256.Sum256(exchange)
+
+	var signature message
+	signature.string([]byte("ssh-ed25519"))
+	signature.string(ed25519.Sign(hostKey, h[:]))
+	reply := message{msgKexECDHReply}
+	reply.string(hostKeyBlob)
+	reply.string(ephemeral.PublicKey().Bytes())
+	reply.string(signature)
+	if err := t.writePacket(reply); err != nil {
+		return err
+	}
+
+	// Switch to the new keys. The exchange hash of the first key exchange
+	// is also the session identifier.
+	derive := func(letter byte, size int) []byte {
+		hash := sha256.New()
+		hash.Write(k)
+		hash.Write(h[:])
+		hash.Write([]byte{letter})
+		hash.Write(h[:])
+		key := hash.Sum(nil)
+		for len(key) < size {
+			hash = sha256.New()
+			hash.Write(k)
+			hash.Write(h[:])
+			hash.Write(key)
+			key = hash.Sum(key)
+		}
+		return key[:size]
+	}
+	newStream := func(ivLetter, keyLetter byte, name string) (cipher.Stream, error) {
+		block, err := aes.NewCipher(derive(keyLetter, cipherKeySizes[name]))
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewCTR(block, derive(ivLetter, aes.BlockSize)), nil
+	}
+	if err := t.writePacket(message{msgNewKeys}); err != nil {
+		return err
+	}
+	t.writeLock.Lock()
+	t.writeCipher, err = newStream('B', 'D', chosen[3])
+	t.writeMAC = hmac.New(sha256.New, derive('F', sha256.Size))
+	t.writeLock.Unlock()
+	if err != nil {
+		return err
+	}
+	newKeys, err := t.readMessage()
+	if err != nil {
+		return err
+	} else if newKeys[0] != msgNewKeys {
+		return fmt.Errorf("sshtest: expected NEWKEYS, got message %d", newKeys[0])
+	}
+	if t.readCipher, err = newStream('A', 'C', chosen[2]); err != nil {
+		return err
+	}
+	t.readMAC = hmac.New(sha256.New, derive('E', sha256.Size))
+	return nil
+}
+
+// Returns the first algorithm in client that is also in server.
+func negotiate(client, server []string) string {
+	for _, c := range client {
+		for _, s := range server {
+			if c == s {
+				return c
+			}
+		}
+	}
+	return ""
+}
+
+// Returns the SSH wire encoding of an ed25519 public key.
+func publicKeyBlob(key ed25519.PublicKey) []byte {
+	var blob message
+	blob.string([]byte("ssh-ed25519"))
+	blob.string(key)
+	return blob
+}
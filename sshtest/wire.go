@@ -0,0 +1,142 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshtest
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// This file contains the encoding of the SSH wire data types from RFC 4251.
+
+// SSH message numbers used by this package.
+const (
+	msgDisconnect          = 1
+	msgIgnore              = 2
+	msgUnimplemented       = 3
+	msgDebug               = 4
+	msgServiceRequest      = 5
+	msgServiceAccept       = 6
+	msgKexInit             = 20
+	msgNewKeys             = 21
+	msgKexECDHInit         = 30
+	msgKexECDHReply        = 31
+	msgUserAuthRequest     = 50
+	msgUserAuthFailure     = 51
+	msgUserAuthSuccess     = 52
+	msgGlobalRequest       = 80
+	msgRequestFailure      = 82
+	msgChannelOpen         = 90
+	msgChannelOpenConfirm  = 91
+	msgChannelOpenFailure  = 92
+	msgChannelWindowAdjust = 93
+	msgChannelData         = 94
+	msgChannelExtendedData = 95
+	msgChannelEOF          = 96
+	msgChannelClose        = 97
+	msgChannelRequest      = 98
+	msgChannelSuccess      = 99
+	msgChannelFailure      = 100
+)
+
+// Returned when a message is shorter than its contents require.
+var errShortMessage = errors.New("sshtest: message too short")
+
+// Builds an SSH message.
+type message []byte
+
+func (m *message) byte(b byte) {
+	*m = append(*m, b)
+}
+
+func (m *message) bool(b bool) {
+	if b {
+		m.byte(1)
+	} else {
+		m.byte(0)
+	}
+}
+
+func (m *message) uint32(v uint32) {
+	*m = binary.BigEndian.AppendUint32(*m, v)
+}
+
+func (m *message) string(s []byte) {
+	m.uint32(uint32(len(s)))
+	*m = append(*m, s...)
+}
+
+func (m *message) nameList(names []string) {
+	m.string([]byte(strings.Join(names, ",")))
+}
+
+// Writes b, an unsigned big endian integer, as an mpint.
+func (m *message) mpint(b []byte) {
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	m.string(b)
+}
+
+// Reads the fields of an SSH message. Once a read fails all further reads
+// return zero values and err is set.
+type parser struct {
+	data []byte
+	err  error
+}
+
+func (p *parser) bytes(n int) []byte {
+	if p.err != nil || len(p.data) < n {
+		p.err = errShortMessage
+		return nil
+	}
+	b := p.data[:n]
+	p.data = p.data[n:]
+	return b
+}
+
+func (p *parser) byte() byte {
+	if b := p.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (p *parser) bool() bool {
+	return p.byte() != 0
+}
+
+func (p *parser) uint32() uint32 {
+	if b := p.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (p *parser) string() []byte {
+	return p.bytes(int(p.uint32()))
+}
+
+func (p *parser) nameList() []string {
+	s := string(p.string())
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
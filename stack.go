@@ -0,0 +1,186 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// This file contains the controls for how stack frames are rendered in
+// failure output.
+
+// Controls how each frame of a failure's stack trace is rendered. Every
+// frame is rendered as the file and line followed by the function name.
+type StackFormat struct {
+	// If set then files under this directory are shown relative to it,
+	// which keeps CI logs short. The special value "module" uses the root of
+	// the module containing the current directory (found by looking for
+	// go.mod). Files outside of the directory keep their full path.
+	RelativeTo string
+
+	// If set then this is prepended to each file, like "file://" or
+	// "vscode://file", so frames are clickable in an IDE or terminal. Since
+	// links need an absolute path RelativeTo is ignored when this is set.
+	LinkPrefix string
+
+	// If true then the source around the top frame (the line that reported
+	// the failure and sourceContext lines either side) is included after
+	// it, so the assertion can be read without opening the file.
+	Source bool
+}
+
+// The number of lines shown either side of the failing line when
+// StackFormat.Source is set.
+const sourceContext = 2
+
+// If set then these environment variables configure the StackFormat used
+// when SetStackFormat() has not been called. Source is enabled by setting
+// TESTLIB_STACK_SOURCE to any non empty value.
+const (
+	stackRelativeEnv = "TESTLIB_STACK_RELATIVE"
+	stackLinkEnv     = "TESTLIB_STACK_LINK"
+	stackSourceEnv   = "TESTLIB_STACK_SOURCE"
+)
+
+// The format set via SetStackFormat(), or nil if it has not been set.
+var (
+	stackFormat     *StackFormat
+	stackFormatLock sync.Mutex
+)
+
+// Sets the format of stack frames for every test in the process. This
+// overrides the TESTLIB_STACK_RELATIVE, TESTLIB_STACK_LINK and
+// TESTLIB_STACK_SOURCE environment variables which otherwise set
+// RelativeTo, LinkPrefix and Source.
+func SetStackFormat(f StackFormat) {
+	stackFormatLock.Lock()
+	defer stackFormatLock.Unlock()
+	stackFormat = &f
+}
+
+// Returns the stack format currently in effect.
+func currentStackFormat() StackFormat {
+	stackFormatLock.Lock()
+	defer stackFormatLock.Unlock()
+	if stackFormat != nil {
+		return *stackFormat
+	}
+	return StackFormat{
+		RelativeTo: osGetenv(stackRelativeEnv),
+		LinkPrefix: osGetenv(stackLinkEnv),
+		Source:     osGetenv(stackSourceEnv) != "",
+	}
+}
+
+// Renders a single stack frame.
+func (f StackFormat) frame(file string, line int, pc uintptr) string {
+	if f.LinkPrefix != "" {
+		file = f.LinkPrefix + file
+	} else if dir := f.relativeDir(); dir != "" {
+		if rel, err := filepath.Rel(dir, filepath.FromSlash(file)); err == nil &&
+			!strings.HasPrefix(rel, "..") {
+			file = filepath.ToSlash(rel)
+		}
+	}
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		return fmt.Sprintf("%s:%d %s", file, line, shortFuncName(fn.Name()))
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// Returns the lines of file around line, with line itself marked, or nil if
+// the file can not be read.
+func sourceSnippet(file string, line int) []string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	source := strings.Split(string(data), "\n")
+	if line < 1 || line > len(source) {
+		return nil
+	}
+	first, last := line-sourceContext, line+sourceContext
+	if first < 1 {
+		first = 1
+	}
+	if last > len(source) {
+		last = len(source)
+	}
+	width := len(strconv.Itoa(last))
+	lines := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		lines = append(lines, fmt.Sprintf("  %s %*d | %s",
+			marker, width, i, source[i-1]))
+	}
+	return lines
+}
+
+// Returns the directory that files should be shown relative to, or an
+// empty string if they should not be.
+func (f StackFormat) relativeDir() string {
+	if f.RelativeTo == "module" {
+		return moduleRoot()
+	}
+	return f.RelativeTo
+}
+
+// Removes the directories from the package path of a function name, so
+// "github.com/liquidgecka/testlib.(*T).Equal" becomes "testlib.(*T).Equal".
+func shortFuncName(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// The root of the module containing the current directory, found once.
+var (
+	moduleRootDir  string
+	moduleRootOnce sync.Once
+)
+
+// Returns the directory containing the go.mod file for the current
+// directory, or an empty string if there is none.
+func moduleRoot() string {
+	moduleRootOnce.Do(func() {
+		dir, err := os.Getwd()
+		if err != nil {
+			return
+		}
+		for {
+			if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+				moduleRootDir = dir
+				return
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return
+			}
+			dir = parent
+		}
+	})
+	return moduleRootDir
+}
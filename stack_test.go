@@ -0,0 +1,131 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSetStackFormat(t *testing.T) {
+	defer func() { stackFormat = nil }()
+	defer func(f func(string) string) { osGetenv = f }(osGetenv)
+	defer func(v int32) { verbosityLevel = v }(verbosityLevel)
+
+	env := map[string]string{}
+	osGetenv = func(key string) string { return env[key] }
+
+	// The environment is used until SetStackFormat() is called.
+	stackFormat = nil
+	env[stackRelativeEnv] = "/src"
+	env[stackLinkEnv] = "file://"
+	if have := currentStackFormat(); have != (StackFormat{
+		RelativeTo: "/src", LinkPrefix: "file://"}) {
+		t.Fatalf("The environment was not used: %#v", have)
+	}
+	SetStackFormat(StackFormat{RelativeTo: "/other"})
+	if have := currentStackFormat(); have != (StackFormat{RelativeTo: "/other"}) {
+		t.Fatalf("SetStackFormat() did not override the environment: %#v", have)
+	}
+
+	// Frames include the function name.
+	pc, file, line, _ := runtime.Caller(0)
+	SetStackFormat(StackFormat{})
+	SetVerbosity(VerbosityVerbose)
+	_, T := testSetup()
+	if have := T.makeStack("msg"); !strings.Contains(have, "testing.tRunner") {
+		t.Fatalf("Function names were not included: %s", have)
+	}
+	if have := (StackFormat{}).frame(file, line, pc); !strings.HasSuffix(
+		have, fmt.Sprintf("stack_test.go:%d testlib.TestSetStackFormat", line)) {
+		t.Fatalf("Unexpected frame: %s", have)
+	}
+
+	// Relative paths.
+	dir := filepath.Dir(filepath.Dir(file))
+	have := StackFormat{RelativeTo: dir}.frame(file, line, pc)
+	if !strings.HasPrefix(have, filepath.Base(filepath.Dir(file))+"/stack_test.go:") {
+		t.Fatalf("The path was not made relative: %s", have)
+	}
+	have = StackFormat{RelativeTo: "/nonexistent/dir"}.frame(file, line, pc)
+	if !strings.HasPrefix(have, file+":") {
+		t.Fatalf("A path outside the directory was changed: %s", have)
+	}
+
+	// Links always use the absolute path.
+	have = StackFormat{RelativeTo: dir, LinkPrefix: "file://"}.frame(
+		file, line, pc)
+	if !strings.HasPrefix(have, "file://"+file+":") {
+		t.Fatalf("The link prefix was not added: %s", have)
+	}
+}
+
+func TestShortFuncName(t *testing.T) {
+	t.Parallel()
+	for name, want := range map[string]string{
+		"github.com/liquidgecka/testlib.(*T).Equal": "testlib.(*T).Equal",
+		"main.main": "main.main",
+	} {
+		if have := shortFuncName(name); have != want {
+			t.Fatalf("%s: have %s, want %s", name, have, want)
+		}
+	}
+}
+
+func TestSourceSnippet(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+	defer T.Finish()
+
+	path := T.WriteTempFile("one\ntwo\nthree\nfour\nfive\nsix\n")
+	want := []string{
+		"    2 | two",
+		"    3 | three",
+		"  > 4 | four",
+		"    5 | five",
+		"    6 | six",
+	}
+	T.Equal(sourceSnippet(path, 4), want)
+	T.Equal(sourceSnippet(path, 1), []string{
+		"  > 1 | one",
+		"    2 | two",
+		"    3 | three",
+	})
+	T.Equal(sourceSnippet(path, 100), []string(nil))
+	T.Equal(sourceSnippet(path+".missing", 1), []string(nil))
+}
+
+func TestMakeStack_Source(t *testing.T) {
+	defer func() { stackFormat = nil }()
+	defer func(v int32) { verbosityLevel = v }(verbosityLevel)
+	_, T := testSetup()
+
+	// Frames in this package are always removed so the top frame comes
+	// from the testing package.
+	SetVerbosity(VerbosityVerbose)
+	SetStackFormat(StackFormat{Source: true})
+	have := strings.Split(T.makeStack("msg"), "\n")
+	if len(have) < 4 {
+		t.Fatalf("Unexpected stack:\n%s", strings.Join(have, "\n"))
+	} else if !strings.HasPrefix(have[2], "  ") || !strings.Contains(have[2], " | ") {
+		t.Fatalf("The source was not included:\n%s", strings.Join(have, "\n"))
+	} else if strings.Count(strings.Join(have, "\n"), "  > ") != 1 {
+		t.Fatalf("Only the top frame should have source:\n%s",
+			strings.Join(have, "\n"))
+	}
+}
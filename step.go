@@ -0,0 +1,95 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"runtime/trace"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// This file contains functions for structuring long tests into steps.
+
+// A step that is running. Failures reported while the step is the inner
+// most one running set failed, which is shared with any T derived from the
+// test (see Group()) so their failures are attributed to the step too.
+type step struct {
+	name   string
+	failed int32
+}
+
+// Runs fn as a named step of the test. The start and end of the step are
+// logged along with how long it took, and any failure reported while the
+// step is running is prefixed with the step's name so it is obvious which
+// part of a long test went wrong. Steps can be nested, in which case the
+// names are joined with " > " and a failure in a nested step is a failure
+// of the steps that enclose it too. If the step fails then its end is logged as
+// a failure, even if fn was terminated by Fatal or the test had already
+// failed before the step started. If the test is being traced (see Trace())
+// then the step is also recorded as a trace region.
+func (t *T) Step(name string, fn func()) {
+	s := &step{name: name}
+	t.lock.Lock()
+	t.steps = append(t.steps, s)
+	path := joinSteps(t.steps)
+	t.lock.Unlock()
+	if ctx := t.traceContext(); ctx != nil {
+		defer trace.StartRegion(ctx, name).End()
+	}
+
+	failed := t.Failed()
+	start := time.Now()
+	t.Logf("=== STEP %s", path)
+	defer func() {
+		t.lock.Lock()
+		t.steps = t.steps[:len(t.steps)-1]
+		t.lock.Unlock()
+		if atomic.LoadInt32(&s.failed) != 0 || (!failed && t.Failed()) {
+			// The failure belongs to the enclosing step as well.
+			t.failStep()
+			t.Logf("--- FAIL STEP %s (%s)", path, time.Since(start))
+		} else {
+			t.Logf("--- PASS STEP %s (%s)", path, time.Since(start))
+		}
+	}()
+	fn()
+}
+
+// Marks the inner most running step, if any, as having failed.
+func (t *T) failStep() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if len(t.steps) > 0 {
+		atomic.StoreInt32(&t.steps[len(t.steps)-1].failed, 1)
+	}
+}
+
+// Returns the names of all running steps joined with " > ", or an empty
+// string if no step is running.
+func (t *T) stepPath() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return joinSteps(t.steps)
+}
+
+// Returns the names of steps joined with " > ".
+func joinSteps(steps []*step) string {
+	names := make([]string, len(steps))
+	for i, s := range steps {
+		names[i] = s.name
+	}
+	return strings.Join(names, " > ")
+}
@@ -0,0 +1,117 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestT_Step(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+	logs := []string{}
+	m.funcLogf = func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+
+	m.CheckPass(t, func() {
+		T.Step("setup", func() {
+			T.Step("database", func() {})
+		})
+	})
+	want := []string{
+		"=== STEP setup",
+		"=== STEP setup > database",
+		"--- PASS STEP setup > database",
+		"--- PASS STEP setup",
+	}
+	if len(logs) != len(want) {
+		t.Fatalf("Unexpected logs: %#v", logs)
+	}
+	for i := range want {
+		if !strings.HasPrefix(logs[i], want[i]) {
+			t.Fatalf("Unexpected log %d: %q, want %q", i, logs[i], want[i])
+		}
+	}
+
+	logs = nil
+	m.CheckFail(t, func() {
+		T.Step("run", func() {
+			T.Step("query", func() {
+				T.Fatalf("broken")
+			})
+		})
+	})
+	if !strings.HasPrefix(msg, "[run > query] broken") {
+		t.Fatalf("The step was not included in the message: %s", msg)
+	} else if len(logs) != 4 || !strings.HasPrefix(logs[2], "--- FAIL STEP run > query") {
+		t.Fatalf("The failed step was not logged: %#v", logs)
+	}
+
+	// Once the steps finish failures are no longer prefixed.
+	m.CheckFail(t, func() { T.Fatalf("after") })
+	if !strings.HasPrefix(msg, "after") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
+
+func TestT_StepAfterFailure(t *testing.T) {
+	t.Parallel()
+	m, parent := testSetup()
+
+	logs := []string{}
+	m.funcLogf = func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+	m.funcError = func(args ...interface{}) {}
+
+	// The test has already failed before any of the steps start.
+	parent.Errorf("earlier")
+	parent.Step("passes", func() {})
+	parent.Step("errors", func() { parent.Errorf("broken") })
+	parent.Step("outer", func() {
+		parent.Step("inner", func() {
+			parent.Group(func(g *T) { g.Fatalf("in a group") })
+		})
+	})
+	parent.Step("after", func() {})
+	want := []string{
+		"=== STEP passes",
+		"--- PASS STEP passes",
+		"=== STEP errors",
+		"--- FAIL STEP errors",
+		"=== STEP outer",
+		"=== STEP outer > inner",
+		"--- FAIL STEP outer > inner",
+		"--- FAIL STEP outer",
+		"=== STEP after",
+		"--- PASS STEP after",
+	}
+	if len(logs) != len(want) {
+		t.Fatalf("Unexpected logs: %#v", logs)
+	}
+	for i := range want {
+		if logs[i] != want[i] && !strings.HasPrefix(logs[i], want[i]+" (") {
+			t.Fatalf("Unexpected log %d: %q, want %q", i, logs[i], want[i])
+		}
+	}
+}
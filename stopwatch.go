@@ -0,0 +1,110 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file contains a stopwatch for timing the phases of an operation.
+
+// Returns a running Stopwatch. When the test finishes a table of every lap
+// recorded is logged.
+func (t *T) Stopwatch() *Stopwatch {
+	s := &Stopwatch{t: t, start: time.Now()}
+	s.last = s.start
+	t.AddNamedFinalizer("stopwatch summary", func() {
+		if summary := s.summary(); summary != "" {
+			t.Logf("Stopwatch laps:\n%s", summary)
+		}
+	})
+	return s
+}
+
+// Records the duration of the named phases of an operation. This is
+// returned from T.Stopwatch() and is safe to use from multiple goroutines.
+type Stopwatch struct {
+	t     *T
+	lock  sync.Mutex
+	start time.Time
+	last  time.Time
+	laps  []lap
+}
+
+// A single recorded lap.
+type lap struct {
+	name     string
+	duration time.Duration
+}
+
+// Records a lap with the given name covering the time since the previous
+// lap (or since the stopwatch was started) and returns its duration.
+func (s *Stopwatch) Lap(name string) time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	d := now.Sub(s.last)
+	s.last = now
+	s.laps = append(s.laps, lap{name: name, duration: d})
+	return d
+}
+
+// Fails the test if the named lap took longer than d. If the lap was
+// recorded more than once then the most recent is checked.
+func (s *Stopwatch) ExpectLapUnder(name string, d time.Duration, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	s.lock.Lock()
+	var found *lap
+	for i := range s.laps {
+		if s.laps[i].name == name {
+			found = &s.laps[i]
+		}
+	}
+	s.lock.Unlock()
+	if found == nil {
+		s.t.Fatalf("%sNo lap named %q was recorded.", prefix, name)
+	} else if found.duration > d {
+		s.t.Fatalf("%sLap %q took %s, expected under %s.\n%s",
+			prefix, name, found.duration, d, s.summary())
+	}
+}
+
+// Returns a table of all laps, or an empty string if none were recorded.
+func (s *Stopwatch) summary() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.laps) == 0 {
+		return ""
+	}
+	width := len("total")
+	for _, l := range s.laps {
+		if len(l.name) > width {
+			width = len(l.name)
+		}
+	}
+	lines := make([]string, 0, len(s.laps)+1)
+	for _, l := range s.laps {
+		lines = append(lines, fmt.Sprintf("  %-*s  %s", width, l.name, l.duration))
+	}
+	lines = append(lines, fmt.Sprintf("  %-*s  %s", width, "total",
+		s.last.Sub(s.start)))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,63 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestT_Stopwatch(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+	logs := []string{}
+	m.funcLogf = func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+
+	s := T.Stopwatch()
+	s.Lap("connect")
+	time.Sleep(10 * time.Millisecond)
+	if d := s.Lap("query"); d < 10*time.Millisecond {
+		t.Fatalf("The lap was too short: %s", d)
+	}
+
+	m.CheckPass(t, func() { s.ExpectLapUnder("connect", time.Second) })
+	m.CheckFail(t, func() { s.ExpectLapUnder("query", time.Millisecond, "prefix") })
+	if !strings.HasPrefix(msg, `prefix: Lap "query" took `) {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { s.ExpectLapUnder("missing", time.Second) })
+	if !strings.HasPrefix(msg, `No lap named "missing" was recorded.`) {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	T.Finish()
+	if len(logs) != 1 {
+		t.Fatalf("The summary was not logged: %#v", logs)
+	}
+	for _, want := range []string{"Stopwatch laps:\n", "  connect  ", "  query    ", "  total    "} {
+		if !strings.Contains(logs[0], want) {
+			t.Fatalf("The summary did not contain %q: %s", want, logs[0])
+		}
+	}
+}
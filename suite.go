@@ -0,0 +1,91 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// This file contains functions for running xUnit style test suites.
+
+// These are the optional hooks that a suite can implement. Each is called
+// with the T for the suite or test that it applies to.
+type suiteSetup interface {
+	SetupSuite(*T)
+}
+type suiteTearDown interface {
+	TearDownSuite(*T)
+}
+type suiteSetupTest interface {
+	SetupTest(*T)
+}
+type suiteTearDownTest interface {
+	TearDownTest(*T)
+}
+
+// Runs every method on suite that is named Test* and has the signature
+// func(*T) as a subtest of t. Each test method is given a fresh T with its
+// own finalizers which are run when that method returns.
+//
+// The suite may optionally implement any of the following methods:
+//
+//	SetupSuite(*T)    - Called once before any test methods are run.
+//	TearDownSuite(*T) - Called once after all test methods have finished.
+//	SetupTest(*T)     - Called before each test method.
+//	TearDownTest(*T)  - Called after each test method, even if it failed.
+//
+// The T given to SetupSuite and TearDownSuite is shared by the whole suite
+// so finalizers added to it are run once the suite has finished.
+func RunSuite(t *testing.T, suite interface{}) {
+	T := NewT(t)
+	defer T.Finish()
+
+	if s, ok := suite.(suiteSetup); ok {
+		s.SetupSuite(T)
+	}
+	if s, ok := suite.(suiteTearDown); ok {
+		T.AddNamedFinalizer("TearDownSuite", func() { s.TearDownSuite(T) })
+	}
+
+	value := reflect.ValueOf(suite)
+	typ := value.Type()
+	tType := reflect.TypeOf(T)
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i)
+		if !strings.HasPrefix(method.Name, "Test") {
+			continue
+		}
+		mtype := method.Type
+		if mtype.NumIn() != 2 || mtype.In(1) != tType || mtype.NumOut() != 0 {
+			T.Errorf("Suite method %s must have the signature func(*T)",
+				method.Name)
+			continue
+		}
+		f := value.Method(i)
+		t.Run(method.Name, func(t *testing.T) {
+			T := NewT(t)
+			defer T.Finish()
+			if s, ok := suite.(suiteSetupTest); ok {
+				s.SetupTest(T)
+			}
+			if s, ok := suite.(suiteTearDownTest); ok {
+				T.AddNamedFinalizer("TearDownTest", func() { s.TearDownTest(T) })
+			}
+			f.Call([]reflect.Value{reflect.ValueOf(T)})
+		})
+	}
+}
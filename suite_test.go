@@ -0,0 +1,75 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"strings"
+	"testing"
+)
+
+// A suite that records the order in which its methods are called.
+type testSuite struct {
+	calls []string
+}
+
+func (s *testSuite) SetupSuite(T *T) {
+	s.calls = append(s.calls, "SetupSuite")
+}
+
+func (s *testSuite) TearDownSuite(T *T) {
+	s.calls = append(s.calls, "TearDownSuite")
+}
+
+func (s *testSuite) SetupTest(T *T) {
+	s.calls = append(s.calls, "SetupTest")
+}
+
+func (s *testSuite) TearDownTest(T *T) {
+	s.calls = append(s.calls, "TearDownTest:"+T.Name())
+}
+
+func (s *testSuite) TestOne(T *T) {
+	s.calls = append(s.calls, "TestOne")
+	T.AddFinalizer(func() { s.calls = append(s.calls, "TestOneFinalizer") })
+}
+
+func (s *testSuite) TestTwo(T *T) {
+	s.calls = append(s.calls, "TestTwo")
+}
+
+// Not a test since it does not start with Test.
+func (s *testSuite) Helper(T *T) {
+	s.calls = append(s.calls, "Helper")
+}
+
+func TestRunSuite(t *testing.T) {
+	s := &testSuite{}
+	RunSuite(t, s)
+
+	want := []string{
+		"SetupSuite",
+		"SetupTest",
+		"TestOne",
+		"TestOneFinalizer",
+		"TearDownTest:TestRunSuite/TestOne",
+		"SetupTest",
+		"TestTwo",
+		"TearDownTest:TestRunSuite/TestTwo",
+		"TearDownSuite",
+	}
+	if strings.Join(s.calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("Unexpected call order:\nhave: %#v\nwant: %#v", s.calls, want)
+	}
+}
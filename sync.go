@@ -0,0 +1,339 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file contains synchronization primitives for coordinating the
+// goroutines in a test.
+
+// Returns a Latch that opens once CountDown() has been called n times.
+func (t *T) NewLatch(n int) *Latch {
+	l := &Latch{t: t, count: n, done: make(chan struct{})}
+	if n <= 0 {
+		close(l.done)
+	}
+	return l
+}
+
+// A one shot latch that opens once it has been counted down to zero. This is
+// returned from T.NewLatch().
+type Latch struct {
+	t     *T
+	lock  sync.Mutex
+	count int
+	done  chan struct{}
+}
+
+// Decrements the count, opening the latch when it reaches zero. Calls made
+// after the latch has opened are ignored.
+func (l *Latch) CountDown() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.count <= 0 {
+		return
+	}
+	l.count--
+	if l.count == 0 {
+		close(l.done)
+	}
+}
+
+// Returns a channel that is closed once the latch opens.
+func (l *Latch) Done() <-chan struct{} {
+	return l.done
+}
+
+// Waits for the latch to open, failing the test if it has not opened within
+// timeout.
+func (l *Latch) WaitTimeout(timeout time.Duration, desc ...string) {
+	defer l.t.trackWait("Latch.WaitTimeout")()
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-l.done:
+	case <-timer.C:
+		l.lock.Lock()
+		count := l.count
+		l.lock.Unlock()
+		l.t.Fatalf("%sLatch did not open within %s, %d count(s) remaining.",
+			prefix, timeout, count)
+	}
+}
+
+// Returns a Barrier that releases goroutines in groups of n.
+func (t *T) Barrier(n int) *Barrier {
+	return &Barrier{t: t, size: n, release: make(chan struct{})}
+}
+
+// A reusable barrier that blocks goroutines until n of them are waiting and
+// then releases them all at once. This is returned from T.Barrier().
+type Barrier struct {
+	t       *T
+	lock    sync.Mutex
+	size    int
+	waiting int
+	release chan struct{}
+}
+
+// Waits for the rest of the group to arrive at the barrier. Since this is
+// normally called from goroutines other than the one running the test it
+// reports a timeout via Errorf (which is safe to call from any goroutine)
+// rather than Fatalf, and returns false so the caller can bail out.
+func (b *Barrier) WaitTimeout(timeout time.Duration, desc ...string) bool {
+	defer b.t.trackWait("Barrier.WaitTimeout")()
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	b.lock.Lock()
+	release := b.release
+	b.waiting++
+	if b.waiting >= b.size {
+		// Last to arrive; release everybody and reset for the next group.
+		b.waiting = 0
+		b.release = make(chan struct{})
+		close(release)
+		b.lock.Unlock()
+		return true
+	}
+	b.lock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-release:
+		return true
+	case <-timer.C:
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	select {
+	case <-release:
+		// Released while the lock was being acquired.
+		return true
+	default:
+	}
+	arrived := b.waiting
+	b.waiting--
+	b.t.Errorf("%sBarrier timed out after %s with %d of %d goroutine(s) "+
+		"waiting.", prefix, timeout, arrived, b.size)
+	return false
+}
+
+// The interval at which ExpectUnlocked() retries a lock that supports
+// TryLock().
+const tryLockInterval = time.Millisecond
+
+// Fails the test if mu can not be locked within timeout, which means the
+// code under test did not release it. The stacks of all goroutines are
+// included in the failure so the holder can be found. If mu has a TryLock()
+// method (like sync.Mutex and sync.RWMutex) then it is polled, otherwise
+// Lock() is called from a goroutine which is abandoned on timeout and
+// releases the lock if it ever acquires it. On success the lock is
+// released again before this returns.
+func (t *T) ExpectUnlocked(
+	mu sync.Locker, timeout time.Duration, desc ...string,
+) {
+	defer t.trackWait("ExpectUnlocked")()
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	if !acquireLock(mu, timeout) {
+		t.Fatalf("%sLock is still held after %s.\nGoroutines:\n%s",
+			prefix, timeout, goroutineStacks())
+	}
+	mu.Unlock()
+}
+
+// Attempts to lock mu for up to timeout, returning true if it was locked.
+func acquireLock(mu sync.Locker, timeout time.Duration) bool {
+	if tl, ok := mu.(interface{ TryLock() bool }); ok {
+		end := time.Now().Add(timeout)
+		for {
+			if tl.TryLock() {
+				return true
+			} else if !time.Now().Before(end) {
+				return false
+			}
+			time.Sleep(tryLockInterval)
+		}
+	}
+
+	// The goroutine and the timeout race under lock so exactly one of them
+	// decides who owns mu.
+	var lock sync.Mutex
+	abandoned := false
+	acquired := make(chan struct{})
+	go func() {
+		mu.Lock()
+		lock.Lock()
+		defer lock.Unlock()
+		if abandoned {
+			mu.Unlock()
+			return
+		}
+		close(acquired)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-acquired:
+		return true
+	case <-timer.C:
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	select {
+	case <-acquired:
+		return true
+	default:
+	}
+	abandoned = true
+	return false
+}
+
+// Fails the test if wg does not reach zero within timeout. On failure the
+// goroutines blocked on channel or lock operations are reported in full,
+// followed by a one line summary of every other goroutine, which usually
+// points straight at the goroutine that never called Done(). This replaces
+// the common pattern of calling Wait() in a goroutine and selecting on a
+// channel with a timeout.
+//
+// If the WaitGroup never reaches zero then the goroutine waiting on it is
+// left running.
+func (t *T) ExpectDone(
+	wg *sync.WaitGroup, timeout time.Duration, desc ...string,
+) {
+	defer t.trackWait("ExpectDone")()
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	blocked, other := blockedGoroutines(goroutineStacks())
+	t.Fatalf("%sWaitGroup did not finish within %s.\n"+
+		"%d goroutine(s) blocked on channel or lock operations:\n\n%s\n\n"+
+		"Other goroutines:\n%s",
+		prefix, timeout, len(blocked), strings.Join(blocked, "\n\n"),
+		strings.Join(other, "\n"))
+}
+
+// How often ExpectCounter() and ExpectCounterAtLeast() call get.
+const counterPollInterval = time.Millisecond
+
+// The most counter values reported when ExpectCounter() times out.
+const maxCounterProgression = 20
+
+// Polls get until it returns want, failing the test if it has not after
+// timeout. This is intended for counters that are updated atomically by
+// other goroutines. On failure every value that was observed is reported
+// along with when it was first seen, which shows whether the counter was
+// stuck, slow or overshot.
+func (t *T) ExpectCounter(
+	get func() int64, want int64, timeout time.Duration, desc ...string,
+) {
+	defer t.trackWait("ExpectCounter")()
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	ok, progression := pollCounter(get, timeout, func(v int64) bool {
+		return v == want
+	})
+	if !ok {
+		t.Fatalf("%sCounter did not reach %d within %s. Observed:\n  %s",
+			prefix, want, timeout, strings.Join(progression, "\n  "))
+	}
+}
+
+// Like ExpectCounter except that the counter only has to reach at least
+// want.
+func (t *T) ExpectCounterAtLeast(
+	get func() int64, want int64, timeout time.Duration, desc ...string,
+) {
+	defer t.trackWait("ExpectCounterAtLeast")()
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	ok, progression := pollCounter(get, timeout, func(v int64) bool {
+		return v >= want
+	})
+	if !ok {
+		t.Fatalf("%sCounter did not reach at least %d within %s. "+
+			"Observed:\n  %s", prefix, want, timeout,
+			strings.Join(progression, "\n  "))
+	}
+}
+
+// Calls get until done returns true for its value or timeout passes. Each
+// distinct value seen is returned along with the time it was first seen.
+func pollCounter(
+	get func() int64, timeout time.Duration, done func(int64) bool,
+) (bool, []string) {
+	start := time.Now()
+	end := start.Add(timeout)
+	progression := []string{}
+	omitted := 0
+	var last int64
+	for i := 0; ; i++ {
+		v := get()
+		if done(v) {
+			return true, nil
+		}
+		if i == 0 || v != last {
+			if len(progression) == maxCounterProgression {
+				progression = progression[1:]
+				omitted++
+			}
+			progression = append(progression, fmt.Sprintf("%d (at %s)",
+				v, time.Since(start).Round(time.Microsecond)))
+			last = v
+		}
+		if !time.Now().Before(end) {
+			break
+		}
+		time.Sleep(counterPollInterval)
+	}
+	if omitted > 0 {
+		progression = append([]string{fmt.Sprintf(
+			"... %d earlier value(s) omitted", omitted)}, progression...)
+	}
+	return false, progression
+}
@@ -0,0 +1,218 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestT_NewLatch(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	m.CheckPass(t, func() { T.NewLatch(0).WaitTimeout(time.Millisecond) })
+
+	l := T.NewLatch(3)
+	l.CountDown()
+	m.CheckFail(t, func() { l.WaitTimeout(time.Millisecond, "prefix") })
+	if !strings.HasPrefix(msg, "prefix: Latch did not open within 1ms, 2 count(s) remaining.") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	go l.CountDown()
+	go l.CountDown()
+	m.CheckPass(t, func() { l.WaitTimeout(time.Second) })
+	l.CountDown()
+	select {
+	case <-l.Done():
+	default:
+		t.Fatalf("The latch was not opened.")
+	}
+}
+
+func TestT_Barrier(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcError = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	// All goroutines in each group pass the barrier together, and the
+	// barrier can be reused.
+	b := T.Barrier(3)
+	var arrived int32
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			atomic.AddInt32(&arrived, 1)
+			if !b.WaitTimeout(time.Second) {
+				t.Errorf("The barrier timed out.")
+			} else if n := atomic.LoadInt32(&arrived); n < 3 {
+				t.Errorf("Released with only %d goroutine(s) arrived.", n)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Incomplete groups time out.
+	b = T.Barrier(2)
+	if b.WaitTimeout(time.Millisecond, "prefix") {
+		t.Fatalf("The barrier should have timed out.")
+	} else if !strings.HasPrefix(msg, "prefix: Barrier timed out after 1ms with 1 of 2 goroutine(s) waiting.") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
+
+// A sync.Locker without a TryLock() method.
+type plainLocker struct {
+	mu sync.Mutex
+}
+
+func (p *plainLocker) Lock()   { p.mu.Lock() }
+func (p *plainLocker) Unlock() { p.mu.Unlock() }
+
+func TestT_ExpectUnlocked(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	for _, mu := range []sync.Locker{&sync.Mutex{}, &plainLocker{}} {
+		m.CheckPass(t, func() { T.ExpectUnlocked(mu, time.Second) })
+
+		// Released while waiting.
+		mu.Lock()
+		go func() {
+			time.Sleep(time.Millisecond * 5)
+			mu.Unlock()
+		}()
+		m.CheckPass(t, func() { T.ExpectUnlocked(mu, time.Second) })
+
+		// Still held.
+		mu.Lock()
+		m.CheckFail(t, func() {
+			T.ExpectUnlocked(mu, time.Millisecond*10, "prefix")
+		})
+		if !strings.HasPrefix(msg, "prefix: Lock is still held after 10ms.") {
+			t.Fatalf("Unexpected message: %s", msg)
+		} else if !strings.Contains(msg, "goroutine ") {
+			t.Fatalf("Goroutine stacks were not reported: %s", msg)
+		}
+
+		// The lock is usable once the holder releases it, including after
+		// an abandoned Lock() call.
+		mu.Unlock()
+		m.CheckPass(t, func() { T.ExpectUnlocked(mu, time.Second) })
+	}
+}
+
+func TestT_ExpectDone(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	var wg sync.WaitGroup
+	m.CheckPass(t, func() { T.ExpectDone(&wg, time.Second) })
+
+	wg.Add(2)
+	go wg.Done()
+	go func() {
+		time.Sleep(time.Millisecond * 5)
+		wg.Done()
+	}()
+	m.CheckPass(t, func() { T.ExpectDone(&wg, time.Second) })
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-stuck
+	}()
+	m.CheckFail(t, func() {
+		T.ExpectDone(&wg, time.Millisecond*10, "prefix")
+	})
+	if !strings.HasPrefix(msg, "prefix: WaitGroup did not finish within 10ms.") {
+		t.Fatalf("Unexpected message: %s", msg)
+	} else if !strings.Contains(msg, "TestT_ExpectDone") {
+		t.Fatalf("The stuck goroutine was not reported: %s", msg)
+	}
+}
+
+func TestT_ExpectCounter(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	var counter int64
+	get := func() int64 { return atomic.LoadInt64(&counter) }
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&counter, 1)
+		}
+	}()
+	m.CheckPass(t, func() { T.ExpectCounter(get, 3, time.Second) })
+	m.CheckPass(t, func() { T.ExpectCounterAtLeast(get, 2, time.Second) })
+
+	m.CheckFail(t, func() {
+		T.ExpectCounter(get, 2, time.Millisecond*5, "prefix")
+	})
+	if !strings.HasPrefix(msg, "prefix: Counter did not reach 2 within 5ms. Observed:\n  3 (at ") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { T.ExpectCounterAtLeast(get, 4, time.Millisecond*5) })
+	if !strings.HasPrefix(msg, "Counter did not reach at least 4 within 5ms.") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	// Only the most recent values are reported.
+	calls := int64(0)
+	m.CheckFail(t, func() {
+		T.ExpectCounter(func() int64 {
+			calls++
+			return calls
+		}, -1, time.Millisecond*50)
+	})
+	if !strings.Contains(msg, "earlier value(s) omitted") {
+		t.Fatalf("Old values were not omitted: %s", msg)
+	} else if n := strings.Count(msg, " (at "); n != maxCounterProgression {
+		t.Fatalf("Expected %d values, got %d: %s", maxCounterProgression, n, msg)
+	}
+}
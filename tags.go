@@ -0,0 +1,91 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This file contains functions for tagging tests so that only some of them
+// are run.
+
+// A comma separated list of tags that selects which tagged tests are run.
+// Tags prefixed with a "-" exclude tests instead.
+const tagsEnv = "TESTLIB_TAGS"
+
+// Tags the test with the given categories, like "integration" or "slow",
+// and skips it if the tags do not match the TESTLIB_TAGS environment
+// variable. This gives a lightweight way of selecting tests that composes
+// with go test -run.
+//
+// TESTLIB_TAGS is a comma separated list of tags. A test is run if it has
+// at least one of the listed tags, and skipped if it has any tag listed
+// with a "-" prefix. For example TESTLIB_TAGS=integration,-slow runs the
+// integration tests that are not also slow. Tests that never call Tag()
+// are always run, and if TESTLIB_TAGS is empty every test is run.
+//
+// Tags accumulate across calls, but since each call checks the filter all
+// of a test's tags should normally be given in a single call.
+func (t *T) Tag(tags ...string) {
+	t.lock.Lock()
+	t.tags = append(t.tags, tags...)
+	all := append([]string(nil), t.tags...)
+	t.lock.Unlock()
+	if reason := tagFilter(osGetenv(tagsEnv), all); reason != "" {
+		t.Skipf("%s", reason)
+	}
+}
+
+// Returns the tags given to Tag().
+func (t *T) Tags() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]string{}, t.tags...)
+}
+
+// Returns the reason a test with the given tags should be skipped under
+// the filter, or an empty string if the test should be run.
+func tagFilter(filter string, tags []string) string {
+	var include, exclude []string
+	for _, tag := range strings.Split(filter, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "-") && len(tag) > 1 {
+			exclude = append(exclude, tag[1:])
+		} else if tag != "" && tag != "-" {
+			include = append(include, tag)
+		}
+	}
+	for _, tag := range tags {
+		for _, ex := range exclude {
+			if tag == ex {
+				return fmt.Sprintf("Test is tagged %q which is excluded by "+
+					"%s=%s", tag, tagsEnv, filter)
+			}
+		}
+	}
+	if len(include) == 0 {
+		return ""
+	}
+	for _, tag := range tags {
+		for _, in := range include {
+			if tag == in {
+				return ""
+			}
+		}
+	}
+	return fmt.Sprintf("Test tags [%s] do not match %s=%s",
+		strings.Join(tags, ", "), tagsEnv, filter)
+}
@@ -0,0 +1,85 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTagFilter(t *testing.T) {
+	t.Parallel()
+	for _, c := range []struct {
+		filter string
+		tags   []string
+		run    bool
+	}{
+		{"", []string{"slow"}, true},
+		{"", nil, true},
+		{"slow", []string{"slow"}, true},
+		{"slow", []string{"fast"}, false},
+		{"integration, slow", []string{"unit", "slow"}, true},
+		{"-slow", []string{"integration"}, true},
+		{"-slow", []string{"integration", "slow"}, false},
+		{"integration,-slow", []string{"integration", "slow"}, false},
+		{"integration,-slow", []string{"integration"}, true},
+		{",,-", []string{"anything"}, true},
+	} {
+		reason := tagFilter(c.filter, c.tags)
+		if (reason == "") != c.run {
+			t.Fatalf("tagFilter(%q, %v) = %q, expected run=%t",
+				c.filter, c.tags, reason, c.run)
+		}
+	}
+}
+
+func TestT_Tag(t *testing.T) {
+	m, T := testSetup()
+	msg := ""
+	m.funcSkip = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+
+	filter := ""
+	osGetenv = func(s string) string {
+		if s == tagsEnv {
+			return filter
+		}
+		return ""
+	}
+	defer func() { osGetenv = os.Getenv }()
+
+	m.CheckPass(t, func() { T.Tag("slow") })
+	filter = "integration"
+	m.CheckPass(t, func() { T.Tag("integration") })
+	if tags := T.Tags(); strings.Join(tags, ",") != "slow,integration" {
+		t.Fatalf("Unexpected tags: %v", tags)
+	}
+
+	filter = "-slow"
+	m.CheckSkips(t, func() { T.Tag() })
+	if !strings.Contains(msg, `tagged "slow" which is excluded`) {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	m, T = testSetup()
+	m.funcSkip = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	filter = "integration"
+	m.CheckSkips(t, func() { T.Tag("unit") })
+	if !strings.HasPrefix(msg,
+		"Test tags [unit] do not match TESTLIB_TAGS=integration\n") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
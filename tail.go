@@ -0,0 +1,117 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// This file contains functions for following files that are being written
+// to, such as the log of a spawned process.
+
+// How often a followed file is checked for new data.
+var tailPollInterval = 10 * time.Millisecond
+
+// Returns a FileTail that follows the file at path from its beginning. The
+// file does not need to exist yet. Any open handle is closed when the test
+// finishes.
+func (t *T) TailFile(path string) *FileTail {
+	f := &FileTail{t: t, path: path}
+	t.AddFinalizer(func() {
+		if f.file != nil {
+			f.file.Close()
+		}
+	})
+	return f
+}
+
+// Follows a growing file line by line. This is returned from T.TailFile().
+// It is not safe to use from multiple goroutines.
+type FileTail struct {
+	t       *T
+	path    string
+	file    *os.File
+	partial string
+	lines   []string
+}
+
+// Reads any new complete lines from the file into the buffer.
+func (f *FileTail) poll() {
+	if f.file == nil {
+		file, err := osOpen(f.path)
+		if err != nil {
+			return
+		}
+		f.file = file
+	}
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := f.file.Read(buf)
+		f.partial += string(buf[:n])
+		if n == 0 || err == io.EOF {
+			break
+		} else if err != nil {
+			f.t.Fatalf("Error reading %s: %s", f.path, err)
+		}
+	}
+	if i := strings.LastIndex(f.partial, "\n"); i >= 0 {
+		f.lines = append(f.lines, strings.Split(f.partial[:i], "\n")...)
+		f.partial = f.partial[i+1:]
+	}
+}
+
+// Waits for a line matching the regular expression re to be written to the
+// file and returns it. Lines are consumed as they are checked, so each call
+// starts from the line after the previous match. If no matching line has
+// been written within timeout then the test fails with the lines that were
+// seen while waiting.
+func (f *FileTail) ExpectLine(
+	re string, timeout time.Duration, desc ...string,
+) string {
+	defer f.t.trackWait("FileTail.ExpectLine")()
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	r, err := regexp.Compile(re)
+	if err != nil {
+		f.t.Fatalf("%sInvalid regular expression %q: %s", prefix, re, err)
+	}
+	seen := []string{}
+	end := time.Now().Add(timeout)
+	for {
+		f.poll()
+		for len(f.lines) > 0 {
+			line := strings.TrimSuffix(f.lines[0], "\r")
+			f.lines = f.lines[1:]
+			if r.MatchString(line) {
+				return line
+			}
+			seen = append(seen, line)
+		}
+		if !time.Now().Before(end) {
+			break
+		}
+		time.Sleep(tailPollInterval)
+	}
+	f.t.Fatalf("%sNo line matching %q was written to %s within %s.\n"+
+		"Lines seen:\n  %s", prefix, re, f.path, timeout,
+		strings.Join(seen, "\n  "))
+	return ""
+}
@@ -0,0 +1,64 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestT_TailFile(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+	defer T.Finish()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	// The file does not exist when the tail starts.
+	path := filepath.Join(T.TempDir(), "log")
+	tail := T.TailFile(path)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		f, err := os.Create(path)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		fmt.Fprintf(f, "starting\r\nlistening on :80")
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprintf(f, "80\nready\n")
+	}()
+
+	var line string
+	m.CheckPass(t, func() { line = tail.ExpectLine(`listening on :\d+`, time.Second) })
+	if line != "listening on :8080" {
+		t.Fatalf("Unexpected line: %q", line)
+	}
+	m.CheckPass(t, func() { tail.ExpectLine(`^ready$`, time.Second) })
+
+	// Lines are consumed so earlier output does not match again.
+	m.CheckFail(t, func() { tail.ExpectLine(`starting`, 20*time.Millisecond, "prefix") })
+	if !strings.HasPrefix(msg, `prefix: No line matching "starting"`) {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { tail.ExpectLine(`(`, time.Millisecond) })
+}
@@ -0,0 +1,45 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// This file contains functions for rendering files from templates.
+
+// Executes tmpl as a text/template with data and writes the result to a
+// temporary file, returning its path. The file is removed when the test
+// finishes. In addition to the standard template functions the following
+// are available, which makes it easy to render configuration files for
+// servers started by the test:
+//
+//	tempDir     - a new directory from TempDir()
+//	testTempDir - the directory returned from TestTempDir()
+//	freePort    - a port from FreePort()
+//	testName    - the name of the running test
+func (t *T) RenderTempFile(tmpl string, data interface{}) string {
+	parsed, err := template.New(t.tempPrefix()).Funcs(template.FuncMap{
+		"tempDir":     t.TempDir,
+		"testTempDir": t.TestTempDir,
+		"freePort":    t.FreePort,
+		"testName":    t.Name,
+	}).Option("missingkey=error").Parse(tmpl)
+	t.ExpectSuccess(err, "Unable to parse template")
+	var buffer bytes.Buffer
+	t.ExpectSuccess(parsed.Execute(&buffer, data), "Unable to render template")
+	return t.WriteTempFile(buffer.String())
+}
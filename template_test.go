@@ -0,0 +1,46 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestT_RenderTempFile(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+	defer T.Finish()
+
+	path := T.RenderTempFile(
+		"name={{.Name}}\nport={{freePort}}\ndata={{tempDir}}\ntest={{testName}}\n",
+		map[string]string{"Name": "server"})
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read the rendered file: %s", err)
+	}
+	re := regexp.MustCompile(`^name=server\nport=\d+\ndata=(.+)\ntest=TestT_RenderTempFile\n$`)
+	match := re.FindStringSubmatch(string(contents))
+	if match == nil {
+		t.Fatalf("Unexpected contents: %s", contents)
+	} else if info, err := os.Stat(match[1]); err != nil || !info.IsDir() {
+		t.Fatalf("The temp dir was not created: %s", match[1])
+	}
+
+	m.CheckFail(t, func() { T.RenderTempFile("{{", nil) })
+	m.CheckFail(t, func() { T.RenderTempFile("{{.Missing}}", map[string]string{}) })
+}
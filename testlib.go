@@ -15,10 +15,17 @@
 package testlib
 
 import (
+	"context"
 	"fmt"
 	"path"
+	"reflect"
 	"runtime"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // This is a mirror of testing.TB except that it does not include the private
@@ -53,38 +60,291 @@ type T struct {
 	// instance.
 	name string
 
+	// The directory returned from TestTempDir(). This is empty until that
+	// function is first called.
+	testTempDir string
+
 	// This is a list of functions that need to be run when the test finishes,
 	// regardless of how the test finishes. This allows us to setup cleanup
 	// functionality without imposing more than a single defer on the
 	// calling test function.
-	finalizers []func()
+	finalizers []finalizer
+
+	// A count of all the finalizers ever added. This is used to name
+	// finalizers that were not given an explicit name.
+	added int
+
+	// Set to true once Finish() has been called.
+	finished bool
+
+	// Protects finalizers and finished so that finalizers can be added
+	// from multiple goroutines.
+	lock sync.Mutex
+
+	// If true then informational messages, like each finalizer as it is
+	// run, are logged.
+	verbose bool
+
+	// The maximum amount of time each finalizer is allowed to run for, or
+	// zero if finalizers are allowed to run forever.
+	finalizerTimeout time.Duration
+
+	// Functions registered via RegisterFormatter() which are used to render
+	// values of specific types in failure output.
+	formatters map[reflect.Type]func(interface{}) string
+
+	// The types registered via RegisterPointerHint() mapped to the type of
+	// the memory they point at.
+	pointerHints map[reflect.Type]reflect.Type
+
+	// The maximum length of a single value rendered in failure output. See
+	// SetMaxValueLength().
+	maxValueLength int
+
+	// If true then Equal failures include the have value as a Go literal.
+	printLiteral bool
+
+	// The steps (see Step()) that are currently running, from outer most
+	// to inner most. This is protected by lock.
+	steps []*step
+
+	// The change in each metric during the last call to RecordMetrics().
+	// This is protected by lock.
+	metricDeltas map[string]float64
+
+	// Set by ForbidSleep() to record each call to a wait helper in waits.
+	// waitDepth counts the tracked helpers currently running so that a
+	// helper built on another one is only recorded once. All are protected
+	// by lock.
+	trackWaits bool
+	waits      []waitRecord
+	waitDepth  int
+
+	// The time that NewT() was called.
+	start time.Time
+
+	// The value returned from Seed(), which is chosen on first use. This
+	// is protected by lock.
+	seed   int64
+	seeded bool
+
+	// The attempt number if this T is running inside of Retry().
+	attempt int
+
+	// Set by TrackResources() to total the size of temporary files in
+	// tempBytes as they are removed. Both are protected by lock.
+	trackTemp bool
+	tempBytes int64
+
+	// The context carrying the trace task started by Trace(), or nil if the
+	// test is not being traced. This is protected by lock.
+	traceCtx context.Context
+
+	// Set by SetDedupeFailures() to suppress repeated failures, which are
+	// counted in failureCounts (keyed by frame and message) and listed in
+	// the order first seen in failureOrder. repeatsLogged is set once the
+	// finalizer that logs the counts has been added. All are protected by
+	// lock.
+	dedupe        bool
+	failureCounts map[string]*repeatedFailure
+	failureOrder  []*repeatedFailure
+	repeatsLogged bool
+
+	// The tags given to Tag(). This is protected by lock.
+	tags []string
+
+	// Values given to AddSecret() which are masked in all output, longest
+	// first. This is protected by lock.
+	secrets []string
+
+	// The value returned from ID().
+	id int64
+
+	// The label given to Field() when this T was created by it, including
+	// the row and the labels of any enclosing fields.
+	label string
+
+	// The number of times Field() has been called from each call site,
+	// which is used as the row number. This is protected by lock.
+	fieldRows map[string]int
 }
 
+// A function registered to run when the test finishes.
+type finalizer struct {
+	// The name given to AddNamedFinalizer(), or "#N" where N is the order
+	// in which the finalizer was added.
+	name string
+
+	// The function to call.
+	f func()
+}
+
+// The number of T instances created by NewT(), used to assign each one a
+// unique ID.
+var tCount int64
+
 // This should be called when the test is started. It will initialize a
 // T instance for the specific test.
 func NewT(t testingTB) *T {
-	return &T{t: t}
+	return &T{
+		t:              t,
+		id:             atomic.AddInt64(&tCount, 1),
+		maxValueLength: defaultMaxValueLength,
+		start:          time.Now(),
+	}
+}
+
+// Returns an identifier that is unique to this T within the test binary.
+// Unlike Name() this is never shared, even by parallel subtests that were
+// given the same name or by the T instances made with Group() and Field(),
+// so it can be used to name artifacts that must not collide.
+func (t *T) ID() string {
+	return strconv.FormatInt(t.id, 10)
 }
 
 // This function should be immediately added as a defer after initializing
-// the T structure. This will clean up after the test.
+// the T structure. This will clean up after the test. Calling this more than
+// once is safe; only the first call will run the finalizers.
 func (t *T) Finish() {
-	for i := len(t.finalizers) - 1; i >= 0; i-- {
-		t.finalizers[i]()
+	t.lock.Lock()
+	finalizers := t.finalizers
+	t.finalizers = nil
+	t.finished = true
+	t.lock.Unlock()
+
+	for i := len(finalizers) - 1; i >= 0; i-- {
+		t.runFinalizer(finalizers[i])
+	}
+}
+
+// Runs a single finalizer. If a finalizer timeout has been set and the
+// finalizer does not complete in time then it is reported as a test error
+// and abandoned so the remaining finalizers can run.
+func (t *T) runFinalizer(fin finalizer) {
+	start := time.Now()
+	if t.isVerbose() {
+		t.Logf("Running finalizer %s", fin.name)
+	}
+	if t.finalizerTimeout <= 0 {
+		t.callFinalizer(fin)
+	} else {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			t.callFinalizer(fin)
+		}()
+		timer := time.NewTimer(t.finalizerTimeout)
+		select {
+		case <-done:
+			timer.Stop()
+		case <-timer.C:
+			t.Errorf("Finalizer %s did not finish within %s, abandoning it.\n"+
+				"Goroutines:\n%s", fin.name, t.finalizerTimeout,
+				goroutineStacks())
+		}
+	}
+	if t.isVerbose() {
+		t.Logf("Finalizer %s finished in %s", fin.name, time.Since(start))
+	}
+}
+
+// Calls the finalizer function. If the finalizer panics then the panic is
+// reported as a test error and swallowed so that the remaining finalizers
+// still get a chance to run.
+func (t *T) callFinalizer(fin finalizer) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("Finalizer %s panicked: %v\n%s",
+				fin.name, r, debug.Stack())
+		}
+	}()
+	fin.f()
+}
+
+// Sets the maximum amount of time that each finalizer is allowed to run
+// for. If a finalizer takes longer than this then Finish() reports an error
+// including the stacks of all running goroutines and moves on to the next
+// finalizer rather than letting one stuck cleanup hang the whole test
+// binary. A duration of zero (the default) disables the timeout.
+func (t *T) SetFinalizerTimeout(d time.Duration) {
+	t.finalizerTimeout = d
+}
+
+// Returns the stack traces of every running goroutine.
+func goroutineStacks() string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, len(buf)*2)
 	}
 }
 
+// Enables or disables verbose mode. In verbose mode informational messages
+// are logged, such as the name and duration of each finalizer as it runs
+// which makes diagnosing slow or hanging teardown far easier. Verbose mode
+// can also be enabled for every test with SetVerbosity().
+func (t *T) SetVerbose(verbose bool) {
+	t.verbose = verbose
+}
+
+// Returns true if Finish() has been called on this T.
+func (t *T) Finished() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.finished
+}
+
 // This adds a function that will be called once the test completes. The
 // functions are called when the test finishes in reverse order from how
-// they were added.
+// they were added. This is safe to call from multiple goroutines.
+//
+// If Finish() has already been called then there is nothing left to
+// trigger the finalizer so it is called immediately instead.
 func (t *T) AddFinalizer(f func()) {
-	t.finalizers = append(t.finalizers, f)
+	t.AddNamedFinalizer("", f)
+}
+
+// Like AddFinalizer except that the finalizer is given a name which is used
+// when logging in verbose mode and when reporting failures.
+func (t *T) AddNamedFinalizer(name string, f func()) {
+	t.lock.Lock()
+	t.added++
+	if name == "" {
+		name = fmt.Sprintf("#%d", t.added)
+	}
+	fin := finalizer{name: name, f: f}
+	if !t.finished {
+		t.finalizers = append(t.finalizers, fin)
+		t.lock.Unlock()
+		return
+	}
+	t.lock.Unlock()
+	t.runFinalizer(fin)
+}
+
+// Like AddFinalizer except that the function returns an error. If the
+// returned error is non nil then it is reported as a test error.
+func (t *T) AddFinalizerErr(f func() error) {
+	t.AddFinalizer(func() {
+		if err := f(); err != nil {
+			t.Errorf("Finalizer returned an error: %s", err)
+		}
+	})
 }
 
 // This call will make a stack trace message for the Fatal/Fatalf and
 // Error/Errorf function calls. This will insert "msg" at the top of the
-// stack and return a string.
+// stack and return a string. At VerbosityQuiet the stack is omitted and at
+// VerbosityTrimmed frames from the Go runtime and testing packages are left
+// out. Each frame is rendered according to SetStackFormat().
 func (t *T) makeStack(msg string) string {
+	verbosity := currentVerbosity()
+	if verbosity == VerbosityQuiet {
+		return msg
+	}
 	lines := make([]string, 0, 100)
 	lines = append(lines, msg)
 
@@ -96,33 +356,78 @@ func (t *T) makeStack(msg string) string {
 	thisdir := path.Dir(thisfile)
 
 	// Now walk through generating a stack trace.
+	format := currentStackFormat()
 	for i := 0; true; i++ {
-		_, file, line, ok := runtime.Caller(i)
+		pc, file, line, ok := runtime.Caller(i)
 		if !ok {
 			break
 		} else if path.Dir(file) == thisdir {
 			continue
+		} else if verbosity == VerbosityTrimmed && isRuntimeFrame(pc) {
+			continue
+		}
+		lines = append(lines, format.frame(file, line, pc))
+		if format.Source && len(lines) == 2 {
+			lines = append(lines, sourceSnippet(file, line)...)
 		}
-		trace := fmt.Sprintf("%s:%d", file, line)
-		lines = append(lines, trace)
 	}
 
 	return strings.Join(lines, "\n")
 }
 
+// Returns true if the function at pc is part of the Go runtime or the
+// testing package, which are rarely interesting in failure output.
+func isRuntimeFrame(pc uintptr) bool {
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return false
+	}
+	name := f.Name()
+	return strings.HasPrefix(name, "runtime.") ||
+		strings.HasPrefix(name, "testing.")
+}
+
+// Builds the message reported for a failure and marks the inner most
+// running step as failed. The message is prefixed with the label given to
+// Field(), if any, and then with the step path if any steps are running,
+// and a stack trace is added.
+func (t *T) failure(msg string) string {
+	t.failStep()
+	msg = t.scrub(msg)
+	if t.label != "" {
+		msg = t.label + ": " + msg
+	}
+	t.recordFailure(msg)
+	if path := t.stepPath(); path != "" {
+		msg = "[" + path + "] " + msg
+	}
+	return t.scrub(t.makeStack(msg))
+}
+
 // Wraps the testing.T.Error function call in order to provide full stack
 // traces around the error being reported rather than just the calling line.
 func (t *T) Error(args ...interface{}) {
-	t.t.Error(t.makeStack(fmt.Sprint(args...)))
+	msg := fmt.Sprint(args...)
+	if t.repeated(msg) {
+		t.failStep()
+		return
+	}
+	t.t.Error(t.failure(msg))
 }
 
 // Like Error() but for formatted strings.
 func (t *T) Errorf(format string, args ...interface{}) {
-	t.t.Error(t.makeStack(fmt.Sprintf(format, args...)))
+	msg := fmt.Sprintf(format, args...)
+	if t.repeated(msg) {
+		t.failStep()
+		return
+	}
+	t.t.Error(t.failure(msg))
 }
 
 // A wrapper around testing.T.FailNow()
 func (t *T) FailNow() {
+	t.failStep()
 	t.t.FailNow()
 }
 
@@ -137,60 +442,147 @@ func (t *T) Failed() bool {
 // or helper functions are used far easier.
 func (t *T) Fatal(args ...interface{}) {
 	// TODO: Add pre-failure helper functions.
-	t.t.Fatal(t.makeStack(fmt.Sprint(args...)))
+	t.t.Fatal(t.failure(fmt.Sprint(args...)))
 }
 
 // Like Fatal() except for formatted strings.
 func (t *T) Fatalf(format string, args ...interface{}) {
 	// TODO: Add pre-failure helper functions.
-	t.t.Fatal(t.makeStack(fmt.Sprintf(format, args...)))
+	t.t.Fatal(t.failure(fmt.Sprintf(format, args...)))
 }
 
 // A wrapper for testing.T.Log to make object passing easier.
 func (t *T) Log(args ...interface{}) {
+	if t.hasSecrets() {
+		t.t.Log(t.scrub(strings.TrimSuffix(fmt.Sprintln(args...), "\n")))
+		return
+	}
 	t.t.Log(args...)
 }
 
 // A wrapper for testing.T.Logf to make object passing easier.
 func (t *T) Logf(format string, args ...interface{}) {
+	if t.hasSecrets() {
+		t.t.Log(t.scrub(fmt.Sprintf(format, args...)))
+		return
+	}
 	t.t.Logf(format, args...)
 }
 
-// Gets the function name of the running test. This is useful since there is
-// no other programatic way of finding out which test is running.
+// Gets the name of the running test. If the underlying testing object
+// provides a Name() function (as testing.T and testing.B do) then that is
+// used, which includes the names of any subtests, for example
+// "TestFoo/case_1". Otherwise the call stack is walked looking for the
+// Test* or Benchmark* function that is running.
 func (t *T) Name() string {
 	// If we already calculated this then just return the cached value.
 	if t.name != "" {
 		return t.name
 	}
 
+	// Prefer the name given by the testing library if it is available.
+	if named, ok := t.t.(interface {
+		Name() string
+	}); ok {
+		if t.name = named.Name(); t.name != "" {
+			return t.name
+		}
+	}
+
 	// Next we need to walk through the call stack checking the name of
-	// each function that is running. Each function follows the form:
-	// "module/file.function" so we need to split on . and take the
-	// last element. We then need to find the first function named "Test*"
-	// in the list.
+	// each function that is running, stopping at the inner most one that
+	// belongs to a Test* or Benchmark* function. Closures (like those
+	// passed to t.Run) are attributed to the function that created them.
+	// Methods on T are skipped so that helpers like TestTempDir() are
+	// never mistaken for the running test.
+	self := ""
 	for i := 0; true; i++ {
 		if pc, _, _, ok := runtime.Caller(i); !ok {
 			break
-		} else {
-			f := runtime.FuncForPC(pc)
-			name := f.Name()
-			index := strings.LastIndex(name, ".")
-			name = name[index+1:]
-			if strings.HasPrefix(name, "Test") {
-				t.name = name
-			} else if strings.HasPrefix(name, "Benchmark") {
-				t.name = name
-			}
+		} else if name := runtime.FuncForPC(pc).Name(); i == 0 {
+			self = name[:strings.LastIndex(name, ".")+1]
+		} else if strings.HasPrefix(name, self) {
+			continue
+		} else if name = testFuncName(name); name != "" {
+			t.name = name
+			break
 		}
 	}
 
 	return t.name
 }
 
+// Returns the name of the Test* or Benchmark* function that the given
+// runtime function name belongs to, or "" if there is not one. The form is
+// "module/package.function" with an optional receiver before the function,
+// and closures add segments like ".func1" after it, so for example
+// "pkg.(*Suite).TestFoo.func1.2" returns "TestFoo".
+func testFuncName(name string) string {
+	name = name[strings.LastIndex(name, "/")+1:]
+	parts := strings.Split(name, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		if isClosureName(parts[i]) {
+			continue
+		} else if strings.HasPrefix(parts[i], "Test") ||
+			strings.HasPrefix(parts[i], "Benchmark") {
+			return parts[i]
+		}
+		break
+	}
+	return ""
+}
+
+// Returns true if the given segment of a function name is one the compiler
+// gives to a closure, like "func1", "gowrap2" or just "3".
+func isClosureName(s string) bool {
+	for _, prefix := range []string{"func", "gowrap", "deferwrap"} {
+		if strings.HasPrefix(s, prefix) {
+			s = s[len(prefix):]
+			break
+		}
+	}
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// The longest prefix returned from tempPrefix(), which keeps deeply nested
+// subtest names from exceeding the file system's limit on name length.
+const maxTempPrefix = 100
+
+// Returns a version of Name() that is safe to use as a prefix for temporary
+// file and directory names, followed by "-" and ID() so that two T
+// instances with the same name never produce ambiguous artifacts. Subtest
+// names contain path separators which are not allowed in temporary file
+// patterns, and may contain spaces or a "*" which would be mistaken for the
+// random part of the pattern, so anything other than letters, digits, '.',
+// '-' and '_' is replaced with '_'.
+func (t *T) tempPrefix() string {
+	id := "-" + t.ID()
+	prefix := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+		default:
+			return '_'
+		}
+		return r
+	}, t.Name())
+	if len(prefix)+len(id) > maxTempPrefix {
+		prefix = prefix[:maxTempPrefix-len(id)]
+	}
+	return prefix + id
+}
+
 // Marks the test as having skipped and reports a full stack trace.
 func (t *T) Skip(args ...interface{}) {
-	t.t.Skip(t.makeStack(fmt.Sprint(args...)))
+	t.t.Skip(t.scrub(t.makeStack(fmt.Sprint(args...))))
 }
 
 // A wrapper around testing.T.SkipNow()
@@ -200,7 +592,7 @@ func (t *T) SkipNow() {
 
 // Wraps around testing.T.Skipf except this provides a full stack trace.
 func (t *T) Skipf(format string, args ...interface{}) {
-	t.t.Skip(t.makeStack(fmt.Sprintf(format, args...)))
+	t.t.Skip(t.scrub(t.makeStack(fmt.Sprintf(format, args...))))
 }
 
 // A wrapper around testing.T.Skipped()
@@ -19,6 +19,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestT_Finalizers(t *testing.T) {
@@ -53,6 +54,162 @@ func TestT_Finalizers(t *testing.T) {
 	}
 }
 
+func TestT_FinalizersParallel(t *testing.T) {
+	t.Parallel()
+	T := NewT(new(mockT))
+
+	// Add finalizers from many goroutines at once.
+	lock := sync.Mutex{}
+	ran := 0
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			T.AddFinalizer(func() {
+				lock.Lock()
+				ran += 1
+				lock.Unlock()
+			})
+		}()
+	}
+	wg.Wait()
+
+	if T.Finished() {
+		t.Fatalf("Finished() returned true before Finish() was called.")
+	}
+	T.Finish()
+	if !T.Finished() {
+		t.Fatalf("Finished() returned false after Finish() was called.")
+	} else if ran != 100 {
+		t.Fatalf("Expected 100 finalizers to run, %d ran.", ran)
+	}
+
+	// Calling Finish() again should not run the finalizers again.
+	T.Finish()
+	if ran != 100 {
+		t.Fatalf("Finalizers ran more than once.")
+	}
+
+	// Finalizers added after Finish() run immediately.
+	T.AddFinalizer(func() { ran += 1 })
+	if ran != 101 {
+		t.Fatalf("Finalizer added after Finish() was not run.")
+	}
+}
+
+func TestT_FinalizerPanics(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	// Capture the error.
+	msg := ""
+	m.funcError = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	ran := false
+	T.AddFinalizer(func() { ran = true })
+	T.AddFinalizer(func() { panic("EXPECTED") })
+	m.CheckFail(t, func() { T.Finish() })
+	if !ran {
+		t.Fatalf("Finalizers after the panic were not run.")
+	} else if !strings.Contains(msg, "EXPECTED") {
+		t.Fatalf("The panic was not reported: %s", msg)
+	}
+}
+
+func TestT_AddFinalizerErr(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	// Capture the error.
+	msg := ""
+	m.funcError = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	T.AddFinalizerErr(func() error { return nil })
+	m.CheckPass(t, func() { T.Finish() })
+
+	T = NewT(m)
+	T.AddFinalizerErr(func() error { return fmt.Errorf("EXPECTED") })
+	m.CheckFail(t, func() { T.Finish() })
+	if !strings.Contains(msg, "EXPECTED") {
+		t.Fatalf("The error was not reported: %s", msg)
+	}
+}
+
+func TestT_AddNamedFinalizer(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	// Capture the log messages.
+	logs := []string{}
+	m.funcLogf = func(f string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(f, args...))
+	}
+
+	// Non verbose mode doesn't log anything.
+	ran := false
+	T.AddNamedFinalizer("cleanup", func() { ran = true })
+	T.Finish()
+	if !ran {
+		t.Fatalf("The named finalizer did not run.")
+	} else if len(logs) != 0 {
+		t.Fatalf("Unexpected log messages: %#v", logs)
+	}
+
+	// Verbose mode logs each finalizer as it starts and finishes.
+	T = NewT(m)
+	T.SetVerbose(true)
+	T.AddNamedFinalizer("cleanup", func() {})
+	T.AddFinalizer(func() {})
+	T.Finish()
+	if len(logs) != 4 {
+		t.Fatalf("Unexpected log messages: %#v", logs)
+	} else if !strings.Contains(logs[0], "#2") {
+		t.Fatalf("Unnamed finalizer was not logged: %s", logs[0])
+	} else if !strings.Contains(logs[2], "cleanup") {
+		t.Fatalf("Named finalizer was not logged: %s", logs[2])
+	} else if !strings.Contains(logs[3], "finished in") {
+		t.Fatalf("Finalizer duration was not logged: %s", logs[3])
+	}
+}
+
+func TestT_SetFinalizerTimeout(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	// Capture the error.
+	msg := ""
+	m.funcError = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	// A finalizer that finishes in time passes.
+	T.SetFinalizerTimeout(time.Second)
+	T.AddFinalizer(func() {})
+	m.CheckPass(t, func() { T.Finish() })
+
+	// A finalizer that blocks is abandoned and the rest still run.
+	T = NewT(m)
+	T.SetFinalizerTimeout(time.Millisecond * 10)
+	block := make(chan struct{})
+	defer close(block)
+	ran := false
+	T.AddFinalizer(func() { ran = true })
+	T.AddNamedFinalizer("stuck", func() { <-block })
+	m.CheckFail(t, func() { T.Finish() })
+	if !ran {
+		t.Fatalf("Finalizers after the stuck finalizer were not run.")
+	} else if !strings.Contains(msg, "stuck did not finish") {
+		t.Fatalf("The timeout was not reported: %s", msg)
+	} else if !strings.Contains(msg, "goroutine ") {
+		t.Fatalf("Goroutine stacks were not reported: %s", msg)
+	}
+}
+
 func TestT_Error(t *testing.T) {
 	t.Parallel()
 	m, T := testSetup()
@@ -194,8 +351,9 @@ func TestT_Name(t *testing.T) {
 		t.Fatalf("Name() returned differing results.")
 	}
 
-	// Test with Benchmark as a prefix.
-	T = NewT(t)
+	// Test with Benchmark as a prefix. A testing.T would provide its own
+	// name so this uses a fake that forces the stack to be walked.
+	_, T = testSetup()
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 	name = ""
@@ -209,6 +367,54 @@ func TestT_Name(t *testing.T) {
 	}
 }
 
+func TestT_NameSubtest(t *testing.T) {
+	t.Parallel()
+
+	// Subtests should include the full subtest name.
+	t.Run("case_1", func(t *testing.T) {
+		if name := NewT(t).Name(); name != "TestT_NameSubtest/case_1" {
+			t.Fatalf("Name() returned the wrong name: %s", name)
+		}
+	})
+
+	// Closures are attributed to the test that created them when the
+	// stack is walked.
+	_, T := testSetup()
+	name := ""
+	func() { name = T.Name() }()
+	if name != "TestT_NameSubtest" {
+		t.Fatalf("Name() returned the wrong name: %s", name)
+	}
+}
+
+func TestTestFuncName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"pkg.TestFoo", "TestFoo"},
+		{"example.com/a/pkg.BenchmarkFoo", "BenchmarkFoo"},
+		{"example.com/a/pkg.TestFoo.func1", "TestFoo"},
+		{"example.com/a/pkg.TestFoo.func1.2", "TestFoo"},
+		{"example.com/a/pkg.TestFoo.gowrap1", "TestFoo"},
+		{"example.com/a/pkg.(*Suite).TestFoo", "TestFoo"},
+		{"example.com/a/pkg.(*Suite).TestFoo.func3", "TestFoo"},
+		{"example.com/a/pkg.Suite.TestFoo", "TestFoo"},
+		{"gopkg.in/yaml.v2.TestFoo", "TestFoo"},
+		{"example.com/a/pkg.helper", ""},
+		{"example.com/a/pkg.helper.func1", ""},
+		{"example.com/a/pkg.(*TestSuite).helper", ""},
+		{"main", ""},
+	}
+	for _, test := range tests {
+		if have := testFuncName(test.input); have != test.want {
+			t.Errorf("testFuncName(%q) returned %q, expected %q",
+				test.input, have, test.want)
+		}
+	}
+}
+
 func TestT_SkipNow(t *testing.T) {
 	t.Parallel()
 	m, T := testSetup()
@@ -0,0 +1,267 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// This file contains functions for comparing blocks of text.
+
+// Options that control how EqualLines() compares text.
+type LinesOptions struct {
+	// If true then whitespace at the end of each line is ignored.
+	IgnoreTrailingSpace bool
+
+	// If true then CRLF and CR line endings are treated as LF.
+	NormalizeEOL bool
+
+	// If true then lines that are empty (after any trailing whitespace has
+	// been removed) are skipped entirely.
+	SkipBlankLines bool
+}
+
+// Compares two blocks of text line by line. If they differ then the test
+// fails with a diff of the lines, numbered by their position in the
+// original text, with removed lines marked "-" and added lines marked "+".
+func (t *T) EqualLines(have, want string, opts LinesOptions, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	haveLines := splitLines(have, opts)
+	wantLines := splitLines(want, opts)
+	if diff, ok := lineDiff(haveLines, wantLines, diffContext()); !ok {
+		t.Fatalf("%sLines are not equal (- have, + want):\n%s", prefix, diff)
+	}
+}
+
+// A single line of text along with its line number in the original text.
+type textLine struct {
+	number int
+	text   string
+}
+
+// Splits text into lines, applying the given options.
+func splitLines(text string, opts LinesOptions) []textLine {
+	if opts.NormalizeEOL {
+		text = strings.Replace(text, "\r\n", "\n", -1)
+		text = strings.Replace(text, "\r", "\n", -1)
+	}
+	text = strings.TrimSuffix(text, "\n")
+	lines := []textLine{}
+	for i, line := range strings.Split(text, "\n") {
+		if opts.IgnoreTrailingSpace {
+			line = strings.TrimRight(line, " \t\r\v\f")
+		}
+		if opts.SkipBlankLines && line == "" {
+			continue
+		}
+		lines = append(lines, textLine{number: i + 1, text: line})
+	}
+	return lines
+}
+
+// Returns a numbered diff of the two sets of lines and false if they
+// differ. The diff is computed using the longest common subsequence so
+// that only the lines that actually changed are reported. Only context
+// unchanged lines are shown around each change, unless context is negative
+// in which case every line is shown.
+func lineDiff(have, want []textLine, context int) (string, bool) {
+	// lcs[i][j] is the length of the common subsequence of have[i:] and
+	// want[j:].
+	lcs := make([][]int, len(have)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(want)+1)
+	}
+	for i := len(have) - 1; i >= 0; i-- {
+		for j := len(want) - 1; j >= 0; j-- {
+			if have[i].text == want[j].text {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := []string{}
+	changed := []bool{}
+	equal := true
+	i, j := 0, 0
+	for i < len(have) || j < len(want) {
+		switch {
+		case i < len(have) && j < len(want) && have[i].text == want[j].text:
+			lines = append(lines,
+				fmt.Sprintf("  %4d  %s", have[i].number, have[i].text))
+			changed = append(changed, false)
+			i++
+			j++
+		case j < len(want) && (i == len(have) || lcs[i][j+1] > lcs[i+1][j]):
+			equal = false
+			lines = append(lines,
+				fmt.Sprintf("+ %4d  %s", want[j].number, want[j].text))
+			changed = append(changed, true)
+			j++
+		default:
+			equal = false
+			lines = append(lines,
+				fmt.Sprintf("- %4d  %s", have[i].number, have[i].text))
+			changed = append(changed, true)
+			i++
+		}
+	}
+	if context < 0 {
+		return strings.Join(lines, "\n"), equal
+	}
+
+	// Only keep unchanged lines that are within context of a change,
+	// replacing each run of skipped lines with "...".
+	keep := make([]bool, len(lines))
+	for n := range lines {
+		if !changed[n] {
+			continue
+		}
+		for k := n - context; k <= n+context; k++ {
+			if k >= 0 && k < len(lines) {
+				keep[k] = true
+			}
+		}
+	}
+	output := []string{}
+	for n := range lines {
+		if keep[n] {
+			output = append(output, lines[n])
+		} else if n == 0 || keep[n-1] {
+			output = append(output, "  ...")
+		}
+	}
+	return strings.Join(output, "\n"), equal
+}
+
+// Matches ANSI escape sequences: CSI sequences (colors, cursor movement and
+// so on), OSC sequences (window titles, hyperlinks) terminated by BEL or ST,
+// and the remaining two byte escapes.
+var ansiRegexp = regexp.MustCompile(
+	"\x1b\\[[0-?]*[ -/]*[@-~]" +
+		"|\x1b\\][^\x07\x1b]*(?:\x07|\x1b\\\\)" +
+		"|\x1b[@-Z\\\\-_]")
+
+// Returns s with all ANSI escape sequences, such as color codes, removed.
+func StripANSI(s string) string {
+	return ansiRegexp.ReplaceAllString(s, "")
+}
+
+// Compares the readable content of command line output, with any ANSI
+// escape sequences (see StripANSI()) removed from both have and want. If
+// they differ then the test fails with a line diff of the plain text
+// followed by the raw have value, escape codes and all, quoted so it can be
+// seen exactly what was written.
+func (t *T) ExpectOutputPlain(have, want string, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	haveLines := splitLines(StripANSI(have), LinesOptions{})
+	wantLines := splitLines(StripANSI(want), LinesOptions{})
+	if diff, ok := lineDiff(haveLines, wantLines, diffContext()); !ok {
+		t.Fatalf("%sOutput is not equal with ANSI codes removed "+
+			"(- have, + want):\n%s\nraw have: %s",
+			prefix, diff, t.truncate(fmt.Sprintf("%q", have)))
+	}
+}
+
+// Fails the test if the Levenshtein edit distance between have and want is
+// greater than maxEditDistance. This is useful for asserting against human
+// readable messages that may drift slightly over time. The failure message
+// includes the computed distance and an alignment of the two strings with
+// each edit marked.
+func (t *T) SimilarString(
+	have, want string, maxEditDistance int, desc ...string,
+) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	distance, haveAligned, wantAligned, marks := editAlignment(
+		[]rune(have), []rune(want))
+	if distance > maxEditDistance {
+		t.Fatalf("%sStrings are not similar enough.\n"+
+			"  distance: %d (max %d)\n  have: %s\n  want: %s\n        %s",
+			prefix, distance, maxEditDistance, haveAligned, wantAligned, marks)
+	}
+}
+
+// Computes the Levenshtein distance between have and want and returns it
+// along with the two strings aligned against each other. Gaps are shown as
+// '-' and the returned marks string has a '^' under every edit.
+func editAlignment(have, want []rune) (int, string, string, string) {
+	// dist[i][j] is the edit distance between have[:i] and want[:j].
+	dist := make([][]int, len(have)+1)
+	for i := range dist {
+		dist[i] = make([]int, len(want)+1)
+		dist[i][0] = i
+	}
+	for j := range dist[0] {
+		dist[0][j] = j
+	}
+	for i := 1; i <= len(have); i++ {
+		for j := 1; j <= len(want); j++ {
+			cost := 1
+			if have[i-1] == want[j-1] {
+				cost = 0
+			}
+			dist[i][j] = dist[i-1][j-1] + cost
+			if d := dist[i-1][j] + 1; d < dist[i][j] {
+				dist[i][j] = d
+			}
+			if d := dist[i][j-1] + 1; d < dist[i][j] {
+				dist[i][j] = d
+			}
+		}
+	}
+
+	// Walk back from the end to build the alignment.
+	var h, w, m []rune
+	i, j := len(have), len(want)
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && have[i-1] == want[j-1] &&
+			dist[i][j] == dist[i-1][j-1]:
+			h, w, m = append(h, have[i-1]), append(w, want[j-1]), append(m, ' ')
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && dist[i][j] == dist[i-1][j-1]+1:
+			h, w, m = append(h, have[i-1]), append(w, want[j-1]), append(m, '^')
+			i, j = i-1, j-1
+		case i > 0 && dist[i][j] == dist[i-1][j]+1:
+			h, w, m = append(h, have[i-1]), append(w, '-'), append(m, '^')
+			i--
+		default:
+			h, w, m = append(h, '-'), append(w, want[j-1]), append(m, '^')
+			j--
+		}
+	}
+	reverse := func(r []rune) string {
+		for a, b := 0, len(r)-1; a < b; a, b = a+1, b-1 {
+			r[a], r[b] = r[b], r[a]
+		}
+		return string(r)
+	}
+	return dist[len(have)][len(want)], reverse(h), reverse(w),
+		strings.TrimRight(reverse(m), " ")
+}
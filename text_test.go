@@ -0,0 +1,147 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestT_EqualLines(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	m.CheckPass(t, func() { T.EqualLines("a\nb\n", "a\nb", LinesOptions{}) })
+	m.CheckFail(t, func() {
+		T.EqualLines("a\nb\nc\n", "a\nx\nc\n", LinesOptions{}, "prefix")
+	})
+	if !strings.HasPrefix(msg, "prefix: ") {
+		t.Fatalf("The prefix was not prepended to the message: '''%s'''", msg)
+	}
+	want := "     1  a\n-    2  b\n+    2  x\n     3  c"
+	if !strings.Contains(msg, want) {
+		t.Fatalf("Unexpected diff:\n%s\nwant:\n%s", msg, want)
+	}
+
+	// Line endings.
+	m.CheckFail(t, func() { T.EqualLines("a\r\nb\r\n", "a\nb\n", LinesOptions{}) })
+	m.CheckPass(t, func() {
+		T.EqualLines("a\r\nb\r\n", "a\nb\n", LinesOptions{NormalizeEOL: true})
+	})
+
+	// Trailing whitespace.
+	m.CheckFail(t, func() { T.EqualLines("a \nb\t\n", "a\nb\n", LinesOptions{}) })
+	m.CheckPass(t, func() {
+		T.EqualLines("a \nb\t\n", "a\nb\n", LinesOptions{IgnoreTrailingSpace: true})
+	})
+
+	// Blank lines keep their original line numbers in the diff.
+	opts := LinesOptions{SkipBlankLines: true}
+	m.CheckPass(t, func() { T.EqualLines("a\n\n\nb\n", "a\nb\n", opts) })
+	m.CheckFail(t, func() { T.EqualLines("a\n\nb\n", "a\nb\nc\n", opts) })
+	if !strings.Contains(msg, "     3  b\n+    3  c") {
+		t.Fatalf("Unexpected diff:\n%s", msg)
+	}
+}
+
+func TestT_SimilarString(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	m.CheckPass(t, func() { T.SimilarString("kitten", "kitten", 0) })
+	m.CheckPass(t, func() { T.SimilarString("kitten", "sitting", 3) })
+	m.CheckFail(t, func() { T.SimilarString("kitten", "sitting", 2, "prefix") })
+	if !strings.HasPrefix(msg, "prefix: ") {
+		t.Fatalf("The prefix was not prepended to the message: '''%s'''", msg)
+	}
+	want := "  distance: 3 (max 2)\n" +
+		"  have: kitten-\n" +
+		"  want: sitting\n" +
+		"        ^   ^ ^"
+	if !strings.Contains(msg, want) {
+		t.Fatalf("Unexpected message:\n%s\nwant:\n%s", msg, want)
+	}
+
+	m.CheckFail(t, func() { T.SimilarString("", "abc", 2) })
+	if !strings.Contains(msg, "  have: ---\n  want: abc\n        ^^^") {
+		t.Fatalf("Unexpected message:\n%s", msg)
+	}
+}
+
+func TestLineDiffContext(t *testing.T) {
+	t.Parallel()
+	_, T := testSetup()
+	lines := func(s string) []textLine { return splitLines(s, LinesOptions{}) }
+
+	have := lines("1\n2\n3\n4\n5\n6\n7\n8\n9\n")
+	want := lines("1\n2\n3\n4\nx\n6\n7\n8\n9\n")
+	diff, ok := lineDiff(have, want, 1)
+	T.Equal(ok, false)
+	T.Equal(diff, "  ...\n     4  4\n-    5  5\n+    5  x\n     6  6\n  ...")
+	diff, _ = lineDiff(have, want, 0)
+	T.Equal(diff, "  ...\n-    5  5\n+    5  x\n  ...")
+	diff, _ = lineDiff(have, want, -1)
+	T.Equal(strings.Count(diff, "\n"), 9)
+}
+
+func TestStripANSI(t *testing.T) {
+	t.Parallel()
+	for in, want := range map[string]string{
+		"plain":                                    "plain",
+		"\x1b[31mred\x1b[0m":                       "red",
+		"\x1b[1;38;5;208mbold orange\x1b[m":        "bold orange",
+		"\x1b[2K\x1b[1Gprogress":                   "progress",
+		"\x1b]0;title\x07text":                     "text",
+		"\x1b]8;;http://x\x1b\\link\x1b]8;;\x1b\\": "link",
+		"\x1bMup":                                  "up",
+	} {
+		if have := StripANSI(in); have != want {
+			t.Fatalf("StripANSI(%q) = %q, want %q", in, have, want)
+		}
+	}
+}
+
+func TestT_ExpectOutputPlain(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+
+	have := "\x1b[32mok\x1b[0m  pkg\n\x1b[31mFAIL\x1b[0m other\n"
+	m.CheckPass(t, func() { T.ExpectOutputPlain(have, "ok  pkg\nFAIL other\n") })
+	m.CheckFail(t, func() {
+		T.ExpectOutputPlain(have, "ok  pkg\nok  other\n", "output")
+	})
+	if !strings.HasPrefix(msg, "output: Output is not equal with ANSI "+
+		"codes removed (- have, + want):\n") {
+		t.Fatalf("Unexpected message: %s", msg)
+	} else if !strings.Contains(msg, "-    2  FAIL other") {
+		t.Fatalf("The diff was not plain text: %s", msg)
+	} else if !strings.Contains(msg, `raw have: "\x1b[32mok`) {
+		t.Fatalf("The raw output was not shown: %s", msg)
+	}
+}
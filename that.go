@@ -0,0 +1,106 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"reflect"
+	"strings"
+)
+
+// This file contains a fluent interface for making assertions about a
+// single value.
+
+// Assertions about a single value, returned from T.That(). Every method
+// returns the Assertion so that calls can be chained:
+//
+//	t.That(users).HasLen(2).Contains("alice")
+type Assertion struct {
+	t     *T
+	value interface{}
+}
+
+// Returns an Assertion about value. Failures are reported exactly like the
+// equivalent T methods, including the stack trace.
+func (t *T) That(value interface{}) *Assertion {
+	return &Assertion{t: t, value: value}
+}
+
+// Fails the test if the value is not deeply equal to want. This is the
+// same as T.Equal() so matchers may be used in want.
+func (a *Assertion) Equals(want interface{}, desc ...string) *Assertion {
+	a.t.Equal(a.value, want, desc...)
+	return a
+}
+
+// Fails the test if the value is deeply equal to unwanted.
+func (a *Assertion) NotEquals(unwanted interface{}, desc ...string) *Assertion {
+	a.t.NotEqual(a.value, unwanted, desc...)
+	return a
+}
+
+// Fails the test if the value does not contain x. Strings must contain x
+// as a substring, slices and arrays must have an element deeply equal to x
+// and maps must have the key x.
+func (a *Assertion) Contains(x interface{}, desc ...string) *Assertion {
+	return a.Matches(Contains(x), desc...)
+}
+
+// Fails the test if the value is not a string, slice, array, map or
+// channel of length n.
+func (a *Assertion) HasLen(n int, desc ...string) *Assertion {
+	return a.Matches(Len(n), desc...)
+}
+
+// Fails the test if the value is not nil. Nil pointers, maps, slices,
+// channels and functions stored in the interface are all considered nil.
+func (a *Assertion) IsNil(desc ...string) *Assertion {
+	if !a.t.isNil(a.value) {
+		prefix := ""
+		if len(desc) > 0 {
+			prefix = strings.Join(desc, " ") + ": "
+		}
+		a.t.Fatalf("%sExpected nil, got %s", prefix,
+			a.t.stringValue(reflect.ValueOf(a.value)))
+	}
+	return a
+}
+
+// Fails the test if the value is nil.
+func (a *Assertion) IsNotNil(desc ...string) *Assertion {
+	if a.t.isNil(a.value) {
+		prefix := ""
+		if len(desc) > 0 {
+			prefix = strings.Join(desc, " ") + ": "
+		}
+		a.t.Fatalf("%sExpected a non nil value.", prefix)
+	}
+	return a
+}
+
+// Fails the test if the value is not accepted by the matcher.
+func (a *Assertion) Matches(m Matcher, desc ...string) *Assertion {
+	if ok, want := m.Match(a.value); !ok {
+		prefix := ""
+		if len(desc) > 0 {
+			prefix = strings.Join(desc, " ") + ": "
+		}
+		have := "nil"
+		if a.value != nil {
+			have = a.t.stringValue(reflect.ValueOf(a.value))
+		}
+		a.t.Fatalf("%sExpected %s, got %s", prefix, want, have)
+	}
+	return a
+}
@@ -0,0 +1,64 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestT_That(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	var nilPtr *int
+	m.CheckPass(t, func() {
+		T.That([]string{"a", "b"}).HasLen(2).Contains("a").Equals([]string{"a", "b"})
+		T.That("hello").Contains("ell").HasLen(5).NotEquals("world")
+		T.That(map[string]int{"a": 1}).Contains("a")
+		T.That(nil).IsNil()
+		T.That(nilPtr).IsNil()
+		T.That(1).IsNotNil().Matches(Range(0, 2))
+	})
+	m.CheckFail(t, func() { T.That(1).Equals(2) })
+	m.CheckFail(t, func() { T.That(1).NotEquals(1) })
+	m.CheckFail(t, func() { T.That("abc").Contains("x") })
+	m.CheckFail(t, func() { T.That([]int{1}).HasLen(2) })
+	m.CheckFail(t, func() { T.That(1).IsNil() })
+	m.CheckFail(t, func() { T.That(nilPtr).IsNotNil() })
+
+	// Chains stop at the first failure.
+	calls := 0
+	counter := &funcMatcher{"counted", func(v interface{}) bool {
+		calls++
+		return true
+	}}
+	m.CheckFail(t, func() { T.That(1).Equals(2).Matches(counter) })
+	if calls != 0 {
+		t.Fatalf("The chain continued after a failure.")
+	}
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	m.CheckFail(t, func() { T.That([]int{1}).HasLen(2, "prefix") })
+	if !strings.HasPrefix(msg, "prefix: Expected a value with length 2, got []int{1}") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { T.That(3).IsNil("prefix") })
+	if !strings.HasPrefix(msg, "prefix: Expected nil, got 3") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+}
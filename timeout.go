@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -30,6 +31,7 @@ import (
 func (t *T) TryUntil(
 	f func() bool, timeout time.Duration, desc ...string,
 ) {
+	defer t.trackWait("TryUntil")()
 	prefix := ""
 	if len(desc) > 0 {
 		prefix = strings.Join(desc, " ") + ": "
@@ -54,6 +56,7 @@ func (t *T) TryUntil(
 func (t *T) TryUntilf(
 	f func() bool, timeout time.Duration, spec string, args ...interface{},
 ) {
+	defer t.trackWait("TryUntilf")()
 	prefix := fmt.Sprintf(spec, args...) + ": "
 
 	end := time.Now().Add(timeout)
@@ -69,3 +72,145 @@ func (t *T) TryUntilf(
 
 	t.Fatalf("%sTimeout after %s", prefix, timeout)
 }
+
+// The goroutine states (as reported in a stack dump) that indicate the
+// goroutine is waiting on a channel or lock.
+var blockedStates = []string{
+	"chan receive",
+	"chan send",
+	"select",
+	"semacquire",
+	"sync.Mutex.Lock",
+	"sync.RWMutex.Lock",
+	"sync.RWMutex.RLock",
+	"sync.WaitGroup.Wait",
+	"sync.Cond.Wait",
+}
+
+// Runs fn and fails the test if it has not returned within timeout. On
+// failure the stacks of all goroutines are examined and those that are
+// blocked on a channel or lock operation are reported first, followed by a
+// one line summary of every other goroutine. This is far easier to act on
+// than the runtime's deadlock panic, which only fires when every goroutine
+// in the process is stuck.
+//
+// Since fn can not be stopped it is left running if it does not finish.
+func (t *T) DetectDeadlock(fn func(), timeout time.Duration, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	blocked, other := blockedGoroutines(goroutineStacks())
+	t.Fatalf("%sPossible deadlock: function did not finish within %s.\n"+
+		"%d goroutine(s) blocked on channel or lock operations:\n\n%s\n\n"+
+		"Other goroutines:\n%s",
+		prefix, timeout, len(blocked), strings.Join(blocked, "\n\n"),
+		strings.Join(other, "\n"))
+}
+
+// Splits a full goroutine stack dump into the full stacks of goroutines
+// that are blocked on a channel or lock, and the header lines of all other
+// goroutines.
+func blockedGoroutines(dump string) (blocked, other []string) {
+	for _, stack := range strings.Split(strings.TrimSpace(dump), "\n\n") {
+		header := stack
+		if i := strings.Index(stack, "\n"); i >= 0 {
+			header = stack[:i]
+		}
+		state := ""
+		if start := strings.Index(header, "["); start >= 0 {
+			if end := strings.Index(header[start:], "]"); end >= 0 {
+				state = header[start+1 : start+end]
+			}
+		}
+		isBlocked := false
+		for _, prefix := range blockedStates {
+			if strings.HasPrefix(state, prefix) {
+				isBlocked = true
+				break
+			}
+		}
+		if isBlocked {
+			blocked = append(blocked, stack)
+		} else {
+			other = append(other, "  "+header)
+		}
+	}
+	return blocked, other
+}
+
+// Logs "Still waiting: msg" along with the elapsed time every interval until
+// the returned function is called. This keeps CI systems that kill jobs
+// after a period without output from terminating slow but healthy tests.
+// The returned function is safe to call more than once and will also be
+// called automatically when the test finishes. The interval must be
+// positive.
+func (t *T) Heartbeat(interval time.Duration, msg string) func() {
+	if interval <= 0 {
+		t.Fatalf("Heartbeat interval must be positive, not %s.", interval)
+	}
+	start := time.Now()
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				t.Logf("Still waiting: %s (%s elapsed)",
+					msg, time.Since(start).Round(time.Millisecond))
+			}
+		}
+	}()
+	var once sync.Once
+	f := func() {
+		once.Do(func() {
+			close(stop)
+			<-stopped
+		})
+	}
+	t.AddFinalizer(f)
+	return f
+}
+
+// Calls fn and fails the test if it did not take at least min and at most
+// max to complete. A max of zero means there is no upper bound. This is
+// useful for asserting latency budgets and debounce or throttle behavior.
+// The measured duration is returned.
+func (t *T) ExpectDuration(
+	fn func(), min, max time.Duration, desc ...string,
+) time.Duration {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	start := time.Now()
+	fn()
+	took := time.Since(start)
+	if took < min || (max > 0 && took > max) {
+		limit := "no limit"
+		if max > 0 {
+			limit = max.String()
+		}
+		t.Fatalf("%sFunction took %s, outside of the expected window.\n"+
+			"  min: %s\n  max: %s", prefix, took, min, limit)
+	}
+	return took
+}
@@ -89,3 +89,119 @@ func TestT_TryUntilYield(t *testing.T) {
 		T.TryUntil(getUnlocked, time.Second)
 	})
 }
+
+func TestT_DetectDeadlock(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	m.CheckPass(t, func() { T.DetectDeadlock(func() {}, time.Second) })
+
+	var lock sync.Mutex
+	lock.Lock()
+	ch := make(chan struct{})
+	defer close(ch)
+	m.CheckFail(t, func() {
+		T.DetectDeadlock(func() {
+			go func() {
+				<-ch
+			}()
+			lock.Lock()
+		}, 50*time.Millisecond, "prefix")
+	})
+	lock.Unlock()
+	if !strings.HasPrefix(msg, "prefix: Possible deadlock") {
+		t.Fatalf("Unexpected message: %s", msg)
+	} else if !strings.Contains(msg, "[sync.Mutex.Lock") {
+		t.Fatalf("The blocked mutex was not reported: %s", msg)
+	} else if !strings.Contains(msg, "[chan receive") {
+		t.Fatalf("The blocked channel was not reported: %s", msg)
+	}
+}
+
+func TestBlockedGoroutines(t *testing.T) {
+	t.Parallel()
+	dump := "goroutine 1 [running]:\nmain.main()\n\n" +
+		"goroutine 2 [chan send, 2 minutes]:\nmain.send()\n\n" +
+		"goroutine 3 [sleep]:\ntime.Sleep()\n"
+	blocked, other := blockedGoroutines(dump)
+	if len(blocked) != 1 || !strings.HasPrefix(blocked[0], "goroutine 2 ") {
+		t.Fatalf("Unexpected blocked goroutines: %#v", blocked)
+	} else if len(other) != 2 || other[1] != "  goroutine 3 [sleep]:" {
+		t.Fatalf("Unexpected other goroutines: %#v", other)
+	}
+}
+
+func TestT_Heartbeat(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	var lock sync.Mutex
+	logs := []string{}
+	m.funcLogf = func(format string, args ...interface{}) {
+		lock.Lock()
+		defer lock.Unlock()
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+
+	stop := T.Heartbeat(time.Millisecond, "the server")
+	time.Sleep(20 * time.Millisecond)
+	stop()
+	stop()
+
+	lock.Lock()
+	count := len(logs)
+	lock.Unlock()
+	if count == 0 {
+		t.Fatalf("No heartbeat was logged.")
+	} else if !strings.HasPrefix(logs[0], "Still waiting: the server (") {
+		t.Fatalf("Unexpected log: %s", logs[0])
+	}
+
+	// Nothing is logged once stopped.
+	time.Sleep(5 * time.Millisecond)
+	lock.Lock()
+	defer lock.Unlock()
+	if len(logs) != count {
+		t.Fatalf("Heartbeats were logged after stopping.")
+	}
+	T.Finish()
+}
+
+func TestT_HeartbeatInvalidInterval(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) { msg = fmt.Sprint(args...) }
+	m.CheckFail(t, func() { T.Heartbeat(0, "the server") })
+	if !strings.HasPrefix(msg, "Heartbeat interval must be positive, not 0s.\n") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+	m.CheckFail(t, func() { T.Heartbeat(-time.Second, "the server") })
+}
+
+func TestT_ExpectDuration(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	sleep := func() { time.Sleep(10 * time.Millisecond) }
+	m.CheckPass(t, func() { T.ExpectDuration(sleep, 10*time.Millisecond, time.Second) })
+	m.CheckPass(t, func() { T.ExpectDuration(sleep, 0, 0) })
+	m.CheckFail(t, func() { T.ExpectDuration(sleep, time.Second, 0, "prefix") })
+	if !strings.HasPrefix(msg, "prefix: Function took ") {
+		t.Fatalf("Unexpected message: %s", msg)
+	} else if !strings.Contains(msg, "  min: 1s\n  max: no limit") {
+		t.Fatalf("The window was not reported: %s", msg)
+	}
+	m.CheckFail(t, func() { T.ExpectDuration(sleep, 0, time.Millisecond) })
+}
@@ -0,0 +1,117 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"context"
+	"os"
+	"runtime/trace"
+	"sync"
+)
+
+// This file contains functions for recording execution traces of tests.
+
+// If this environment variable is set then the execution trace started by
+// Trace() is written to the named file rather than a new file in the
+// system temporary directory.
+const traceFileEnv = "TESTLIB_TRACE_FILE"
+
+// Protects traceOutput.
+var traceLock sync.Mutex
+
+// The file that the execution trace started by Trace() is being written to,
+// or nil if this library did not start the trace.
+var traceOutput *os.File
+
+// Records the test in the process wide execution trace so scheduling and
+// blocking problems can be examined with "go tool trace". The test is
+// recorded as a task named after the test and each Step() run from now on
+// is recorded as a region within it. The returned context carries the task
+// so the code under test can add its own regions with trace.WithRegion().
+//
+// If the test binary was run with -trace then that trace is used,
+// otherwise the first call to Trace() starts a trace which is written to
+// the file named by the TESTLIB_TRACE_FILE environment variable, or to a
+// new file in the system temporary directory. The path is logged. Since
+// the trace is only complete once it has been stopped, the package must
+// use Main() (which stops it when the tests finish) for the file to be
+// readable.
+func (t *T) Trace() context.Context {
+	path, err := startTrace()
+	if err != nil {
+		t.Fatalf("Unable to start the execution trace: %s", err)
+	} else if path != "" {
+		t.Logf("Writing the execution trace to %s", path)
+	}
+	ctx, task := trace.NewTask(context.Background(), t.Name())
+	t.lock.Lock()
+	t.traceCtx = ctx
+	t.lock.Unlock()
+	t.AddNamedFinalizer("trace task", func() {
+		t.lock.Lock()
+		t.traceCtx = nil
+		t.lock.Unlock()
+		task.End()
+	})
+	return ctx
+}
+
+// Starts the process wide execution trace if it is not already running,
+// returning the path of the file it is written to. If the trace was already
+// running then an empty path is returned.
+func startTrace() (string, error) {
+	traceLock.Lock()
+	defer traceLock.Unlock()
+	if trace.IsEnabled() {
+		return "", nil
+	}
+	var f *os.File
+	var err error
+	if path := osGetenv(traceFileEnv); path != "" {
+		f, err = os.Create(path)
+	} else {
+		f, err = ioutilTempFile(osTempDir(), "testlib-*.trace")
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := trace.Start(f); err != nil {
+		f.Close()
+		return "", err
+	}
+	traceOutput = f
+	return f.Name(), nil
+}
+
+// Stops the execution trace if it was started by Trace().
+func stopTrace() error {
+	traceLock.Lock()
+	defer traceLock.Unlock()
+	if traceOutput == nil {
+		return nil
+	}
+	trace.Stop()
+	err := traceOutput.Close()
+	traceOutput = nil
+	return err
+}
+
+// Returns the context carrying the test's trace task, or nil if Trace() has
+// not been called.
+func (t *T) traceContext() context.Context {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.traceCtx
+}
@@ -0,0 +1,87 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/trace"
+	"strings"
+	"testing"
+)
+
+func TestT_Trace(t *testing.T) {
+	// The execution trace is process wide so this can not be parallel.
+	if trace.IsEnabled() {
+		t.Skip("The test binary is already being traced.")
+	}
+	defer func() { osGetenv = os.Getenv }()
+	_, setup := testSetup()
+	defer setup.Finish()
+	path := filepath.Join(setup.TempDir(), "run.trace")
+	osGetenv = func(key string) string {
+		if key == traceFileEnv {
+			return path
+		}
+		return os.Getenv(key)
+	}
+
+	m, T := testSetup()
+	msg := ""
+	m.funcLogf = func(f string, args ...interface{}) {
+		msg += fmt.Sprintf(f, args...)
+	}
+	ctx := T.Trace()
+	if ctx == nil {
+		t.Fatalf("No context was returned.")
+	} else if !trace.IsEnabled() {
+		t.Fatalf("Tracing was not started.")
+	} else if !strings.Contains(msg, path) {
+		t.Fatalf("The trace file was not logged: %s", msg)
+	}
+	T.Step("inner", func() {
+		trace.WithRegion(ctx, "code under test", func() {})
+	})
+
+	// A second traced test shares the running trace.
+	m2, T2 := testSetup()
+	msg = ""
+	m2.funcLogf = m.funcLogf
+	T2.Trace()
+	if strings.Contains(msg, "Writing") {
+		t.Fatalf("A second trace was started: %s", msg)
+	}
+	m2.CheckPass(t, func() { T2.Finish() })
+	m.CheckPass(t, func() { T.Finish() })
+	if T.traceContext() != nil {
+		t.Fatalf("The trace context was not cleared.")
+	}
+
+	if err := stopTrace(); err != nil {
+		t.Fatalf("Unable to stop the trace: %s", err)
+	} else if trace.IsEnabled() {
+		t.Fatalf("Tracing was not stopped.")
+	} else if err := stopTrace(); err != nil {
+		t.Fatalf("Stopping a stopped trace failed: %s", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read the trace: %s", err)
+	} else if !strings.HasPrefix(string(data), "go 1.") {
+		t.Fatalf("The trace file is not a Go execution trace.")
+	}
+}
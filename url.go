@@ -0,0 +1,140 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// This file contains functions for comparing URLs.
+
+// Parses both URLs and compares them component by component. The scheme and
+// host are compared case insensitively and the query parameters are
+// compared as multisets so the order in which parameters appear does not
+// matter. If the URLs differ then the test is failed with a list of the
+// components that differ.
+func (t *T) EqualURL(have, want string, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	haveURL, err := url.Parse(have)
+	if err != nil {
+		t.Fatalf("%sUnable to parse have URL %q: %s", prefix, have, err)
+	}
+	wantURL, err := url.Parse(want)
+	if err != nil {
+		t.Fatalf("%sUnable to parse want URL %q: %s", prefix, want, err)
+	}
+
+	diffs := []string{}
+	compare := func(name, have, want string) {
+		if have != want {
+			diffs = append(diffs,
+				fmt.Sprintf("%s: not equal.", name),
+				fmt.Sprintf("  have: %q", have),
+				fmt.Sprintf("  want: %q", want))
+		}
+	}
+	compare("scheme",
+		strings.ToLower(haveURL.Scheme), strings.ToLower(wantURL.Scheme))
+	compare("opaque", haveURL.Opaque, wantURL.Opaque)
+	compare("user", haveURL.User.String(), wantURL.User.String())
+	compare("host",
+		strings.ToLower(haveURL.Host), strings.ToLower(wantURL.Host))
+	compare("path", haveURL.Path, wantURL.Path)
+	compare("fragment", haveURL.Fragment, wantURL.Fragment)
+
+	haveQuery, err := url.ParseQuery(haveURL.RawQuery)
+	if err != nil {
+		t.Fatalf("%sUnable to parse have query %q: %s",
+			prefix, haveURL.RawQuery, err)
+	}
+	wantQuery, err := url.ParseQuery(wantURL.RawQuery)
+	if err != nil {
+		t.Fatalf("%sUnable to parse want query %q: %s",
+			prefix, wantURL.RawQuery, err)
+	}
+	diffs = append(diffs, multisetDiffs("query", haveQuery, wantQuery)...)
+
+	if len(diffs) > 0 {
+		t.Fatalf("%sURLs are not equal\n  have: %s\n  want: %s\n%s",
+			prefix, have, want, strings.Join(diffs, "\n"))
+	}
+}
+
+// Compares two maps of string slices treating each slice as a multiset so
+// the order of the values is ignored. This returns a list of differences
+// in the same format as Equal().
+func multisetDiffs(name string, have, want map[string][]string) []string {
+	keys := make(map[string]bool, len(have)+len(want))
+	for k := range have {
+		keys[k] = true
+	}
+	for k := range want {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	diffs := []string{}
+	for _, k := range sorted {
+		haveValues, haveOk := have[k]
+		wantValues, wantOk := want[k]
+		switch {
+		case !haveOk:
+			diffs = append(diffs,
+				fmt.Sprintf("%s[%q]: Expected key is missing.", name, k),
+				"  have: not present",
+				fmt.Sprintf("  want: %#v", wantValues))
+		case !wantOk:
+			diffs = append(diffs,
+				fmt.Sprintf("%s[%q]: Unexpected key.", name, k),
+				fmt.Sprintf("  have: %#v", haveValues),
+				"  want: not present")
+		default:
+			h := append([]string{}, haveValues...)
+			w := append([]string{}, wantValues...)
+			sort.Strings(h)
+			sort.Strings(w)
+			if strings.Join(h, "\x00") != strings.Join(w, "\x00") ||
+				len(h) != len(w) {
+				diffs = append(diffs,
+					fmt.Sprintf("%s[%q]: not equal.", name, k),
+					fmt.Sprintf("  have: %#v", haveValues),
+					fmt.Sprintf("  want: %#v", wantValues))
+			}
+		}
+	}
+	return diffs
+}
+
+// Compares two sets of form values. The order of the values for each key is
+// ignored since it is rarely significant.
+func (t *T) EqualForm(have, want url.Values, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	if diffs := multisetDiffs("form", have, want); len(diffs) > 0 {
+		t.Fatalf("%sForms are not equal\n%s", prefix, strings.Join(diffs, "\n"))
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestT_EqualURL(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	// Equal URLs.
+	m.CheckPass(t, func() {
+		T.EqualURL("http://host/path?a=1&b=2", "http://host/path?a=1&b=2")
+	})
+	m.CheckPass(t, func() {
+		T.EqualURL("HTTP://Host/path?b=2&a=1&a=3", "http://host/path?a=3&b=2&a=1")
+	})
+
+	// Differences.
+	m.CheckFail(t, func() {
+		T.EqualURL("http://host/path", "https://host/path", "prefix")
+	})
+	if !strings.HasPrefix(msg, "prefix: ") {
+		t.Fatalf("The prefix was not prepended to the message: '''%s'''", msg)
+	} else if !strings.Contains(msg, "scheme: not equal.") {
+		t.Fatalf("The scheme difference was not reported: %s", msg)
+	}
+	m.CheckFail(t, func() { T.EqualURL("http://a/path", "http://b/path") })
+	m.CheckFail(t, func() { T.EqualURL("http://a/path1", "http://a/path2") })
+	m.CheckFail(t, func() { T.EqualURL("http://a/p#x", "http://a/p#y") })
+	m.CheckFail(t, func() { T.EqualURL("http://u@a/p", "http://v@a/p") })
+	m.CheckFail(t, func() { T.EqualURL("http://a/p?a=1", "http://a/p?a=2") })
+	if !strings.Contains(msg, `query["a"]: not equal.`) {
+		t.Fatalf("The query difference was not reported: %s", msg)
+	}
+	m.CheckFail(t, func() { T.EqualURL("http://a/p?a=1", "http://a/p?b=1") })
+	if !strings.Contains(msg, `query["b"]: Expected key is missing.`) {
+		t.Fatalf("The missing key was not reported: %s", msg)
+	} else if !strings.Contains(msg, `query["a"]: Unexpected key.`) {
+		t.Fatalf("The unexpected key was not reported: %s", msg)
+	}
+	m.CheckFail(t, func() {
+		T.EqualURL("http://a/p?a=1&a=1", "http://a/p?a=1")
+	})
+
+	// Parse failures.
+	m.CheckFail(t, func() { T.EqualURL("%zz", "http://a/") })
+	m.CheckFail(t, func() { T.EqualURL("http://a/", "%zz") })
+	m.CheckFail(t, func() { T.EqualURL("http://a/?%zz", "http://a/") })
+}
+
+func TestT_EqualForm(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	have := url.Values{"a": {"1", "2"}, "b": {"3"}}
+	m.CheckPass(t, func() {
+		T.EqualForm(have, url.Values{"a": {"2", "1"}, "b": {"3"}})
+	})
+	m.CheckFail(t, func() {
+		T.EqualForm(have, url.Values{"a": {"1"}, "b": {"3"}}, "prefix")
+	})
+	if !strings.HasPrefix(msg, "prefix: ") {
+		t.Fatalf("The prefix was not prepended to the message: '''%s'''", msg)
+	} else if !strings.Contains(msg, `form["a"]: not equal.`) {
+		t.Fatalf("The difference was not reported: %s", msg)
+	}
+}
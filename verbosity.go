@@ -0,0 +1,97 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// This file contains the package wide controls for how much output this
+// library produces.
+
+// Controls how much detail is included in the output of this library.
+type Verbosity int32
+
+const (
+	// Failures are reported without stack traces, diffs only include the
+	// lines that changed and no informational messages are logged.
+	VerbosityQuiet Verbosity = iota
+
+	// Failures include a stack trace with Go runtime and testing package
+	// frames removed and diffs include a few lines of context.
+	VerbosityTrimmed
+
+	// Failures include the full stack trace and diffs include all of their
+	// context. This is the default.
+	VerbosityNormal
+
+	// Like VerbosityNormal except that informational messages (temporary
+	// paths, finalizer runs and so on) are logged for every test.
+	VerbosityVerbose
+)
+
+// If set then this environment variable sets the verbosity level used when
+// SetVerbosity() has not been called. Valid values are "quiet", "trimmed",
+// "normal" and "verbose".
+const verbosityEnv = "TESTLIB_VERBOSITY"
+
+// The number of lines of unchanged context shown around each change in a
+// diff at VerbosityTrimmed.
+const trimmedDiffContext = 3
+
+// The level set via SetVerbosity(), or -1 if it has not been set.
+var verbosityLevel int32 = -1
+
+// Sets the verbosity of this library for every test in the process. This
+// overrides the TESTLIB_VERBOSITY environment variable.
+func SetVerbosity(v Verbosity) {
+	atomic.StoreInt32(&verbosityLevel, int32(v))
+}
+
+// Returns the verbosity level currently in effect.
+func currentVerbosity() Verbosity {
+	if v := atomic.LoadInt32(&verbosityLevel); v >= 0 {
+		return Verbosity(v)
+	}
+	switch strings.ToLower(osGetenv(verbosityEnv)) {
+	case "quiet":
+		return VerbosityQuiet
+	case "trimmed":
+		return VerbosityTrimmed
+	case "verbose":
+		return VerbosityVerbose
+	}
+	return VerbosityNormal
+}
+
+// Returns true if informational messages should be logged for this test,
+// either because SetVerbose() was called on it or the package wide
+// verbosity is VerbosityVerbose.
+func (t *T) isVerbose() bool {
+	return t.verbose || currentVerbosity() >= VerbosityVerbose
+}
+
+// Returns the number of lines of unchanged context that should be shown
+// around each change in a diff, or -1 if all context should be shown.
+func diffContext() int {
+	switch currentVerbosity() {
+	case VerbosityQuiet:
+		return 0
+	case VerbosityTrimmed:
+		return trimmedDiffContext
+	}
+	return -1
+}
@@ -0,0 +1,116 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSetVerbosity(t *testing.T) {
+	defer func(v int32) { verbosityLevel = v }(verbosityLevel)
+	defer func(f func(string) string) { osGetenv = f }(osGetenv)
+
+	env := ""
+	osGetenv = func(key string) string {
+		if key == verbosityEnv {
+			return env
+		}
+		return ""
+	}
+
+	// The environment is used until SetVerbosity() is called.
+	verbosityLevel = -1
+	tests := map[string]Verbosity{
+		"":        VerbosityNormal,
+		"quiet":   VerbosityQuiet,
+		"trimmed": VerbosityTrimmed,
+		"VERBOSE": VerbosityVerbose,
+		"bogus":   VerbosityNormal,
+	}
+	for value, want := range tests {
+		env = value
+		if have := currentVerbosity(); have != want {
+			t.Fatalf("%q: have %d, want %d", value, have, want)
+		}
+	}
+	SetVerbosity(VerbosityQuiet)
+	env = "verbose"
+	if have := currentVerbosity(); have != VerbosityQuiet {
+		t.Fatalf("SetVerbosity() did not override the environment: %d", have)
+	}
+
+	_, T := testSetup()
+
+	// Stack traces.
+	if have := T.makeStack("msg"); have != "msg" {
+		t.Fatalf("Quiet mode should not include a stack: %s", have)
+	}
+	SetVerbosity(VerbosityTrimmed)
+	if have := T.makeStack("msg"); strings.Contains(have, "testing.go") {
+		t.Fatalf("The testing package was not trimmed: %s", have)
+	}
+	SetVerbosity(VerbosityNormal)
+	if have := T.makeStack("msg"); !strings.Contains(have, "testing.go") {
+		t.Fatalf("The full stack was not included by default: %s", have)
+	}
+	SetVerbosity(VerbosityVerbose)
+	if have := T.makeStack("msg"); !strings.Contains(have, "testing.go") {
+		t.Fatalf("The full stack was not included: %s", have)
+	}
+
+	// Diff context.
+	for v, want := range map[Verbosity]int{
+		VerbosityQuiet:   0,
+		VerbosityTrimmed: trimmedDiffContext,
+		VerbosityNormal:  -1,
+		VerbosityVerbose: -1,
+	} {
+		SetVerbosity(v)
+		if have := diffContext(); have != want {
+			t.Fatalf("Verbosity %d: have context %d, want %d", v, have, want)
+		}
+	}
+}
+
+func TestT_isVerbose(t *testing.T) {
+	defer func(v int32) { verbosityLevel = v }(verbosityLevel)
+
+	m, T := testSetup()
+	defer T.Finish()
+	logs := []string{}
+	m.funcLogf = func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+
+	SetVerbosity(VerbosityNormal)
+	if T.isVerbose() {
+		t.Fatalf("The test should not be verbose.")
+	}
+	T.TempDir()
+	if len(logs) != 0 {
+		t.Fatalf("Unexpected logs: %#v", logs)
+	}
+
+	SetVerbosity(VerbosityVerbose)
+	if !T.isVerbose() {
+		t.Fatalf("The test should be verbose.")
+	}
+	dir := T.TempDir()
+	if len(logs) != 1 || logs[0] != "Created temporary directory "+dir {
+		t.Fatalf("Unexpected logs: %#v", logs)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// This file contains functions for comparing XML documents.
+
+// Compares two XML documents semantically. Attribute order, whitespace
+// between elements, comments and the prefixes used for namespaces are all
+// ignored; elements and attributes are compared using their fully resolved
+// namespace URL and local name. All differences are reported along with the
+// path of the element they were found in.
+func (t *T) EqualXML(have, want []byte, desc ...string) {
+	prefix := ""
+	if len(desc) > 0 {
+		prefix = strings.Join(desc, " ") + ": "
+	}
+	haveRoot, err := parseXML(have)
+	if err != nil {
+		t.Fatalf("%sUnable to parse have XML: %s", prefix, err)
+	}
+	wantRoot, err := parseXML(want)
+	if err != nil {
+		t.Fatalf("%sUnable to parse want XML: %s", prefix, err)
+	}
+	diffs := xmlDiffs("", haveRoot, wantRoot)
+	if len(diffs) > 0 {
+		t.Fatalf("%sXML documents are not equal\n%s",
+			prefix, strings.Join(diffs, "\n"))
+	}
+}
+
+// A single parsed XML element.
+type xmlNode struct {
+	name     xml.Name
+	attrs    map[xml.Name]string
+	text     string
+	children []*xmlNode
+}
+
+// Returns the display name of the node including its namespace, if any.
+func xmlName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return "{" + n.Space + "}" + n.Local
+}
+
+// Parses a document into a tree of xmlNodes. The returned node is a
+// synthetic document node whose children are the top level elements.
+func parseXML(data []byte) (*xmlNode, error) {
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch tok := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: tok.Name, attrs: map[xml.Name]string{}}
+			for _, attr := range tok.Attr {
+				// Namespace declarations only control prefix naming.
+				if attr.Name.Space == "xmlns" ||
+					(attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				node.attrs[attr.Name] = attr.Value
+			}
+			top.children = append(top.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			top.text = strings.TrimSpace(top.text)
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			top.text += string(tok)
+		}
+	}
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("unexpected end of document")
+	}
+	root.text = strings.TrimSpace(root.text)
+	return root, nil
+}
+
+// Returns a list of the differences between two nodes.
+func xmlDiffs(path string, have, want *xmlNode) []string {
+	diffs := []string{}
+	if have.name != want.name {
+		return append(diffs, fmt.Sprintf("%s: element name not equal.", path),
+			fmt.Sprintf("  have: %s", xmlName(have.name)),
+			fmt.Sprintf("  want: %s", xmlName(want.name)))
+	}
+
+	names := make([]xml.Name, 0, len(want.attrs))
+	for name := range want.attrs {
+		names = append(names, name)
+	}
+	for name := range have.attrs {
+		if _, ok := want.attrs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return xmlName(names[i]) < xmlName(names[j])
+	})
+	for _, name := range names {
+		haveValue, haveOK := have.attrs[name]
+		wantValue, wantOK := want.attrs[name]
+		switch {
+		case !haveOK:
+			diffs = append(diffs, fmt.Sprintf(
+				"%s@%s: Expected attribute is missing.", path, xmlName(name)))
+		case !wantOK:
+			diffs = append(diffs, fmt.Sprintf(
+				"%s@%s: Unexpected attribute.", path, xmlName(name)))
+		case haveValue != wantValue:
+			diffs = append(diffs,
+				fmt.Sprintf("%s@%s: not equal.", path, xmlName(name)),
+				fmt.Sprintf("  have: %q", haveValue),
+				fmt.Sprintf("  want: %q", wantValue))
+		}
+	}
+
+	if have.text != want.text {
+		diffs = append(diffs, fmt.Sprintf("%s: text not equal.", path),
+			fmt.Sprintf("  have: %q", have.text),
+			fmt.Sprintf("  want: %q", want.text))
+	}
+
+	if len(have.children) != len(want.children) {
+		diffs = append(diffs, fmt.Sprintf(
+			"%s: child element count: have %d, want %d",
+			path, len(have.children), len(want.children)))
+	}
+	for i := 0; i < len(have.children) && i < len(want.children); i++ {
+		child := fmt.Sprintf("%s/%s[%d]",
+			path, xmlName(want.children[i].name), i+1)
+		diffs = append(diffs,
+			xmlDiffs(child, have.children[i], want.children[i])...)
+	}
+	return diffs
+}
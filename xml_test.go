@@ -0,0 +1,87 @@
+// Copyright 2014 Brady Catherman
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testlib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestT_EqualXML(t *testing.T) {
+	t.Parallel()
+	m, T := testSetup()
+
+	msg := ""
+	m.funcFatal = func(args ...interface{}) {
+		msg = fmt.Sprint(args...)
+	}
+
+	doc := `<a:env xmlns:a="urn:env"><a:body id="1" kind="x">text</a:body></a:env>`
+
+	// Attribute order, whitespace, comments and prefixes are all ignored.
+	m.CheckPass(t, func() {
+		T.EqualXML([]byte(doc), []byte(`<?xml version="1.0"?>
+			<b:env xmlns:b="urn:env">
+				<!-- comment -->
+				<b:body kind="x" id="1"> text </b:body>
+			</b:env>`))
+	})
+
+	// Namespace URLs are compared.
+	m.CheckFail(t, func() {
+		T.EqualXML([]byte(doc),
+			[]byte(`<a:env xmlns:a="urn:other"><a:body id="1" kind="x">text</a:body></a:env>`),
+			"prefix")
+	})
+	if !strings.HasPrefix(msg, "prefix: ") {
+		t.Fatalf("The prefix was not prepended to the message: '''%s'''", msg)
+	} else if !strings.Contains(msg, "element name not equal.") {
+		t.Fatalf("The element name was not reported: %s", msg)
+	}
+
+	// Attributes.
+	m.CheckFail(t, func() {
+		T.EqualXML([]byte(doc),
+			[]byte(`<a:env xmlns:a="urn:env"><a:body id="2" new="y">text</a:body></a:env>`))
+	})
+	for _, want := range []string{
+		"/{urn:env}env[1]/{urn:env}body[1]@id: not equal.",
+		"@kind: Unexpected attribute.",
+		"@new: Expected attribute is missing.",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("Message did not contain %q: %s", want, msg)
+		}
+	}
+
+	// Text and children.
+	m.CheckFail(t, func() {
+		T.EqualXML([]byte("<a><b>1</b><b>2</b></a>"), []byte("<a><b>1</b><b>3</b></a>"))
+	})
+	if !strings.Contains(msg, "/a[1]/b[2]: text not equal.") {
+		t.Fatalf("The text difference was not reported: %s", msg)
+	}
+	m.CheckFail(t, func() {
+		T.EqualXML([]byte("<a><b/></a>"), []byte("<a><b/><b/></a>"))
+	})
+	if !strings.Contains(msg, "child element count: have 1, want 2") {
+		t.Fatalf("The child count was not reported: %s", msg)
+	}
+
+	// Parse errors.
+	m.CheckFail(t, func() { T.EqualXML([]byte("<a>"), []byte("<a/>")) })
+	m.CheckFail(t, func() { T.EqualXML([]byte("<a/>"), []byte("<a></b>")) })
+}